@@ -0,0 +1,100 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains phone activity status checks.
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	cmdActivity    = []byte("+CPAS")  // Query phone activity status
+	activityPrefix = []byte("+CPAS:") // Activity status response prefix
+)
+
+var ErrDeviceBusy = errors.New("device busy")
+
+// ActivityStatus is the phone activity reported by AT+CPAS
+type ActivityStatus uint8
+
+const (
+	ActivityReady   ActivityStatus = 0 // Ready to accept commands
+	ActivityUnknown ActivityStatus = 2 // Unknown, may not respond to commands
+	ActivityRinging ActivityStatus = 3 // Incoming call is ringing
+	ActivityInCall  ActivityStatus = 4 // Voice call in progress
+)
+
+func (s ActivityStatus) String() string {
+	switch s {
+	case ActivityReady:
+		return "ready"
+	case ActivityUnknown:
+		return "unknown"
+	case ActivityRinging:
+		return "ringing"
+	case ActivityInCall:
+		return "in call"
+	default:
+		return "invalid"
+	}
+}
+
+// BusyError is returned when an operation is refused because the module is busy
+type BusyError struct {
+	Status ActivityStatus // Why the module is busy
+}
+
+// Error returns the error message, implementing the error interface
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("device busy: %s", e.Status)
+}
+
+// Is makes errors.Is(err, ErrDeviceBusy) match a BusyError
+func (e *BusyError) Is(target error) bool {
+	return target == ErrDeviceBusy
+}
+
+// Activity returns the current phone activity status of the module
+func (d *Device) Activity() (ActivityStatus, error) {
+	d.lock()
+	defer d.unlock()
+	return d.activity()
+}
+
+// activity queries the activity status with the lock held
+func (d *Device) activity() (ActivityStatus, error) {
+	err := d.sendWithOptions(cmdActivity, func(buffer []byte) error {
+		if bytes.HasPrefix(buffer, activityPrefix) {
+			return nil
+		}
+		return defaultResponseCheck(buffer)
+	}, DefaultTimeout)
+	if err != nil {
+		return ActivityUnknown, err
+	}
+
+	val, ok := d.parseValue(cmdActivity)
+	if !ok {
+		return ActivityUnknown, ErrUnexpectedResponse
+	}
+	status, err := strconv.Atoi(string(val))
+	if err != nil {
+		return ActivityUnknown, ErrUnexpectedResponse
+	}
+	return ActivityStatus(status), nil
+}
+
+// checkNotBusy returns a BusyError if a call keeps the module from serving
+// long operations, which would otherwise just time out
+func (d *Device) checkNotBusy() error {
+	status, err := d.activity()
+	if err != nil {
+		return err
+	}
+	if status == ActivityRinging || status == ActivityInCall {
+		return &BusyError{Status: status}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_Activity(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CPAS": "\r\n+CPAS: 3\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+
+	status, err := d.Activity()
+	if err != nil {
+		t.Fatalf("failed to query activity: %v", err)
+	}
+	if status != ActivityRinging {
+		t.Errorf("expected %v, got %v", ActivityRinging, status)
+	}
+}
+
+func TestDevice_DialWhileInCall(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CPAS": "\r\n+CPAS: 4\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.IP = "10.0.0.1"
+
+	_, err := d.Dial("tcp", "example.com:80")
+	if !errors.Is(err, ErrDeviceBusy) {
+		t.Fatalf("expected ErrDeviceBusy, got %v", err)
+	}
+	var busy *BusyError
+	if !errors.As(err, &busy) || busy.Status != ActivityInCall {
+		t.Errorf("expected in call BusyError, got %v", err)
+	}
+	if len(modem.commands) != 1 {
+		t.Errorf("expected no connection attempt, got commands %q", modem.commands)
+	}
+}
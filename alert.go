@@ -0,0 +1,317 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the high-priority alert path for alarm devices.
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Defaults for AlertConfig fields left zero
+const (
+	DefaultAlertAttempts      = 3
+	DefaultAlertRetryInterval = 10 * time.Second
+	DefaultAlertAckTimeout    = 30 * time.Second
+)
+
+// callPollInterval is the time between call status checks of a call alert
+const callPollInterval = time.Second
+
+var (
+	cmdDial      = []byte("D")      // Dial a voice call, followed by the number and ';'
+	cmdHangUp    = []byte("H")      // Hang up the current call
+	cmdCallList  = []byte("+CLCC")  // List current calls
+	callListItem = []byte("+CLCC:") // Current call response prefix
+)
+
+var (
+	ErrPreempted            = errors.New("preempted by alert")
+	ErrNoAlertConfigured    = errors.New("no alert channels configured")
+	ErrAlertNotAcknowledged = errors.New("alert not acknowledged")
+)
+
+// AlertChannel is a way of delivering an alert
+type AlertChannel uint8
+
+const (
+	AlertSMS  AlertChannel = iota // Text message to AlertConfig.Number
+	AlertCall                     // Voice call to AlertConfig.Number, acknowledged when answered
+	AlertTCP                      // Message sent to AlertConfig.TCPAddress
+)
+
+func (c AlertChannel) String() string {
+	switch c {
+	case AlertSMS:
+		return "SMS"
+	case AlertCall:
+		return "Call"
+	case AlertTCP:
+		return "TCP"
+	default:
+		return "Unknown"
+	}
+}
+
+// AlertConfig describes how Alert reaches the receiver of alarms. Numbers
+// and addresses are deployment and region specific, so nothing is built in.
+type AlertConfig struct {
+	Channels   []AlertChannel // Channels in the order they are tried
+	Number     string         // Phone number for SMS and call alerts
+	Message    string         // Text of the SMS and payload of the TCP alert
+	TCPAddress string         // host:port of the TCP alert receiver
+
+	// TCPAck is the reply that acknowledges a TCP alert. If empty, the
+	// remote host acknowledging the data on the TCP level is enough.
+	TCPAck []byte
+
+	Attempts      int           // Rounds over all channels, DefaultAlertAttempts if zero
+	RetryInterval time.Duration // Pause between rounds, DefaultAlertRetryInterval if zero
+	AckTimeout    time.Duration // Wait for an answer or a TCP reply, DefaultAlertAckTimeout if zero
+}
+
+// Validate reports a Number that SMS and call alerts can't use, one
+// other than digits with an optional leading + and the * and # of service
+// codes, or none while those channels are configured. Configure logs it
+// and Alert fails with it before trying any channel; checking at startup
+// finds it before an alarm does.
+func (c AlertConfig) Validate() error {
+	needed := false
+	for _, ch := range c.Channels {
+		needed = needed || ch == AlertSMS || ch == AlertCall
+	}
+	if (needed || c.Number != "") && !validPhoneNumber(c.Number) {
+		return fmt.Errorf("%w: alert number %q", ErrBadParameter, c.Number)
+	}
+	return nil
+}
+
+// validPhoneNumber reports whether number is digits, * and #, after an
+// optional leading +
+func validPhoneNumber(number string) bool {
+	number = strings.TrimPrefix(number, "+")
+	if number == "" {
+		return false
+	}
+	for _, c := range []byte(number) {
+		if (c < '0' || c > '9') && c != '*' && c != '#' {
+			return false
+		}
+	}
+	return true
+}
+
+// Alert sends the alert set with Configure, trying every channel in turn
+// until one is acknowledged, and retrying for the configured number of
+// rounds. It returns the channel that got through.
+//
+// Alert takes the device ahead of every operation waiting for it. Long
+// running operations, like a Dial waiting for the remote host or a large
+// Write, give up with ErrPreempted. If the TCP channel needs a connection
+// slot and none is free, one connection not marked Critical is closed.
+func (d *Device) Alert(ctx context.Context) (AlertChannel, error) {
+	d.lockUrgent()
+	defer d.unlockUrgent()
+
+	cfg := d.alert
+	if len(cfg.Channels) == 0 {
+		return 0, ErrNoAlertConfigured
+	}
+	if err := cfg.Validate(); err != nil {
+		return 0, err
+	}
+	attempts := cfg.Attempts
+	if attempts <= 0 {
+		attempts = DefaultAlertAttempts
+	}
+	interval := cfg.RetryInterval
+	if interval <= 0 {
+		interval = DefaultAlertRetryInterval
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+		for _, ch := range cfg.Channels {
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+			err := d.sendAlert(ctx, ch, &cfg)
+			if err == nil {
+				d.log(SubsystemCommand, slog.LevelInfo, "alert acknowledged", "channel", ch, "attempt", attempt+1)
+				return ch, nil
+			}
+			d.log(SubsystemCommand, slog.LevelWarn, "alert failed", "channel", ch, "attempt", attempt+1, "error", err)
+			lastErr = err
+		}
+	}
+	return 0, fmt.Errorf("%w: %v", ErrAlertNotAcknowledged, lastErr)
+}
+
+// sendAlert delivers the alert over one channel with the lock held
+func (d *Device) sendAlert(ctx context.Context, ch AlertChannel, cfg *AlertConfig) error {
+	ackTimeout := cfg.AckTimeout
+	if ackTimeout <= 0 {
+		ackTimeout = DefaultAlertAckTimeout
+	}
+
+	switch ch {
+	case AlertSMS:
+		return d.sendSMS(cfg.Number, cfg.Message)
+	case AlertCall:
+		return d.alertCall(ctx, cfg.Number, ackTimeout)
+	case AlertTCP:
+		return d.alertTCP(ctx, cfg, ackTimeout)
+	default:
+		return ErrBadParameter
+	}
+}
+
+// alertCall calls number and waits until the call is answered
+func (d *Device) alertCall(ctx context.Context, number string, timeout time.Duration) error {
+	if number == "" {
+		return ErrBadParameter
+	}
+
+	var buf [MaxCommandSize]byte
+	cmd := append(buf[:0], cmdDial...)
+	cmd = append(cmd, number...)
+	cmd = append(cmd, ';')
+	if err := d.send(cmd); err != nil {
+		return err
+	}
+	// Hang up whatever the outcome, an answered alert call has done its job
+	defer func() {
+		if err := d.send(cmdHangUp); err != nil {
+			d.log(SubsystemCommand, slog.LevelWarn, "failed to hang up alert call", "error", err)
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := d.sendWithOptions(cmdCallList, func(buffer []byte) error {
+			if bytes.HasPrefix(buffer, callListItem) {
+				return nil
+			}
+			return defaultResponseCheck(buffer)
+		}, DefaultTimeout)
+		if err != nil {
+			return err
+		}
+
+		// A bare OK means the call is gone: rejected, busy or unanswered
+		val, ok := d.parseValue(cmdCallList)
+		if !ok {
+			return ErrAlertNotAcknowledged
+		}
+		// Format: +CLCC: <id>,<dir>,<stat>,<mode>,<mpty>,...
+		var fields [3]int
+		if parseInts(val, fields[:]) != len(fields) {
+			return ErrUnexpectedResponse
+		}
+		if fields[2] == 0 {
+			return nil // Active, the call was answered
+		}
+		time.Sleep(callPollInterval)
+	}
+	return ErrTimeout
+}
+
+// alertTCP sends the alert message to the TCP alert receiver and waits
+// for it to be acknowledged
+func (d *Device) alertTCP(ctx context.Context, cfg *AlertConfig, timeout time.Duration) error {
+	if d.IP == "" {
+		return ErrNoIP
+	}
+	if cfg.TCPAddress == "" || cfg.Message == "" {
+		return ErrBadParameter
+	}
+	d.freeConnectionSlot()
+	return d.sendAcked(ctx, cfg.TCPAddress, []byte(cfg.Message), cfg.TCPAck, timeout)
+}
+
+// sendAcked sends payload to address over a new TCP connection and waits
+// until the remote host replied with ack or, if ack is empty,
+// acknowledged the data on the TCP level
+func (d *Device) sendAcked(ctx context.Context, address string, payload, ack []byte, timeout time.Duration) error {
+	if d.IP == "" {
+		return ErrNoIP
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := d.dial(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = d.closeConnection(conn.ID, DefaultTimeout)
+	}()
+
+	if _, err := d.sendData(conn.ID, payload); err != nil {
+		return err
+	}
+
+	// Wait for the acknowledgement
+	for {
+		if len(ack) == 0 {
+			n, err := d.unacked(conn.ID)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return nil
+			}
+		} else {
+			if err := d.poll(); err != nil {
+				return err
+			}
+			if bytes.HasPrefix(d.recvBuffers[conn.ID][:d.recvBufLengths[conn.ID]], ack) {
+				return nil
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		time.Sleep(readPollInterval)
+	}
+}
+
+// freeConnectionSlot closes a connection not marked Critical if all
+// connection slots or all receive buffers are in use
+func (d *Device) freeConnectionSlot() {
+	victim := -1
+	full := !d.recvBufferFree()
+	for i := MaxConnections - 1; i >= 0; i-- {
+		conn := d.connections[i]
+		if conn == nil {
+			if !full {
+				return
+			}
+			continue
+		}
+		if !conn.Critical && victim < 0 {
+			victim = i
+		}
+	}
+	if victim < 0 {
+		return
+	}
+	d.log(SubsystemCommand, slog.LevelWarn, "closing connection for alert", "id", victim)
+	if err := d.closeConnection(uint8(victim), DefaultTimeout); err != nil {
+		d.log(SubsystemCommand, slog.LevelWarn, "failed to close connection for alert", "id", victim, "error", err)
+	}
+}
@@ -0,0 +1,196 @@
+package sim800l
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDevice_AlertSMS(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		`AT+CMGS="+15550100"`: "\r\n> ",
+	})
+	modem.dataReply = "\r\n+CMGS: 12\r\n\r\nOK\r\n"
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.Configure(Config{Alert: AlertConfig{
+		Channels: []AlertChannel{AlertSMS},
+		Number:   "+15550100",
+		Message:  "ALARM zone 3",
+	}})
+
+	ch, err := d.Alert(context.Background())
+	if err != nil {
+		t.Fatalf("alert failed: %v", err)
+	}
+	if ch != AlertSMS {
+		t.Errorf("expected alert over %v, got %v", AlertSMS, ch)
+	}
+	if got := modem.tx.String(); got != "AT+CMGS=\"+15550100\"\r\nALARM zone 3\x1a" {
+		t.Errorf("unexpected transmission %q", got)
+	}
+}
+
+func TestDevice_AlertFallsBackToNextChannel(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"ATD+15550100;": "\r\nOK\r\n",
+		"AT+CLCC":       "\r\n+CLCC: 1,0,0,0,0,\"+15550100\",145,\"\"\r\n\r\nOK\r\n",
+		"ATH":           "\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.SetLogLevel(SubsystemCommand, slog.LevelError)
+	d.Configure(Config{Alert: AlertConfig{
+		// Without GPRS the TCP channel fails at once
+		Channels: []AlertChannel{AlertTCP, AlertCall},
+		Number:   "+15550100",
+		Message:  "ALARM",
+	}})
+
+	ch, err := d.Alert(context.Background())
+	if err != nil {
+		t.Fatalf("alert failed: %v", err)
+	}
+	if ch != AlertCall {
+		t.Errorf("expected alert over %v, got %v", AlertCall, ch)
+	}
+	if last := modem.commands[len(modem.commands)-1]; last != "ATH" {
+		t.Errorf("expected the call to be hung up, last command was %q", last)
+	}
+}
+
+func TestDevice_AlertNotAcknowledged(t *testing.T) {
+	// The call is never answered
+	modem := newMockModem(map[string]string{
+		"ATD+15550100;": "\r\nOK\r\n",
+		"AT+CLCC":       "\r\nOK\r\n",
+		"ATH":           "\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Configure(Config{Alert: AlertConfig{
+		Channels:      []AlertChannel{AlertCall},
+		Number:        "+15550100",
+		Attempts:      2,
+		RetryInterval: time.Millisecond,
+	}})
+
+	if _, err := d.Alert(context.Background()); !errors.Is(err, ErrAlertNotAcknowledged) {
+		t.Fatalf("expected ErrAlertNotAcknowledged, got %v", err)
+	}
+	dials := 0
+	for _, cmd := range modem.commands {
+		if cmd == "ATD+15550100;" {
+			dials++
+		}
+	}
+	if dials != 2 {
+		t.Errorf("expected 2 call attempts, got %d", dials)
+	}
+}
+
+func TestDevice_AlertTCPDropsNonCriticalConnection(t *testing.T) {
+	s4 := slot(t, 4)
+	modem := newMockModem(map[string]string{
+		"AT+CPAS":       "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		"AT+CIPCLOSE=4": "\r\n4, CLOSE OK\r\n",
+		"AT+CIPSTART=4,\"TCP\",\"alarm.example.com\",\"9000\"": "\r\nOK\r\n\r\n4, CONNECT OK\r\n",
+		"AT+CIPSEND=4,5": "\r\n> ",
+	})
+	modem.dataReply = "\r\nSEND OK\r\n+RECEIVE,4,3:\r\nACK"
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.SetLogLevel(SubsystemCommand, slog.LevelError)
+	d.IP = "10.0.0.1"
+	d.Configure(Config{Alert: AlertConfig{
+		Channels:   []AlertChannel{AlertTCP},
+		Message:    "ALARM",
+		TCPAddress: "alarm.example.com:9000",
+		TCPAck:     []byte("ACK"),
+	}})
+	for i := uint8(0); i < MaxConnections; i++ {
+		d.connections[i] = &Connection{ID: i, Type: TCP, state: StateConnected, Device: d}
+	}
+	kept := d.connections[0]
+	kept.Critical = true
+
+	if _, err := d.Alert(context.Background()); err != nil {
+		t.Fatalf("alert failed: %v", err)
+	}
+	if d.connections[0] != kept {
+		t.Error("critical connection was closed")
+	}
+	if d.connections[s4] != nil {
+		t.Error("alert connection still open")
+	}
+}
+
+func TestDevice_AlertPreemptsDial(t *testing.T) {
+	// The remote host never answers, so the dial would wait ConnectTimeout
+	modem := newMockModem(map[string]string{
+		"AT+CPAS": "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		"AT+CIPSTART=0,\"TCP\",\"example.com\",\"80\"": "\r\nOK\r\n",
+		"AT+CIPCLOSE=0":       "\r\n0, CLOSE OK\r\n",
+		`AT+CMGS="+15550100"`: "\r\n> ",
+	})
+	modem.dataReply = "\r\n+CMGS: 12\r\n\r\nOK\r\n"
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.SetLogLevel(SubsystemCommand, slog.LevelError)
+	d.IP = "10.0.0.1"
+	d.Configure(Config{Alert: AlertConfig{
+		Channels: []AlertChannel{AlertSMS},
+		Number:   "+15550100",
+		Message:  "ALARM",
+	}})
+
+	dialErr := make(chan error, 1)
+	go func() {
+		_, err := d.Dial("tcp", "example.com:80")
+		dialErr <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if _, err := d.Alert(context.Background()); err != nil {
+		t.Fatalf("alert failed: %v", err)
+	}
+	select {
+	case err := <-dialErr:
+		if !errors.Is(err, ErrPreempted) {
+			t.Errorf("expected ErrPreempted from dial, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("dial was not preempted")
+	}
+}
+
+func TestAlertConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  AlertConfig
+		ok   bool
+	}{
+		{"international", AlertConfig{Channels: []AlertChannel{AlertCall}, Number: "+15550100"}, true},
+		{"service code", AlertConfig{Channels: []AlertChannel{AlertSMS}, Number: "*100#"}, true},
+		{"TCP only", AlertConfig{Channels: []AlertChannel{AlertTCP}}, true},
+		{"no number", AlertConfig{Channels: []AlertChannel{AlertSMS}}, false},
+		{"plus only", AlertConfig{Channels: []AlertChannel{AlertCall}, Number: "+"}, false},
+		{"injection", AlertConfig{Channels: []AlertChannel{AlertCall}, Number: "123;\r\nAT+CPOWD=1"}, false},
+		{"plus inside", AlertConfig{Channels: []AlertChannel{AlertCall}, Number: "1+2"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err == nil) != tt.ok {
+				t.Errorf("expected ok %v, got %v", tt.ok, err)
+			}
+		})
+	}
+
+	// Alert fails before sending anything
+	modem := newMockModem(nil)
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Configure(Config{Alert: AlertConfig{Channels: []AlertChannel{AlertCall}, Number: "123;H"}})
+	if _, err := d.Alert(context.Background()); !errors.Is(err, ErrBadParameter) {
+		t.Errorf("expected ErrBadParameter, got %v", err)
+	}
+	if len(modem.commands) != 0 {
+		t.Errorf("expected no commands, got %q", modem.commands)
+	}
+}
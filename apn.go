@@ -0,0 +1,65 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the selection of the APN by the SIM's IMSI.
+package sim800l
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var cmdIMSI = []byte("+CIMI") // Query the IMSI of the SIM
+
+var ErrUnknownAPN = errors.New("no APN known for the SIM's network")
+
+// LookupAPN returns the APN settings for a SIM with the given IMSI, found
+// by the MCC and MNC it starts with. The table covers the contract SIMs of
+// common operators and is only compiled in with the sim800l_apns build
+// tag, to save flash; without it no APN is found.
+func LookupAPN(imsi string) (GPRSConfig, bool) {
+	// apnTable holds one "<plmn> <apn>[ <user> <password>]" line per
+	// operator. MNCs have two or three digits.
+	for table := apnTable; table != ""; {
+		line, rest, _ := strings.Cut(table, "\n")
+		table = rest
+		plmn, settings, _ := strings.Cut(line, " ")
+		if !strings.HasPrefix(imsi, plmn) {
+			continue
+		}
+		var cfg GPRSConfig
+		cfg.APN, settings, _ = strings.Cut(settings, " ")
+		cfg.User, cfg.Password, _ = strings.Cut(settings, " ")
+		return cfg, true
+	}
+	return GPRSConfig{}, false
+}
+
+// IMSI returns the IMSI of the SIM, read with AT+CIMI
+func (d *Device) IMSI() (string, error) {
+	d.lock()
+	defer d.unlock()
+	return d.imsi()
+}
+
+// imsi reads the IMSI with the lock held
+func (d *Device) imsi() (string, error) {
+	if err := d.sendWithOptions(cmdIMSI, imeiCheck, DefaultTimeout); err != nil {
+		return "", fmt.Errorf("failed to read IMSI: %w", err)
+	}
+	return strings.TrimSpace(string(d.rxBuffer[:d.end])), nil
+}
+
+// ConnectAuto establishes a GPRS connection like Connect, with the APN
+// looked up by the SIM's IMSI with LookupAPN. It returns ErrUnknownAPN if
+// the table has no entry for the SIM's network.
+func (d *Device) ConnectAuto() error {
+	imsi, err := d.IMSI()
+	if err != nil {
+		return err
+	}
+	cfg, ok := LookupAPN(imsi)
+	if !ok {
+		return fmt.Errorf("%w: IMSI %.6s", ErrUnknownAPN, imsi)
+	}
+	return d.ConnectWithConfig(cfg)
+}
@@ -0,0 +1,8 @@
+//go:build !sim800l_apns
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the empty APN table used by default.
+package sim800l
+
+// apnTable is empty unless built with the sim800l_apns tag
+const apnTable = ""
@@ -0,0 +1,46 @@
+//go:build sim800l_apns
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the table of APNs by numeric PLMN.
+package sim800l
+
+// apnTable maps the numeric PLMN of common operators to the APN of their
+// contract SIMs, one "<plmn> <apn>[ <user> <password>]" line each. It is a
+// single string constant so it stays in flash.
+const apnTable = "" +
+	"20201 internet\n" +
+	"20205 internet.vodafone.gr\n" +
+	"20404 live.vodafone.com\n" +
+	"20416 smartsites.t-mobile\n" +
+	"20801 orange orange orange\n" +
+	"20810 sl2sfr\n" +
+	"20815 free\n" +
+	"20820 mmsbouygtel.com\n" +
+	"21401 airtelwap.es wap@wap wap125\n" +
+	"21403 orangeworld orange orange\n" +
+	"21407 movistar.es MOVISTAR MOVISTAR\n" +
+	"22201 ibox.tim.it\n" +
+	"22210 mobile.vodafone.it\n" +
+	"22288 internet.it\n" +
+	"22801 gprs.swisscom.ch\n" +
+	"22802 internet\n" +
+	"23001 internet.t-mobile.cz gprs gprs\n" +
+	"23002 internet\n" +
+	"23003 internet\n" +
+	"23201 A1.net ppp@a1plus.at ppp\n" +
+	"23410 mobile.o2.co.uk o2web password\n" +
+	"23415 pp.vodafone.co.uk wap wap\n" +
+	"23420 three.co.uk\n" +
+	"23430 everywhere eesecure secure\n" +
+	"24001 online.telia.se\n" +
+	"26001 internet\n" +
+	"26002 internet\n" +
+	"26003 internet\n" +
+	"26006 internet\n" +
+	"26201 internet.telekom t-mobile tm\n" +
+	"26202 web.vodafone.de\n" +
+	"26203 internet\n" +
+	"28403 internet.vivacom.bg VIVACOM VIVACOM\n" +
+	"310260 fast.t-mobile.com\n" +
+	"310410 broadband\n" +
+	"50501 telstra.internet\n"
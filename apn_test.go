@@ -0,0 +1,43 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_ConnectAuto(t *testing.T) {
+	modem := newSessionModem()
+	modem.responses["AT+CIMI"] = "\r\n999990123456789\r\n\r\nOK\r\n"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	if imsi, err := d.IMSI(); err != nil || imsi != "999990123456789" {
+		t.Fatalf("expected the IMSI, got %q, %v", imsi, err)
+	}
+	if err := d.ConnectAuto(); !errors.Is(err, ErrUnknownAPN) {
+		t.Errorf("expected ErrUnknownAPN for a test network, got %v", err)
+	}
+
+	cfg, ok := LookupAPN("262011234567890")
+	if ok != d.Supports(FeatureAPNTable) {
+		t.Fatalf("expected lookups to match FeatureAPNTable, got %v", ok)
+	}
+	if !ok {
+		t.Skip("built without the sim800l_apns tag")
+	}
+	if cfg.APN != "internet.telekom" || cfg.User != "t-mobile" || cfg.Password != "tm" {
+		t.Errorf("expected the Telekom DE APN with credentials, got %+v", cfg)
+	}
+	if cfg, _ := LookupAPN("310260123456789"); cfg.APN != "fast.t-mobile.com" || cfg.User != "" {
+		t.Errorf("expected a three digit MNC to match without credentials, got %+v", cfg)
+	}
+
+	modem.responses["AT+CIMI"] = "\r\n262020123456789\r\n\r\nOK\r\n"
+	modem.responses["AT+CSTT=\"web.vodafone.de\""] = "\r\nOK\r\n"
+	if err := d.ConnectAuto(); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if d.gprs.APN != "web.vodafone.de" {
+		t.Errorf("expected the APN of the SIM's network, got %q", d.gprs.APN)
+	}
+}
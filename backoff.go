@@ -0,0 +1,27 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the jitter applied to retry backoff.
+package sim800l
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RandomSource returns uniformly distributed pseudo-random numbers, e.g.
+// from a PRNG seeded by a hardware random number generator. Reconnects and
+// SuperviseSession may call it from different goroutines at once.
+type RandomSource func() uint32
+
+// jitter returns a random wait between half of backoff and backoff, so
+// devices that lost the network together don't retry in step
+func (d *Device) jitter(backoff time.Duration) time.Duration {
+	if backoff <= 1 {
+		return backoff
+	}
+	random := d.random
+	if random == nil {
+		random = rand.Uint32
+	}
+	half := backoff / 2
+	return backoff - half + time.Duration(float64(half)*float64(random())/(1<<32))
+}
@@ -0,0 +1,34 @@
+package sim800l
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDevice_jitter(t *testing.T) {
+	d := New(newMockModem(nil), nil, slog.New(&MockHandler{t: t}))
+
+	tests := []struct {
+		random uint32
+		want   time.Duration
+	}{
+		{random: 0, want: 5 * time.Second},
+		{random: 1 << 31, want: 7500 * time.Millisecond},
+		{random: 1<<32 - 1, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		d.Configure(Config{Random: func() uint32 { return tt.random }})
+		if got := d.jitter(10 * time.Second); got.Round(time.Millisecond) != tt.want {
+			t.Errorf("random %d: expected %v, got %v", tt.random, tt.want, got)
+		}
+	}
+
+	// The default source stays within the range
+	d.Configure(Config{})
+	for range 100 {
+		if got := d.jitter(time.Minute); got < 30*time.Second || got > time.Minute {
+			t.Fatalf("expected a wait between 30s and 1m, got %v", got)
+		}
+	}
+}
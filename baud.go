@@ -0,0 +1,112 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the detection of and recovery from a wrong baud rate.
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// BaudCheckBytes is how many bytes Init reads at least before it blames
+// the baud rate
+const BaudCheckBytes = 8
+
+// baudGarbageShare is the share of garbage, one byte in baudGarbageShare,
+// that the bytes read while syncing must exceed to blame the baud rate. A
+// glitch on the line while the module boots stays below it.
+const baudGarbageShare = 4
+
+// baudProbeTimeout bounds the wait for an answer at each rate tried
+const baudProbeTimeout = time.Second
+
+// BaudRates are the fixed rates tried by the baud rate recovery
+var BaudRates = [...]uint32{115200, 57600, 38400, 19200, 9600, 4800, 2400, 1200}
+
+var cmdBaudRate = []byte("+IPR") // Set a fixed baud rate
+
+var ErrBaudMismatch = errors.New("baud rate mismatch")
+
+// BaudRateSetter is implemented by UARTs that can change their baud rate,
+// like TinyGo's machine.UART. It lets Init recover a module set to another
+// fixed rate.
+type BaudRateSetter interface {
+	SetBaudRate(br uint32)
+}
+
+// watchBaud starts counting the bytes read while syncing with the module
+func (d *Device) watchBaud() {
+	d.baudWatch = true
+	d.baudSeen, d.baudGarbage = 0, 0
+	d.baudToken = false
+}
+
+// countBaudByte counts a byte read while syncing. Bytes a module at the
+// right rate never sends are framing garbage.
+func (d *Device) countBaudByte(b byte) {
+	d.baudSeen++
+	if b >= 0x80 || (b < ' ' && b != '\r' && b != '\n') {
+		d.baudGarbage++
+	}
+}
+
+// countBaudLine notes a line read while syncing that a module at the
+// right rate sends, like a result code, an echo or a URC, which proves the
+// rate right
+func (d *Device) countBaudLine(line []byte) {
+	if bytes.Contains(line, okToken) || bytes.Contains(line, errorToken) || bytes.HasPrefix(line, at) ||
+		bytes.Equal(line, bootReady) || isKnownURC(nil, line) {
+		d.baudToken = true
+	}
+}
+
+// baudMismatch returns ErrBaudMismatch with a hint if more than one in
+// baudGarbageShare of the bytes read while syncing were garbage and none
+// of them formed a recognizable line. Clean output the driver doesn't
+// understand, or a stray glitch, doesn't blame the baud rate.
+func (d *Device) baudMismatch() error {
+	if d.baudToken || d.baudSeen < BaudCheckBytes || d.baudGarbage*baudGarbageShare <= d.baudSeen {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d bytes received were garbage, check that the UART runs at the module's baud rate "+
+		"or set Config.BaudRate to recover", ErrBaudMismatch, d.baudGarbage, d.baudSeen)
+}
+
+// recoverBaud finds the fixed rate the module was set to among BaudRates,
+// switches it to d.baudRate and returns the UART to that rate
+func (d *Device) recoverBaud(ctx context.Context) error {
+	uart, ok := d.uart.(BaudRateSetter)
+	if !ok || d.baudRate == 0 {
+		return ErrBaudMismatch
+	}
+	defer uart.SetBaudRate(d.baudRate)
+
+	var buf [MaxCommandSize]byte
+	cmd := append(buf[:0], cmdBaudRate...)
+	cmd = fmt.Appendf(cmd, "=%d", d.baudRate)
+	for _, rate := range BaudRates {
+		if rate == d.baudRate {
+			continue
+		}
+		uart.SetBaudRate(rate)
+		probeCtx, cancel := context.WithTimeout(ctx, baudProbeTimeout)
+		err := d.sendContext(probeCtx, at, defaultResponseCheck)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+		d.log(SubsystemPower, slog.LevelWarn, "module found at another baud rate", "rate", rate, "want", d.baudRate)
+		// The module answers the rate change at the old rate
+		if err := d.sendContext(ctx, cmd, defaultResponseCheck); err != nil {
+			return fmt.Errorf("failed to set baud rate: %w", err)
+		}
+		return nil
+	}
+	return ErrBaudMismatch
+}
@@ -0,0 +1,109 @@
+package sim800l
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// baudModem is a mockModem running at a fixed baud rate that answers with
+// garbage when the driver's UART runs at another rate
+type baudModem struct {
+	*mockModem
+	rate       uint32 // Rate of the driver's UART
+	moduleRate uint32 // Fixed rate of the module
+}
+
+func (m *baudModem) SetBaudRate(br uint32) {
+	m.rate = br
+}
+
+func (m *baudModem) Write(p []byte) (int, error) {
+	if m.rate != m.moduleRate {
+		m.rx.WriteString("\xf8\x00\x9c\xfe\x80\x1f\r\n")
+		return len(p), nil
+	}
+	if string(p) == "AT+IPR=115200\r\n" {
+		m.moduleRate = 115200
+	}
+	return m.mockModem.Write(p)
+}
+
+func TestDevice_InitBaudMismatch(t *testing.T) {
+	responses := map[string]string{
+		"AT":            "\r\nOK\r\n",
+		"AT+IPR=115200": "\r\nOK\r\n",
+		"ATE0":          "\r\nOK\r\n",
+		"AT+CMEE=2":     "\r\nOK\r\n",
+		"AT+IPR=0":      "\r\nOK\r\n",
+		"AT+CFUN=1":     "\r\nOK\r\n",
+		"AT+CIPMUX=1":   "\r\nOK\r\n",
+		"AT+CMGF=1":     "\r\nOK\r\n",
+	}
+
+	t.Run("detected", func(t *testing.T) {
+		modem := &baudModem{mockModem: newMockModem(responses), rate: 115200, moduleRate: 9600}
+		d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+		start := time.Now()
+		status, err := d.InitContext(context.Background())
+		if !errors.Is(err, ErrBaudMismatch) {
+			t.Fatalf("expected ErrBaudMismatch, got %v", err)
+		}
+		if status.Responding {
+			t.Error("expected the module not to be responding")
+		}
+		if elapsed := time.Since(start); elapsed > initSyncInterval {
+			t.Errorf("expected the mismatch to be reported at once, took %v", elapsed)
+		}
+	})
+
+	t.Run("recovered", func(t *testing.T) {
+		modem := &baudModem{mockModem: newMockModem(responses), rate: 115200, moduleRate: 9600}
+		d := New(modem, nil, slog.New(slog.DiscardHandler))
+		d.Configure(Config{BaudRate: 115200})
+
+		status, err := d.InitContext(context.Background())
+		if err != nil {
+			t.Fatalf("init failed: %v", err)
+		}
+		if !status.Configured {
+			t.Errorf("expected the module to be configured, got %+v", status)
+		}
+		if modem.rate != 115200 || modem.moduleRate != 115200 {
+			t.Errorf("expected both ends at 115200, got UART %d and module %d", modem.rate, modem.moduleRate)
+		}
+	})
+}
+
+func TestDevice_baudMismatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		mismatch bool
+	}{
+		{"garbage", "\xf8\x00\x9c\xfe\x80\x1f\r\n\xf8\x00\x9c\xfe\r\n", true},
+		{"single glitch byte", "\xfeHello world\r\n", false},
+		{"clean URC chatter", "\r\nsome chatter the driver doesn't know\r\n\r\nand more of it\r\n", false},
+		{"garbage before a URC", "\xf8\x00\x9c\xfe\x80\x1f\r\n\r\n+CREG: 1\r\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modem := newMockModem(nil)
+			d := New(modem, nil, slog.New(slog.DiscardHandler))
+			modem.inject(tt.input)
+			d.watchBaud()
+			for {
+				if _, err := d.readLine(20 * time.Millisecond); errors.Is(err, ErrTimeout) {
+					break
+				}
+			}
+			if err := d.baudMismatch(); errors.Is(err, ErrBaudMismatch) != tt.mismatch {
+				t.Errorf("expected mismatch %v, got %v", tt.mismatch, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,508 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains Bluetooth scanning, pairing and SPP connections.
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// BTRecvBufSize is the size of the receive buffer of a Bluetooth SPP
+// connection. Data arriving while it is full is dropped.
+const BTRecvBufSize = 512
+
+const (
+	btMaxSend        = 1024 // Longest data AT+BTSPPSEND takes at once
+	btMinScanSeconds = 10   // Shortest scan AT+BTSCAN takes
+	btMaxScanSeconds = 60   // Longest scan AT+BTSCAN takes
+)
+
+var (
+	cmdBTPowerOn  = []byte("+BTPOWER=1")  // Power Bluetooth on
+	cmdBTPowerOff = []byte("+BTPOWER=0")  // Power Bluetooth off
+	cmdBTScan     = []byte("+BTSCAN=1,")  // Scan for devices, followed by the seconds
+	cmdBTPair     = []byte("+BTPAIR=0,")  // Pair with a scanned device, followed by its ID
+	cmdBTConfirm  = []byte("+BTPAIR=1,1") // Confirm the passkey of a pairing
+	cmdBTProfiles = []byte("+BTGETPROF=") // List the profiles of a paired device
+	cmdBTConnect  = []byte("+BTCONNECT=") // Connect a profile of a paired device
+	cmdBTAccept   = []byte("+BTACPT=1")   // Accept an incoming connection
+	cmdBTReject   = []byte("+BTACPT=0")   // Reject an incoming connection
+	cmdBTSend     = []byte("+BTSPPSEND=") // Send SPP data, followed by its length
+	cmdBTDisconn  = []byte("+BTDISCONN=") // Disconnect, followed by the connection ID
+
+	btScanned    = []byte("+BTSCAN")       // A device found, or the end of the scan
+	btPairing    = []byte("+BTPAIRING")    // Passkey of a pairing to confirm
+	btPaired     = []byte("+BTPAIR")       // Result of a pairing
+	btProfile    = []byte("+BTGETPROF")    // A profile of a paired device
+	btConnecting = []byte("+BTCONNECTING") // Incoming connection to accept
+	btConnected  = []byte("+BTCONNECT")    // Connection established
+	btData       = []byte("+BTSPPDATA")    // SPP data received
+	btDisconn    = []byte("+BTDISCONN")    // Connection closed
+	btSPP        = []byte("SPP")           // Name of the serial port profile
+)
+
+var ErrPairingFailed = errors.New("bluetooth pairing failed")
+
+// BTDevice is a Bluetooth device found by BTScan
+type BTDevice struct {
+	ID      int    // ID to pair with, valid until the next scan
+	Name    string // Device name
+	Address string // Bluetooth address, e.g. 33:7d:54:15:92:26
+	RSSI    int    // Signal strength in dBm
+}
+
+// BTConn is a Bluetooth serial port profile (SPP) connection. Like a
+// Connection it reads and writes a byte stream; the module keeps one SPP
+// connection at a time. Received data comes in +BTSPPDATA lines, so a
+// line break in it cuts it short.
+type BTConn struct {
+	ID      int    // Connection ID given by the module
+	Name    string // Name of the remote device
+	Address string // Bluetooth address of the remote device
+
+	device       *Device
+	buf          [BTRecvBufSize]byte // Data received and not read yet
+	n            int                 // Length of the data in buf
+	closed       bool                // Disconnected by either side
+	readDeadline atomic.Int64        // Unix nanoseconds, zero for none
+	blocking     atomic.Bool         // Read waits for data, see SetBlocking
+}
+
+// BTPower powers Bluetooth on or off. Powering it off closes the SPP
+// connection. Fails with ErrNotSupported on modules without Bluetooth,
+// like the SIM800L.
+func (d *Device) BTPower(on bool) error {
+	d.lock()
+	defer d.unlock()
+
+	if err := d.checkBluetooth(); err != nil {
+		return err
+	}
+	if on {
+		return d.send(cmdBTPowerOn)
+	}
+	if d.bt != nil {
+		d.bt.closed = true
+		d.bt = nil
+	}
+	return d.send(cmdBTPowerOff)
+}
+
+// BTScan searches for devices for timeout, 10 to 60 seconds, and stores
+// those found in dst. It returns the number of devices stored; the rest
+// are dropped.
+func (d *Device) BTScan(dst []BTDevice, timeout time.Duration) (int, error) {
+	d.lock()
+	defer d.unlock()
+
+	if err := d.checkBluetooth(); err != nil {
+		return 0, err
+	}
+	seconds := min(max(int(timeout/time.Second), btMinScanSeconds), btMaxScanSeconds)
+	var buf [24]byte
+	cmd := strconv.AppendInt(append(buf[:0], cmdBTScan...), int64(seconds), 10)
+	if err := d.send(cmd); err != nil {
+		return 0, err
+	}
+
+	// The module reports each device, then +BTSCAN: 1 once done
+	deadline := time.Now().Add(time.Duration(seconds)*time.Second + DefaultTimeout)
+	n := 0
+	for {
+		_, v, err := d.awaitBT(deadline, btScanned)
+		if err != nil {
+			return n, err
+		}
+		if !bytes.HasPrefix(v, []byte("0,")) {
+			return n, nil
+		}
+		if n == len(dst) {
+			continue
+		}
+		// Format: 0,<id>,"<name>",<address>,<rssi>
+		id, _ := strconv.Atoi(string(listField(v, 1)))
+		rssi, _ := strconv.Atoi(string(listField(v, 4)))
+		dst[n] = BTDevice{ID: id, Name: string(listField(v, 2)), Address: string(listField(v, 3)), RSSI: rssi}
+		n++
+	}
+}
+
+// BTPair pairs with a device found by BTScan, confirming the passkey the
+// module reports. It fails with ErrPairingFailed if the device refuses.
+func (d *Device) BTPair(id int, timeout time.Duration) error {
+	d.lock()
+	defer d.unlock()
+
+	if err := d.checkBluetooth(); err != nil {
+		return err
+	}
+	var buf [24]byte
+	cmd := strconv.AppendInt(append(buf[:0], cmdBTPair...), int64(id), 10)
+	if err := d.send(cmd); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		i, v, err := d.awaitBT(deadline, btPairing, btPaired)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			// Format: "<name>",<address>,<passkey>
+			d.log(SubsystemCommand, slog.LevelInfo, "confirming bluetooth passkey", "passkey", listField(v, 2))
+			if err := d.send(cmdBTConfirm); err != nil {
+				return err
+			}
+			continue
+		}
+		// Format: <result>,"<name>",<address>
+		if !bytes.HasPrefix(v, []byte("1")) {
+			return fmt.Errorf("%w: %s", ErrPairingFailed, v)
+		}
+		return nil
+	}
+}
+
+// BTDial opens an SPP connection to a paired device, with IDs counted
+// from 1 in the order the devices were paired.
+func (d *Device) BTDial(pairedID int, timeout time.Duration) (*BTConn, error) {
+	d.lock()
+	defer d.unlock()
+
+	if err := d.checkBluetooth(); err != nil {
+		return nil, err
+	}
+	if d.bt != nil {
+		return nil, ErrMaxConn
+	}
+	deadline := time.Now().Add(timeout)
+	profile, err := d.sppProfile(pairedID, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf [32]byte
+	cmd := strconv.AppendInt(append(buf[:0], cmdBTConnect...), int64(pairedID), 10)
+	cmd = strconv.AppendInt(append(cmd, ','), int64(profile), 10)
+	if err := d.send(cmd); err != nil {
+		return nil, err
+	}
+	_, v, err := d.awaitBT(deadline, btConnected)
+	if err != nil {
+		return nil, err
+	}
+	return d.newBTConn(v), nil
+}
+
+// BTListen waits for a device to pair and open an SPP connection,
+// confirming its passkey and accepting the connection, until timeout.
+// Requests to connect to other profiles are rejected. Requests arriving
+// while nobody listens are dropped.
+func (d *Device) BTListen(timeout time.Duration) (*BTConn, error) {
+	d.lock()
+	defer d.unlock()
+
+	if err := d.checkBluetooth(); err != nil {
+		return nil, err
+	}
+	if d.bt != nil {
+		return nil, ErrMaxConn
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		i, v, err := d.awaitBT(deadline, btPairing, btConnecting, btConnected)
+		if err != nil {
+			return nil, err
+		}
+		switch i {
+		case 0:
+			err = d.send(cmdBTConfirm)
+		case 1:
+			// Format: "<address>","<profile>"
+			if bytes.Equal(listField(v, 1), btSPP) {
+				err = d.send(cmdBTAccept)
+			} else {
+				err = d.send(cmdBTReject)
+			}
+		default:
+			return d.newBTConn(v), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Read reads data received on the connection. It waits until data
+// arrives, the connection closes or the read deadline passes. Without a
+// deadline it returns ErrWouldBlock after DefaultTimeout, unless the read
+// is blocking like a Connection's, with SetBlocking or
+// Config.BlockingRead; it then waits for data or Config.ReadTimeout.
+func (c *BTConn) Read(b []byte) (int, error) {
+	d := c.device
+	d.lock()
+	blocking, readTimeout := d.blockingRead || c.blocking.Load(), d.readTimeout
+	d.unlock()
+
+	// Without a read deadline the wait is bounded by the read mode
+	limit, expired := time.Now().Add(DefaultTimeout), ErrWouldBlock
+	if blocking {
+		limit, expired = time.Time{}, ErrDeadlineExceeded
+		if readTimeout > 0 {
+			limit = time.Now().Add(readTimeout)
+		}
+	}
+	for {
+		deadline := c.readDeadline.Load()
+		if deadline != 0 && time.Now().UnixNano() >= deadline {
+			return 0, ErrDeadlineExceeded
+		}
+
+		d.lock()
+		if c.n == 0 && !c.closed {
+			if err := d.poll(); err != nil {
+				d.log(SubsystemData, slog.LevelDebug, "error checking for data", "error", err)
+			}
+		}
+		if c.n > 0 {
+			n := copy(b, c.buf[:c.n])
+			c.n = copy(c.buf[:], c.buf[n:c.n])
+			d.unlock()
+			return n, nil
+		}
+		closed := c.closed
+		d.unlock()
+
+		if closed {
+			return 0, io.EOF
+		}
+		if deadline == 0 && !limit.IsZero() && !time.Now().Before(limit) {
+			return 0, expired
+		}
+		// Wait unlocked so other goroutines can use the device
+		time.Sleep(readPollInterval)
+	}
+}
+
+// Write sends data on the connection
+func (c *BTConn) Write(b []byte) (int, error) {
+	d := c.device
+	d.lock()
+	defer d.unlock()
+
+	sent := 0
+	for sent < len(b) {
+		if c.closed {
+			return sent, ErrConnectionClosed
+		}
+		size := min(len(b)-sent, btMaxSend)
+		var buf [24]byte
+		cmd := strconv.AppendInt(append(buf[:0], cmdBTSend...), int64(size), 10)
+		if err := d.sendRaw(cmd); err != nil {
+			return sent, err
+		}
+		t, err := d.readLine(DefaultTimeout)
+		if err != nil {
+			return sent, fmt.Errorf("failed to read prompt: %w", err)
+		}
+		if t != TokenPrompt {
+			return sent, ErrUnexpectedResponse
+		}
+		if _, err := d.uart.Write(b[sent : sent+size]); err != nil {
+			return sent, fmt.Errorf("failed to send data: %w", err)
+		}
+		if err := d.readResponse(nil, func(buffer []byte) error {
+			if bytes.Equal(buffer, []byte("SEND OK")) {
+				return nil
+			}
+			if bytes.Equal(buffer, []byte("SEND FAIL")) {
+				return ErrCannotSend
+			}
+			return defaultResponseCheck(buffer)
+		}, DefaultTimeout); err != nil {
+			return sent, err
+		}
+		sent += size
+	}
+	return sent, nil
+}
+
+// Close disconnects the connection. Data received and not read is lost.
+func (c *BTConn) Close() error {
+	d := c.device
+	d.lock()
+	defer d.unlock()
+
+	if c.closed {
+		return ErrConnectionClosed
+	}
+	c.closed = true
+	if d.bt == c {
+		d.bt = nil
+	}
+	var buf [24]byte
+	return d.send(strconv.AppendInt(append(buf[:0], cmdBTDisconn...), int64(c.ID), 10))
+}
+
+// SetBlocking makes Read wait for data like a net.Conn, as
+// Config.BlockingRead does for every connection, so the connection can be
+// used as an io.Reader without handling ErrWouldBlock
+func (c *BTConn) SetBlocking(on bool) {
+	c.blocking.Store(on)
+}
+
+// SetReadDeadline sets the deadline for Read calls. A zero value means
+// Read waits as its read mode says, see Read.
+func (c *BTConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Store(deadlineNanos(t))
+	return nil
+}
+
+// checkBluetooth fails with ErrNotSupported if the module has no Bluetooth
+func (d *Device) checkBluetooth() error {
+	if !d.capabilities().Bluetooth {
+		return fmt.Errorf("%w: no bluetooth", ErrNotSupported)
+	}
+	return nil
+}
+
+// sppProfile returns the ID of the serial port profile of a paired device
+func (d *Device) sppProfile(pairedID int, deadline time.Time) (int, error) {
+	var buf [24]byte
+	cmd := strconv.AppendInt(append(buf[:0], cmdBTProfiles...), int64(pairedID), 10)
+	if err := d.sendRaw(cmd); err != nil {
+		return 0, err
+	}
+
+	// Format: +BTGETPROF: <profile id>,"<profile name>" per profile, then OK
+	profile := -1
+	for {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return 0, ErrTimeout
+		}
+		if err := d.readResponse(btProfile, nil, timeout); err != nil {
+			return 0, err
+		}
+		if v, ok := d.parseValue(btProfile); ok {
+			if id, err := strconv.Atoi(string(listField(v, 0))); err == nil && bytes.Equal(listField(v, 1), btSPP) {
+				profile = id
+			}
+			continue
+		}
+		if err := defaultResponseCheck(d.rxBuffer[:d.end]); err != nil {
+			return 0, err
+		}
+		if profile < 0 {
+			return 0, fmt.Errorf("%w: no serial port profile", ErrNotSupported)
+		}
+		return profile, nil
+	}
+}
+
+// awaitBT reads lines until one named one of names arrives and returns
+// which and its value. Other lines are dispatched or skipped.
+func (d *Device) awaitBT(deadline time.Time, names ...[]byte) (int, []byte, error) {
+	for {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return 0, nil, fmt.Errorf("%w: no %s", ErrTimeout, names[0])
+		}
+		if err := d.readResponse(names[0], nil, timeout); err != nil {
+			return 0, nil, err
+		}
+		for i, name := range names {
+			if v, ok := d.parseValue(name); ok {
+				return i, v, nil
+			}
+		}
+		line := d.rxBuffer[:d.end]
+		if bytes.Contains(line, errorToken) {
+			return 0, nil, defaultResponseCheck(line)
+		}
+		d.log(SubsystemCommand, slog.LevelDebug, "skipping line", "line", line)
+	}
+}
+
+// newBTConn makes the connection reported by a +BTCONNECT line the
+// current one, with the lock held
+func (d *Device) newBTConn(v []byte) *BTConn {
+	// Format: <id>,"<name>",<address>,"<profile>"
+	id, _ := strconv.Atoi(string(listField(v, 0)))
+	c := &BTConn{ID: id, Name: string(listField(v, 1)), Address: string(listField(v, 2)), device: d}
+	d.bt = c
+	d.log(SubsystemData, slog.LevelInfo, "bluetooth connected", "name", c.Name, "address", c.Address)
+	return c
+}
+
+// btInput takes the SPP data and disconnections of the connection and
+// reports whether line was one
+func (d *Device) btInput(line []byte) bool {
+	if v, ok := cutValue(line, btData); ok {
+		// Format: <id>,<length>,<data>
+		c := d.bt
+		if c == nil {
+			return true
+		}
+		data := v
+		for range 2 {
+			if i := bytes.IndexByte(data, ','); i >= 0 {
+				data = data[i+1:]
+			}
+		}
+		n := copy(c.buf[c.n:], data)
+		c.n += n
+		if n < len(data) || d.truncated {
+			d.log(SubsystemData, slog.LevelWarn, "bluetooth data dropped", "received", len(data), "kept", n)
+		}
+		return true
+	}
+	if _, ok := cutValue(line, btDisconn); ok {
+		if d.bt != nil {
+			d.bt.closed = true
+			d.bt = nil
+		}
+		d.log(SubsystemData, slog.LevelInfo, "bluetooth disconnected")
+		return true
+	}
+	return false
+}
+
+// cutValue returns the value of line if it is named k, "1,5,hello" of
+// "+BTSPPDATA: 1,5,hello" for +BTSPPDATA. Unlike parseValue it keeps the
+// value's spaces, which may be data.
+func cutValue(line, k []byte) ([]byte, bool) {
+	v, ok := bytes.CutPrefix(line, k)
+	if !ok || len(v) == 0 || v[0] != ':' {
+		return nil, false
+	}
+	return bytes.TrimPrefix(v[1:], []byte(" ")), true
+}
+
+// listField returns the n-th (zero based) comma separated field of v
+// without its quotes, skipping commas in quotes, or nil if there are not
+// enough fields
+func listField(v []byte, n int) []byte {
+	start, quoted := 0, false
+	for i := 0; i <= len(v); i++ {
+		if i < len(v) && (v[i] != ',' || quoted) {
+			if v[i] == '"' {
+				quoted = !quoted
+			}
+			continue
+		}
+		if n == 0 {
+			return bytes.Trim(bytes.TrimSpace(v[start:i]), "\"")
+		}
+		n--
+		start = i + 1
+	}
+	return nil
+}
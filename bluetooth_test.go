@@ -0,0 +1,132 @@
+package sim800l
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDevice_BTScanPairDial(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+BTPOWER=1": "\r\nOK\r\n",
+		"AT+BTSCAN=1,10": "\r\nOK\r\n" +
+			"\r\n+BTSCAN: 0,1,\"Phone, Inc\",33:7d:54:15:92:26,-41\r\n" +
+			"\r\n+BTSCAN: 0,2,\"Keyboard\",aa:bb:cc:dd:ee:ff,-70\r\n" +
+			"\r\n+BTSCAN: 1\r\n",
+		"AT+BTPAIR=0,1":    "\r\nOK\r\n\r\n+BTPAIRING: \"Phone, Inc\",33:7d:54:15:92:26,832722\r\n",
+		"AT+BTPAIR=1,1":    "\r\nOK\r\n\r\n+BTPAIR: 1,\"Phone, Inc\",33:7d:54:15:92:26\r\n",
+		"AT+BTGETPROF=1":   "\r\n+BTGETPROF: 5,\"HFP\"\r\n\r\n+BTGETPROF: 4,\"SPP\"\r\n\r\nOK\r\n",
+		"AT+BTCONNECT=1,4": "\r\nOK\r\n\r\n+BTCONNECT: 1,\"Phone, Inc\",33:7d:54:15:92:26,\"SPP\"\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	// The SIM800L has no Bluetooth
+	d.Model = "SIMCOM_SIM800L"
+	if err := d.BTPower(true); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+
+	d.Model = "SIMCOM_SIM800C"
+	if err := d.BTPower(true); err != nil {
+		t.Fatalf("power on failed: %v", err)
+	}
+	var found [1]BTDevice
+	n, err := d.BTScan(found[:], time.Second)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	expected := BTDevice{ID: 1, Name: "Phone, Inc", Address: "33:7d:54:15:92:26", RSSI: -41}
+	if n != 1 || found[0] != expected {
+		t.Errorf("expected %+v, got %d: %+v", expected, n, found[0])
+	}
+
+	if err := d.BTPair(1, time.Second); err != nil {
+		t.Fatalf("pairing failed: %v", err)
+	}
+	conn, err := d.BTDial(1, time.Second)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	if conn.ID != 1 || conn.Name != "Phone, Inc" || conn.Address != "33:7d:54:15:92:26" {
+		t.Errorf("unexpected connection %+v", conn)
+	}
+	if _, err := d.BTDial(1, time.Second); !errors.Is(err, ErrMaxConn) {
+		t.Errorf("expected ErrMaxConn for a second connection, got %v", err)
+	}
+}
+
+func TestDevice_BTListen(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+BTPAIR=1,1":  "\r\nOK\r\n\r\n+BTCONNECTING: \"33:7d:54:15:92:26\",\"SPP\"\r\n",
+		"AT+BTACPT=1":    "\r\nOK\r\n\r\n+BTCONNECT: 1,\"Phone\",33:7d:54:15:92:26,\"SPP\"\r\n",
+		"AT+BTSPPSEND=5": "\r\n> ",
+		"AT+BTDISCONN=1": "\r\nOK\r\n\r\n+BTDISCONN: \"Phone\",33:7d:54:15:92:26,\"SPP\"\r\n",
+	})
+	modem.dataReply = "\r\nSEND OK\r\n"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Model = "SIMCOM_SIM808"
+
+	modem.inject("\r\n+BTPAIRING: \"Phone\",33:7d:54:15:92:26,832722\r\n")
+	conn, err := d.BTListen(time.Second)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	modem.inject("\r\n+BTSPPDATA: 1,11,hello world\r\n")
+	buf := make([]byte, 5)
+	if n, err := conn.Read(buf); err != nil || string(buf[:n]) != "hello" {
+		t.Errorf("expected hello, got %q, %v", buf[:n], err)
+	}
+	if n, err := conn.Read(buf); err != nil || string(buf[:n]) != " worl" {
+		t.Errorf("expected the rest of the data, got %q, %v", buf[:n], err)
+	}
+	if n, err := conn.Write([]byte("howdy")); err != nil || n != 5 {
+		t.Errorf("write failed: %d, %v", n, err)
+	}
+
+	// Data received before the remote device disconnected is still read
+	modem.inject("\r\n+BTDISCONN: \"Phone\",33:7d:54:15:92:26,\"SPP\"\r\n")
+	if n, err := conn.Read(buf); err != nil || string(buf[:n]) != "d" {
+		t.Errorf("expected the last byte, got %q, %v", buf[:n], err)
+	}
+	if _, err := conn.Read(buf); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+	if err := conn.Close(); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed, got %v", err)
+	}
+}
+
+func TestBTConn_BlockingRead(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+BTPAIR=1,1": "\r\nOK\r\n\r\n+BTCONNECTING: \"33:7d:54:15:92:26\",\"SPP\"\r\n",
+		"AT+BTACPT=1":   "\r\nOK\r\n\r\n+BTCONNECT: 1,\"Phone\",33:7d:54:15:92:26,\"SPP\"\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Model = "SIMCOM_SIM808"
+	modem.inject("\r\n+BTPAIRING: \"Phone\",33:7d:54:15:92:26,832722\r\n")
+	conn, err := d.BTListen(time.Second)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	// A blocking read waits for data like a Connection's
+	conn.SetBlocking(true)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		modem.inject("\r\n+BTSPPDATA: 1,2,hi\r\n")
+	}()
+	buf := make([]byte, 8)
+	if n, err := conn.Read(buf); err != nil || string(buf[:n]) != "hi" {
+		t.Errorf("expected hi, got %q, %v", buf[:n], err)
+	}
+
+	// Config.ReadTimeout bounds it, without ErrWouldBlock
+	conn.SetBlocking(false)
+	d.Configure(Config{BlockingRead: true, ReadTimeout: 20 * time.Millisecond})
+	if _, err := conn.Read(buf); !errors.Is(err, ErrDeadlineExceeded) {
+		t.Errorf("expected ErrDeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the detection of the end of the module's boot.
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+const (
+	// bootProbeDelay is the time after a reset before the module is asked
+	// whether it is up. It doesn't answer earlier, and after AT+CFUN=1,1
+	// it may still answer from before the restart.
+	bootProbeDelay = 3 * time.Second
+
+	// bootPollInterval is the time between probes of a module that sends
+	// no boot URCs, and the longest wait for its answer
+	bootPollInterval = 500 * time.Millisecond
+)
+
+var (
+	bootReady     = []byte("RDY")          // Sent first at a fixed baud rate
+	smsReady      = []byte("SMS Ready")    // Sent last, once the SIM is ready
+	simStateLine  = []byte("+CPIN: ")      // Followed by READY, or why the SIM can't be used
+	simReadyState = []byte("+CPIN: READY") // The SIM is ready, SMS Ready follows
+)
+
+// waitReady waits, after a reset, until the module has booted: until it
+// reports SMS Ready, the last of its boot URCs, or that the SIM can't be
+// used, so no SMS Ready follows. A module in auto-baud mode, or with its
+// boot URCs disabled, says nothing until it hears from the host; it is
+// polled with AT every bootPollInterval and ready once it answers. Boot
+// URCs are still read between the probes, and once one arrives the wait
+// is for SMS Ready again. The wait ends after StartupTime at the latest
+// and fails only if ctx is done. The time the boot took is logged and
+// kept for Diagnostics.
+func (d *Device) waitReady(ctx context.Context) error {
+	start := time.Now()
+	deadline := start.Add(StartupTime)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	d.bootTime = 0
+
+	booting := false    // Boot URCs arrived, so SMS Ready will follow
+	var probe time.Time // When the module was last probed
+	for time.Now().Before(deadline) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		wait := min(bootPollInterval, time.Until(deadline))
+		if !booting && time.Since(start) >= bootProbeDelay {
+			// No boot URCs so far, the module may only speak when spoken to
+			if since := time.Since(probe); since < bootPollInterval {
+				wait = min(wait, bootPollInterval-since)
+			} else {
+				probe = time.Now()
+				queued := d.urcCount
+				err := d.exchange(at, defaultResponseCheck, wait)
+				if err == nil {
+					d.booted(start, "module answering")
+					return nil
+				}
+				// Boot URCs may come with the answer to the probe, queued
+				// or in place of it
+				for i := queued; i < d.urcCount; i++ {
+					q := &d.urcQueue[(d.urcHead+i)%MaxQueuedURCs]
+					if d.bootMessage(q.line[:q.n], start, &booting) {
+						return nil
+					}
+				}
+				if !errors.Is(err, ErrTimeout) && d.bootMessage(d.rxBuffer[:d.end], start, &booting) {
+					return nil
+				}
+				continue
+			}
+		}
+
+		t, err := d.readLine(wait)
+		if err != nil || t != TokenLine {
+			continue
+		}
+		line := d.rxBuffer[:d.end]
+		d.log(SubsystemPower, slog.LevelDebug, "boot message", "line", line, "after", time.Since(start))
+		ready := d.bootMessage(line, start, &booting)
+		if _, err := d.dispatchInput(line); err != nil {
+			d.log(SubsystemPower, slog.LevelDebug, "failed to process boot message", "error", err)
+		}
+		if ready {
+			return nil
+		}
+	}
+	d.log(SubsystemPower, slog.LevelWarn, "module not ready after startup time", "booting", booting)
+	return ctx.Err()
+}
+
+// bootMessage notes in booting whether line, sent by the module while it
+// boots, is a boot URC, and reports whether the boot is over
+func (d *Device) bootMessage(line []byte, start time.Time, booting *bool) bool {
+	*booting = *booting || bytes.Equal(line, bootReady) || isKnownURC(nil, line)
+	if bytes.Equal(line, smsReady) || (bytes.HasPrefix(line, simStateLine) && !bytes.Equal(line, simReadyState)) {
+		d.booted(start, "module ready")
+		return true
+	}
+	return false
+}
+
+// booted records the time since start the module took to boot
+func (d *Device) booted(start time.Time, msg string) {
+	d.bootTime = time.Since(start)
+	d.log(SubsystemPower, slog.LevelInfo, msg, "boot", d.bootTime.Round(time.Millisecond))
+}
@@ -0,0 +1,116 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the detection of the module variant and what it can do.
+package sim800l
+
+import "strings"
+
+// Variant identifies a module of the SIM800 family, or its SIM900
+// predecessor, that speaks the same AT command set
+type Variant uint8
+
+const (
+	VariantUnknown Variant = iota // Not identified yet, or another module
+	VariantSIM800L                // SIM800L, GSM/GPRS, with Bluetooth on _BT firmware
+	VariantSIM800C                // SIM800C, with Bluetooth
+	VariantSIM808                 // SIM808, with Bluetooth and a GNSS receiver
+	VariantSIM900                 // SIM900, the SIM800's predecessor
+)
+
+func (v Variant) String() string {
+	switch v {
+	case VariantSIM800L:
+		return "SIM800L"
+	case VariantSIM800C:
+		return "SIM800C"
+	case VariantSIM808:
+		return "SIM808"
+	case VariantSIM900:
+		return "SIM900"
+	default:
+		return "Unknown"
+	}
+}
+
+// Capabilities describes what the module can do, as far as the driver
+// cares
+type Capabilities struct {
+	Variant        Variant // Module variant
+	SSL            bool    // TLS connections with AT+CIPSSL
+	Bluetooth      bool    // Bluetooth with AT+BTPOWER
+	GNSS           bool    // GNSS receiver with AT+CGNSPWR
+	MaxConnections int     // Connections open at once in multi-connection mode
+}
+
+// firmwareBluetooth ends the firmware revision of SIM800 builds with
+// Bluetooth, like 1418B05SIM800L24_BT, which the SIM800L can be flashed with
+const firmwareBluetooth = "_BT"
+
+// variantNames maps the names the modules give in AT+CGMM and in their
+// firmware revision to the variants. SIM808 comes before SIM800 so the
+// longer matches win.
+var variantNames = [...]struct {
+	name    string
+	variant Variant
+}{
+	{"SIM808", VariantSIM808},
+	{"SIM800C", VariantSIM800C},
+	{"SIM800L", VariantSIM800L},
+	{"SIM900", VariantSIM900},
+}
+
+// Capabilities returns what the module can do. It is known once Init or
+// Info has read the model and firmware revision; before that the variant
+// is unknown and only what every variant has is reported.
+func (d *Device) Capabilities() Capabilities {
+	d.lock()
+	defer d.unlock()
+	return d.capabilities()
+}
+
+// capabilities returns what the module can do, with the lock held
+func (d *Device) capabilities() Capabilities {
+	return detectCapabilities(d.Model, d.Firmware)
+}
+
+// detectCapabilities derives the capabilities from the model, like
+// SIMCOM_SIM800L, and the firmware revision, like 1418B05SIM800L24, which
+// also names the variant if the model doesn't
+func detectCapabilities(model, firmware string) Capabilities {
+	c := Capabilities{Variant: moduleVariant(model)}
+	if c.Variant == VariantUnknown {
+		c.Variant = moduleVariant(firmware)
+	}
+
+	// Every SIM800 has 6 connections, 0-5; the SIM900 has 8
+	c.MaxConnections = 6
+	switch c.Variant {
+	case VariantSIM800C:
+		c.Bluetooth = true
+	case VariantSIM808:
+		c.Bluetooth = true
+		c.GNSS = true
+	case VariantSIM900:
+		c.MaxConnections = 8
+	}
+	if c.Variant != VariantSIM900 && strings.HasSuffix(strings.ToUpper(firmware), firmwareBluetooth) {
+		c.Bluetooth = true
+	}
+
+	// The SIM900's releases are numbered apart from the SIM800's
+	if c.Variant != VariantSIM900 {
+		release, ok := firmwareRelease(firmware)
+		c.SSL = ok && release >= minFirmwareRelease
+	}
+	return c
+}
+
+// moduleVariant returns the variant named in s
+func moduleVariant(s string) Variant {
+	s = strings.ToUpper(s)
+	for _, v := range variantNames {
+		if strings.Contains(s, v.name) {
+			return v.variant
+		}
+	}
+	return VariantUnknown
+}
@@ -0,0 +1,43 @@
+package sim800l
+
+import "testing"
+
+func TestDetectCapabilities(t *testing.T) {
+	tests := []struct {
+		model, firmware string
+		expected        Capabilities
+	}{
+		{"", "", Capabilities{MaxConnections: 6}},
+		{"SIMCOM_SIM800L", "1418B05SIM800L24", Capabilities{Variant: VariantSIM800L, SSL: true, MaxConnections: 6}},
+		{"SIMCOM_SIM800L", "1308B08SIM800L16", Capabilities{Variant: VariantSIM800L, MaxConnections: 6}},
+		// A SIM800L flashed with the Bluetooth firmware
+		{"SIMCOM_SIM800L", "1418B05SIM800L24_BT", Capabilities{Variant: VariantSIM800L, SSL: true, Bluetooth: true, MaxConnections: 6}},
+		{"SIMCOM_SIM800C", "1418B04SIM800C32", Capabilities{Variant: VariantSIM800C, SSL: true, Bluetooth: true, MaxConnections: 6}},
+		{"SIMCOM_SIM808", "1418B04SIM808M32", Capabilities{Variant: VariantSIM808, SSL: true, Bluetooth: true, GNSS: true, MaxConnections: 6}},
+		{"SIMCOM_SIM900", "1137B13SIM900M64_ST", Capabilities{Variant: VariantSIM900, MaxConnections: 8}},
+		// The firmware names the variant if the model doesn't
+		{"", "1418B04sim808M32", Capabilities{Variant: VariantSIM808, SSL: true, Bluetooth: true, GNSS: true, MaxConnections: 6}},
+	}
+
+	for _, tt := range tests {
+		if got := detectCapabilities(tt.model, tt.firmware); got != tt.expected {
+			t.Errorf("%q, %q: expected %+v, got %+v", tt.model, tt.firmware, tt.expected, got)
+		}
+	}
+}
+
+func TestDevice_CapabilitiesGateFeatures(t *testing.T) {
+	d := &Device{Model: "SIMCOM_SIM900", Firmware: "1418B13SIM900M64_ST"}
+	if d.Supports(FeatureSSL) {
+		t.Error("expected no SSL on a SIM900")
+	}
+	if got := d.Capabilities().Variant.String(); got != "SIM900" {
+		t.Errorf("expected SIM900, got %s", got)
+	}
+
+	// GNSS needs the receiver and the sim800l_gnss tag
+	d.Model = "SIMCOM_SIM808"
+	if d.Supports(FeatureGNSS) != gnssCompiled {
+		t.Errorf("expected GNSS support %v on a SIM808", gnssCompiled)
+	}
+}
@@ -0,0 +1,229 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the certificates kept in the module's file system for
+// its SSL.
+package sim800l
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MaxCertSize    = 10240 // Largest certificate AT+FSWRITE takes at once
+	MaxCertNameLen = 32    // Longest certificate file name
+	MaxCerts       = 4     // Certificates whose upload the driver remembers
+
+	certWriteSeconds = 10 // Time the module waits for the certificate data
+)
+
+var (
+	cmdFSCreate   = []byte("+FSCREATE=")   // Create a file, followed by its path
+	cmdFSWrite    = []byte("+FSWRITE=")    // Write a file, followed by its path, mode, size and timeout
+	cmdFSSize     = []byte("+FSFLSIZE=")   // Query the size of a file, followed by its path
+	cmdFSDelete   = []byte("+FSDEL=")      // Delete a file, followed by its path
+	cmdSSLSetCert = []byte("+SSLSETCERT=") // Load a certificate for SSL, followed by its path
+	fsSize        = []byte("+FSFLSIZE")    // Size of a file
+	sslCertResult = []byte("+SSLSETCERT")  // Result of loading a certificate, sent after OK
+	certDir       = `C:\USER\`             // Directory of the certificates
+)
+
+var ErrCertRejected = errors.New("certificate rejected by the module")
+
+// certFile is a certificate the driver uploaded
+type certFile struct {
+	name string // File name, upper case
+	size int    // Length of the data
+	sum  uint32 // CRC-32 of the data
+}
+
+// UploadCert writes a CA or client certificate, PEM or DER encoded, to the
+// module's file system as name, replacing a file of that name. The upload
+// is skipped if the driver already wrote the same data to name, so it can
+// be called on every reconnect without wearing the flash. The file stays
+// on the module across resets; SelectCert loads it for SSL.
+func (d *Device) UploadCert(name string, data []byte) error {
+	if err := checkCertName(name); err != nil {
+		return err
+	}
+	if len(data) == 0 || len(data) > MaxCertSize {
+		return fmt.Errorf("%w: certificate of %d bytes, max %d", ErrBadParameter, len(data), MaxCertSize)
+	}
+	name = strings.ToUpper(name)
+
+	d.lock()
+	defer d.unlock()
+
+	sum := crc32.ChecksumIEEE(data)
+	slot := d.certSlot(name)
+	if c := d.certs[slot]; c.name == name && c.size == len(data) && c.sum == sum {
+		d.log(SubsystemData, slog.LevelDebug, "certificate unchanged, not uploaded", "name", name)
+		return nil
+	}
+	// The module keeps the file's old data until the next load
+	if d.sslCert == name {
+		d.sslCert = ""
+	}
+	d.certs[slot] = certFile{}
+
+	if err := d.writeCert(name, data); err != nil {
+		return fmt.Errorf("failed to upload certificate %s: %w", name, err)
+	}
+	d.certs[slot] = certFile{name: name, size: len(data), sum: sum}
+	d.log(SubsystemData, slog.LevelInfo, "certificate uploaded", "name", name, "size", len(data))
+	return nil
+}
+
+// SelectCert loads the certificate uploaded as name, with the password of
+// its private key if it has one, for the SSL connections started next.
+// Loading the certificate already loaded is skipped until the module is
+// reset. A certificate the module can't parse fails with ErrCertRejected.
+// The password is redacted from errors, logs and the error history.
+func (d *Device) SelectCert(name, password string) error {
+	if err := checkCertName(name); err != nil {
+		return err
+	}
+	if strings.ContainsAny(password, "\"\r\n") {
+		return fmt.Errorf("%w: password", ErrBadParameter)
+	}
+	name = strings.ToUpper(name)
+
+	d.lock()
+	defer d.unlock()
+
+	if !d.capabilities().SSL {
+		return fmt.Errorf("%w: no SSL in firmware %q", ErrNotSupported, d.Firmware)
+	}
+	if d.sslCert == name && d.sslCertPassword == password {
+		return nil
+	}
+
+	var buf [MaxCommandSize]byte
+	cmd := append(buf[:0], cmdSSLSetCert...)
+	cmd = append(cmd, '"')
+	cmd = appendCertPath(cmd, name)
+	cmd = append(cmd, '"')
+	if password != "" {
+		cmd = append(cmd, ",\""...)
+		cmd = append(cmd, password...)
+		cmd = append(cmd, '"')
+	}
+	if err := d.send(cmd); err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", name, err)
+	}
+	if err := d.readResponse(cmd, prefixCheck(sslCertResult), DefaultTimeout); err != nil {
+		return fmt.Errorf("failed to load certificate %s: %w", name, err)
+	}
+	val, ok := d.parseValue(sslCertResult)
+	if !ok {
+		return ErrUnexpectedResponse
+	}
+	if string(val) != "0" {
+		return fmt.Errorf("%w: %s, error %s", ErrCertRejected, name, val)
+	}
+
+	d.sslCert, d.sslCertPassword = name, password
+	d.log(SubsystemData, slog.LevelInfo, "certificate loaded", "name", name)
+	return nil
+}
+
+// DeleteCert deletes the certificate uploaded as name from the module's
+// file system
+func (d *Device) DeleteCert(name string) error {
+	if err := checkCertName(name); err != nil {
+		return err
+	}
+	name = strings.ToUpper(name)
+
+	d.lock()
+	defer d.unlock()
+
+	var buf [MaxCommandSize]byte
+	if err := d.send(appendCertPath(append(buf[:0], cmdFSDelete...), name)); err != nil {
+		return fmt.Errorf("failed to delete certificate %s: %w", name, err)
+	}
+	if slot := d.certSlot(name); d.certs[slot].name == name {
+		d.certs[slot] = certFile{}
+	}
+	if d.sslCert == name {
+		d.sslCert = ""
+	}
+	return nil
+}
+
+// writeCert replaces the file name with data, with the lock held. An
+// existing file is deleted first, as AT+FSWRITE doesn't truncate it.
+func (d *Device) writeCert(name string, data []byte) error {
+	// A missing file fails the query, which isn't worth logging as an error
+	var buf [MaxCommandSize]byte
+	if d.exchange(appendCertPath(append(buf[:0], cmdFSSize...), name), prefixCheck(fsSize), DefaultTimeout) == nil {
+		if err := d.send(appendCertPath(append(buf[:0], cmdFSDelete...), name)); err != nil {
+			return err
+		}
+	}
+	if err := d.send(appendCertPath(append(buf[:0], cmdFSCreate...), name)); err != nil {
+		return err
+	}
+
+	cmd := appendCertPath(append(buf[:0], cmdFSWrite...), name)
+	cmd = append(cmd, ",0,"...)
+	cmd = strconv.AppendInt(cmd, int64(len(data)), 10)
+	cmd = append(cmd, ',')
+	cmd = strconv.AppendInt(cmd, certWriteSeconds, 10)
+	if err := d.sendRaw(cmd); err != nil {
+		return err
+	}
+	t, err := d.readLine(DefaultTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to read prompt: %w", err)
+	}
+	if t != TokenPrompt {
+		return ErrUnexpectedResponse
+	}
+	if _, err := d.uart.Write(data); err != nil {
+		return fmt.Errorf("failed to send data: %w", err)
+	}
+	return d.readResponse(nil, defaultResponseCheck, certWriteSeconds*time.Second)
+}
+
+// certSlot returns the index in certs of the upload of name, or of the
+// slot to record it in
+func (d *Device) certSlot(name string) int {
+	free := -1
+	for i, c := range d.certs {
+		if c.name == name {
+			return i
+		}
+		if free < 0 && c.name == "" {
+			free = i
+		}
+	}
+	if free < 0 {
+		// Forget the first, the next upload of it writes it again
+		free = 0
+	}
+	return free
+}
+
+// checkCertName checks that name is a plain file name the module's file
+// system takes
+func checkCertName(name string) error {
+	if name == "" || len(name) > MaxCertNameLen {
+		return fmt.Errorf("%w: certificate name %q", ErrBadParameter, name)
+	}
+	for _, c := range []byte(name) {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '.' && c != '_' && c != '-' {
+			return fmt.Errorf("%w: certificate name %q", ErrBadParameter, name)
+		}
+	}
+	return nil
+}
+
+// appendCertPath appends the path of the certificate name to dst
+func appendCertPath(dst []byte, name string) []byte {
+	return append(append(dst, certDir...), name...)
+}
@@ -0,0 +1,127 @@
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDevice_Certificates(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		`AT+FSCREATE=C:\USER\CA.PEM`:              "\r\nOK\r\n",
+		`AT+FSWRITE=C:\USER\CA.PEM,0,11,10`:       "\r\n> ",
+		`AT+FSFLSIZE=C:\USER\CA.PEM`:              "\r\n+FSFLSIZE: 11\r\n\r\nOK\r\n",
+		`AT+FSDEL=C:\USER\CA.PEM`:                 "\r\nOK\r\n",
+		`AT+SSLSETCERT="C:\USER\CA.PEM"`:          "\r\nOK\r\n\r\n+SSLSETCERT: 0\r\n",
+		`AT+SSLSETCERT="C:\USER\CA.PEM","secret"`: "\r\nOK\r\n\r\n+SSLSETCERT: 0\r\n",
+		`AT+SSLSETCERT="C:\USER\BAD.PEM"`:         "\r\nOK\r\n\r\n+SSLSETCERT: 1\r\n",
+		`AT+FSCREATE=C:\USER\NONE.PEM`:            "\r\nERROR\r\n",
+	})
+	modem.dataReply = "\r\nOK\r\n"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Firmware = "1418B05SIM800L24"
+
+	// An existing file is deleted and written again, once per content
+	for range 2 {
+		if err := d.UploadCert("ca.pem", []byte("certificate")); err != nil {
+			t.Fatalf("upload failed: %v", err)
+		}
+	}
+	for range 2 {
+		if err := d.SelectCert("ca.pem", ""); err != nil {
+			t.Fatalf("select failed: %v", err)
+		}
+	}
+	want := `AT+FSFLSIZE=C:\USER\CA.PEM|AT+FSDEL=C:\USER\CA.PEM|AT+FSCREATE=C:\USER\CA.PEM|` +
+		`AT+FSWRITE=C:\USER\CA.PEM,0,11,10|AT+SSLSETCERT="C:\USER\CA.PEM"`
+	if got := strings.Join(modem.commands, "|"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// New content is uploaded and must be loaded again
+	if err := d.UploadCert("ca.pem", []byte("CERTIFICATE")); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if err := d.SelectCert("ca.pem", ""); err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if n := modem.commandCount(`AT+FSWRITE=C:\USER\CA.PEM,0,11,10`); n != 2 {
+		t.Errorf("expected 2 writes, got %d", n)
+	}
+	if n := modem.commandCount(`AT+SSLSETCERT="C:\USER\CA.PEM"`); n != 2 {
+		t.Errorf("expected 2 loads, got %d", n)
+	}
+
+	// A password loads it again, as does a reset
+	if err := d.SelectCert("ca.pem", "secret"); err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	d.forgetSettings()
+	if err := d.SelectCert("ca.pem", "secret"); err != nil {
+		t.Fatalf("select failed: %v", err)
+	}
+	if n := modem.commandCount(`AT+SSLSETCERT="C:\USER\CA.PEM","secret"`); n != 2 {
+		t.Errorf("expected 2 loads with the password, got %d", n)
+	}
+
+	if err := d.SelectCert("bad.pem", ""); !errors.Is(err, ErrCertRejected) {
+		t.Errorf("expected ErrCertRejected, got %v", err)
+	}
+	var atErr *ATError
+	if err := d.UploadCert("none.pem", []byte("certificate")); !errors.As(err, &atErr) {
+		t.Errorf("expected an ATError, got %v", err)
+	}
+	for _, name := range []string{"", `..\ca.pem`, "ca pem", strings.Repeat("a", MaxCertNameLen+1)} {
+		if err := d.UploadCert(name, []byte("certificate")); !errors.Is(err, ErrBadParameter) {
+			t.Errorf("%q: expected ErrBadParameter, got %v", name, err)
+		}
+	}
+	if err := d.UploadCert("big.pem", make([]byte, MaxCertSize+1)); !errors.Is(err, ErrBadParameter) {
+		t.Errorf("expected ErrBadParameter, got %v", err)
+	}
+
+	// Deleting forgets the upload, so the next one writes it again
+	if err := d.DeleteCert("ca.pem"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if err := d.UploadCert("ca.pem", []byte("CERTIFICATE")); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if n := modem.commandCount(`AT+FSWRITE=C:\USER\CA.PEM,0,11,10`); n != 3 {
+		t.Errorf("expected 3 writes, got %d", n)
+	}
+
+	// Firmware without SSL can't load certificates
+	d.Firmware = "1308B08SIM800L16"
+	if err := d.SelectCert("bad.pem", ""); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestDevice_SelectCertRedactsPassword(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		`AT+SSLSETCERT="C:\USER\CA.PEM","secret"`: "\r\n+CME ERROR: 3\r\n",
+	})
+	var logs bytes.Buffer
+	d := New(modem, nil, slog.New(slog.NewTextHandler(&logs, nil)))
+	d.Firmware = "1418B05SIM800L24"
+
+	err := d.SelectCert("ca.pem", "secret")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	recorded := []string{err.Error(), logs.String()}
+	for _, e := range d.Diagnostics().RecentErrors {
+		recorded = append(recorded, e.Command)
+	}
+	for _, s := range recorded {
+		if strings.Contains(s, "secret") {
+			t.Errorf("password recorded in %q", s)
+		}
+	}
+	if !strings.Contains(err.Error(), `"C:\USER\CA.PEM","***"`) {
+		t.Errorf("expected the redacted command in %q", err)
+	}
+}
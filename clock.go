@@ -0,0 +1,181 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains network time and host clock drift compensation.
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// clockValidYear is the first year accepted from the module's clock. Until
+// the network has set it the clock counts from its factory default in 2004.
+const clockValidYear = 2020
+
+var (
+	cmdClock    = []byte("+CCLK?")  // Read the real-time clock
+	clockStatus = []byte("+CCLK")   // Real-time clock response key
+	cmdNITZ     = []byte("+CLTS=1") // Sync the clock with network time (NITZ)
+)
+
+var ErrClockNotSet = errors.New("network time not available")
+
+// EnableNetworkTime makes the module set its clock from the network time
+// (NITZ) sent by the operator. The setting is stored in the module's
+// profile and takes effect after the next restart; not every operator
+// sends NITZ.
+func (d *Device) EnableNetworkTime() error {
+	d.lock()
+	defer d.unlock()
+
+	if err := d.send(cmdNITZ); err != nil {
+		return err
+	}
+	// Save the setting so it survives the restart it needs
+	return d.send(cmdSaveProfile)
+}
+
+// NetworkTime returns the time of the module's real-time clock. It returns
+// ErrClockNotSet while the clock hasn't been set from the network.
+func (d *Device) NetworkTime() (time.Time, error) {
+	d.lock()
+	defer d.unlock()
+
+	err := d.sendWithOptions(cmdClock, func(buffer []byte) error {
+		if bytes.HasPrefix(buffer, clockStatus) {
+			return nil
+		}
+		return defaultResponseCheck(buffer)
+	}, DefaultTimeout)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	val, ok := d.parseValue(clockStatus)
+	if !ok {
+		return time.Time{}, ErrUnexpectedResponse
+	}
+	return parseClock(val)
+}
+
+// parseClock parses a clock value like "24/06/30,15:23:54+08", where the
+// zone is given in quarter hours
+func parseClock(v []byte) (time.Time, error) {
+	v = bytes.Trim(v, "\"")
+	if len(v) != len("yy/MM/dd,hh:mm:ss+zz") {
+		return time.Time{}, ErrUnexpectedResponse
+	}
+
+	// Fields are two digits at fixed offsets, separated by single
+	// characters, except the zone which starts with its sign
+	var f [7]int
+	for i := range f {
+		start, end := i*3, i*3+2
+		if i == len(f)-1 {
+			start = i*3 - 1
+		}
+		n, err := strconv.Atoi(string(v[start:end]))
+		if err != nil {
+			return time.Time{}, ErrUnexpectedResponse
+		}
+		f[i] = n
+	}
+	year, month, day, hour, minute, second, quarters := 2000+f[0], f[1], f[2], f[3], f[4], f[5], f[6]
+	if year < clockValidYear {
+		return time.Time{}, ErrClockNotSet
+	}
+
+	zone := time.FixedZone("", quarters*15*60)
+	return time.Date(year, time.Month(month), day, hour, minute, second, 0, zone), nil
+}
+
+// ClockSync estimates how fast the host clock drifts against network time,
+// so timestamps stay accurate on boards without a precise crystal. Call
+// Update periodically, or use Run. The module's clock has a resolution of
+// one second, so the drift estimate gets better the longer the updates span.
+type ClockSync struct {
+	device *Device
+	now    func() time.Time // Host clock
+
+	mu           mutex
+	samples      int           // Number of successful updates
+	anchorHost   time.Time     // Host time of the first update
+	anchorOffset time.Duration // Network minus host time at the first update
+	drift        float64       // Host clock error, in seconds per second
+}
+
+// NewClockSync returns a ClockSync measuring against the module's network time
+func NewClockSync(d *Device) *ClockSync {
+	return &ClockSync{device: d, now: time.Now}
+}
+
+// Update reads the network time once and refines the drift estimate
+func (c *ClockSync) Update() error {
+	network, err := c.device.NetworkTime()
+	if err != nil {
+		return err
+	}
+	host := c.now()
+	offset := network.Sub(host)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.samples == 0 {
+		c.anchorHost = host
+		c.anchorOffset = offset
+	} else if elapsed := host.Sub(c.anchorHost); elapsed > 0 {
+		c.drift = float64(offset-c.anchorOffset) / float64(elapsed)
+	}
+	c.samples++
+	return nil
+}
+
+// Run calls Update every interval until ctx is done. Failed updates are
+// logged and retried at the next interval.
+func (c *ClockSync) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Update(); err != nil {
+			c.device.log(SubsystemCommand, slog.LevelWarn, "clock sync failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Now returns the current time corrected by the measured offset and drift.
+// Before the first successful update it returns the host time.
+func (c *ClockSync) Now() time.Time {
+	host := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.samples == 0 {
+		return host
+	}
+	elapsed := host.Sub(c.anchorHost)
+	return host.Add(c.anchorOffset + time.Duration(c.drift*float64(elapsed)))
+}
+
+// Drift returns the measured drift of the host clock in parts per million.
+// It is positive when the host clock runs slow and zero until two updates
+// have been made.
+func (c *ClockSync) Drift() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.drift * 1e6
+}
+
+// Synced reports whether at least one update succeeded
+func (c *ClockSync) Synced() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.samples > 0
+}
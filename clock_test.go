@@ -0,0 +1,85 @@
+package sim800l
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+)
+
+func Test_parseClock(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Time
+		err      error
+	}{
+		{
+			name:     "UTC plus two hours",
+			input:    `"24/06/30,15:23:54+08"`,
+			expected: time.Date(2024, 6, 30, 13, 23, 54, 0, time.UTC),
+		},
+		{
+			name:     "Negative zone",
+			input:    `"25/01/02,03:04:05-20"`,
+			expected: time.Date(2025, 1, 2, 8, 4, 5, 0, time.UTC),
+		},
+		{
+			name:  "Factory default",
+			input: `"04/01/01,00:00:12+00"`,
+			err:   ErrClockNotSet,
+		},
+		{
+			name:  "Malformed",
+			input: `"24/06/30 15:23"`,
+			err:   ErrUnexpectedResponse,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseClock([]byte(tc.input))
+			if err != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if err == nil && !got.Equal(tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestClockSync_Drift(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CCLK?": "\r\n+CCLK: \"24/06/30,12:00:00+00\"\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	cs := NewClockSync(d)
+
+	host := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	cs.now = func() time.Time { return host }
+
+	if cs.Synced() {
+		t.Fatal("expected clock sync without updates to be unsynced")
+	}
+	if err := cs.Update(); err != nil {
+		t.Fatalf("first update failed: %v", err)
+	}
+
+	// After 1000 host seconds the network is one second further ahead
+	host = host.Add(1000 * time.Second)
+	modem.responses["AT+CCLK?"] = "\r\n+CCLK: \"24/06/30,12:16:41+00\"\r\n\r\nOK\r\n"
+	if err := cs.Update(); err != nil {
+		t.Fatalf("second update failed: %v", err)
+	}
+
+	if drift := cs.Drift(); math.Abs(drift-1000) > 1e-6 {
+		t.Errorf("expected drift of 1000 ppm, got %v", drift)
+	}
+
+	host = host.Add(1000 * time.Second)
+	expected := time.Date(2024, 6, 30, 12, 33, 22, 0, time.UTC)
+	if got := cs.Now(); !got.Equal(expected) {
+		t.Errorf("expected corrected time %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,216 @@
+//go:build !tinygo
+
+// Command sim800lctl drives a SIM800L attached to a serial port of the
+// host with the sim800l package, to debug modules in the field and to run
+// the package against real hardware.
+//
+// Usage:
+//
+//	sim800lctl [flags] <command> [arguments]
+//
+// The commands are:
+//
+//	info                    initialize the module and show its status
+//	diag                    initialize the module and show diagnostics
+//	connect                 set up the GPRS session and show the IP address
+//	dial <host:port> [data] connect, send data and print the reply
+//	sms <number> <text>     send an SMS
+//	at <command>            send an AT command and print its response
+//
+// The exit status is 1 if the command failed.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/m-s-sh/sim800l"
+)
+
+var (
+	port     = flag.String("port", "/dev/ttyUSB0", "serial `device` of the module")
+	baud     = flag.Uint("baud", 115200, "baud `rate` of the serial port")
+	apn      = flag.String("apn", "internet", "`APN` of the GPRS session")
+	user     = flag.String("user", "", "user `name` for the APN")
+	password = flag.String("password", "", "`password` for the APN")
+	wait     = flag.Duration("wait", 10*time.Second, "how long dial waits for the reply")
+	verbose  = flag.Bool("v", false, "log the driver's debug output to stderr")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `usage: sim800lctl [flags] <command> [arguments]
+
+commands:
+  info                     initialize the module and show its status
+  diag                     initialize the module and show diagnostics
+  connect                  set up the GPRS session and show the IP address
+  dial <host:port> [data]  connect, send data and print the reply
+  sms <number> <text>      send an SMS
+  at <command>             send an AT command and print its response
+
+flags:
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "sim800lctl:", err)
+		os.Exit(1)
+	}
+}
+
+// run opens the serial port and runs the command
+func run(cmd string, args []string) error {
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	uart, err := openSerial(*port, uint32(*baud))
+	if err != nil {
+		return err
+	}
+	defer uart.Close()
+
+	d := sim800l.New(uart, nil, logger)
+	d.Configure(sim800l.Config{BaudRate: uint32(*baud)})
+
+	switch cmd {
+	case "info":
+		return info(d)
+	case "diag":
+		return diag(d)
+	case "connect":
+		return connect(d)
+	case "dial":
+		if len(args) < 1 {
+			return errors.New("usage: dial <host:port> [data]")
+		}
+		return dial(d, args[0], strings.Join(args[1:], " "))
+	case "sms":
+		if len(args) < 2 {
+			return errors.New("usage: sms <number> <text>")
+		}
+		return sms(d, args[0], strings.Join(args[1:], " "))
+	case "at":
+		if len(args) < 1 {
+			return errors.New("usage: at <command>")
+		}
+		return at(d, strings.Join(args, " "))
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+// initialize initializes the module and prints its status
+func initialize(d *sim800l.Device) error {
+	status, err := d.InitContext(context.Background())
+	fmt.Printf("responding:  %v\nconfigured:  %v\nSIM ready:   %v\nregistered:  %v\nroaming:     %v\n",
+		status.Responding, status.Configured, status.SIMReady, status.Registered, status.Roaming)
+	return err
+}
+
+func info(d *sim800l.Device) error {
+	if err := initialize(d); err != nil {
+		return err
+	}
+	fmt.Printf("IMEI:        %s\n", d.IMEI)
+	fmt.Printf("model:       %s\n", d.Model)
+	fmt.Printf("firmware:    %s\n", d.Firmware)
+	fmt.Printf("variant:     %s\n", d.Capabilities().Variant)
+	if imsi, err := d.IMSI(); err == nil {
+		fmt.Printf("IMSI:        %s\n", imsi)
+	}
+	fmt.Printf("operator:    %s\n", d.OperatorName())
+	fmt.Printf("signal:      %d\n", d.Signal())
+	return nil
+}
+
+func diag(d *sim800l.Device) error {
+	err := initialize(d)
+	diag := d.Diagnostics()
+	fmt.Printf("errors:      %d\ntruncated:   %d\n", diag.TotalErrors, diag.TruncatedLines)
+	for _, e := range diag.RecentErrors {
+		fmt.Printf("  %s  %s  %s\n", e.Time.Format(time.TimeOnly), e.Command, e.Response)
+	}
+	return err
+}
+
+// gprs initializes the module and sets up the GPRS session
+func gprs(d *sim800l.Device) error {
+	if err := d.Init(); err != nil {
+		return err
+	}
+	if err := d.WaitForNetwork(time.Minute); err != nil {
+		return err
+	}
+	return d.ConnectWithConfig(sim800l.GPRSConfig{APN: *apn, User: *user, Password: *password})
+}
+
+func connect(d *sim800l.Device) error {
+	if err := gprs(d); err != nil {
+		return err
+	}
+	fmt.Printf("IP:          %s\n", d.IP)
+	return nil
+}
+
+func dial(d *sim800l.Device, address, data string) error {
+	if err := gprs(d); err != nil {
+		return err
+	}
+	defer d.Disconnect()
+
+	conn, err := d.Dial("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if data != "" {
+		// Allow line endings in the data, e.g. for HTTP requests
+		data = strings.NewReplacer(`\r`, "\r", `\n`, "\n").Replace(data)
+		if _, err := io.WriteString(conn, data); err != nil {
+			return err
+		}
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(*wait))
+	_, err = io.Copy(os.Stdout, conn)
+	if errors.Is(err, sim800l.ErrDeadlineExceeded) || errors.Is(err, sim800l.ErrWouldBlock) {
+		return nil
+	}
+	return err
+}
+
+func sms(d *sim800l.Device, number, text string) error {
+	if err := d.Init(); err != nil {
+		return err
+	}
+	if err := d.WaitForNetwork(time.Minute); err != nil {
+		return err
+	}
+	return d.SendSMS(number, text)
+}
+
+func at(d *sim800l.Device, cmd string) error {
+	tokens, err := d.Command(cmd, sim800l.DefaultTimeout)
+	for _, tok := range tokens {
+		fmt.Printf("%s\n", tok.Data)
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+//go:build linux && !tinygo
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// cbaud masks the baud rate bits of the control flags
+const cbaud = 0o10017
+
+// baudRates maps the supported baud rates to their termios speeds
+var baudRates = map[uint32]uint32{
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+}
+
+// serialPort is a serial device in raw mode. It implements the driver's
+// UART and BaudRateSetter interfaces. Reads don't block, like a TinyGo
+// UART: they return what has arrived, possibly nothing.
+type serialPort struct {
+	fd int
+}
+
+// openSerial opens the serial device at path with the given baud rate,
+// 8 data bits, no parity and one stop bit
+func openSerial(path string, baud uint32) (*serialPort, error) {
+	fd, err := syscall.Open(path, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	p := &serialPort{fd: fd}
+	if err := p.configure(baud); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("failed to configure %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// configure puts the port in raw mode at baud
+func (p *serialPort) configure(baud uint32) error {
+	speed, ok := baudRates[baud]
+	if !ok {
+		return fmt.Errorf("unsupported baud rate %d", baud)
+	}
+	var t syscall.Termios
+	if err := p.ioctl(syscall.TCGETS, unsafe.Pointer(&t)); err != nil {
+		return err
+	}
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON | syscall.IXOFF
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB | syscall.CSTOPB | cbaud
+	t.Cflag |= syscall.CS8 | syscall.CREAD | syscall.CLOCAL | speed
+	t.Ispeed, t.Ospeed = speed, speed
+	// Return at once, with whatever has arrived
+	t.Cc[syscall.VMIN], t.Cc[syscall.VTIME] = 0, 0
+	return p.ioctl(syscall.TCSETS, unsafe.Pointer(&t))
+}
+
+// ioctl runs an ioctl request on the port
+func (p *serialPort) ioctl(req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(p.fd), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Read reads the bytes that have arrived, without waiting
+func (p *serialPort) Read(b []byte) (int, error) {
+	n, err := syscall.Read(p.fd, b)
+	if err == syscall.EAGAIN || err == syscall.EINTR {
+		return 0, nil
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n, err
+}
+
+// Write writes b to the port
+func (p *serialPort) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		n, err := syscall.Write(p.fd, b[written:])
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// Buffered returns the number of bytes waiting to be read
+func (p *serialPort) Buffered() int {
+	var n int32
+	if err := p.ioctl(syscall.TIOCINQ, unsafe.Pointer(&n)); err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// SetBaudRate changes the baud rate, so the driver can recover a module
+// running at another rate
+func (p *serialPort) SetBaudRate(br uint32) {
+	_ = p.configure(br)
+}
+
+// Close closes the port
+func (p *serialPort) Close() error {
+	return syscall.Close(p.fd)
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !tinygo
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// serialPort is only implemented on Linux
+type serialPort struct {
+	io.ReadWriteCloser
+}
+
+func (p *serialPort) Buffered() int { return 0 }
+
+// openSerial reports that serial ports aren't supported on this system
+func openSerial(path string, baud uint32) (*serialPort, error) {
+	return nil, errors.New("serial ports are only supported on Linux")
+}
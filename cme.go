@@ -0,0 +1,123 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the typed errors of +CME ERROR reports and the
+// lookup shared with +CMS ERROR reports.
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	ErrSIMNotInserted = errors.New("SIM not inserted")
+	ErrSIMPINRequired = errors.New("SIM PIN required")
+	ErrSIMPUKRequired = errors.New("SIM PUK required")
+	ErrSIMBusy        = errors.New("SIM busy")
+	ErrIncorrectPIN   = errors.New("incorrect SIM PIN")
+	ErrNoNetwork      = errors.New("no network service")
+)
+
+// errorMeaning is a CME or CMS error code with the text the module
+// reports for it in verbose mode and the sentinel error it matches, if any
+type errorMeaning struct {
+	code int
+	text string
+	err  error
+}
+
+// cmeMeanings lists the common CME error codes, 3GPP TS 27.007 9.2
+var cmeMeanings = [...]errorMeaning{
+	{3, "operation not allowed", nil},
+	{4, "operation not supported", nil},
+	{10, "SIM not inserted", ErrSIMNotInserted},
+	{11, "SIM PIN required", ErrSIMPINRequired},
+	{12, "SIM PUK required", ErrSIMPUKRequired},
+	{13, "SIM failure", nil},
+	{14, "SIM busy", ErrSIMBusy},
+	{15, "SIM wrong", nil},
+	{16, "incorrect password", ErrIncorrectPIN},
+	{20, "memory full", nil},
+	{30, "no network service", ErrNoNetwork},
+	{31, "network timeout", nil},
+	{32, "network not allowed - emergency calls only", nil},
+	{100, "unknown", nil},
+}
+
+// CMEError is an equipment error reported by the module as
+// "+CME ERROR: <code>", or as "+CME ERROR: <text>" in verbose mode. It is
+// the cause of the *ATError of the failed command, so errors.As finds it
+// and errors.Is matches it against ErrSIMNotInserted, ErrSIMPINRequired,
+// ErrSIMPUKRequired, ErrSIMBusy, ErrIncorrectPIN and ErrNoNetwork.
+type CMEError struct {
+	Code    int    // Error code, -1 if the module reported a text the driver doesn't know
+	Message string // Meaning of the code, or the text the module reported
+}
+
+// Error returns the error message, implementing the error interface
+func (e *CMEError) Error() string {
+	if e.Code < 0 {
+		return "+CME ERROR: " + e.Message
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("+CME ERROR: %d", e.Code)
+	}
+	return fmt.Sprintf("+CME ERROR: %d (%s)", e.Code, e.Message)
+}
+
+// Is reports whether the error's code is the one target stands for
+func (e *CMEError) Is(target error) bool {
+	return codeMatches(cmeMeanings[:], e.Code, target)
+}
+
+// parseCMEError returns the *CMEError of a "+CME ERROR" line, or nil if
+// line is another response
+func parseCMEError(line []byte) error {
+	v, ok := bytes.CutPrefix(line, cmeErrorPrefix)
+	if !ok {
+		return nil
+	}
+	code, msg := parseErrorCode(v, cmeMeanings[:])
+	return &CMEError{Code: code, Message: msg}
+}
+
+// moduleError returns the typed error of a "+CME ERROR" or "+CMS ERROR"
+// line, or nil if line is another response
+func moduleError(line []byte) error {
+	if err := parseCMEError(line); err != nil {
+		return err
+	}
+	return parseCMSError(line)
+}
+
+// parseErrorCode parses the value of an error report, a code or the text
+// of one, and returns the code and its meaning. The code is -1 if v is a
+// text not in meanings.
+func parseErrorCode(v []byte, meanings []errorMeaning) (int, string) {
+	v = bytes.TrimSpace(bytes.TrimPrefix(v, []byte(":")))
+	if code, err := strconv.Atoi(string(v)); err == nil {
+		for _, m := range meanings {
+			if m.code == code {
+				return code, m.text
+			}
+		}
+		return code, ""
+	}
+	for _, m := range meanings {
+		if bytes.EqualFold(v, []byte(m.text)) {
+			return m.code, m.text
+		}
+	}
+	return -1, string(v)
+}
+
+// codeMatches reports whether target is the sentinel error of code
+func codeMatches(meanings []errorMeaning, code int, target error) bool {
+	for _, m := range meanings {
+		if m.code == code {
+			return m.err != nil && m.err == target
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_CMEError(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CPIN?": "\r\n+CME ERROR: SIM not inserted\r\n",
+		"AT+COPS?": "\r\n+CME ERROR: 30\r\n",
+		"AT+CSQ":   "\r\n+CME ERROR: 772\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	_, err := d.QueryString("+CPIN?")
+	var cmeErr *CMEError
+	if !errors.Is(err, ErrSIMNotInserted) || !errors.As(err, &cmeErr) || cmeErr.Code != 10 {
+		t.Errorf("expected code 10 matching ErrSIMNotInserted, got %v", err)
+	}
+	var atErr *ATError
+	if !errors.As(err, &atErr) {
+		t.Errorf("expected the CME error to be an ATError too, got %v", err)
+	}
+
+	_, err = d.QueryString("+COPS?")
+	if !errors.Is(err, ErrNoNetwork) || errors.Is(err, ErrSIMNotInserted) {
+		t.Errorf("expected ErrNoNetwork only, got %v", err)
+	}
+
+	// Codes without a meaning keep the number
+	_, err = d.QueryInt("+CSQ")
+	if !errors.As(err, &cmeErr) || cmeErr.Code != 772 || cmeErr.Error() != "+CME ERROR: 772" {
+		t.Errorf("expected code 772, got %v", err)
+	}
+}
+
+func TestDevice_CMSError(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CMGR=3": "\r\n+CMS ERROR: 321\r\n",
+		"AT+CMGD=3": "\r\n+CMS ERROR: no network service\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	_, err := d.ReadSMS(3)
+	var cmsErr *CMSError
+	if !errors.Is(err, ErrNoSMS) || !errors.As(err, &cmsErr) || cmsErr.Message != "invalid memory index" {
+		t.Errorf("expected an invalid memory index matching ErrNoSMS, got %v", err)
+	}
+
+	err = d.DeleteSMS(3)
+	if !errors.Is(err, ErrNoNetwork) || errors.Is(err, ErrInvalidPDU) || !errors.As(err, &cmsErr) || cmsErr.Code != 331 {
+		t.Errorf("expected code 331 matching ErrNoNetwork, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the typed errors of +CMS ERROR reports.
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidPDU = errors.New("invalid PDU mode parameter")
+
+// cmsMeanings lists the common CMS error codes, 3GPP TS 27.005 3.2.5
+var cmsMeanings = [...]errorMeaning{
+	{302, "operation not allowed", nil},
+	{303, "operation not supported", nil},
+	{304, "invalid PDU mode parameter", ErrInvalidPDU},
+	{305, "invalid text mode parameter", nil},
+	{310, "SIM not inserted", ErrSIMNotInserted},
+	{311, "SIM PIN required", ErrSIMPINRequired},
+	{313, "SIM failure", nil},
+	{314, "SIM busy", ErrSIMBusy},
+	{316, "SIM PUK required", ErrSIMPUKRequired},
+	{320, "memory failure", nil},
+	{321, "invalid memory index", ErrNoSMS},
+	{322, "memory full", nil},
+	{330, "SMSC address unknown", nil},
+	{331, "no network service", ErrNoNetwork},
+	{332, "network timeout", nil},
+	{500, "unknown error", nil},
+}
+
+// CMSError is a message service error reported by the module as
+// "+CMS ERROR: <code>", or with the text of the code in verbose mode. Like
+// a CMEError it is the cause of the *ATError of the failed command;
+// errors.Is matches it against ErrNoNetwork, ErrInvalidPDU, ErrNoSMS for
+// an empty storage index, and the SIM errors.
+type CMSError struct {
+	Code    int    // Error code, -1 if the module reported a text the driver doesn't know
+	Message string // Meaning of the code, or the text the module reported
+}
+
+// Error returns the error message, implementing the error interface
+func (e *CMSError) Error() string {
+	if e.Code < 0 {
+		return "+CMS ERROR: " + e.Message
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("+CMS ERROR: %d", e.Code)
+	}
+	return fmt.Sprintf("+CMS ERROR: %d (%s)", e.Code, e.Message)
+}
+
+// Is reports whether the error's code is the one target stands for
+func (e *CMSError) Is(target error) bool {
+	return codeMatches(cmsMeanings[:], e.Code, target)
+}
+
+// parseCMSError returns the *CMSError of a "+CMS ERROR" line, or nil if
+// line is another response
+func parseCMSError(line []byte) error {
+	v, ok := bytes.CutPrefix(line, cmsErrorPrefix)
+	if !ok {
+		return nil
+	}
+	code, msg := parseErrorCode(v, cmsMeanings[:])
+	return &CMSError{Code: code, Message: msg}
+}
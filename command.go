@@ -0,0 +1,131 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the raw AT command interface.
+package sim800l
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// MaxCommandTokens is the number of response lines Command collects
+const MaxCommandTokens = 32
+
+// escChar cancels the data input of a command waiting at the "> " prompt
+const escChar = byte(0x1B)
+
+// finalResults are the lines that end the response of a command
+var finalResults = [...][]byte{
+	okToken,
+	errorToken,
+	cmeErrorPrefix,
+	cmsErrorPrefix,
+	[]byte("NO CARRIER"),
+	[]byte("NO DIALTONE"),
+	[]byte("NO ANSWER"),
+	[]byte("BUSY"),
+	[]byte("SHUT OK"),
+}
+
+// Command sends an AT command the driver doesn't wrap, e.g. "AT+CBC" or
+// "+CCLK?", and returns the lines of its response, up to and including
+// the final result code like OK, as tokens that stay valid after the
+// call. The echo of the command, received data and URCs aren't part of
+// the response; they are dispatched or queued like during any command.
+//
+// An ERROR, +CME ERROR or +CMS ERROR result returns the tokens with an
+// *ATError. If no final result code arrives within timeout, as for
+// commands like AT+CIFSR that answer without one, the lines received so
+// far are returned with an error matching ErrTimeout. Commands that
+// prompt for data, like AT+CMGS, are cancelled and return the prompt
+// token with ErrNotSupported.
+func (d *Device) Command(cmd string, timeout time.Duration) ([]Token, error) {
+	if len(cmd) > MaxCommandSize {
+		return nil, fmt.Errorf("%w: command too long", ErrBadParameter)
+	}
+
+	d.lock()
+	defer d.unlock()
+
+	// sendRaw upper-cases the command in place, so copy it
+	var buf [MaxCommandSize]byte
+	c := append(buf[:0], cmd...)
+	if err := d.sendRaw(c); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	var tokens []Token
+	for {
+		t, err := d.readLine(time.Until(deadline))
+		if err != nil {
+			return tokens, d.commandError(err)
+		}
+		if t == TokenPrompt {
+			// The module waits for data the caller can't send
+			if _, err := d.uart.Write([]byte{escChar}); err != nil {
+				d.log(SubsystemCommand, slog.LevelWarn, "failed to cancel data input", "error", err)
+			}
+			tokens = append(tokens, Token{Type: TokenPrompt})
+			return tokens, fmt.Errorf("%w: data input", ErrNotSupported)
+		}
+		if t != TokenLine {
+			continue
+		}
+
+		line := d.rxBuffer[:d.end]
+		if bytes.HasPrefix(line, at) {
+			continue // Echo of the command
+		}
+		handled, err := d.dispatchInput(line)
+		if err != nil {
+			return tokens, err
+		}
+		if handled || d.queueURC(c, line) {
+			continue
+		}
+
+		if len(tokens) == MaxCommandTokens {
+			return tokens, fmt.Errorf("%w: more than %d response lines", ErrBufferFull, MaxCommandTokens)
+		}
+		tokens = append(tokens, Token{Type: TokenLine, Data: bytes.Clone(line), Truncated: d.truncated})
+		if isFinalResult(line) {
+			d.recordModuleError(line)
+			if bytes.Contains(line, errorToken) {
+				return tokens, d.commandError(defaultResponseCheck(line))
+			}
+			return tokens, nil
+		}
+	}
+}
+
+// CommandResult is the outcome of a command sent with CommandAsync
+type CommandResult struct {
+	Tokens []Token // Response lines, as returned by Command
+	Err    error   // Failure, as returned by Command
+}
+
+// CommandAsync sends cmd like Command from a new goroutine and returns a
+// channel that delivers the result once, so the caller can do other work
+// while a slow command like AT+COPS=? runs. Commands sent from several
+// goroutines are still sent one at a time. The channel is buffered, so
+// the result may be collected late or not at all.
+func (d *Device) CommandAsync(cmd string, timeout time.Duration) <-chan CommandResult {
+	result := make(chan CommandResult, 1)
+	go func() {
+		tokens, err := d.Command(cmd, timeout)
+		result <- CommandResult{Tokens: tokens, Err: err}
+	}()
+	return result
+}
+
+// isFinalResult reports whether line ends the response of a command
+func isFinalResult(line []byte) bool {
+	for _, result := range finalResults {
+		if bytes.HasPrefix(line, result) {
+			return true
+		}
+	}
+	return false
+}
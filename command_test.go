@@ -0,0 +1,73 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDevice_Command(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CBC":              "AT+CBC\r\r\n+CBC: 0,85,4100\r\n\r\nRING\r\n\r\nOK\r\n",
+		"AT+CSCB?":            "\r\n+CME ERROR: operation not allowed\r\n",
+		"AT+CIFSR":            "\r\n10.0.0.1\r\n",
+		"AT+CMGS=\"+155501\"": "\r\n> ",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	tokens, err := d.Command("AT+CBC", time.Second)
+	if err != nil {
+		t.Fatalf("command failed: %v", err)
+	}
+	var lines []string
+	for _, tok := range tokens {
+		lines = append(lines, string(tok.Data))
+	}
+	if got := strings.Join(lines, "|"); got != "+CBC: 0,85,4100|OK" {
+		t.Errorf("expected the response without echo and URC, got %q", got)
+	}
+
+	tokens, err = d.Command("+CSCB?", time.Second)
+	var atErr *ATError
+	if !errors.As(err, &atErr) || len(tokens) != 1 {
+		t.Errorf("expected an ATError with the error line, got %+v, %v", tokens, err)
+	}
+
+	// Without a final result code the lines received are returned
+	tokens, err = d.Command("AT+CIFSR", 100*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) || len(tokens) != 1 || string(tokens[0].Data) != "10.0.0.1" {
+		t.Errorf("expected the address with ErrTimeout, got %+v, %v", tokens, err)
+	}
+
+	// Data input is cancelled
+	tokens, err = d.Command(`AT+CMGS="+155501"`, time.Second)
+	if !errors.Is(err, ErrNotSupported) || len(tokens) != 1 || tokens[0].Type != TokenPrompt {
+		t.Errorf("expected the prompt with ErrNotSupported, got %+v, %v", tokens, err)
+	}
+	if tx := modem.tx.String(); !strings.HasSuffix(tx, "\x1b") {
+		t.Errorf("expected ESC to cancel the input, got %q", tx)
+	}
+}
+
+func TestDevice_CommandAsync(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CBC": "\r\n+CBC: 0,85,4100\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	pending := d.CommandAsync("AT+CBC", time.Second)
+	failing := d.CommandAsync("AT+CSCB?", time.Second)
+	select {
+	case r := <-pending:
+		if r.Err != nil || len(r.Tokens) != 2 || string(r.Tokens[0].Data) != "+CBC: 0,85,4100" {
+			t.Errorf("expected the battery status, got %+v, %v", r.Tokens, r.Err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no result delivered")
+	}
+	if r := <-failing; r.Err == nil {
+		t.Errorf("expected an error, got %+v", r.Tokens)
+	}
+}
@@ -0,0 +1,243 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains allocation-free payload compression for uploads.
+package sim800l
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// CompressBlockSize is the most data a CompressWriter compresses at once
+const CompressBlockSize = 512
+
+// Stream framing of a CompressWriter. The stream starts with the magic
+// "SZ" followed by the codec ID. Each block is a 2-byte big-endian length
+// followed by that many bytes; blocks with the high bit of the length set
+// are stored uncompressed. A zero length ends the stream.
+const (
+	compressMagic0 = 'S'
+	compressMagic1 = 'Z'
+	storedBlock    = 0x8000
+)
+
+// CodecLZ4 is the codec ID of LZ4Block
+const CodecLZ4 = 1
+
+// BlockCompressor compresses one block of at most CompressBlockSize bytes.
+// CompressBlock returns the size of the compressed data written to dst, or
+// 0 if it doesn't fit; the block is then sent uncompressed.
+type BlockCompressor interface {
+	ID() byte
+	CompressBlock(dst, src []byte) int
+}
+
+// CompressWriter compresses data written to it and writes it to an
+// underlying writer, such as a Connection, in framed blocks. It doesn't
+// allocate, so telemetry can be compressed on constrained targets where
+// 2G data is slow and billed per kilobyte. A server tells compressed
+// uploads from plain ones by the magic "SZ" and codec ID they start with.
+//
+//	var cw sim800l.CompressWriter
+//	cw.Reset(conn, nil)
+//	cw.Write(payload)
+//	cw.Close()
+type CompressWriter struct {
+	w       io.Writer
+	codec   BlockCompressor
+	started bool                        // The stream header was written
+	in      [CompressBlockSize]byte     // Data waiting to be compressed
+	n       int                         // Bytes in in
+	out     [2 + CompressBlockSize]byte // Framed block
+	lz4     LZ4Block                    // Default codec
+}
+
+// Reset discards buffered data and starts a new stream to w. A nil codec
+// selects LZ4Block.
+func (c *CompressWriter) Reset(w io.Writer, codec BlockCompressor) {
+	c.w = w
+	c.codec = codec
+	if codec == nil {
+		c.codec = &c.lz4
+	}
+	c.started = false
+	c.n = 0
+}
+
+// Write buffers p and writes every block that is full
+func (c *CompressWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(c.in[c.n:], p)
+		c.n += n
+		p = p[n:]
+		written += n
+		if c.n == len(c.in) {
+			if err := c.Flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Flush compresses the buffered data into a block and writes it
+func (c *CompressWriter) Flush() error {
+	if err := c.header(); err != nil {
+		return err
+	}
+	if c.n == 0 {
+		return nil
+	}
+
+	src := c.in[:c.n]
+	size := c.codec.CompressBlock(c.out[2:2+c.n-1], src)
+	length := uint16(size)
+	if size == 0 {
+		// Compression didn't help, send the data as it is
+		size = copy(c.out[2:], src)
+		length = uint16(size) | storedBlock
+	}
+	binary.BigEndian.PutUint16(c.out[:2], length)
+	c.n = 0
+	_, err := c.w.Write(c.out[:2+size])
+	return err
+}
+
+// Close flushes the buffered data and ends the stream. It doesn't close
+// the underlying writer.
+func (c *CompressWriter) Close() error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	c.out[0], c.out[1] = 0, 0
+	_, err := c.w.Write(c.out[:2])
+	return err
+}
+
+// header writes the stream header before the first block
+func (c *CompressWriter) header() error {
+	if c.started {
+		return nil
+	}
+	if c.codec == nil {
+		c.codec = &c.lz4
+	}
+	c.started = true
+	c.out[0], c.out[1], c.out[2] = compressMagic0, compressMagic1, c.codec.ID()
+	_, err := c.w.Write(c.out[:3])
+	return err
+}
+
+// LZ4 block format limits
+const (
+	lz4MinMatch   = 4  // Shortest match
+	lz4LastLits   = 5  // The last bytes of a block are always literals
+	lz4MatchLimit = 12 // No match starts in the last bytes of a block
+	lz4HashLog    = 8  // Size of the match finder's hash table
+)
+
+// LZ4Block compresses blocks in the LZ4 block format, so any LZ4 block
+// decoder can read them. It uses a small hash table and a greedy match
+// finder, trading ratio for RAM.
+type LZ4Block struct {
+	table [1 << lz4HashLog]uint16 // Positions + 1 of recent 4-byte sequences
+}
+
+// ID returns CodecLZ4
+func (z *LZ4Block) ID() byte {
+	return CodecLZ4
+}
+
+// CompressBlock compresses src into dst and returns the compressed size,
+// or 0 if it doesn't fit in dst
+func (z *LZ4Block) CompressBlock(dst, src []byte) int {
+	for i := range z.table {
+		z.table[i] = 0
+	}
+
+	out, anchor, i := 0, 0, 0
+	limit := len(src) - lz4MatchLimit
+	for i < limit {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := (seq * 2654435761) >> (32 - lz4HashLog)
+		ref := int(z.table[h]) - 1
+		z.table[h] = uint16(i + 1)
+		if ref < 0 || binary.LittleEndian.Uint32(src[ref:]) != seq {
+			i++
+			continue
+		}
+
+		// Extend the match, leaving the last literals alone
+		m := lz4MinMatch
+		for i+m < len(src)-lz4LastLits && src[ref+m] == src[i+m] {
+			m++
+		}
+		out = lz4Sequence(dst, out, src[anchor:i], i-ref, m)
+		if out < 0 {
+			return 0
+		}
+		i += m
+		anchor = i
+	}
+	out = lz4Sequence(dst, out, src[anchor:], 0, 0)
+	if out < 0 {
+		return 0
+	}
+	return out
+}
+
+// lz4Sequence appends one sequence to dst at out and returns the new end,
+// or -1 if it doesn't fit. A zero match length writes the final literals.
+func lz4Sequence(dst []byte, out int, literals []byte, offset, match int) int {
+	token := out
+	if out >= len(dst) {
+		return -1
+	}
+	out++
+
+	lits := len(literals)
+	if lits >= 15 {
+		dst[token] = 15 << 4
+		if out = lz4Length(dst, out, lits-15); out < 0 {
+			return -1
+		}
+	} else {
+		dst[token] = byte(lits << 4)
+	}
+	if out+lits > len(dst) {
+		return -1
+	}
+	out += copy(dst[out:], literals)
+	if match == 0 {
+		return out
+	}
+
+	if out+2 > len(dst) {
+		return -1
+	}
+	binary.LittleEndian.PutUint16(dst[out:], uint16(offset))
+	out += 2
+	match -= lz4MinMatch
+	if match >= 15 {
+		dst[token] |= 15
+		return lz4Length(dst, out, match-15)
+	}
+	dst[token] |= byte(match)
+	return out
+}
+
+// lz4Length appends the extra bytes of a long literal or match length
+func lz4Length(dst []byte, out, n int) int {
+	for {
+		if out >= len(dst) {
+			return -1
+		}
+		if n < 255 {
+			dst[out] = byte(n)
+			return out + 1
+		}
+		dst[out] = 255
+		out++
+		n -= 255
+	}
+}
@@ -0,0 +1,152 @@
+package sim800l
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// decodeLZ4Block is a reference decoder for the LZ4 block format
+func decodeLZ4Block(src []byte) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+		length := func(n int) (int, error) {
+			if n != 15 {
+				return n, nil
+			}
+			for {
+				if i >= len(src) {
+					return 0, fmt.Errorf("truncated length")
+				}
+				b := src[i]
+				i++
+				n += int(b)
+				if b != 255 {
+					return n, nil
+				}
+			}
+		}
+		lits, err := length(int(token >> 4))
+		if err != nil {
+			return nil, err
+		}
+		if i+lits > len(src) {
+			return nil, fmt.Errorf("truncated literals")
+		}
+		out = append(out, src[i:i+lits]...)
+		i += lits
+		if i == len(src) {
+			break
+		}
+		if i+2 > len(src) {
+			return nil, fmt.Errorf("truncated offset")
+		}
+		offset := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		match, err := length(int(token & 15))
+		if err != nil {
+			return nil, err
+		}
+		if offset == 0 || offset > len(out) {
+			return nil, fmt.Errorf("invalid offset %d", offset)
+		}
+		for start, n := len(out)-offset, match+4; n > 0; n-- {
+			out = append(out, out[start])
+			start++
+		}
+	}
+	return out, nil
+}
+
+// decodeStream reads a CompressWriter stream with the reference decoder
+func decodeStream(t *testing.T, stream []byte) []byte {
+	t.Helper()
+	if len(stream) < 3 || string(stream[:2]) != "SZ" || stream[2] != CodecLZ4 {
+		t.Fatalf("invalid stream header % x", stream[:min(3, len(stream))])
+	}
+	var out []byte
+	for i := 3; ; {
+		length := binary.BigEndian.Uint16(stream[i:])
+		i += 2
+		if length == 0 {
+			if i != len(stream) {
+				t.Fatalf("%d bytes after the end of the stream", len(stream)-i)
+			}
+			return out
+		}
+		size := int(length &^ storedBlock)
+		block := stream[i : i+size]
+		i += size
+		if length&storedBlock != 0 {
+			out = append(out, block...)
+			continue
+		}
+		data, err := decodeLZ4Block(block)
+		if err != nil {
+			t.Fatalf("failed to decode block: %v", err)
+		}
+		out = append(out, data...)
+	}
+}
+
+func TestCompressWriter(t *testing.T) {
+	random := make([]byte, 700)
+	rand.New(rand.NewSource(1)).Read(random)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"telemetry", bytes.Repeat([]byte(`{"id":"pico-1","temp":21.5,"hum":40}`), 40)},
+		{"zeros", make([]byte, 2000)},
+		{"random", random},
+		{"short", []byte("hi")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stream bytes.Buffer
+			var cw CompressWriter
+			cw.Reset(&stream, nil)
+			// Write in odd sized pieces to cross block boundaries
+			for data := tt.data; len(data) > 0; {
+				n := min(len(data), 97)
+				if _, err := cw.Write(data[:n]); err != nil {
+					t.Fatalf("write failed: %v", err)
+				}
+				data = data[n:]
+			}
+			if err := cw.Close(); err != nil {
+				t.Fatalf("close failed: %v", err)
+			}
+
+			if got := decodeStream(t, stream.Bytes()); !bytes.Equal(got, tt.data) {
+				t.Fatalf("decoded data differs from the input")
+			}
+			if tt.name == "telemetry" && stream.Len() > len(tt.data)/4 {
+				t.Errorf("expected repetitive data to compress, got %d of %d bytes", stream.Len(), len(tt.data))
+			}
+		})
+	}
+}
+
+func TestCompressWriter_NoAllocs(t *testing.T) {
+	var sink discardWriter
+	var cw CompressWriter
+	data := bytes.Repeat([]byte("temp=21.5;"), 100)
+	allocs := testing.AllocsPerRun(10, func() {
+		cw.Reset(sink, nil)
+		_, _ = cw.Write(data)
+		_ = cw.Close()
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+// discardWriter is an io.Writer that doesn't allocate
+type discardWriter struct{}
+
+func (discardWriter) Write(p []byte) (int, error) { return len(p), nil }
@@ -0,0 +1,173 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains optional driver configuration.
+package sim800l
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Config holds optional driver settings. The zero value keeps the defaults.
+type Config struct {
+	// LogLevels sets the minimum log level per subsystem. Subsystems
+	// left out log everything the logger's handler accepts.
+	LogLevels map[Subsystem]slog.Level
+
+	// Quirks adjusts the connect sequence for the SIM's carrier,
+	// e.g. Quirks1NCE or QuirksHologram.
+	Quirks CarrierQuirks
+
+	// Alert is sent by Device.Alert
+	Alert AlertConfig
+
+	// IdleTimeout is how long the modem may go silent in the middle of
+	// a response line before the read gives up, IdleTimeout if zero.
+	// Command deadlines, like ConnectTimeout, still bound the whole wait.
+	IdleTimeout time.Duration
+
+	// QuickSend makes Connect enable AT+CIPQSEND=1. Writes then return
+	// once the module has the data instead of waiting for the remote
+	// host's TCP acknowledgement; use Connection.Acked, Unacked and
+	// Flush to track delivery.
+	QuickSend bool
+
+	// SenderAddress makes Connect enable AT+CIPSRIP=1, so received data
+	// carries the address of its sender. Connection.ReadFrom returns it
+	// and Connection.Stats counts TCP data from unexpected senders.
+	SenderAddress bool
+
+	// BlockingRead makes Connection.Read and BTConn.Read wait for data
+	// like a net.Conn instead of returning ErrWouldBlock after
+	// DefaultTimeout. The wait ends at the read deadline, or after
+	// ReadTimeout if no deadline is set, with ErrDeadlineExceeded; without
+	// either it is unbounded.
+	BlockingRead bool
+
+	// ReadTimeout bounds a blocking Read on a connection without a read
+	// deadline. Zero waits until data arrives.
+	ReadTimeout time.Duration
+
+	// ProbeHost is pinged by CheckIPStack and WatchIPStack to tell whether
+	// the data session still works, DefaultProbeHost if empty
+	ProbeHost string
+
+	// TemperatureHigh makes Device.Temperature emit EventOverheating when
+	// a reading reaches it, in degrees Celsius. Zero disables the events.
+	TemperatureHigh float64
+
+	// TemperatureLow is the reading at or below which an overheated
+	// module emits EventTemperatureNormal, DefaultTemperatureHysteresis
+	// below TemperatureHigh if zero
+	TemperatureLow float64
+
+	// BaudRate is the UART's baud rate. If Init finds the module garbled
+	// and the UART implements BaudRateSetter, it looks for the module at
+	// the rates in BaudRates and switches it back to BaudRate.
+	BaudRate uint32
+
+	// Random supplies the jitter of the backoff between reconnect and
+	// session restore attempts, e.g. a fixed sequence in tests or a PRNG
+	// seeded from a hardware source. If nil, math/rand/v2 is used.
+	Random RandomSource
+
+	// ResetStore keeps the counters of ResetCounters across restarts of
+	// the microcontroller. Configure loads them from it.
+	ResetStore ResetStore
+
+	// Strict makes the driver fail fast on protocol anomalies, like stray
+	// lines, truncated responses or connections the module dropped
+	// without telling, instead of recovering. Each returns an error
+	// matching ErrProtocolAnomaly and emits EventProtocolAnomaly. Meant
+	// for development and CI against a simulator.
+	Strict bool
+
+	// OutboxStore keeps the messages queued with Enqueue across restarts
+	// of the microcontroller. Configure loads them from it.
+	OutboxStore OutboxStore
+
+	// ReceiveBuffers is the number of connections that can buffer
+	// received data at once, MaxConnections if zero. Each buffer of
+	// RecvBufSize bytes is allocated when a connection first needs it
+	// and reused after it closes, so RAM grows with the connections open
+	// at once; a lower count caps it. Dial returns ErrNoReceiveBuffer when
+	// none is free.
+	ReceiveBuffers int
+
+	// SingleConnection makes Init and Connect put the module in
+	// single-connection mode with AT+CIPMUX=0, for devices that only
+	// talk to one server. Dial then uses connection 0 only, Listen and
+	// SenderAddress aren't supported and, unless ReceiveBuffers is set,
+	// a single receive buffer is allocated.
+	SingleConnection bool
+
+	// Retry sends commands that fail with a transient error, like a SIM
+	// still busy after a reset, again after a backoff. The zero value
+	// makes DefaultRetryAttempts; set Attempts to 1 to disable retries.
+	Retry RetryPolicy
+
+	// PowerDownOnClose makes Device.Close power the module down with
+	// AT+CPOWD after detaching from GPRS, like Shutdown
+	PowerDownOnClose bool
+
+	// PIN unlocks a SIM protected by a PIN during Init, 4 to 8 digits.
+	// Init fails with ErrIncorrectPIN if the SIM rejects it and doesn't
+	// try the same PIN again, so the SIM doesn't lock itself.
+	PIN string
+
+	// InitProgress is called after each stage of Init, InitContext and
+	// Reinit, with the error that stopped the stage, if any, e.g. to show
+	// boot progress or tell which stage stalls
+	InitProgress InitProgressFunc
+}
+
+// Configure applies the optional settings in cfg to the device. It
+// replaces the whole configuration: a field left at its zero value
+// restores that setting's default, so put every setting in one Config
+// rather than calling Configure once per setting. Log levels are the
+// exception, only the subsystems in LogLevels change.
+func (d *Device) Configure(cfg Config) {
+	for s, level := range cfg.LogLevels {
+		d.SetLogLevel(s, level)
+	}
+	d.lock()
+	d.quirks = cfg.Quirks
+	d.alert = cfg.Alert
+	if err := cfg.Alert.Validate(); err != nil {
+		d.log(SubsystemCommand, slog.LevelWarn, "alert can't be sent", "error", err)
+	}
+	d.idleTimeout = cfg.IdleTimeout
+	d.quickSend = cfg.QuickSend
+	d.probeHost = cfg.ProbeHost
+	d.senderAddress = cfg.SenderAddress
+	d.blockingRead = cfg.BlockingRead
+	d.readTimeout = cfg.ReadTimeout
+	d.tempHigh = cfg.TemperatureHigh
+	d.tempLow = cfg.TemperatureLow
+	d.baudRate = cfg.BaudRate
+	d.random = cfg.Random
+	d.resetStore = cfg.ResetStore
+	d.strict = cfg.Strict
+	d.outboxStore = cfg.OutboxStore
+	d.singleConn = cfg.SingleConnection
+	d.retry = cfg.Retry
+	d.powerDownOnClose = cfg.PowerDownOnClose
+	d.initProgress = cfg.InitProgress
+	if cfg.PIN != d.pin {
+		d.pin = cfg.PIN
+		d.pinRejected = false
+	}
+	if d.recvPoolUsed == 0 {
+		// Takes effect with the next connection
+		d.recvBufCount = cfg.ReceiveBuffers
+		if d.recvBufCount == 0 && d.singleConn {
+			d.recvBufCount = 1
+		}
+		// Buffers beyond the new count are never claimed again
+		for i := d.recvPoolBuffers(); i < MaxConnections; i++ {
+			d.recvPool[i] = nil
+		}
+	}
+	d.loadResets()
+	d.loadOutbox()
+	d.unlock()
+}
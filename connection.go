@@ -6,6 +6,9 @@ import (
 	"errors"
 	"io"
 	"net"
+	"net/netip"
+	"os"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,8 +16,23 @@ var (
 	ErrInvalidConnection        = errors.New("invalid connection")
 	ErrConnectionNotEstablished = errors.New("connection not established")
 	ErrConnectionClosed         = errors.New("connection closed")
+
+	// ErrDeadlineExceeded is returned by Read and Write when the connection's
+	// deadline has passed. It is a net.Error whose Timeout method returns true.
+	ErrDeadlineExceeded error = &deadlineExceededError{}
 )
 
+// deadlineExceededError implements net.Error for exceeded deadlines
+type deadlineExceededError struct{}
+
+func (e *deadlineExceededError) Error() string   { return "i/o timeout" }
+func (e *deadlineExceededError) Timeout() bool   { return true }
+func (e *deadlineExceededError) Temporary() bool { return true }
+
+// Is makes the error match os.ErrDeadlineExceeded, as the net.Conn
+// contract asks
+func (e *deadlineExceededError) Is(target error) bool { return target == os.ErrDeadlineExceeded }
+
 // ConnectionType represents different connection protocols
 type ConnectionType uint8
 
@@ -46,6 +64,34 @@ const (
 	StateError
 )
 
+func (s ConnectionState) String() string {
+	switch s {
+	case StateInitial:
+		return "INITIAL"
+	case StateConnecting:
+		return "CONNECTING"
+	case StateConnected:
+		return "CONNECTED"
+	case StateClosing:
+		return "CLOSING"
+	case StateClosed:
+		return "CLOSED"
+	case StateError:
+		return "ERROR"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ConnectionStatus is the state of a connection channel as reported by the module
+type ConnectionStatus struct {
+	ID         uint8           // Connection ID
+	Type       ConnectionType  // Connection type (TCP/UDP)
+	RemoteIP   string          // Remote IP address, empty if unused
+	RemotePort string          // Remote port, empty if unused
+	State      ConnectionState // State of the channel
+}
+
 // Connection represents a single connection to a remote server
 type Connection struct {
 	ID         uint8           // Connection ID (0-5)
@@ -55,6 +101,104 @@ type Connection struct {
 	RemotePort string          // Remote port
 	LocalPort  uint16          // Local port (if any)
 	Device     *Device         // Reference to parent device
+	Critical   bool            // Kept open when an alert needs a connection slot
+	TLS        bool            // Encrypted by the module's SSL, dialed as "tls"
+
+	onProgress    ProgressFunc    // Progress callback for large writes
+	onStateChange StateChangeFunc // Called on every state transition
+	tee           io.Writer       // Gets a copy of the data read
+	foreignData   int             // Data notifications from another host than RemoteIP
+	closed        bool            // Close was called
+	dropped       bool            // Lost with the data session or by the module, not closed by the remote host
+	writeMu       mutex           // Serializes the writes on the connection
+	blocking      atomic.Bool     // Read waits for data like a net.Conn, see SetBlocking
+
+	bytesSent     uint64    // Bytes accepted by the module for sending
+	bytesReceived uint64    // Bytes received from the module
+	sendFailures  int       // Writes that failed
+	connectedAt   time.Time // When the connection was established
+	lastActivity  time.Time // When data was last sent or received
+
+	// Deadlines in Unix nanoseconds, zero for none. They are atomic so
+	// they can be changed while a Read or Write is waiting.
+	readDeadline  atomic.Int64
+	writeDeadline atomic.Int64
+}
+
+// ProgressMinSize is the smallest write that reports progress
+const ProgressMinSize = 2048
+
+// SendProgress describes how far a large write has got
+type SendProgress struct {
+	Sent       int           // Bytes accepted by the module so far
+	Total      int           // Total bytes in the write
+	Throughput int           // Average bytes per second since the write started
+	ETA        time.Duration // Estimated time until the write completes
+}
+
+// ProgressFunc is called after every chunk of a large write is accepted by the module
+type ProgressFunc func(p SendProgress)
+
+// OnProgress sets a callback reporting progress of writes of at least
+// ProgressMinSize bytes, so slow-but-progressing uploads can be told apart
+// from stalled ones. Pass nil to disable progress reporting.
+// The callback runs while the write holds the device, so it must not
+// call back into the Device.
+func (c *Connection) OnProgress(fn ProgressFunc) {
+	c.onProgress = fn
+}
+
+// StateChangeFunc is called when a connection moves from one state to another
+type StateChangeFunc func(from, to ConnectionState)
+
+// OnStateChange sets a callback reporting every state transition, such as
+// CONNECTED to CLOSED when the remote host closes the connection or to
+// ERROR when a send fails. Pass nil to disable it. The callback runs while
+// the driver holds the device, so it must not call back into the Device.
+func (c *Connection) OnStateChange(fn StateChangeFunc) {
+	c.onStateChange = fn
+}
+
+// setState changes the connection state and reports the transition
+func (c *Connection) setState(s ConnectionState) {
+	from := c.state
+	if from == s {
+		return
+	}
+	c.state = s
+	if c.onStateChange != nil {
+		c.onStateChange(from, s)
+	}
+}
+
+// SetBlocking makes Read wait for data like a net.Conn, as
+// Config.BlockingRead does for every connection, so the connection can
+// carry a protocol layered on a net.Conn, like a host-side TLS client:
+//
+//	conn.SetBlocking(true)
+//	tlsConn := tls.Client(conn, &tls.Config{ServerName: "example.com"})
+//
+// Reads then never return ErrWouldBlock; without a deadline they wait
+// for data, the close of the connection or Config.ReadTimeout. A read
+// may run in one goroutine while another writes: data arriving during
+// the write is kept for the read.
+func (c *Connection) SetBlocking(on bool) {
+	c.blocking.Store(on)
+}
+
+// Tee sets a writer that gets a copy of the data read from the connection,
+// like io.TeeReader, so a raw logger or a second parser can follow the
+// stream while the application reads it. The data is written from the
+// reader's buffer after each read, without buffering it again. Pass an
+// io.MultiWriter for several writers and nil to stop. A failing writer
+// doesn't fail the read.
+func (c *Connection) Tee(w io.Writer) {
+	if c == nil || c.Device == nil {
+		return
+	}
+	c.Device.lock()
+	defer c.Device.unlock()
+	c.tee = w
 }
 
 // Connection represents a single connection to a remote server
@@ -72,13 +216,92 @@ func (c *Connection) Read(b []byte) (int, error) {
 		return 0, ErrInvalidConnection
 	}
 
-	// Check connection state
-	if c.state != StateConnected {
+	// A connection closed by the remote host still returns the data
+	// received before the close, then io.EOF
+	if c.state != StateConnected && c.state != StateClosed {
 		return 0, io.EOF
 	}
 
 	// Use the module's connection read implementation
-	return c.Device.connectionRead(c.ID, b)
+	return c.Device.connectionRead(c, b, 0)
+}
+
+// ReadInto reads received data into buf, waiting until the deadline if
+// none is buffered. A zero deadline falls back to the read deadline, as
+// for Read; the connection's read deadline is left unchanged.
+//
+// ReadInto never allocates: it copies straight from the connection's
+// receive buffer into buf. A call copies at most min(len(buf),
+// RecvBufSize) bytes; on UDP connections it copies one datagram, dropping
+// the part that doesn't fit. Data isn't split or reordered, so the bytes
+// returned are exactly those the module delivered.
+func (c *Connection) ReadInto(buf []byte, deadline time.Time) (int, error) {
+	if c == nil || c.Device == nil {
+		return 0, ErrInvalidConnection
+	}
+	if c.state != StateConnected && c.state != StateClosed {
+		return 0, io.EOF
+	}
+	return c.Device.connectionRead(c, buf, deadlineNanos(deadline))
+}
+
+// ReadFrom reads data like Read and also returns its sender. The sender
+// is only known when Config.SenderAddress is set, otherwise addr is nil.
+// On UDP connections it is the sender of the returned datagram, on TCP
+// connections the sender of the latest data received.
+func (c *Connection) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	if c == nil || c.Device == nil {
+		return 0, nil, ErrInvalidConnection
+	}
+	if c.state != StateConnected && c.state != StateClosed {
+		return 0, nil, io.EOF
+	}
+	n, from, err := c.Device.connectionReadFrom(c, b, 0)
+	if from.IsValid() {
+		if c.Type == UDP {
+			addr = net.UDPAddrFromAddrPort(from)
+		} else {
+			addr = net.TCPAddrFromAddrPort(from)
+		}
+	}
+	return n, addr, err
+}
+
+// ConnectionStats describes the traffic on a connection
+type ConnectionStats struct {
+	BytesSent     uint64         // Bytes accepted by the module for sending
+	BytesReceived uint64         // Bytes received, whether read yet or not
+	SendFailures  int            // Writes that failed, e.g. with SEND FAIL or a deadline
+	ConnectedAt   time.Time      // When the connection was established
+	LastActivity  time.Time      // When data was last sent or received, zero if never
+	LastSender    netip.AddrPort // Sender of the latest TCP data, if reported
+	ForeignData   int            // TCP data notifications from another host than RemoteIP
+}
+
+// Stats returns statistics about the traffic on the connection, so
+// long-running firmware can report link health. Senders are only known
+// when Config.SenderAddress is set. ForeignData above zero means the slot
+// carries another connection's data, e.g. after the driver and the module
+// lost track of each other.
+func (c *Connection) Stats() (ConnectionStats, error) {
+	if c == nil || c.Device == nil {
+		return ConnectionStats{}, ErrInvalidConnection
+	}
+	d := c.Device
+	d.lock()
+	defer d.unlock()
+	stats := ConnectionStats{
+		BytesSent:     c.bytesSent,
+		BytesReceived: c.bytesReceived,
+		SendFailures:  c.sendFailures,
+		ConnectedAt:   c.connectedAt,
+		LastActivity:  c.lastActivity,
+		ForeignData:   c.foreignData,
+	}
+	if d.connections[c.ID] == c {
+		stats.LastSender = d.recvFrom[c.ID]
+	}
+	return stats, nil
 }
 
 // Write writes data to the connection
@@ -95,7 +318,40 @@ func (c *Connection) Write(b []byte) (int, error) {
 	}
 
 	// Use the module's SendData function
-	return c.Device.connectionSend(c.ID, b)
+	return c.Device.connectionSend(c, b)
+}
+
+// Unacked returns the number of bytes written to the connection that the
+// remote host has not acknowledged yet
+func (c *Connection) Unacked() (int, error) {
+	if c == nil || c.Device == nil {
+		return 0, ErrInvalidConnection
+	}
+	_, n, err := c.Device.connectionAcks(c.ID)
+	return n, err
+}
+
+// Acked returns the number of bytes written to the connection that the
+// remote host has acknowledged. Together with Unacked it tracks delivery
+// when quick send mode lets Write return before the remote host acknowledges.
+func (c *Connection) Acked() (int, error) {
+	if c == nil || c.Device == nil {
+		return 0, ErrInvalidConnection
+	}
+	n, _, err := c.Device.connectionAcks(c.ID)
+	return n, err
+}
+
+// Flush waits until the remote host has acknowledged everything written to
+// the connection. It returns ErrTimeout if that doesn't happen before the deadline.
+func (c *Connection) Flush(deadline time.Time) error {
+	if c == nil || c.Device == nil {
+		return ErrInvalidConnection
+	}
+	if c.state != StateConnected {
+		return ErrConnectionNotEstablished
+	}
+	return c.Device.flushConnection(c.ID, deadline)
 }
 
 // Close closes the connection
@@ -106,8 +362,7 @@ func (c *Connection) Close() error {
 		return ErrInvalidConnection
 	}
 
-	// Use the module's CloseConnection function
-	return c.Device.CloseConnection(c.ID)
+	return c.Device.connectionClose(c)
 }
 
 // LocalAddr returns the local network address
@@ -139,26 +394,67 @@ func (c *Connection) RemoteAddr() net.Addr {
 }
 
 // SetDeadline sets the read and write deadlines
-// Note: This implementation is a placeholder, as the SIM800L doesn't support precise deadlines
+// Implements the net.Conn interface
 func (c *Connection) SetDeadline(t time.Time) error {
-	// Not fully implemented due to SIM800L limitations
+	if c == nil {
+		return ErrInvalidConnection
+	}
+	c.readDeadline.Store(deadlineNanos(t))
+	c.writeDeadline.Store(deadlineNanos(t))
 	return nil
 }
 
-// SetReadDeadline sets the read deadline
-// Note: This implementation is a placeholder, as the SIM800L doesn't support precise deadlines
+// SetReadDeadline sets the deadline for Read calls. A zero value means
+// Read returns ErrWouldBlock after DefaultTimeout without data, as before.
+// Implements the net.Conn interface
 func (c *Connection) SetReadDeadline(t time.Time) error {
-	// Not fully implemented due to SIM800L limitations
+	if c == nil {
+		return ErrInvalidConnection
+	}
+	c.readDeadline.Store(deadlineNanos(t))
 	return nil
 }
 
-// SetWriteDeadline sets the write deadline
-// Note: This implementation is a placeholder, as the SIM800L doesn't support precise deadlines
+// SetWriteDeadline sets the deadline for Write calls. The deadline bounds
+// the wait for the module; a write waiting for another goroutine's command
+// to finish may start late. Implements the net.Conn interface
 func (c *Connection) SetWriteDeadline(t time.Time) error {
-	// Not fully implemented due to SIM800L limitations
+	if c == nil {
+		return ErrInvalidConnection
+	}
+	c.writeDeadline.Store(deadlineNanos(t))
 	return nil
 }
 
+// deadlineNanos converts a deadline to Unix nanoseconds, zero for none
+func deadlineNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// deadlineTimeout returns how long an operation bounded by the deadline in
+// Unix nanoseconds may wait for the module, at most DefaultTimeout
+func deadlineTimeout(deadline int64) (time.Duration, error) {
+	if deadline == 0 {
+		return DefaultTimeout, nil
+	}
+	remaining := time.Until(time.Unix(0, deadline))
+	if remaining <= 0 {
+		return 0, ErrDeadlineExceeded
+	}
+	return min(DefaultTimeout, remaining), nil
+}
+
+// deadlineError replaces a timeout caused by the deadline with ErrDeadlineExceeded
+func deadlineError(err error, deadline int64) error {
+	if errors.Is(err, ErrTimeout) && deadline != 0 && time.Now().UnixNano() >= deadline {
+		return ErrDeadlineExceeded
+	}
+	return err
+}
+
 // networkString returns the network type as a string
 func (c *Connection) networkString() string {
 	if c.Type == TCP {
@@ -191,21 +487,5 @@ func (c *Connection) GetState() string {
 	if c == nil {
 		return "INVALID"
 	}
-
-	switch c.state {
-	case StateInitial:
-		return "INITIAL"
-	case StateConnecting:
-		return "CONNECTING"
-	case StateConnected:
-		return "CONNECTED"
-	case StateClosing:
-		return "CLOSING"
-	case StateClosed:
-		return "CLOSED"
-	case StateError:
-		return "ERROR"
-	default:
-		return "UNKNOWN"
-	}
+	return c.state.String()
 }
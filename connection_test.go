@@ -0,0 +1,425 @@
+package sim800l
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConnection_ReadDeadline(t *testing.T) {
+	d := New(newMockModem(nil), nil, slog.New(&MockHandler{t: t}))
+	conn := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+	d.connections[0] = conn
+
+	if err := conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatalf("failed to set read deadline: %v", err)
+	}
+	start := time.Now()
+	_, err := conn.Read(make([]byte, 16))
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected a timeout net.Error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("read returned after %v, long past the deadline", elapsed)
+	}
+}
+
+func TestConnection_ReadDeadlineExpired(t *testing.T) {
+	d := New(newMockModem(nil), nil, slog.New(&MockHandler{t: t}))
+	conn := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+	d.connections[0] = conn
+	d.claimRecvBuffer(0)
+	d.recvBufLengths[0] = copy(d.recvBuffers[0][:], "data")
+
+	// Like a net.Conn, an expired deadline fails reads even with data waiting
+	_ = conn.SetDeadline(time.Now().Add(-time.Second))
+	if _, err := conn.Read(make([]byte, 16)); err != ErrDeadlineExceeded {
+		t.Fatalf("expected ErrDeadlineExceeded, got %v", err)
+	}
+
+	// Clearing the deadline makes the data readable again
+	_ = conn.SetDeadline(time.Time{})
+	buf := make([]byte, 16)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(buf[:n]) != "data" {
+		t.Errorf("expected %q, got %q", "data", buf[:n])
+	}
+}
+
+func TestConnection_WriteDeadline(t *testing.T) {
+	// The module takes the payload but never confirms it
+	modem := newMockModem(map[string]string{
+		"AT+CIPSEND=0,4": "\r\n> ",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	conn := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+	d.connections[0] = conn
+
+	_ = conn.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+	start := time.Now()
+	n, err := conn.Write([]byte("ping"))
+	if err != ErrDeadlineExceeded {
+		t.Fatalf("expected ErrDeadlineExceeded, got %v", err)
+	}
+	if n != 0 {
+		t.Errorf("expected 0 bytes written, got %d", n)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("write returned after %v, long past the deadline", elapsed)
+	}
+}
+
+func TestConnection_ReadRemoteClosed(t *testing.T) {
+	s1, s2 := slot(t, 1), slot(t, 2)
+	modem := newMockModem(nil)
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	conn := &Connection{ID: 1, Type: TCP, state: StateConnected, Device: d}
+	d.connections[s1] = conn
+
+	modem.inject("+RECEIVE,1,3:\r\nbye\r\n1, CLOSED\r\n")
+
+	// Data received before the close is still returned
+	buf := make([]byte, 16)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(buf[:n]) != "bye" {
+		t.Errorf("expected %q, got %q", "bye", buf[:n])
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(buf); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+	if conn.State() != StateClosed {
+		t.Errorf("expected state closed, got %s", conn.GetState())
+	}
+	if d.connections[s1] != nil {
+		t.Error("expected the connection slot to be released")
+	}
+
+	// Closing a remotely closed connection doesn't need the module
+	other := &Connection{ID: 2, Type: TCP, state: StateConnected, Device: d}
+	d.connections[s2] = other
+	modem.inject("\r\n2, CLOSED\r\n")
+	if err := d.Poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if err := other.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if d.connections[s2] != nil {
+		t.Error("expected the connection slot to be released")
+	}
+}
+
+func TestConnection_ReadInto(t *testing.T) {
+	d := New(newMockModem(nil), nil, slog.New(&MockHandler{t: t}))
+	conn := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+	d.connections[0] = conn
+	d.claimRecvBuffer(0)
+
+	buf := make([]byte, 4)
+	allocs := testing.AllocsPerRun(10, func() {
+		d.recvBufLengths[0] = copy(d.recvBuffers[0][:], "data")
+		n, err := conn.ReadInto(buf, time.Time{})
+		if err != nil || n != 4 {
+			t.Fatalf("expected 4 bytes, got %d: %v", n, err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+
+	// The deadline bounds the wait without changing the read deadline
+	if _, err := conn.ReadInto(buf, time.Now().Add(20*time.Millisecond)); err != ErrDeadlineExceeded {
+		t.Fatalf("expected ErrDeadlineExceeded, got %v", err)
+	}
+	if conn.readDeadline.Load() != 0 {
+		t.Error("expected the read deadline to be unchanged")
+	}
+}
+
+func TestConnection_Tee(t *testing.T) {
+	d := New(newMockModem(nil), nil, slog.New(&MockHandler{t: t}))
+	conn := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+	d.connections[0] = conn
+	d.claimRecvBuffer(0)
+
+	var raw strings.Builder
+	conn.Tee(&raw)
+	d.recvBufLengths[0] = copy(d.recvBuffers[0][:], "hello world")
+
+	// The tee sees exactly what the reader consumed, read by read
+	var got strings.Builder
+	buf := make([]byte, 4)
+	for got.Len() < len("hello world") {
+		n, err := conn.ReadInto(buf, time.Now().Add(100*time.Millisecond))
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		got.Write(buf[:n])
+		if raw.String() != got.String() {
+			t.Fatalf("expected the tee to have %q, got %q", got.String(), raw.String())
+		}
+	}
+
+	conn.Tee(nil)
+	d.recvBufLengths[0] = copy(d.recvBuffers[0][:], "more")
+	if _, err := conn.Read(buf); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if raw.String() != "hello world" {
+		t.Errorf("expected no copy after removing the tee, got %q", raw.String())
+	}
+}
+
+func TestConnection_OnStateChange(t *testing.T) {
+	s1, s2 := slot(t, 1), slot(t, 2)
+	modem := newMockModem(map[string]string{
+		"AT+CIPSEND=0,4": "\r\n> ",
+		"AT+CIPCLOSE=1":  "\r\n1, CLOSE OK\r\n",
+	})
+	modem.dataReply = "\r\n0, SEND FAIL\r\n"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	var changes []string
+	record := func(from, to ConnectionState) {
+		changes = append(changes, from.String()+">"+to.String())
+	}
+	for id := uint8(0); id <= s2; id++ {
+		conn := &Connection{ID: id, Type: TCP, state: StateConnected, Device: d}
+		conn.OnStateChange(record)
+		d.connections[id] = conn
+	}
+
+	// A failed send, a local close and a remote close
+	if _, err := d.connections[0].Write([]byte("ping")); !errors.Is(err, ErrCannotSend) {
+		t.Fatalf("expected ErrCannotSend, got %v", err)
+	}
+	if err := d.connections[s1].Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	modem.inject("\r\n2, CLOSED\r\n")
+	if err := d.Poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+
+	expected := []string{"CONNECTED>ERROR", "CONNECTED>CLOSING", "CLOSING>CLOSED", "CONNECTED>CLOSED"}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected transitions %q, got %q", expected, changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("expected transition %q, got %q", expected[i], changes[i])
+		}
+	}
+}
+
+func TestConnection_BlockingRead(t *testing.T) {
+	modem := newMockModem(nil)
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.Configure(Config{BlockingRead: true, ReadTimeout: 50 * time.Millisecond})
+	conn := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+	d.connections[0] = conn
+
+	// Without data the read times out like a net.Conn
+	buf := make([]byte, 16)
+	start := time.Now()
+	_, err := conn.Read(buf)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected a timeout net.Error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("read returned after %v, long past the read timeout", elapsed)
+	}
+
+	modem.inject("+RECEIVE,0,5:\r\nhello")
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(buf[:n]) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", buf[:n])
+	}
+}
+
+func TestConnection_Stats(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CPAS": "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		"AT+CIPSTART=0,\"TCP\",\"example.com\",\"80\"": "\r\nOK\r\n\r\n0, CONNECT OK\r\n",
+		"AT+CIPSEND=0,5": "\r\n> ",
+	})
+	modem.dataReply = "\r\n0, SEND OK\r\n"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.IP = "10.0.0.1"
+
+	before := time.Now()
+	c, err := d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	conn := c.(*Connection)
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	modem.inject("+RECEIVE,0,3:\r\nbye")
+	buf := make([]byte, 16)
+	if _, err := conn.Read(buf); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	// The module rejects the next send
+	modem.dataReply = "\r\n0, SEND FAIL\r\n"
+	if _, err := conn.Write([]byte("again")); !errors.Is(err, ErrCannotSend) {
+		t.Fatalf("expected ErrCannotSend, got %v", err)
+	}
+
+	stats, err := conn.Stats()
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+	if stats.BytesSent != 5 || stats.BytesReceived != 3 || stats.SendFailures != 1 {
+		t.Errorf("expected 5 bytes sent, 3 received and 1 failure, got %+v", stats)
+	}
+	if stats.ConnectedAt.Before(before) || stats.LastActivity.Before(stats.ConnectedAt) {
+		t.Errorf("expected connect and activity times after the dial, got %+v", stats)
+	}
+}
+
+// tlsModem is a mockModem whose connection 0 leads to wire: data sent
+// with AT+CIPSEND is written to it and what it returns is received
+type tlsModem struct {
+	*mockModem
+	wire net.Conn
+	sent chan []byte
+}
+
+func newTLSModem(wire net.Conn) *tlsModem {
+	m := &tlsModem{mockModem: newMockModem(map[string]string{}), wire: wire, sent: make(chan []byte, 16)}
+	m.dataReply = "\r\nSEND OK\r\n"
+	go func() {
+		for data := range m.sent {
+			if _, err := wire.Write(data); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, err := wire.Read(buf)
+			if err != nil {
+				return
+			}
+			m.inject(fmt.Sprintf("\r\n+RECEIVE,0,%d:\r\n%s", n, buf[:n]))
+		}
+	}()
+	return m
+}
+
+func (m *tlsModem) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	payload := m.inData
+	if cmd, ok := strings.CutSuffix(string(p), "\r\n"); ok && strings.HasPrefix(cmd, "AT+CIPSEND=0,") {
+		m.responses[cmd] = "\r\n> "
+	}
+	m.mu.Unlock()
+	if payload {
+		m.sent <- bytes.Clone(p)
+	}
+	return m.mockModem.Write(p)
+}
+
+// selfSignedCert returns a certificate for example.com and a pool trusting it
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "example.com"},
+		DNSNames:              []string{"example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestConnection_TLSClient(t *testing.T) {
+	cert, pool := selfSignedCert(t)
+	server, wire := net.Pipe()
+	defer server.Close()
+	defer wire.Close()
+
+	// The remote host answers ping with pong
+	go func() {
+		conn := tls.Server(server, &tls.Config{Certificates: []tls.Certificate{cert}})
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(conn, buf); err == nil && string(buf) == "ping" {
+			_, _ = conn.Write([]byte("pong"))
+		}
+	}()
+
+	modem := newTLSModem(wire)
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	conn := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+	d.connections[0] = conn
+	d.claimRecvBuffer(0)
+	conn.SetBlocking(true)
+
+	client := tls.Client(conn, &tls.Config{RootCAs: pool, ServerName: "example.com"})
+	_ = client.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := client.Handshake(); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(client, buf); err != nil || string(buf) != "pong" {
+		t.Fatalf("expected pong, got %q, %v", buf, err)
+	}
+
+	// A deadline ends the wait with an error TLS doesn't keep
+	_ = client.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err := client.Read(buf)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected os.ErrDeadlineExceeded, got %v", err)
+	}
+	_ = client.SetReadDeadline(time.Time{})
+}
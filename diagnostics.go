@@ -0,0 +1,70 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains diagnostics collected while talking to the module.
+package sim800l
+
+import (
+	"bytes"
+	"time"
+)
+
+// MaxErrorHistory is the number of recent module errors kept for diagnostics
+const MaxErrorHistory = 8
+
+var (
+	cmeErrorPrefix = []byte("+CME ERROR") // Equipment error report
+	cmsErrorPrefix = []byte("+CMS ERROR") // SMS error report
+)
+
+// ErrorRecord describes a CME or CMS error reported by the module
+type ErrorRecord struct {
+	Time     time.Time // When the error was received
+	Command  string    // Command that triggered the error
+	Response string    // Raw error line, e.g. "+CME ERROR: SIM not inserted"
+}
+
+// Diagnostics is a snapshot of the driver's diagnostic state
+type Diagnostics struct {
+	RecentErrors []ErrorRecord // Most recent module errors, oldest first
+	TotalErrors  int           // Number of module errors seen since New
+
+	// TruncatedLines counts response lines that didn't fit the buffer and
+	// were cut to MaxBufferSize bytes
+	TruncatedLines int
+
+	// BootTime is how long the module took to boot after the driver last
+	// reset it, zero if unknown or it didn't get ready within StartupTime
+	BootTime time.Duration
+}
+
+// Diagnostics returns a snapshot of the diagnostic information collected so far.
+// Recent errors let intermittent carrier-side failures be correlated with
+// application logs after the fact.
+func (d *Device) Diagnostics() Diagnostics {
+	d.lock()
+	defer d.unlock()
+
+	n := min(d.errCount, MaxErrorHistory)
+	diag := Diagnostics{
+		RecentErrors:   make([]ErrorRecord, 0, n),
+		TotalErrors:    d.errCount,
+		TruncatedLines: d.truncCount,
+		BootTime:       d.bootTime,
+	}
+	for i := d.errCount - n; i < d.errCount; i++ {
+		diag.RecentErrors = append(diag.RecentErrors, d.errHistory[i%MaxErrorHistory])
+	}
+	return diag
+}
+
+// recordModuleError stores the line in the error history if it is a CME or CMS error
+func (d *Device) recordModuleError(line []byte) {
+	if !bytes.HasPrefix(line, cmeErrorPrefix) && !bytes.HasPrefix(line, cmsErrorPrefix) {
+		return
+	}
+	d.errHistory[d.errCount%MaxErrorHistory] = ErrorRecord{
+		Time:     time.Now(),
+		Command:  string(d.lastCmd[:d.lastCmdLen]),
+		Response: string(line),
+	}
+	d.errCount++
+}
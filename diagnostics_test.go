@@ -0,0 +1,64 @@
+package sim800l
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/m-s-sh/mockhw"
+)
+
+func TestDiagnostics_RecentErrors(t *testing.T) {
+	uart := mockhw.NewUART(0)
+	d := Device{
+		uart:   uart,
+		logger: slog.New(&MockHandler{t: t}),
+	}
+
+	if err := d.sendRaw([]byte("+CPIN?")); err != nil {
+		t.Fatalf("failed to send command: %v", err)
+	}
+	uart.SetRxBuffer([]byte("\r\n+CME ERROR: SIM not inserted\r\n"))
+	if err := d.readResponse(nil, defaultResponseCheck, time.Second); err == nil {
+		t.Fatal("expected error response")
+	}
+
+	diag := d.Diagnostics()
+	if len(diag.RecentErrors) != 1 {
+		t.Fatalf("expected 1 recent error, got %d", len(diag.RecentErrors))
+	}
+	rec := diag.RecentErrors[0]
+	if rec.Command != "AT+CPIN?" {
+		t.Errorf("expected command %q, got %q", "AT+CPIN?", rec.Command)
+	}
+	if rec.Response != "+CME ERROR: SIM not inserted" {
+		t.Errorf("unexpected response %q", rec.Response)
+	}
+	if rec.Time.IsZero() {
+		t.Error("expected error timestamp")
+	}
+}
+
+func TestDiagnostics_HistoryWraps(t *testing.T) {
+	d := Device{}
+	for i := 0; i < MaxErrorHistory+3; i++ {
+		d.lastCmdLen = copy(d.lastCmd[:], []byte{'A', 'T', byte('a' + i)})
+		d.recordModuleError([]byte("+CMS ERROR: 500"))
+	}
+	// Ordinary errors are not recorded
+	d.recordModuleError([]byte("ERROR"))
+
+	diag := d.Diagnostics()
+	if diag.TotalErrors != MaxErrorHistory+3 {
+		t.Errorf("expected %d total errors, got %d", MaxErrorHistory+3, diag.TotalErrors)
+	}
+	if len(diag.RecentErrors) != MaxErrorHistory {
+		t.Fatalf("expected %d recent errors, got %d", MaxErrorHistory, len(diag.RecentErrors))
+	}
+	if diag.RecentErrors[0].Command != "ATd" {
+		t.Errorf("expected oldest command %q, got %q", "ATd", diag.RecentErrors[0].Command)
+	}
+	if last := diag.RecentErrors[MaxErrorHistory-1].Command; last != "ATk" {
+		t.Errorf("expected newest command %q, got %q", "ATk", last)
+	}
+}
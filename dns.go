@@ -0,0 +1,153 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains host name resolution by the module.
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// DNSTimeout bounds the wait for the module's answer to a lookup
+const DNSTimeout = 30 * time.Second
+
+var (
+	cmdLookup    = []byte("+CDNSGIP") // Resolve a host name
+	lookupStatus = []byte("+CDNSGIP") // Lookup result, sent after OK
+	cmdDNSConfig = []byte("+CDNSCFG") // Set the DNS servers
+)
+
+var ErrLookupFailed = errors.New("host lookup failed")
+
+// LookupHost asks the module to resolve a host name and returns its IP
+// addresses, so names can be resolved without opening a connection. It
+// needs the GPRS connection set up by Connect. A failed lookup returns an
+// error matching ErrLookupFailed with the module's error code, and a name
+// with quotes or control bytes, which would break the command,
+// ErrBadParameter.
+func (d *Device) LookupHost(name string) ([]string, error) {
+	if !validHostName(name) {
+		return nil, fmt.Errorf("%w: host name %q", ErrBadParameter, name)
+	}
+
+	d.lock()
+	defer d.unlock()
+
+	if d.IP == "" {
+		return nil, ErrNoIP
+	}
+
+	var buf [MaxCommandSize]byte
+	cmd := append(buf[:0], cmdLookup...)
+	cmd = fmt.Appendf(cmd, "=\"%s\"", name)
+	if err := d.send(cmd); err != nil {
+		return nil, fmt.Errorf("failed to start lookup: %w", err)
+	}
+
+	// The result arrives once the network answered
+	deadline := time.Now().Add(DNSTimeout)
+	for {
+		err := d.readResponse(cmd, func(buffer []byte) error {
+			if bytes.HasPrefix(buffer, lookupStatus) {
+				return nil
+			}
+			return ErrUnexpectedResponse
+		}, time.Until(deadline))
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrUnexpectedResponse) {
+			return nil, err
+		}
+		d.log(SubsystemCommand, slog.LevelDebug, "discarding line while waiting for lookup", "line", d.rxBuffer[:d.end])
+		if err := d.anomaly("unexpected line", d.rxBuffer[:d.end]); err != nil {
+			return nil, err
+		}
+	}
+
+	val, ok := d.parseValue(lookupStatus)
+	if !ok {
+		return nil, ErrUnexpectedResponse
+	}
+	return parseLookup(val)
+}
+
+// validHostName reports whether name is not empty and can be quoted in a
+// command: no quotes and no control bytes
+func validHostName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c == '"' || c < ' ' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLookup parses the value of a lookup result like
+// 1,"example.com","93.184.216.34" or 0,8 for a failed lookup
+func parseLookup(val []byte) ([]string, error) {
+	fields := bytes.Split(val, []byte(","))
+	if len(fields) < 2 {
+		return nil, ErrUnexpectedResponse
+	}
+	if !bytes.Equal(fields[0], []byte("1")) {
+		return nil, fmt.Errorf("%w: error %s", ErrLookupFailed, bytes.TrimSpace(fields[1]))
+	}
+
+	addrs := make([]string, 0, len(fields)-2)
+	for _, f := range fields[2:] {
+		if addr := bytes.Trim(f, "\" "); len(addr) > 0 {
+			addrs = append(addrs, string(addr))
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("%w: no addresses", ErrLookupFailed)
+	}
+	return addrs, nil
+}
+
+// SetDNS makes the module resolve host names with the given servers
+// instead of the carrier's, for private APNs whose DNS doesn't work. The
+// secondary server may be empty. The servers are applied at once when
+// connected and again by every Connect. Both empty restore the carrier's
+// servers, which the module takes again when the next Connect activates
+// the PDP context.
+func (d *Device) SetDNS(primary, secondary string) error {
+	if primary == "" && secondary != "" {
+		return fmt.Errorf("%w: secondary DNS server without a primary", ErrBadParameter)
+	}
+	if (primary != "" && net.ParseIP(primary) == nil) || (secondary != "" && net.ParseIP(secondary) == nil) {
+		return ErrBadParameter
+	}
+
+	d.lock()
+	defer d.unlock()
+	d.dnsPrimary, d.dnsSecondary = primary, secondary
+	if d.IP == "" || primary == "" {
+		return nil
+	}
+	return d.applyDNS()
+}
+
+// applyDNS sends the DNS servers set with SetDNS, if any
+func (d *Device) applyDNS() error {
+	if d.dnsPrimary == "" {
+		return nil
+	}
+	var buf [MaxCommandSize]byte
+	cmd := append(buf[:0], cmdDNSConfig...)
+	cmd = fmt.Appendf(cmd, "=\"%s\"", d.dnsPrimary)
+	if d.dnsSecondary != "" {
+		cmd = fmt.Appendf(cmd, ",\"%s\"", d.dnsSecondary)
+	}
+	if err := d.send(cmd); err != nil {
+		return fmt.Errorf("failed to set DNS servers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_LookupHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:  "one address",
+			reply: "\r\nOK\r\n\r\n+CDNSGIP: 1,\"example.com\",\"93.184.216.34\"\r\n",
+			want:  []string{"93.184.216.34"},
+		},
+		{
+			name:  "two addresses after an unrelated line",
+			reply: "\r\nOK\r\n\r\n+CSQ: 20,0\r\n\r\n+CDNSGIP: 1,\"example.com\",\"93.184.216.34\",\"93.184.216.35\"\r\n",
+			want:  []string{"93.184.216.34", "93.184.216.35"},
+		},
+		{
+			name:    "not found",
+			reply:   "\r\nOK\r\n\r\n+CDNSGIP: 0,8\r\n",
+			wantErr: ErrLookupFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modem := newMockModem(map[string]string{
+				"AT+CDNSGIP=\"example.com\"": tt.reply,
+			})
+			d := New(modem, nil, slog.New(&MockHandler{t: t}))
+			d.IP = "10.0.0.1"
+
+			addrs, err := d.LookupHost("example.com")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lookup failed: %v", err)
+			}
+			if len(addrs) != len(tt.want) {
+				t.Fatalf("expected %q, got %q", tt.want, addrs)
+			}
+			for i := range addrs {
+				if addrs[i] != tt.want[i] {
+					t.Errorf("expected %q, got %q", tt.want[i], addrs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDevice_SetDNS(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CPAS":                            "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		"AT+CGATT?":                          "\r\n+CGATT: 1\r\n\r\nOK\r\n",
+		"AT+CIPMUX=1":                        "\r\nOK\r\n",
+		"AT+CSTT=\"iot\"":                    "\r\nOK\r\n",
+		"AT+CIICR":                           "\r\nOK\r\n",
+		"AT+CIFSR":                           "\r\n10.0.0.1\r\n",
+		"AT+CDNSCFG=\"1.1.1.1\",\"9.9.9.9\"": "\r\nOK\r\n",
+		"AT+CDNSCFG=\"8.8.8.8\"":             "\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+
+	if err := d.SetDNS("dns.example.com", ""); err != ErrBadParameter {
+		t.Errorf("expected ErrBadParameter for a host name, got %v", err)
+	}
+
+	// Stored until connected, then applied by Connect
+	if err := d.SetDNS("1.1.1.1", "9.9.9.9"); err != nil {
+		t.Fatalf("set DNS failed: %v", err)
+	}
+	if len(modem.commands) != 0 {
+		t.Fatalf("expected no commands before Connect, got %q", modem.commands)
+	}
+	if err := d.Connect("iot", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if last := modem.commands[len(modem.commands)-1]; last != "AT+CDNSCFG=\"1.1.1.1\",\"9.9.9.9\"" {
+		t.Errorf("expected Connect to set the DNS servers, last command %q", last)
+	}
+
+	// Applied at once while connected
+	if err := d.SetDNS("8.8.8.8", ""); err != nil {
+		t.Fatalf("set DNS failed: %v", err)
+	}
+	if last := modem.commands[len(modem.commands)-1]; last != "AT+CDNSCFG=\"8.8.8.8\"" {
+		t.Errorf("expected the DNS server to be set, last command %q", last)
+	}
+	// Cleared servers leave the module as it is until the next Connect
+	if err := d.SetDNS("", "9.9.9.9"); !errors.Is(err, ErrBadParameter) {
+		t.Errorf("expected ErrBadParameter for a secondary server alone, got %v", err)
+	}
+	n := len(modem.commands)
+	if err := d.SetDNS("", ""); err != nil {
+		t.Fatalf("clear DNS failed: %v", err)
+	}
+	if len(modem.commands) != n || d.dnsPrimary != "" {
+		t.Errorf("expected the servers cleared without commands, got %q", modem.commands[n:])
+	}
+}
+
+func TestDevice_LookupHostBadName(t *testing.T) {
+	modem := newMockModem(nil)
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.IP = "10.0.0.1"
+
+	for _, name := range []string{"", "a\"b", "a\r\nAT+CPOWD=1", "a\x00b", "a\x7fb"} {
+		if _, err := d.LookupHost(name); !errors.Is(err, ErrBadParameter) {
+			t.Errorf("%q: expected ErrBadParameter, got %v", name, err)
+		}
+	}
+	if len(modem.commands) != 0 {
+		t.Errorf("expected no commands, got %q", modem.commands)
+	}
+}
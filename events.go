@@ -0,0 +1,64 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains asynchronous event reporting.
+package sim800l
+
+// EventType identifies an asynchronous event reported by the driver
+type EventType uint8
+
+const (
+	EventDataModeEscaped   EventType = iota // Modem unexpectedly entered data mode and was returned to command mode
+	EventIPStackRefreshed                   // A dead PDP context was shut down and brought up again
+	EventOverheating                        // The module temperature reached Config.TemperatureHigh
+	EventTemperatureNormal                  // The module temperature fell back to Config.TemperatureLow
+	EventSessionLost                        // The data session dropped; Err tells why
+	EventSessionRestored                    // SuperviseSession brought the data session up again
+	EventProtocolAnomaly                    // Strict mode hit unexpected output; Err describes it
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventDataModeEscaped:
+		return "DataModeEscaped"
+	case EventIPStackRefreshed:
+		return "IPStackRefreshed"
+	case EventOverheating:
+		return "Overheating"
+	case EventTemperatureNormal:
+		return "TemperatureNormal"
+	case EventSessionLost:
+		return "SessionLost"
+	case EventSessionRestored:
+		return "SessionRestored"
+	case EventProtocolAnomaly:
+		return "ProtocolAnomaly"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event describes something that happened outside the call that observed it
+type Event struct {
+	Type EventType // What happened
+	Err  error     // Error associated with the event, if any
+
+	Temperature float64 // Module temperature in degrees Celsius, for temperature events
+}
+
+// EventHandler is called synchronously from the driver when an event occurs.
+// It must not call back into the Device.
+type EventHandler func(e Event)
+
+// SetEventHandler sets the function called for asynchronous events.
+// Pass nil to stop receiving events.
+func (d *Device) SetEventHandler(fn EventHandler) {
+	d.lock()
+	defer d.unlock()
+	d.eventHandler = fn
+}
+
+// emit passes the event to the event handler, if any
+func (d *Device) emit(e Event) {
+	if d.eventHandler != nil {
+		d.eventHandler(e)
+	}
+}
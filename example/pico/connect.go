@@ -55,6 +55,10 @@ func main() {
 		logger.Error("failed to configure SIM800L device", slog.String("error", err.Error()))
 		return
 	}
+	if err := device.WaitForNetwork(time.Minute); err != nil {
+		logger.Error("SIM800L not registered on a network", slog.String("error", err.Error()))
+		return
+	}
 	logger.Info("GPRS device configured, connecting to network...")
 	if err := device.Connect("internet.vivacom.bg", "VIVACOM", "VIVACOM"); err != nil {
 		logger.Error("failed to initialize SIM800L device", slog.String("error", err.Error()))
@@ -0,0 +1,78 @@
+//go:build tinygo
+
+// Telemetry posts a JSON sensor reading to an HTTP server every minute.
+package main
+
+import (
+	"log/slog"
+	"machine"
+	"time"
+
+	"github.com/m-s-sh/sim800l"
+)
+
+const (
+	server = "telemetry.example.com"
+	path   = "/v1/readings"
+)
+
+func main() {
+	time.Sleep(5 * time.Second) // Wait for the serial port to be ready.
+	logger := slog.New(slog.NewTextHandler(machine.Serial, nil))
+
+	err := machine.UART1.Configure(machine.UARTConfig{
+		TX:       machine.GPIO4,
+		RX:       machine.GPIO5,
+		BaudRate: 9600,
+	})
+	if err != nil {
+		logger.Error("failed to configure UART", "error", err)
+		return
+	}
+
+	device := sim800l.New(machine.UART1, machine.GPIO0, logger)
+	if err := device.Init(); err != nil {
+		logger.Error("failed to initialize SIM800L device", "error", err)
+		return
+	}
+	if err := device.ConnectWithConfig(sim800l.GPRSConfig{
+		APN:                 "internet",
+		RegistrationTimeout: time.Minute,
+	}); err != nil {
+		logger.Error("failed to connect to network", "error", err)
+		return
+	}
+
+	// The reading is formatted into a fixed buffer, so the loop doesn't allocate
+	var storage [64]byte
+	var doc sim800l.JSONBuffer
+	for {
+		doc.Reset(storage[:])
+		doc.BeginObject()
+		doc.IntField("uptime", int64(time.Since(start)/time.Second))
+		doc.FloatField("temperature", readTemperature(), 1)
+		doc.EndObject()
+		if err := doc.Err(); err != nil {
+			logger.Error("failed to encode reading", "error", err)
+			return
+		}
+
+		code, err := device.PostTelemetry(server, path, doc.Bytes())
+		switch {
+		case err != nil:
+			logger.Error("failed to post reading", "error", err)
+		case code/100 != 2:
+			logger.Warn("server rejected reading", "status", code)
+		default:
+			logger.Info("reading posted", "status", code)
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+var start = time.Now()
+
+// readTemperature stands in for a real sensor
+func readTemperature() float64 {
+	return 21.5
+}
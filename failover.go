@@ -0,0 +1,67 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains dialing with failover to alternative addresses.
+package sim800l
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+)
+
+var ErrAllAddressesFailed = errors.New("all addresses failed")
+
+// DialFailover connects to the first of the addresses that answers, trying
+// them in order, so primary and backup servers get failover without each
+// application reinventing it. Host names are resolved with LookupHost and
+// every address they resolve to is tried. Each attempt gives up after
+// timeout, or ConnectTimeout if zero. If all attempts fail the error
+// matches ErrAllAddressesFailed and each attempt's error.
+func (d *Device) DialFailover(network string, addresses []string, timeout time.Duration) (net.Conn, error) {
+	var errs []error
+	for _, address := range addresses {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid address %s: %w", address, err))
+			continue
+		}
+		ips := []string{host}
+		if net.ParseIP(host) == nil {
+			if ips, err = d.LookupHost(host); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", address, err))
+				if !retryable(err) {
+					break
+				}
+				continue
+			}
+		}
+
+		for _, ip := range ips {
+			target := net.JoinHostPort(ip, port)
+			conn, err := d.dialAttempt(network, target, timeout)
+			if err == nil {
+				return conn, nil
+			}
+			d.log(SubsystemData, slog.LevelWarn, "dial failed, trying next address", "address", target, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", target, err))
+			if !retryable(err) {
+				return nil, fmt.Errorf("%w: %w", ErrAllAddressesFailed, errors.Join(errs...))
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %w", ErrAllAddressesFailed, errors.Join(errs...))
+}
+
+// dialAttempt dials one address, bounded by timeout if it isn't zero
+func (d *Device) dialAttempt(network, address string, timeout time.Duration) (net.Conn, error) {
+	if timeout <= 0 {
+		return d.Dial(network, address)
+	}
+	return d.DialTimeout(network, address, timeout)
+}
+
+// retryable reports whether another address may succeed after err
+func retryable(err error) bool {
+	return !errors.Is(err, ErrNoIP) && !errors.Is(err, ErrMaxConn) && !errors.Is(err, ErrDeviceBusy)
+}
@@ -0,0 +1,39 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDevice_DialFailover(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CPAS": "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		// The primary server never answers
+		"AT+CIPSTART=0,\"TCP\",\"10.0.0.9\",\"80\"": "\r\nOK\r\n",
+		"AT+CIPCLOSE=0": "\r\n0, CLOSE OK\r\n",
+		// The backup name resolves to two servers, the first one down
+		"AT+CDNSGIP=\"backup.example.com\"":        "\r\nOK\r\n\r\n+CDNSGIP: 1,\"backup.example.com\",\"1.2.3.4\",\"1.2.3.5\"\r\n",
+		"AT+CIPSTART=0,\"TCP\",\"1.2.3.4\",\"80\"": "\r\nOK\r\n\r\n0, CONNECT FAIL\r\n",
+		"AT+CIPSTART=0,\"TCP\",\"1.2.3.5\",\"80\"": "\r\nOK\r\n\r\n0, CONNECT OK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.IP = "10.0.0.1"
+
+	conn, err := d.DialFailover("tcp", []string{"10.0.0.9:80", "backup.example.com:80"}, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	if c := conn.(*Connection); c.RemoteIP != "1.2.3.5" {
+		t.Errorf("expected a connection to 1.2.3.5, got %s", c.RemoteIP)
+	}
+
+	// Every address fails
+	_ = conn.Close()
+	delete(modem.responses, "AT+CIPSTART=0,\"TCP\",\"1.2.3.5\",\"80\"")
+	_, err = d.DialFailover("tcp", []string{"10.0.0.9:80", "backup.example.com:80", "bad"}, 100*time.Millisecond)
+	if !errors.Is(err, ErrAllAddressesFailed) || !errors.Is(err, ErrCannotConnect) {
+		t.Errorf("expected ErrAllAddressesFailed with each attempt's error, got %v", err)
+	}
+}
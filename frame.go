@@ -0,0 +1,138 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains length-prefixed framing for binary protocols.
+package sim800l
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// EncodeFrame writes payload into dst as one frame, its length as a
+// uvarint followed by the payload, and returns the frame's size. It
+// returns ErrBufferFull if the frame doesn't fit in dst. Write the frame
+// with a single Write so it goes out in one send.
+func EncodeFrame(dst, payload []byte) (int, error) {
+	var header [binary.MaxVarintLen64]byte
+	h := binary.PutUvarint(header[:], uint64(len(payload)))
+	if h+len(payload) > len(dst) {
+		return 0, ErrBufferFull
+	}
+	copy(dst, header[:h])
+	copy(dst[h:], payload)
+	return h + len(payload), nil
+}
+
+// DecodeFrame decodes the frame at the start of src and returns its
+// payload, which aliases src, and the frame's size. If src doesn't hold a
+// whole frame yet it returns a size of zero. A frame longer than max bytes
+// returns ErrFrameTooLarge.
+func DecodeFrame(src []byte, max int) (payload []byte, n int, err error) {
+	length, h := binary.Uvarint(src)
+	if h < 0 || length > uint64(max) {
+		return nil, 0, ErrFrameTooLarge
+	}
+	if h == 0 || uint64(len(src)-h) < length {
+		return nil, 0, nil
+	}
+	end := h + int(length)
+	return src[h:end], end, nil
+}
+
+// FrameReader reads frames written with EncodeFrame from a connection
+// into a caller-provided buffer, without allocating. It waits through
+// ErrWouldBlock and reads split across several notifications, so protocol
+// code only sees whole frames.
+//
+//	var storage [256]byte
+//	var fr sim800l.FrameReader
+//	fr.Reset(conn, storage[:])
+//	msg, err := fr.ReadFrame(time.Now().Add(30 * time.Second))
+type FrameReader struct {
+	r     io.Reader
+	buf   []byte // Received data, never grown
+	start int    // Start of the data not yet returned
+	end   int    // End of the received data
+	max   int    // Largest payload that fits buf with its header
+	err   error  // Error that desynchronized the stream
+}
+
+// Reset discards buffered data and reads frames from r into buf. The
+// largest payload accepted is len(buf) less the size of its header.
+func (f *FrameReader) Reset(r io.Reader, buf []byte) {
+	f.r = r
+	f.buf = buf
+	f.start, f.end = 0, 0
+	f.err = nil
+	f.max = len(buf)
+	for f.max > 0 && f.max+uvarintLen(uint64(f.max)) > len(buf) {
+		f.max--
+	}
+}
+
+// ReadFrame returns the payload of the next frame, waiting for it until
+// the deadline, or without limit if the deadline is zero. The payload is
+// only valid until the next call. A deadline that passes returns
+// ErrDeadlineExceeded and keeps a partly received frame for the next call.
+// A frame larger than the buffer returns ErrFrameTooLarge, as does every
+// later call, since the stream can't be followed past it.
+func (f *FrameReader) ReadFrame(deadline time.Time) ([]byte, error) {
+	for {
+		if f.err != nil {
+			return nil, f.err
+		}
+		payload, n, err := DecodeFrame(f.buf[f.start:f.end], f.max)
+		if err != nil {
+			f.err = err
+			return nil, err
+		}
+		if n > 0 {
+			f.start += n
+			return payload, nil
+		}
+
+		// Make room for the rest of the frame
+		if f.start > 0 {
+			f.end = copy(f.buf, f.buf[f.start:f.end])
+			f.start = 0
+		}
+		n, err = f.read(f.buf[f.end:], deadline)
+		f.end += n
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrWouldBlock):
+			if !deadline.IsZero() && !time.Now().Before(deadline) {
+				return nil, ErrDeadlineExceeded
+			}
+			time.Sleep(readPollInterval)
+		case err == io.EOF:
+			if f.end > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, io.EOF
+		default:
+			return nil, err
+		}
+	}
+}
+
+// read reads from the underlying reader, bounded by the deadline when it
+// is a Connection
+func (f *FrameReader) read(b []byte, deadline time.Time) (int, error) {
+	if c, ok := f.r.(*Connection); ok {
+		return c.ReadInto(b, deadline)
+	}
+	return f.r.Read(b)
+}
+
+// uvarintLen returns the size of x encoded as a uvarint
+func uvarintLen(x uint64) int {
+	n := 1
+	for ; x >= 0x80; x >>= 7 {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,117 @@
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// chunkReader returns its chunks one per read, with ErrWouldBlock in between
+type chunkReader struct {
+	chunks  [][]byte
+	blocked bool
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	if r.blocked = !r.blocked; r.blocked {
+		return 0, ErrWouldBlock
+	}
+	n := copy(p, r.chunks[0])
+	if r.chunks[0] = r.chunks[0][n:]; len(r.chunks[0]) == 0 {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+func TestEncodeDecodeFrame(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 200)
+	var buf [256]byte
+	n, err := EncodeFrame(buf[:], payload)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if n != 202 || buf[0] != 0xc8 || buf[1] != 0x01 {
+		t.Errorf("expected a 2 byte uvarint header, got % x", buf[:2])
+	}
+	if _, err := EncodeFrame(buf[:201], payload); err != ErrBufferFull {
+		t.Errorf("expected ErrBufferFull, got %v", err)
+	}
+
+	got, size, err := DecodeFrame(buf[:n], 256)
+	if err != nil || size != n || !bytes.Equal(got, payload) {
+		t.Errorf("expected the payload back, got %d bytes of %d, %v", len(got), size, err)
+	}
+	if _, size, err := DecodeFrame(buf[:n-1], 256); size != 0 || err != nil {
+		t.Errorf("expected an incomplete frame, got size %d, %v", size, err)
+	}
+	if _, _, err := DecodeFrame(buf[:n], 100); err != ErrFrameTooLarge {
+		t.Errorf("expected ErrFrameTooLarge, got %v", err)
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	var stream []byte
+	var buf [64]byte
+	for _, msg := range []string{"hello", "", "split across reads"} {
+		n, _ := EncodeFrame(buf[:], []byte(msg))
+		stream = append(stream, buf[:n]...)
+	}
+	r := &chunkReader{chunks: [][]byte{stream[:3], stream[3:9], stream[9:]}}
+
+	var storage [32]byte
+	var fr FrameReader
+	fr.Reset(r, storage[:])
+	for _, want := range []string{"hello", "", "split across reads"} {
+		got, err := fr.ReadFrame(time.Time{})
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+	if _, err := fr.ReadFrame(time.Time{}); err != io.EOF {
+		t.Errorf("expected io.EOF at the end of the stream, got %v", err)
+	}
+
+	// Frames larger than the buffer desynchronize the stream
+	n, _ := EncodeFrame(buf[:], bytes.Repeat([]byte("x"), 40))
+	fr.Reset(&chunkReader{chunks: [][]byte{buf[:n]}}, storage[:])
+	for range 2 {
+		if _, err := fr.ReadFrame(time.Time{}); err != ErrFrameTooLarge {
+			t.Errorf("expected ErrFrameTooLarge, got %v", err)
+		}
+	}
+}
+
+func TestFrameReader_Connection(t *testing.T) {
+	modem := newMockModem(nil)
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	conn := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+	d.connections[0] = conn
+
+	var storage [32]byte
+	var fr FrameReader
+	fr.Reset(conn, storage[:])
+
+	// Half a frame arrives before the deadline
+	modem.inject("+RECEIVE,0,4:\r\n\x05hel")
+	if _, err := fr.ReadFrame(time.Now().Add(50 * time.Millisecond)); !errors.Is(err, ErrDeadlineExceeded) {
+		t.Fatalf("expected ErrDeadlineExceeded, got %v", err)
+	}
+
+	modem.inject("+RECEIVE,0,2:\r\nlo")
+	got, err := fr.ReadFrame(time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", got)
+	}
+}
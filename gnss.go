@@ -0,0 +1,147 @@
+//go:build sim800l_gnss
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the GNSS receiver of the SIM808.
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// gnssCompiled is set when the GNSS support is compiled in
+const gnssCompiled = true
+
+var (
+	cmdGNSSOn   = []byte("+CGNSPWR=1") // Power the GNSS receiver on
+	cmdGNSSOff  = []byte("+CGNSPWR=0") // Power the GNSS receiver off
+	cmdGNSSInfo = []byte("+CGNSINF")   // Read the navigation information
+	gnssInfo    = []byte("+CGNSINF")   // Navigation information response key
+)
+
+var ErrNoFix = errors.New("no GNSS fix")
+
+// gnssFields is the number of fields of a +CGNSINF line
+const gnssFields = 21
+
+// Fix is a position reported by the GNSS receiver
+type Fix struct {
+	Time       time.Time // UTC time of the fix
+	Latitude   float64   // Degrees, north positive
+	Longitude  float64   // Degrees, east positive
+	Altitude   float64   // Meters above mean sea level
+	Speed      float64   // Speed over ground in km/h
+	Course     float64   // Course over ground in degrees from true north
+	Satellites int       // Satellites used for the fix
+}
+
+// GNSSPower powers the GNSS receiver on or off. Off it draws no current,
+// and after powering it on the first fix takes from seconds to minutes,
+// depending on how long it was off. Fails with ErrNotSupported on modules
+// without a receiver; the SIM808 is the only one.
+func (d *Device) GNSSPower(on bool) error {
+	d.lock()
+	defer d.unlock()
+
+	if !d.capabilities().GNSS {
+		return fmt.Errorf("%w: no GNSS receiver", ErrNotSupported)
+	}
+	if on {
+		return d.send(cmdGNSSOn)
+	}
+	return d.send(cmdGNSSOff)
+}
+
+// GNSSFix returns the current position. It fails with ErrNoFix while the
+// receiver is off or hasn't found enough satellites yet.
+func (d *Device) GNSSFix() (Fix, error) {
+	d.lock()
+	defer d.unlock()
+
+	if !d.capabilities().GNSS {
+		return Fix{}, fmt.Errorf("%w: no GNSS receiver", ErrNotSupported)
+	}
+	if err := d.sendWithOptions(cmdGNSSInfo, prefixCheck(gnssInfo), DefaultTimeout); err != nil {
+		return Fix{}, err
+	}
+	val, ok := d.parseValue(gnssInfo)
+	if !ok {
+		return Fix{}, ErrUnexpectedResponse
+	}
+	return parseGNSSInfo(val)
+}
+
+// parseGNSSInfo parses the value of a +CGNSINF line like
+// "1,1,20150327014838.000,31.221783,121.354528,114.600,0.28,0.0,1,,1.9,2.1,1.0,,8,4,,,42,,"
+func parseGNSSInfo(v []byte) (Fix, error) {
+	var fields [gnssFields][]byte
+	n := 0
+	for n < len(fields) {
+		i := bytes.IndexByte(v, ',')
+		if i < 0 {
+			fields[n] = v
+			n++
+			break
+		}
+		fields[n], v = v[:i], v[i+1:]
+		n++
+	}
+	if n < 8 {
+		return Fix{}, fmt.Errorf("%w: %d GNSS fields", ErrUnexpectedResponse, n)
+	}
+	if !bytes.Equal(fields[0], []byte("1")) {
+		return Fix{}, fmt.Errorf("%w: receiver off", ErrNoFix)
+	}
+	if !bytes.Equal(fields[1], []byte("1")) {
+		return Fix{}, ErrNoFix
+	}
+
+	var fix Fix
+	var err error
+	if fix.Time, err = parseGNSSTime(fields[2]); err != nil {
+		return Fix{}, err
+	}
+	for i, dst := range [...]*float64{&fix.Latitude, &fix.Longitude, &fix.Altitude, &fix.Speed, &fix.Course} {
+		f := fields[3+i]
+		if len(f) == 0 {
+			continue // Not known yet, e.g. the altitude of a 2D fix
+		}
+		if *dst, err = strconv.ParseFloat(string(f), 64); err != nil {
+			return Fix{}, fmt.Errorf("%w: %q", ErrUnexpectedResponse, f)
+		}
+	}
+	if n > 15 && len(fields[15]) > 0 {
+		fix.Satellites, _ = strconv.Atoi(string(fields[15]))
+	}
+	return fix, nil
+}
+
+// parseGNSSTime parses a UTC time like "20150327014838.000"
+func parseGNSSTime(v []byte) (time.Time, error) {
+	if len(v) < len("yyyyMMddhhmmss") {
+		return time.Time{}, fmt.Errorf("%w: GNSS time %q", ErrUnexpectedResponse, v)
+	}
+	var f [6]int
+	for i := range f {
+		start, end := 2+i*2, 4+i*2
+		if i == 0 {
+			start = 0
+		}
+		n, err := strconv.Atoi(string(v[start:end]))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: GNSS time %q", ErrUnexpectedResponse, v)
+		}
+		f[i] = n
+	}
+	var nsec int
+	if frac := v[len("yyyyMMddhhmmss"):]; len(frac) > 1 && frac[0] == '.' {
+		ms, err := strconv.Atoi(string(frac[1:]))
+		if err == nil && len(frac) == len(".sss") {
+			nsec = ms * int(time.Millisecond)
+		}
+	}
+	return time.Date(f[0], time.Month(f[1]), f[2], f[3], f[4], f[5], nsec, time.UTC), nil
+}
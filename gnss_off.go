@@ -0,0 +1,8 @@
+//go:build !sim800l_gnss
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the stand-in for the GNSS support left out by default.
+package sim800l
+
+// gnssCompiled is unset unless built with the sim800l_gnss tag
+const gnssCompiled = false
@@ -0,0 +1,72 @@
+//go:build sim800l_gnss
+
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestParseGNSSInfo(t *testing.T) {
+	fix, err := parseGNSSInfo([]byte("1,1,20150327014838.250,31.221783,121.354528,114.600,0.28,12.5,1,,1.9,2.1,1.0,,8,4,,,42,,"))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	expected := Fix{
+		Time:       time.Date(2015, time.March, 27, 1, 48, 38, 250*int(time.Millisecond), time.UTC),
+		Latitude:   31.221783,
+		Longitude:  121.354528,
+		Altitude:   114.6,
+		Speed:      0.28,
+		Course:     12.5,
+		Satellites: 4,
+	}
+	if fix != expected {
+		t.Errorf("expected %+v, got %+v", expected, fix)
+	}
+
+	for _, v := range []string{
+		"0,,,,,,,,,,,,,,,,,,,,",
+		"1,0,20150327014838.000,,,,0.00,0.0,0,,,,,,8,0,,,,,",
+	} {
+		if _, err := parseGNSSInfo([]byte(v)); !errors.Is(err, ErrNoFix) {
+			t.Errorf("%q: expected ErrNoFix, got %v", v, err)
+		}
+	}
+	if _, err := parseGNSSInfo([]byte("1,1,2015")); !errors.Is(err, ErrUnexpectedResponse) {
+		t.Errorf("expected ErrUnexpectedResponse, got %v", err)
+	}
+}
+
+func TestDevice_GNSS(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CGNSPWR=1": "\r\nOK\r\n",
+		"AT+CGNSINF":   "\r\n+CGNSINF: 1,1,20240630152354.000,48.137154,11.576124,519.000,3.20,90.0,1,,1.1,1.4,0.9,,11,7,,,40,,\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	// Unknown modules aren't asked
+	if err := d.GNSSPower(true); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+	if len(modem.commands) != 0 {
+		t.Errorf("expected no commands, got %q", modem.commands)
+	}
+
+	d.Model = "SIMCOM_SIM808"
+	if !d.Supports(FeatureGNSS) {
+		t.Error("expected GNSS on a SIM808")
+	}
+	if err := d.GNSSPower(true); err != nil {
+		t.Fatalf("power on failed: %v", err)
+	}
+	fix, err := d.GNSSFix()
+	if err != nil {
+		t.Fatalf("fix failed: %v", err)
+	}
+	if fix.Latitude != 48.137154 || fix.Longitude != 11.576124 || fix.Satellites != 7 || fix.Time.Hour() != 15 {
+		t.Errorf("unexpected fix %+v", fix)
+	}
+}
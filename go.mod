@@ -2,4 +2,7 @@ module github.com/m-s-sh/sim800l
 
 go 1.24.4
 
-require github.com/m-s-sh/mockhw v0.0.2 // indirect
+require (
+	github.com/m-s-sh/mockhw v0.0.2
+	tinygo.org/x/drivers v0.34.0
+)
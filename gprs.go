@@ -4,9 +4,13 @@ package sim800l
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
+	"net/netip"
 	"strconv"
 	"strings"
 	"time"
@@ -14,87 +18,315 @@ import (
 
 // GPRS command constants
 var (
-	cmdGprsAttachQuery  = []byte("+CGATT?")    // Query GPRS attachment status
-	cmdGprsAttach       = []byte("+CGATT=1")   // Attach to GPRS service
-	cmdGprsDetach       = []byte("+CGATT=0")   // Detach from GPRS service
-	cmdMultiConn        = []byte("+CIPMUX=1")  // Enable multi-connection mode
-	cmdStartWireless    = []byte("+CIICR")     // Start wireless connection
-	cmdGetIp            = []byte("+CIFSR")     // Get local IP address
-	cmdShutPdp          = []byte("+CIPSHUT")   // Shut down PDP context
-	cmdConnStatusPrefix = []byte("+CIPSTATUS") // Connection status prefix
-	cmdClipStart        = []byte("+CIPSTART")  // Start connection command
-	cmdClipClose        = []byte("+CIPCLOSE")  // Close connection command
-	cmdClipSend         = []byte("+CIPSEND")   // Send data command
-	cmdCstt             = []byte("+CSTT")      // Set APN command
+	cmdGprsAttachQuery = []byte("+CGATT?")     // Query GPRS attachment status
+	cmdGprsAttach      = []byte("+CGATT=1")    // Attach to GPRS service
+	cmdGprsDetach      = []byte("+CGATT=0")    // Detach from GPRS service
+	cmdMultiConn       = []byte("+CIPMUX=1")   // Enable multi-connection mode
+	cmdStartWireless   = []byte("+CIICR")      // Start wireless connection
+	cmdGetIp           = []byte("+CIFSR")      // Get local IP address
+	cmdShutPdp         = []byte("+CIPSHUT")    // Shut down PDP context
+	cmdConnStatus      = []byte("+CIPSTATUS")  // Query IP state and connection status
+	ipStatePrefix      = []byte("STATE:")      // IP state line of the connection status
+	connStatusPrefix   = []byte("C:")          // Channel line of the connection status
+	cmdClipStart       = []byte("+CIPSTART")   // Start connection command
+	cmdClipClose       = []byte("+CIPCLOSE")   // Close connection command
+	cmdClipSend        = []byte("+CIPSEND")    // Send data command
+	cmdCstt            = []byte("+CSTT")       // Set APN command
+	gprsAttachStatus   = []byte("+CGATT")      // GPRS attachment status response
+	receivePrefix      = []byte("+RECEIVE")    // Received data notification prefix
+	cmdSendAck         = []byte("+CIPACK")     // Query data transmission state
+	cmdQuickSend       = []byte("+CIPQSEND=1") // Confirm sends once the module has the data
+	dataAccepted       = []byte("DATA ACCEPT") // Send confirmation in quick send mode
+	cmdSenderAddress   = []byte("+CIPSRIP=1")  // Report the sender address of received data
+	remoteClosedInfo   = []byte(", CLOSED")    // Follows the ID of a connection closed by the remote host
 )
 
+// flushPollInterval is the time between acknowledgement checks while flushing
+const flushPollInterval = 500 * time.Millisecond
+
+// cipStatusChannels is the number of channels AT+CIPSTATUS reports in
+// multi-connection mode, including the one the driver doesn't use
+const cipStatusChannels = 6
+
+// sendChunkDelay is the pause after each chunk of a write
+const sendChunkDelay = 100 * time.Millisecond
+
+// readPollInterval is the time between checks for received data while a read waits
+const readPollInterval = 10 * time.Millisecond
+
 var (
 	ErrWouldBlock    = errors.New("would block")
 	ErrCannotSend    = errors.New("cannot send data")
 	ErrCannotConnect = errors.New("cannot connect to remote host")
 )
 
+// AuthType selects whether the APN credentials are sent. The module
+// negotiates PAP or CHAP with the network itself.
+type AuthType uint8
+
+const (
+	AuthAuto AuthType = iota // Send user and password if both are set
+	AuthNone                 // Never send user and password, like CarrierQuirks.NoAuth
+)
+
+// GPRSConfig holds the settings of the data session set up by
+// ConnectWithConfig. Zero timeouts keep the defaults. The SIM800L is GSM
+// only, so there is no radio access technology to choose.
+type GPRSConfig struct {
+	APN      string   // Access point name
+	User     string   // APN user name, if any
+	Password string   // APN password, if any
+	Auth     AuthType // Whether the credentials are sent, AuthAuto if zero
+
+	// RegistrationTimeout makes ConnectWithConfig wait for network
+	// registration, like WaitForNetwork, before attaching. Zero doesn't wait.
+	RegistrationTimeout time.Duration
+
+	// ActivateTimeout bounds bringing up the PDP context with AT+CIICR,
+	// DefaultTimeout if zero
+	ActivateTimeout time.Duration
+
+	// DialTimeout replaces the wait of Dial for the remote host during
+	// this connection, over SetConnectTimeout, if not zero. It is kept
+	// with these settings, so the next Connect without it restores the
+	// device's wait.
+	DialTimeout time.Duration
+}
+
+// validate rejects settings that would break the commands they are quoted
+// in: a quote, CR or LF in the APN, user or password
+func (c GPRSConfig) validate() error {
+	for _, f := range [...]struct{ name, val string }{
+		{"APN", c.APN},
+		{"user", c.User},
+		{"password", c.Password},
+	} {
+		if strings.ContainsAny(f.val, "\"\r\n") {
+			return fmt.Errorf("%w: %s", ErrBadParameter, f.name)
+		}
+	}
+	return nil
+}
+
 // Connect establishes a GPRS connection with the specified APN
 // If user and password are empty, they will not be included
 func (d *Device) Connect(apn, user, password string) error {
+	return d.ConnectWithConfig(GPRSConfig{APN: apn, User: user, Password: password})
+}
+
+// ConnectContext is like Connect but gives up when ctx is done. The
+// context is checked between the steps of the sequence, and each command
+// waits at most until its deadline. An abandoned sequence shuts down the
+// half configured PDP context, so the next Connect starts clean.
+func (d *Device) ConnectContext(ctx context.Context, apn, user, password string) error {
+	return d.ConnectWithConfigContext(ctx, GPRSConfig{APN: apn, User: user, Password: password})
+}
+
+// ConnectWithConfig establishes a GPRS connection with the settings in
+// cfg. It is Connect with room for options that don't fit its arguments.
+// An APN, user or password with a quote, CR or LF returns ErrBadParameter
+// before any command is sent.
+func (d *Device) ConnectWithConfig(cfg GPRSConfig) error {
+	return d.ConnectWithConfigContext(context.Background(), cfg)
+}
+
+// ConnectWithConfigContext is ConnectWithConfig bounded by ctx like
+// ConnectContext
+func (d *Device) ConnectWithConfigContext(ctx context.Context, cfg GPRSConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	if cfg.RegistrationTimeout > 0 {
+		if err := d.WaitForNetwork(cfg.RegistrationTimeout); err != nil {
+			return err
+		}
+	}
 
+	d.lock()
+	defer d.unlock()
+	err := d.connect(ctx, cfg)
+	if err != nil && contextExpired(ctx) {
+		d.abandonConnect()
+	}
+	return err
+}
+
+// connect establishes a GPRS connection with the lock held
+func (d *Device) connect(ctx context.Context, cfg GPRSConfig) error {
+	// Remember the settings so a dead PDP context can be brought up again
+	d.gprs = cfg
+	d.sessionUp = true
+
+	// A voice call blocks the data session setup
+	if err := d.checkNotBusy(); err != nil {
+		return err
+	}
+	if err := d.attach(ctx); err != nil {
+		return err
+	}
+	return d.activateContext(ctx, cfg)
+}
+
+// abandonConnect shuts down the PDP context a cancelled Connect left
+// half configured. It isn't bounded by the cancelled context.
+func (d *Device) abandonConnect() {
+	d.log(SubsystemCommand, slog.LevelInfo, "connect abandoned, shutting down PDP context")
+	d.IP = ""
+	d.sessionUp = false
+	if err := d.send(cmdShutPdp); err != nil {
+		d.log(SubsystemCommand, slog.LevelWarn, "failed to shut down PDP context", "error", err)
+	}
+}
+
+// Attach attaches the module to the GPRS service unless it is attached
+// already. Together with ActivateContext it makes up Connect, so custom
+// sequences can e.g. attach early during boot and activate the context
+// lazily before the first Dial.
+func (d *Device) Attach() error {
+	d.lock()
+	defer d.unlock()
+	return d.attach(context.Background())
+}
+
+// attach attaches to the GPRS service with the lock held
+func (d *Device) attach(ctx context.Context) error {
 	// Check if module is attached to GPRS service
-	err := d.send(cmdGprsAttachQuery)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	attached, err := d.attached()
+	if err != nil || attached {
+		return err
+	}
+
+	// If not attached, attach to GPRS service
+	d.log(SubsystemCommand, slog.LevelInfo, "not attached to GPRS, attaching now...")
+	if err := d.sendContext(ctx, cmdGprsAttach, defaultResponseCheck); err != nil {
+		d.log(SubsystemCommand, slog.LevelError, "failed to attach to GPRS", "error", err)
+		return fmt.Errorf("failed to attach to GPRS: %w", err)
+	}
+	return nil
+}
+
+// attached queries whether the module is attached to the GPRS service
+func (d *Device) attached() (bool, error) {
+	err := d.sendWithOptions(cmdGprsAttachQuery, prefixCheck(gprsAttachStatus), DefaultTimeout)
+	if err != nil {
+		return false, fmt.Errorf("failed to check GPRS attachment: %w", err)
+	}
+	val, ok := d.parseValue(gprsAttachStatus)
+	return ok && bytes.Equal(val, []byte("1")), nil
+}
+
+// Detach detaches the module from the GPRS service. Deactivate the PDP
+// context with DeactivateContext first.
+func (d *Device) Detach() error {
+	d.lock()
+	defer d.unlock()
+	return d.detach(context.Background())
+}
+
+// detach detaches from the GPRS service with the lock held
+func (d *Device) detach(ctx context.Context) error {
+	if err := d.sendContext(ctx, cmdGprsDetach, defaultResponseCheck); err != nil {
+		return fmt.Errorf("failed to detach from GPRS: %w", err)
+	}
+	return nil
+}
+
+// ActivateContext brings up the PDP context with the specified APN and
+// stores the local IP address in IP. The module must be attached, see
+// Attach. If user and password are empty, they will not be included. To
+// change the APN, call DeactivateContext first.
+func (d *Device) ActivateContext(apn, user, password string) error {
+	d.lock()
+	defer d.unlock()
+	cfg := d.gprs
+	cfg.APN, cfg.User, cfg.Password = apn, user, password
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	return d.activateContext(context.Background(), cfg)
+}
+
+// activateContext brings up the PDP context with the lock held
+func (d *Device) activateContext(ctx context.Context, cfg GPRSConfig) error {
+	// Remember the settings so a dead PDP context can be brought up again
+	d.gprs = cfg
+	d.sessionUp = true
+	apn, user, password := cfg.APN, cfg.User, cfg.Password
+
+	// Enable multi-connection or single-connection mode
+	err := d.sendContext(ctx, d.connMode(), defaultResponseCheck)
 	if err != nil {
-		return fmt.Errorf("failed to check GPRS attachment: %w", err)
+		return fmt.Errorf("failed to set connection mode: %w", err)
 	}
 
-	// Parse attachment status
-	attached := false
-	if val, ok := d.parseValue(cmdGprsAttach); ok {
-		if bytes.Equal(val, []byte("1")) {
-			attached = true
+	// Announce received data with +IPD, like +RECEIVE in multi-connection mode
+	if d.singleConn {
+		if err := d.sendContext(ctx, cmdIPHeader, defaultResponseCheck); err != nil {
+			return fmt.Errorf("failed to enable IP header: %w", err)
 		}
 	}
 
-	// If not attached, attach to GPRS service
-	if !attached {
-		d.logger.Info("not attached to GPRS, attaching now...")
-		err = d.send(cmdGprsAttach)
-		if err != nil {
-			d.logger.Error("failed to attach to GPRS", "error", err)
-			return fmt.Errorf("failed to attach to GPRS: %w", err)
+	// Let writes return without waiting for the remote host
+	if d.quickSend {
+		if err := d.sendContext(ctx, cmdQuickSend, defaultResponseCheck); err != nil {
+			return fmt.Errorf("failed to enable quick send: %w", err)
 		}
 	}
 
-	// Enable multi-connection mode
-	err = d.send(cmdMultiConn)
-	if err != nil {
-		return fmt.Errorf("failed to enable multi-connection: %w", err)
+	// Tag received data with the address of its sender
+	if d.senderAddress && !d.singleConn {
+		if err := d.sendContext(ctx, cmdSenderAddress, defaultResponseCheck); err != nil {
+			return fmt.Errorf("failed to enable sender address: %w", err)
+		}
+	}
+
+	// Carrier specific steps, e.g. for roaming IoT SIMs
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := d.applyQuirks(apn); err != nil {
+		return fmt.Errorf("failed to apply carrier quirks: %w", err)
+	}
+	if d.quirks.NoAuth || cfg.Auth == AuthNone {
+		user, password = "", ""
 	}
 
 	// Start wireless connection with specified APN
-	cmd := append(d.buffer[:0], cmdCstt...)
+	var buf [MaxCommandSize]byte
+	cmd := append(buf[:0], cmdCstt...)
+	cmd = append(cmd, '=')
 	if user != "" && password != "" {
 		cmd = fmt.Appendf(cmd, "\"%s\",\"%s\",\"%s\"", apn, user, password)
 	} else {
 		cmd = fmt.Appendf(cmd, "\"%s\"", apn)
 	}
 
-	err = d.send(cmd)
+	err = d.sendContext(ctx, cmd, defaultResponseCheck)
 	if err != nil {
 		return fmt.Errorf("failed to set APN: %w", err)
 	}
 
 	// Start wireless connection
-	err = d.send(cmdStartWireless)
+	activateTimeout := cfg.ActivateTimeout
+	if activateTimeout <= 0 {
+		activateTimeout = DefaultTimeout
+	}
+	err = d.sendContextTimeout(ctx, cmdStartWireless, defaultResponseCheck, activateTimeout)
 	if err != nil {
 		return fmt.Errorf("failed to bring up wireless connection: %w", err)
 	}
 
 	// Get local IP address - use custom mode that doesn't expect OK response
-	err = d.sendWithOptions(cmdGetIp, func(buffer []byte) error {
+	err = d.sendContextTimeout(ctx, cmdGetIp, func(buffer []byte) error {
 		// Custom check function to look for valid IP address
-		if buffer[len(buffer)-1] != '\n' {
-			return fmt.Errorf("invalid response format")
+		if bytes.Contains(buffer, errorToken) {
+			return defaultResponseCheck(buffer)
 		}
 		if !bytes.Contains(buffer, []byte(".")) {
-			return fmt.Errorf("no valid IP address found")
+			return fmt.Errorf("%w: no valid IP address found", ErrUnexpectedResponse)
 		}
 		return nil
 	}, DefaultTimeout)
@@ -103,52 +335,129 @@ func (d *Device) Connect(apn, user, password string) error {
 	}
 
 	// Parse IP address response - check all lines for valid IP
-	ip := strings.TrimSpace(string(d.buffer[:d.end]))
+	ip := strings.TrimSpace(string(d.rxBuffer[:d.end]))
 	if net.ParseIP(ip) == nil {
-		d.logger.Error("invalid IP address in all response lines")
+		d.log(SubsystemCommand, slog.LevelError, "invalid IP address in all response lines")
+		if err := d.anomaly("invalid IP address", d.rxBuffer[:d.end]); err != nil {
+			return err
+		}
 	}
 	d.IP = ip
-	return nil
+
+	// Use the configured DNS servers instead of the carrier's
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.applyDNS()
 }
 
-// Disconnect closes the GPRS connection
-func (d *Device) Disconnect() error {
+// DeactivateContext closes all connections and shuts down the PDP
+// context, leaving the module attached to the GPRS service
+func (d *Device) DeactivateContext() error {
+	d.lock()
+	defer d.unlock()
+	return d.deactivateContext(context.Background())
+}
+
+// deactivateContext shuts down the PDP context with the lock held. The
+// session is meant to stay down, so supervisors no longer restore it.
+func (d *Device) deactivateContext(ctx context.Context) error {
+	d.sessionUp = false
+
 	// Close all active connections first
 	for i := 0; i < MaxConnections; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if d.connections[i] != nil {
-			_ = d.CloseConnection(uint8(i))
+			_ = d.closeConnection(uint8(i), contextTimeout(ctx, DefaultTimeout))
 		}
 	}
 
 	// Shut down PDP context
-	err := d.send(cmdShutPdp)
-	if err != nil {
+	if err := d.sendContext(ctx, cmdShutPdp, defaultResponseCheck); err != nil {
 		return fmt.Errorf("failed to shut down PDP context: %w", err)
 	}
 
-	// Detach from GPRS service
-	err = d.send(cmdGprsDetach)
-	if err != nil {
-		return fmt.Errorf("failed to detach from GPRS: %w", err)
-	}
-
 	// Clear IP address
 	d.IP = ""
 
+	// Shutting down the PDP context stopped the server too
+	d.forgetListener()
 	return nil
 }
 
+// Disconnect closes the GPRS connection
+func (d *Device) Disconnect() error {
+	return d.DisconnectContext(context.Background())
+}
+
+// DisconnectContext is like Disconnect but gives up when ctx is done,
+// checking it between closing each connection, shutting down the PDP
+// context and detaching
+func (d *Device) DisconnectContext(ctx context.Context) error {
+	d.lock()
+	defer d.unlock()
+	if err := d.deactivateContext(ctx); err != nil {
+		return err
+	}
+	return d.detach(ctx)
+}
+
 // Dial establishes a connection to the remote host
-// Returns a Connection object that implements the net.Conn interface
+// Returns a Connection object that implements the net.Conn interface.
+// network is "tcp", "udp", or "tls" for a TCP connection encrypted by the
+// module's SSL, on firmware that has it, see FeatureSSL.
 func (d *Device) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialTimeout is like Dial but gives up after timeout, like
+// net.DialTimeout, so callers can fail fast and try another server
+func (d *Device) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
+
+// SetConnectTimeout changes how long Dial waits for the remote host from
+// ConnectTimeout to timeout. Zero restores ConnectTimeout.
+func (d *Device) SetConnectTimeout(timeout time.Duration) {
+	d.lock()
+	defer d.unlock()
+	d.connectTimeout = timeout
+}
+
+// DialContext is like Dial but gives up when ctx is done, instead of
+// waiting up to ConnectTimeout for the remote host. A cancelled attempt
+// is aborted on the module so its connection slot can be reused.
+func (d *Device) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.lock()
+	defer d.unlock()
+
+	conn, err := d.dial(ctx, network, address)
+	if err != nil {
+		// Don't return a typed nil in the interface
+		return nil, err
+	}
+	return conn, nil
+}
+
+// dial establishes a connection with the lock held
+func (d *Device) dial(ctx context.Context, network, address string) (*Connection, error) {
 	// Check if we're connected to GPRS
 	if d.IP == "" {
 		return nil, ErrNoIP
 	}
 
+	// A voice call would make the connection attempt time out
+	if err := d.checkNotBusy(); err != nil {
+		return nil, err
+	}
+
 	// Find available connection slot
 	cid := -1
-	for i := 0; i < MaxConnections; i++ {
+	for i := 0; i < d.connSlots(); i++ {
 		if d.connections[i] == nil {
 			cid = i
 			break
@@ -158,16 +467,28 @@ func (d *Device) Dial(network, address string) (net.Conn, error) {
 	if cid == -1 {
 		return nil, ErrMaxConn
 	}
+	if !d.claimRecvBuffer(uint8(cid)) {
+		return nil, ErrNoReceiveBuffer
+	}
+	defer func() {
+		// Return the buffer if the connection failed
+		if d.connections[cid] == nil {
+			d.releaseRecvBuffer(uint8(cid))
+		}
+	}()
 
 	// Parse network type
 	var connType ConnectionType
+	secure := false
 	switch strings.ToLower(network) {
 	case "tcp":
 		connType = TCP
+	case "tls":
+		connType, secure = TCP, true
 	case "udp":
 		connType = UDP
 	default:
-		return nil, fmt.Errorf("unsupported network type: %s", network)
+		return nil, fmt.Errorf("%w: unsupported network type: %s", ErrBadParameter, network)
 	}
 
 	// Parse address (host:port)
@@ -184,9 +505,12 @@ func (d *Device) Dial(network, address string) (net.Conn, error) {
 		RemoteIP:   host,
 		RemotePort: port,
 		Device:     d,
+		TLS:        secure,
 	}
 
-	// Store the connection
+	if err := d.setSSL(secure); err != nil {
+		return nil, err
+	}
 
 	// Start connection
 	networkType := "TCP"
@@ -194,15 +518,31 @@ func (d *Device) Dial(network, address string) (net.Conn, error) {
 		networkType = "UDP"
 	}
 
-	cmd := fmt.Appendf(d.buffer[:0], "+CIPSTART=%d,\"%s\",\"%s\",\"%s\"",
-		cid, networkType, host, port)
+	var buf [MaxCommandSize]byte
+	cmd := d.appendConnCommand(buf[:0], cmdClipStart, uint8(cid))
+	if d.singleConn {
+		cmd = append(cmd, '=')
+	} else {
+		cmd = append(cmd, ',')
+	}
+	cmd = fmt.Appendf(cmd, "\"%s\",\"%s\",\"%s\"", networkType, host, port)
 
 	err = d.send(cmd)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start connection: %w", err)
 	}
 
-	if err := d.readResponse(cmdClipStart, func(buffer []byte) error {
+	timeout := d.gprs.DialTimeout
+	if timeout <= 0 {
+		timeout = d.connectTimeout
+	}
+	if timeout <= 0 {
+		timeout = ConnectTimeout
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	if err := d.readResponseContext(ctx, cmdClipStart, func(buffer []byte) error {
 		// Custom check function to look for CONNECT OK or ALREADY CONNECT
 		if bytes.Contains(buffer, []byte("CONNECT OK")) {
 			return nil
@@ -214,32 +554,101 @@ func (d *Device) Dial(network, address string) (net.Conn, error) {
 			return nil
 		}
 		return ErrUnexpectedResponse
-	}, ConnectTimeout); err != nil {
+	}, timeout); err != nil {
+		if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrPreempted) || errors.Is(err, ErrTimeout) {
+			d.abortConnection(uint8(cid))
+		}
 		return nil, fmt.Errorf("connection failed: %w", err)
 	}
 
 	// Connection successful
-	conn.state = StateConnected
+	conn.connectedAt = time.Now()
+	conn.setState(StateConnected)
 	d.connections[cid] = conn
 	return conn, nil
 }
 
+// readResponseContext is readResponse that also gives up when ctx is done.
+// Reading only starts once data has arrived so a line isn't cut short.
+func (d *Device) readResponseContext(ctx context.Context, cmd []byte, checkFunc ResponseCheckFunc, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for d.input().Buffered() == 0 {
+		if err := ctx.Err(); err != nil {
+			return d.commandError(err)
+		}
+		if d.preempted() {
+			return d.commandError(ErrPreempted)
+		}
+		if !time.Now().Before(deadline) {
+			// The context's timer may not have fired yet
+			if end, ok := ctx.Deadline(); ok && !time.Now().Before(end) {
+				return d.commandError(context.DeadlineExceeded)
+			}
+			return d.commandError(ErrTimeout)
+		}
+		time.Sleep(readPollInterval)
+	}
+	return d.readResponse(cmd, checkFunc, time.Until(deadline))
+}
+
+// abortConnection stops a connection attempt that is still in progress.
+// The module may answer with an error if the attempt already ended.
+func (d *Device) abortConnection(cid uint8) {
+	var buf [16]byte
+	cmd := d.appendConnCommand(buf[:0], cmdClipClose, cid)
+	if err := d.send(cmd); err != nil {
+		d.log(SubsystemCommand, slog.LevelDebug, "failed to abort connection", "id", cid, "error", err)
+	}
+}
+
 // CloseConnection closes a specific connection by ID
 func (d *Device) CloseConnection(cid uint8) error {
+	d.lock()
+	defer d.unlock()
+	return d.closeConnection(cid, DefaultTimeout)
+}
+
+// connectionClose closes a connection that may have lost its slot already
+// Used internally by the Connection's Close method
+func (d *Device) connectionClose(c *Connection) error {
+	d.lock()
+	defer d.unlock()
+	if d.connections[c.ID] != c {
+		// The remote host or the module closed it and it was read to io.EOF,
+		// or it was closed before; the slot may belong to another connection
+		if c.closed {
+			return ErrConnectionClosed
+		}
+		c.closed = true
+		return nil
+	}
+	return d.closeConnection(c.ID, DefaultTimeout)
+}
+
+// closeConnection closes a connection with the lock held, waiting at most
+// timeout for the module to confirm
+func (d *Device) closeConnection(cid uint8, timeout time.Duration) error {
 	if cid >= MaxConnections || d.connections[cid] == nil {
-		return fmt.Errorf("invalid connection ID: %d", cid)
+		return fmt.Errorf("%w: ID %d", ErrInvalidConnection, cid)
 	}
 
 	conn := d.connections[cid]
-	conn.state = StateClosing
+	conn.closed = true
+	if conn.state == StateClosed {
+		// The remote host already closed it, the module has nothing to close
+		d.releaseConnection(cid)
+		return nil
+	}
+	conn.setState(StateClosing)
 
 	// Send close command
-	cmd := append(d.buffer[:0], cmdClipClose...)
-	cmd = strconv.AppendInt(cmd, int64(cid), 10)
-	err := d.send(cmd)
+	var buf [16]byte
+	cmd := d.appendConnCommand(buf[:0], cmdClipClose, cid)
+	err := d.sendWithOptions(cmd, defaultResponseCheck, timeout)
 
 	// Even if there was an error, mark the connection as closed
-	d.connections[cid] = nil
+	conn.setState(StateClosed)
+	d.releaseConnection(cid)
 
 	if err != nil {
 		return fmt.Errorf("failed to close connection %d: %w", cid, err)
@@ -248,48 +657,231 @@ func (d *Device) CloseConnection(cid uint8) error {
 	return nil
 }
 
-// // GetConnectionStatus returns the status of all connections
-// func (d *Device) GetConnectionStatus() error {
-// 	// CIPSTATUS returns STATE: info and multiple +CIPSTATUS lines that we need to parse
-// 	err := d.sendWithOptions("+CIPSTATUS", func(buffer []byte) bool {
-// 		// Custom check function to look for +CIPSTATUS lines
-// 		return bytes.Contains(buffer, []byte("+CIPSTATUS:"))
-// 	}, DefaultTimeout)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	// Parse connection status
-// 	// for _, line := range resp.Lines {
-// 	// 	if strings.HasPrefix(line, "+CIPSTATUS:") {
-// 	// 		parts := strings.Split(line[11:], ",")
-// 	// 		if len(parts) >= 4 {
-// 	// 			// Parse connection ID
-// 	// 			id, err := strconv.Atoi(strings.TrimSpace(parts[0]))
-// 	// 			if err == nil && id >= 0 && id < MaxConnections {""
-// 	// 				// If we have this connection, update its state
-// 	// 				if d.connections[id] != nil {
-// 	// 					switch strings.Trim(parts[1], "\"") {
-// 	// 					case "TCP", "UDP":
-// 	// 						d.connections[id].State = StateConnected
-// 	// 					case "CLOSED":
-// 	// 						d.connections[id].State = StateClosed
-// 	// 						d.connections[id] = nil
-// 	// 					}
-// 	// 				}
-// 	// 			}
-// 	// 		}
-// 	// 	}
-// 	// }
-
-// 	return nil
-// }
-
-// connectionSend sends data through a connection
-func (d *Device) connectionSend(id uint8, data []byte) (int, error) {
+// GetConnectionStatus queries AT+CIPSTATUS, updates the state of open
+// connections to match what the module reports and returns the status of
+// every connection channel. Connections the module reports as closed are
+// released. The overall IP state is stored in IPStatus.
+func (d *Device) GetConnectionStatus() ([]ConnectionStatus, error) {
+	d.lock()
+	defer d.unlock()
+	return d.connectionStatus()
+}
+
+// connectionStatus queries the connection status with the lock held
+func (d *Device) connectionStatus() ([]ConnectionStatus, error) {
+	// The OK comes first, followed by the state and one line per channel
+	if err := d.send(cmdConnStatus); err != nil {
+		return nil, fmt.Errorf("failed to query connection status: %w", err)
+	}
+	if err := d.readResponse(cmdConnStatus, func(buffer []byte) error {
+		if bytes.HasPrefix(buffer, ipStatePrefix) {
+			return nil
+		}
+		return ErrUnexpectedResponse
+	}, DefaultTimeout); err != nil {
+		return nil, fmt.Errorf("failed to read IP state: %w", err)
+	}
+	d.IPStatus = string(bytes.TrimSpace(d.rxBuffer[len(ipStatePrefix):d.end]))
+	if d.singleConn {
+		// The IP state is the state of the only connection
+		status := singleConnectionStatus([]byte(d.IPStatus), d.connections[0])
+		return []ConnectionStatus{status}, d.reconcileConnection(status)
+	}
+
+	statuses := make([]ConnectionStatus, 0, cipStatusChannels)
+	for len(statuses) < cipStatusChannels {
+		t, err := d.readLine(pendingLineTimeout)
+		if err != nil {
+			// Some states have no channel list
+			if errors.Is(err, ErrTimeout) && len(statuses) == 0 {
+				break
+			}
+			return statuses, fmt.Errorf("failed to read connection status: %w", err)
+		}
+		if t != TokenLine {
+			continue
+		}
+		line := d.rxBuffer[:d.end]
+		if !bytes.HasPrefix(line, connStatusPrefix) {
+			handled, err := d.dispatchInput(line)
+			if err != nil {
+				return statuses, err
+			}
+			if !handled && !d.queueURC(cmdConnStatus, line) {
+				d.log(SubsystemURC, slog.LevelDebug, "discarding unexpected line", "line", line)
+				if err := d.anomaly("unexpected line", line); err != nil {
+					return statuses, err
+				}
+			}
+			continue
+		}
+		status, ok := parseConnectionStatus(line)
+		if !ok {
+			return statuses, ErrUnexpectedResponse
+		}
+		statuses = append(statuses, status)
+		if err := d.reconcileConnection(status); err != nil {
+			return statuses, err
+		}
+	}
+	return statuses, nil
+}
+
+// parseConnectionStatus parses a channel line of AT+CIPSTATUS like
+// C: 0,0,"TCP","93.184.216.34","80","CONNECTED"
+func parseConnectionStatus(line []byte) (ConnectionStatus, bool) {
+	v := bytes.TrimSpace(line[len(connStatusPrefix):])
+	comma := bytes.IndexByte(v, ',')
+	if comma < 0 {
+		return ConnectionStatus{}, false
+	}
+	id, err := strconv.Atoi(string(v[:comma]))
+	if err != nil || id < 0 || id >= cipStatusChannels {
+		return ConnectionStatus{}, false
+	}
+
+	status := ConnectionStatus{
+		ID:         uint8(id),
+		RemoteIP:   string(quotedField(v, 1)),
+		RemotePort: string(quotedField(v, 2)),
+	}
+	if string(quotedField(v, 0)) == "UDP" {
+		status.Type = UDP
+	}
+	switch string(quotedField(v, 3)) {
+	case "INITIAL":
+		status.State = StateInitial
+	case "CONNECTING":
+		status.State = StateConnecting
+	case "CONNECTED":
+		status.State = StateConnected
+	case "REMOTE CLOSING", "CLOSING":
+		status.State = StateClosing
+	case "CLOSED":
+		status.State = StateClosed
+	default:
+		return ConnectionStatus{}, false
+	}
+	return status, true
+}
+
+// reconcileConnection updates the open connection on the channel of status
+// to the state reported by the module
+func (d *Device) reconcileConnection(status ConnectionStatus) error {
+	if status.ID >= MaxConnections || d.connections[status.ID] == nil {
+		return nil
+	}
+	conn := d.connections[status.ID]
+	switch status.State {
+	case StateConnected, StateConnecting, StateClosing:
+		conn.setState(status.State)
+		return nil
+	default:
+		// The module has no connection on this channel any more
+		d.log(SubsystemData, slog.LevelDebug, "connection closed by module", "id", status.ID)
+		conn.dropped = true
+		d.markClosed(status.ID)
+		return d.anomaly("connection closed without notice", []byte(conn.RemoteIP))
+	}
+}
+
+// remoteClosed handles a line like "0, CLOSED", sent when the remote host
+// closes a connection
+func (d *Device) remoteClosed(line []byte) bool {
+	if !bytes.HasSuffix(line, remoteClosedInfo) {
+		return false
+	}
+	id, err := strconv.Atoi(string(line[:len(line)-len(remoteClosedInfo)]))
+	if err != nil || id < 0 || id >= MaxConnections {
+		return false
+	}
+	d.log(SubsystemURC, slog.LevelDebug, "connection closed by remote host", "id", id)
+	d.markClosed(uint8(id))
+	return true
+}
+
+// markClosed marks a connection the module no longer has as closed. Like a
+// socket, it keeps its slot until the data received before the close has
+// been read or it is closed; Read then returns io.EOF.
+func (d *Device) markClosed(id uint8) {
+	conn := d.connections[id]
+	if conn == nil {
+		return
+	}
+	conn.setState(StateClosed)
+	if d.recvBufLengths[id] == 0 {
+		d.releaseConnection(id)
+	}
+}
+
+// releaseConnection frees a connection slot and drops its received data
+func (d *Device) releaseConnection(id uint8) {
+	d.connections[id] = nil
+	d.releaseRecvBuffer(id)
+	d.recvBufLengths[id] = 0
+	d.recvMsgCount[id] = 0
+	d.recvFrom[id] = netip.AddrPort{}
+}
+
+// checkSender counts data on a TCP connection that comes from another
+// host than the one it was opened to. It happens when the driver and the
+// module disagree about which connection uses the slot.
+// It reports whether the data is foreign.
+func (d *Device) checkSender(id uint8, from netip.AddrPort) bool {
+	conn := d.connections[id]
+	if conn == nil {
+		return false
+	}
+	remote, err := netip.ParseAddr(conn.RemoteIP)
+	if err != nil || remote == from.Addr().Unmap() {
+		// A host name can't be compared with the sender
+		return false
+	}
+	conn.foreignData++
+	d.log(SubsystemData, slog.LevelWarn, "data from unexpected sender", "id", id, "remote", conn.RemoteIP, "sender", from)
+	return true
+}
+
+// connectionSend sends data through a connection. Writes on the same
+// connection run one at a time so their chunks don't interleave, while
+// the device is released between chunks so writers on other connections
+// and other operations take turns.
+func (d *Device) connectionSend(c *Connection, data []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	d.lock()
+	defer d.unlock()
+	if d.connections[c.ID] != c {
+		return 0, ErrConnectionClosed
+	}
+	return d.sendChunks(c.ID, data, 0, true)
+}
+
+// sendData sends data through a connection with the lock held
+func (d *Device) sendData(id uint8, data []byte) (int, error) {
+	return d.sendDataUntil(id, data, 0)
+}
+
+// sendDataUntil is sendData that also gives up at until, in Unix
+// nanoseconds, if it comes before the write deadline. Zero leaves the
+// write deadline alone.
+func (d *Device) sendDataUntil(id uint8, data []byte, until int64) (int, error) {
+	return d.sendChunks(id, data, until, false)
+}
+
+// sendChunks sends data in chunks the module accepts, with the lock held.
+// With yield set it releases the lock between chunks and fails with
+// ErrConnectionClosed if the connection is closed meanwhile.
+func (d *Device) sendChunks(id uint8, data []byte, until int64, yield bool) (_ int, err error) {
 	if id >= MaxConnections || d.connections[id] == nil {
-		return 0, fmt.Errorf("invalid connection ID: %d", id)
+		return 0, fmt.Errorf("%w: ID %d", ErrInvalidConnection, id)
 	}
+	conn := d.connections[id]
+	defer func() {
+		if err != nil {
+			conn.sendFailures++
+		}
+	}()
 
 	if len(data) == 0 {
 		return 0, nil
@@ -298,27 +890,62 @@ func (d *Device) connectionSend(id uint8, data []byte) (int, error) {
 	// Maximum size for a single send
 	const maxChunk = 1024
 
+	// Report progress only for large writes
+	onProgress := conn.onProgress
+	if len(data) < ProgressMinSize {
+		onProgress = nil
+	}
+	start := time.Now()
+
 	// Send data in chunks if needed
 	totalSent := 0
+	if yield {
+		defer d.leaveSendQueue(id)
+	}
 	for offset := 0; offset < len(data); offset += maxChunk {
+		if yield {
+			d.takeSendTurn(id)
+			if d.connections[id] != conn {
+				return totalSent, ErrConnectionClosed
+			}
+		}
+		// Let a pending alert have the device between chunks
+		if d.preempted() {
+			return totalSent, ErrPreempted
+		}
+
 		// Calculate chunk size
 		size := len(data) - offset
 		if size > maxChunk {
 			size = maxChunk
 		}
 
+		// Every step of the chunk is bounded by the write deadline
+		deadline := conn.writeDeadline.Load()
+		if until != 0 && (deadline == 0 || until < deadline) {
+			deadline = until
+		}
+		timeout, err := deadlineTimeout(deadline)
+		if err != nil {
+			return totalSent, err
+		}
+
 		// Send command to prepare for data
-		cmd := append(d.buffer[:0], cmdClipSend...)
-		cmd = strconv.AppendInt(cmd, int64(id), 10)
-		cmd = append(cmd, ',')
+		var buf [24]byte
+		cmd := d.appendConnCommand(buf[:0], cmdClipSend, id)
+		if d.singleConn {
+			cmd = append(cmd, '=')
+		} else {
+			cmd = append(cmd, ',')
+		}
 		cmd = strconv.AppendInt(cmd, int64(size), 10)
 		if err := d.sendRaw(cmd); err != nil {
 			return totalSent, err
 		}
 
-		t, err := d.readLine(DefaultTimeout)
+		t, err := d.readLine(timeout)
 		if err != nil {
-			return totalSent, fmt.Errorf("failed to read prompt: %w", err)
+			return totalSent, fmt.Errorf("failed to read prompt: %w", deadlineError(err, deadline))
 		}
 		if t != TokenPrompt {
 			return totalSent, ErrUnexpectedResponse
@@ -335,52 +962,279 @@ func (d *Device) connectionSend(id uint8, data []byte) (int, error) {
 			if bytes.Contains(buffer, []byte("SEND OK")) {
 				return nil
 			}
+			// In quick send mode the module confirms it took the data
+			if bytes.HasPrefix(buffer, dataAccepted) {
+				return nil
+			}
 			if bytes.Contains(buffer, []byte("SEND FAIL")) {
 				return ErrCannotSend
 			}
 			return ErrUnexpectedResponse
-		}, DefaultTimeout); err != nil {
-			return totalSent, err
+		}, timeout); err != nil {
+			if errors.Is(err, ErrCannotSend) {
+				conn.setState(StateError)
+			}
+			return totalSent, deadlineError(err, deadline)
 		}
 
+		d.logPayload("data sent", id, data[offset:offset+size])
 		totalSent += size
-		// Small delay between chunks
-		time.Sleep(100 * time.Millisecond)
+		conn.bytesSent += uint64(size)
+		conn.lastActivity = time.Now()
+		if onProgress != nil {
+			onProgress(sendProgress(totalSent, len(data), time.Since(start)))
+		}
+		if !yield {
+			// Small delay between chunks
+			time.Sleep(sendChunkDelay)
+			continue
+		}
+		if totalSent < len(data) {
+			// Let writers on other connections send their chunk meanwhile
+			d.passSendTurn(id)
+			d.unlock()
+			time.Sleep(sendChunkDelay)
+			d.lock()
+		}
 	}
 	return totalSent, nil
 }
 
+// connectionAcks returns the acknowledged and unacknowledged bytes of a connection
+// Used internally by the Connection's Acked and Unacked methods
+func (d *Device) connectionAcks(id uint8) (acked, unacked int, err error) {
+	d.lock()
+	defer d.unlock()
+	return d.sendAcks(id)
+}
+
+// unacked returns the number of bytes sent on a connection that the
+// remote host has not acknowledged yet
+func (d *Device) unacked(id uint8) (int, error) {
+	_, n, err := d.sendAcks(id)
+	return n, err
+}
+
+// sendAcks returns the number of bytes sent on a connection that the
+// remote host has and hasn't acknowledged, as reported by AT+CIPACK
+func (d *Device) sendAcks(id uint8) (acked, unacked int, err error) {
+	if id >= MaxConnections || d.connections[id] == nil {
+		return 0, 0, fmt.Errorf("%w: ID %d", ErrInvalidConnection, id)
+	}
+
+	var buf [16]byte
+	cmd := d.appendConnCommand(buf[:0], cmdSendAck, id)
+	err = d.sendWithOptions(cmd, func(buffer []byte) error {
+		if bytes.HasPrefix(buffer, cmdSendAck) {
+			return nil
+		}
+		return defaultResponseCheck(buffer)
+	}, DefaultTimeout)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Format: +CIPACK: <txlen>,<acklen>,<nacklen>
+	val, ok := d.parseValue(cmdSendAck)
+	var lengths [3]int
+	if !ok || parseInts(val, lengths[:]) != len(lengths) {
+		return 0, 0, ErrUnexpectedResponse
+	}
+	return lengths[1], lengths[2], nil
+}
+
+// flushConnection waits until the remote host has acknowledged everything
+// sent on a connection, or the deadline passes
+func (d *Device) flushConnection(id uint8, deadline time.Time) error {
+	for {
+		// Lock per query only, so other goroutines can use the
+		// device while we wait
+		_, n, err := d.connectionAcks(id)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		if time.Until(deadline) < flushPollInterval {
+			return ErrTimeout
+		}
+		time.Sleep(flushPollInterval)
+	}
+}
+
+// Flush waits until the remote hosts have acknowledged all data sent on
+// every open connection, or the deadline passes. Call it before an
+// intentional power-down so telemetry isn't lost.
+func (d *Device) Flush(deadline time.Time) error {
+	// Snapshot the open connections, flushConnection takes the lock itself
+	var open [MaxConnections]bool
+	d.lock()
+	for i, conn := range d.connections {
+		open[i] = conn != nil && conn.state == StateConnected
+	}
+	d.unlock()
+
+	for i := 0; i < MaxConnections; i++ {
+		if !open[i] {
+			continue
+		}
+		if err := d.flushConnection(uint8(i), deadline); err != nil {
+			return fmt.Errorf("failed to flush connection %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// sendProgress computes the progress of a write after elapsed time
+func sendProgress(sent, total int, elapsed time.Duration) SendProgress {
+	p := SendProgress{Sent: sent, Total: total}
+	if elapsed > 0 {
+		p.Throughput = int(int64(sent) * int64(time.Second) / int64(elapsed))
+	}
+	if p.Throughput > 0 {
+		p.ETA = time.Duration(int64(total-sent) * int64(time.Second) / int64(p.Throughput))
+	}
+	return p
+}
+
 // connectionRead implements reading data from a specific connection
-// Used internally by the Connection's Read method
-func (d *Device) connectionRead(id uint8, b []byte) (int, error) {
-	// Check if there's data available in the buffer
-	if d.recvBufLengths[id] == 0 {
-		// Try to check for new data from the device
-		err := d.checkForReceivedData(DefaultTimeout)
-		if err != nil && err != ErrTimeout {
-			// Non-blocking, just log the error
-			d.logger.Debug("error checking for data", "error", err)
+// Used internally by the Connection's Read and ReadInto methods. A non-zero
+// until, in Unix nanoseconds, replaces the connection's read deadline.
+func (d *Device) connectionRead(conn *Connection, b []byte, until int64) (int, error) {
+	n, _, err := d.connectionReadFrom(conn, b, until)
+	return n, err
+}
+
+// connectionReadFrom is connectionRead that also returns the sender of the
+// data, if the module reported it
+func (d *Device) connectionReadFrom(conn *Connection, b []byte, until int64) (int, netip.AddrPort, error) {
+	id := conn.ID
+	d.lock()
+	blocking, readTimeout := d.blockingRead || conn.blocking.Load(), d.readTimeout
+	d.unlock()
+
+	// Without a read deadline the wait is bounded by the read mode
+	limit, expired := time.Now().Add(DefaultTimeout), ErrWouldBlock
+	if blocking {
+		limit, expired = time.Time{}, ErrDeadlineExceeded
+		if readTimeout > 0 {
+			limit = time.Now().Add(readTimeout)
+		}
+	}
+	for {
+		d.lock()
+		if d.connections[id] != conn {
+			d.unlock()
+			return 0, netip.AddrPort{}, io.EOF
+		}
+		// The deadline is read on every pass as it may be changed while we wait
+		deadline := until
+		if deadline == 0 {
+			deadline = conn.readDeadline.Load()
 		}
+		if deadline != 0 && time.Now().UnixNano() >= deadline {
+			d.unlock()
+			return 0, netip.AddrPort{}, ErrDeadlineExceeded
+		}
+
+		// Check if there's data available in the buffer
+		if d.recvBufLengths[id] == 0 && conn.state == StateConnected && !d.streaming(id) {
+			// Try to check for new data from the device
+			if err := d.poll(); err != nil {
+				// Non-blocking, just log the error
+				d.log(SubsystemData, slog.LevelDebug, "error checking for data", "error", err)
+			}
+		}
+		n, from := 0, netip.AddrPort{}
+		if d.recvBufLengths[id] > 0 {
+			n, from = d.takeReceived(id, b)
+		} else if d.streaming(id) {
+			// Data that didn't fit the receive buffer comes straight from the UART
+			var err error
+			if n, err = d.readStream(id, b); err != nil {
+				d.unlock()
+				return 0, netip.AddrPort{}, fmt.Errorf("failed to read data for connection %d: %w", id, err)
+			}
+		}
+		if n > 0 {
+			tee := conn.tee
+			d.unlock()
+			if tee != nil {
+				// Outside the lock, so the writer may use the device
+				if _, err := tee.Write(b[:n]); err != nil {
+					d.log(SubsystemData, slog.LevelDebug, "tee write failed", "id", id, "error", err)
+				}
+			}
+			return n, from, nil
+		}
+		if conn.state == StateClosed && !d.streaming(id) {
+			// Everything received before the remote host closed it was read
+			d.releaseConnection(id)
+			d.unlock()
+			return 0, netip.AddrPort{}, io.EOF
+		}
+		d.unlock()
 
-		// If still no data, return would-block error
-		if d.recvBufLengths[id] == 0 {
-			return 0, ErrWouldBlock
+		if deadline == 0 && !limit.IsZero() && !time.Now().Before(limit) {
+			return 0, netip.AddrPort{}, expired
 		}
+		// Wait unlocked so other goroutines can use the device
+		time.Sleep(readPollInterval)
+	}
+}
+
+// takeReceived moves buffered data of a connection into b and returns the
+// sender of the data, if the module reported it
+func (d *Device) takeReceived(id uint8, b []byte) (int, netip.AddrPort) {
+	// UDP connections return exactly one datagram per read
+	if d.isMessageOriented(id) {
+		return d.readDatagram(id, b)
 	}
 
 	// Copy data from receive buffer to the provided buffer
 	n := copy(b, d.recvBuffers[id][:d.recvBufLengths[id]])
+	d.consumeReceived(id, n)
+	return n, d.recvFrom[id]
+}
+
+// isMessageOriented reports whether reads on the connection preserve
+// the boundaries of each +RECEIVE notification
+func (d *Device) isMessageOriented(id uint8) bool {
+	conn := d.connections[id]
+	return conn != nil && conn.Type == UDP
+}
 
+// readDatagram copies the oldest queued datagram into b.
+// If b is too small the rest of the datagram is discarded, like a UDP socket does.
+func (d *Device) readDatagram(id uint8, b []byte) (int, netip.AddrPort) {
+	size := d.recvBufLengths[id]
+	from := d.recvFrom[id]
+	if d.recvMsgCount[id] > 0 {
+		size = d.recvMsgLengths[id][0]
+		from = d.recvMsgFrom[id][0]
+		// Drop the datagram from the queue
+		copy(d.recvMsgLengths[id][:], d.recvMsgLengths[id][1:d.recvMsgCount[id]])
+		copy(d.recvMsgFrom[id][:], d.recvMsgFrom[id][1:d.recvMsgCount[id]])
+		d.recvMsgCount[id]--
+	}
+
+	n := copy(b, d.recvBuffers[id][:size])
+	d.consumeReceived(id, size)
+	return n, from
+}
+
+// consumeReceived removes n bytes from the front of a connection's receive buffer
+func (d *Device) consumeReceived(id uint8, n int) {
 	// If we read all data, reset the buffer
 	if n >= d.recvBufLengths[id] {
 		d.recvBufLengths[id] = 0
-	} else {
-		// Otherwise, shift remaining data to the beginning of the buffer
-		copy(d.recvBuffers[id][:], d.recvBuffers[id][n:d.recvBufLengths[id]])
-		d.recvBufLengths[id] -= n
+		d.recvMsgCount[id] = 0
+		return
 	}
-
-	return n, nil
+	// Otherwise, shift remaining data to the beginning of the buffer
+	copy(d.recvBuffers[id][:], d.recvBuffers[id][n:d.recvBufLengths[id]])
+	d.recvBufLengths[id] -= n
 }
 
 // checkForReceivedData checks for any new data received on any connection
@@ -388,72 +1242,127 @@ func (d *Device) connectionRead(id uint8, b []byte) (int, error) {
 func (d *Device) checkForReceivedData(timeout time.Duration) error {
 	deadline := time.Now().Add(timeout)
 
-	// State machine variables
-	const (
-		stateStart = iota // 0=start looking for +RECEIVE, 1=reading data
-		stateFound        // 1=reading data directly
-	)
-	state := stateStart
-	cid := -1       // Current connection ID
-	dataLength := 0 // Expected data length
-
 	for time.Since(deadline) < 0 {
-		switch state {
-		case stateStart: // Looking for +RECEIVE notification
-			// Try to find +RECEIVE
+		// Try to find +RECEIVE
+		t, err := d.readLine(DefaultTimeout)
+		if err != nil {
+			return err
+		}
+		if t != TokenLine {
+			return fmt.Errorf("%w: token type %v", ErrUnexpectedResponse, t)
+		}
+		line := d.rxBuffer[:d.end]
 
-			t, err := d.readLine(DefaultTimeout)
-			if err != nil {
-				return err
-			}
-			if t != TokenLine {
-				return fmt.Errorf("unexpected token type: %v", t)
-			}
-			line := (d.buffer[:d.end])
-			parts := bytes.Split(line, []byte(","))
-			if len(parts) < 3 || !bytes.HasPrefix(parts[0], []byte("+RECEIVE")) {
-				return fmt.Errorf("invalid +RECEIVE format: %s", line)
-			}
+		// Unsolicited messages may arrive before the data
+		if !d.isDataNotice(line) && d.dispatchURC(line) {
+			continue
+		}
+		return d.receiveData(line, deadline)
+	}
+	return ErrTimeout
+}
 
-			// Parse connection ID
-			cid, err = strconv.Atoi(string(bytes.TrimSpace(parts[1])))
-			if err != nil || cid < 0 || cid >= MaxConnections {
-				return fmt.Errorf("invalid connection ID in +RECEIVE: %s", parts[1])
-			}
-			// Parse data length
-			end := bytes.Index(parts[2], []byte(":"))
-			if end < 0 {
-				return fmt.Errorf("invalid +RECEIVE format, missing data length: %s", parts[2])
-			}
-			dataLength, err = strconv.Atoi(string(parts[2][:end])) // Remove trailing :
-			// Check if data length is valid
-			if err != nil || dataLength <= 0 {
-				return fmt.Errorf("invalid data length in +RECEIVE: %s", parts[2])
-			}
-			if dataLength > MaxBufferSize {
-				return fmt.Errorf("data length exceeds maximum buffer size: %d", dataLength)
-			}
-			state = 1 // Move to reading data state
-			// Reset the receive buffer for this connection
-			d.recvBufLengths[cid] = 0
+// receiveData parses a +RECEIVE notification line, or +IPD in
+// single-connection mode, and reads the data that follows it into the
+// connection's receive buffer
+func (d *Device) receiveData(line []byte, deadline time.Time) error {
+	if d.singleConn && bytes.HasPrefix(line, ipdPrefix) {
+		return d.receiveIPD(line, deadline)
+	}
+	parts := bytes.Split(line, []byte(","))
+	if len(parts) < 3 || !bytes.HasPrefix(parts[0], receivePrefix) {
+		return fmt.Errorf("%w: invalid +RECEIVE format: %s", ErrUnexpectedResponse, line)
+	}
 
-		case stateFound: // Reading data directly
-			// Read to to the expected data length
-			n, err := d.uart.Read(d.buffer[:])
-			if err != nil {
-				return fmt.Errorf("failed to read data for connection %d: %w", cid, err)
-			}
-			n = min(n, dataLength)
-			// copy the data to the receive buffer and if are not done read one more time
-			if n > 0 {
-				n = copy(d.recvBuffers[cid][d.recvBufLengths[cid]:], d.buffer[:n])
-				d.recvBufLengths[cid] += n
-				dataLength -= n
+	// Parse connection ID
+	cid, err := strconv.Atoi(string(bytes.TrimSpace(parts[1])))
+	if err != nil || cid < 0 || cid >= MaxConnections {
+		return fmt.Errorf("%w: invalid connection ID in +RECEIVE: %s", ErrUnexpectedResponse, parts[1])
+	}
+	// Parse data length
+	end := bytes.Index(parts[2], []byte(":"))
+	var from netip.AddrPort
+	if len(parts) > 3 {
+		// With AT+CIPSRIP=1 the sender follows: +RECEIVE,<id>,<length>,<ip>:<port>
+		end = len(parts[2])
+		from, err = netip.ParseAddrPort(string(bytes.TrimSuffix(bytes.TrimSpace(parts[3]), []byte(":"))))
+		if err != nil {
+			return fmt.Errorf("%w: invalid sender address in +RECEIVE: %s", ErrUnexpectedResponse, parts[3])
+		}
+	}
+	if end < 0 {
+		return fmt.Errorf("%w: invalid +RECEIVE format, missing data length: %s", ErrUnexpectedResponse, parts[2])
+	}
+	dataLength, err := strconv.Atoi(string(parts[2][:end])) // Remove trailing :
+	// Check if data length is valid
+	if err != nil || dataLength <= 0 {
+		return fmt.Errorf("%w: invalid data length in +RECEIVE: %s", ErrUnexpectedResponse, parts[2])
+	}
+	return d.receivePayload(uint8(cid), dataLength, from, deadline)
+}
+
+// receivePayload reads dataLength bytes of data announced for connection
+// cid, sent by from if known, into the connection's receive buffer. TCP
+// data that doesn't fit is left on the UART as the connection's stream,
+// read by Read straight into the caller's buffer.
+func (d *Device) receivePayload(cid uint8, dataLength int, from netip.AddrPort, deadline time.Time) error {
+	if d.connections[cid] == nil {
+		// Nobody can read it, skip it so the module's output stays in step
+		d.streamID, d.streamLeft = cid, dataLength
+		if err := d.drainStream(deadline); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: discarded data for connection %d", ErrInvalidConnection, cid)
+	}
+	if !d.claimRecvBuffer(cid) {
+		return fmt.Errorf("%w: no receive buffer for connection %d", ErrBufferFull, cid)
+	}
+	free := RecvBufSize - d.recvBufLengths[cid]
+	// Remember the datagram boundary before the data arrives
+	foreign := false
+	if d.isMessageOriented(cid) {
+		// Datagrams are kept whole
+		if dataLength > free {
+			return fmt.Errorf("%w: receive buffer of connection %d", ErrBufferFull, cid)
+		}
+		if d.recvMsgCount[cid] >= MaxDatagrams {
+			return fmt.Errorf("%w: too many queued datagrams for connection %d", ErrBufferFull, cid)
+		}
+		d.recvMsgLengths[cid][d.recvMsgCount[cid]] = dataLength
+		d.recvMsgFrom[cid][d.recvMsgCount[cid]] = from
+		d.recvMsgCount[cid]++
+	} else if from.IsValid() {
+		d.recvFrom[cid] = from
+		foreign = d.checkSender(cid, from)
+	}
+
+	start := d.recvBufLengths[cid]
+	size := min(dataLength, free)
+	for time.Since(deadline) < 0 {
+		// Read no more than the expected data length, anything after it
+		// belongs to the stream or the next notification
+		n, err := d.input().Read(d.recvBuffers[cid][d.recvBufLengths[cid] : start+size])
+		if err != nil {
+			return fmt.Errorf("failed to read data for connection %d: %w", cid, err)
+		}
+		if n > 0 {
+			d.recvBufLengths[cid] += n
+			conn := d.connections[cid]
+			conn.bytesReceived += uint64(n)
+			conn.lastActivity = time.Now()
+		}
+		// Check if we have read enough data
+		if d.recvBufLengths[cid] == start+size {
+			d.logPayload("data received", cid, d.recvBuffers[cid][start:d.recvBufLengths[cid]])
+			if size < dataLength {
+				d.log(SubsystemData, slog.LevelDebug, "streaming received data", "id", cid, "length", dataLength-size)
+				d.streamID, d.streamLeft = cid, dataLength-size
 			}
-			// Check if we have read enough data
-			if dataLength <= 0 {
-				return nil // Successfully read all expected data
+			if foreign {
+				// Reported once the data is off the UART, so it stays in step
+				return d.anomaly("data from unexpected sender", from.AppendTo(nil))
 			}
+			return nil // Successfully read all expected data
 		}
 	}
 	return ErrTimeout
@@ -3,7 +3,11 @@ package sim800l
 import (
 	"bytes"
 	"context"
+	"errors"
+	"io"
 	"log/slog"
+	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -120,11 +124,13 @@ func Test_checkForReceivedData(t *testing.T) {
 		uart := mockhw.NewUART(1000) // 1 second max delay
 		uart.SetRxBuffer(tc.inputData)
 		t.Run(tc.name, func(t *testing.T) {
+			if tc.setupBuffers {
+				slot(t, tc.connectionID)
+			}
 			d := Device{
 				uart:           uart,
 				logger:         slog.New(&MockHandler{t: t}),
 				connections:    [MaxConnections]*Connection{},
-				recvBuffers:    [MaxConnections][1024]byte{},
 				recvBufLengths: [MaxConnections]int{},
 			}
 
@@ -183,3 +189,542 @@ func compare(t *testing.T, a, b []byte, size int) bool {
 	}
 	return true
 }
+
+func Test_connectionReadDatagrams(t *testing.T) {
+	s2 := slot(t, 2)
+	uart := mockhw.NewUART(0)
+	uart.SetRxBuffer([]byte("+RECEIVE,2,5:\r\nfirst+RECEIVE,2,6:\r\nsecond"))
+	d := Device{
+		uart:   uart,
+		logger: slog.New(&MockHandler{t: t}),
+	}
+	d.connections[s2] = &Connection{ID: 2, Type: UDP, Device: &d, state: StateConnected}
+
+	// Queue both datagrams before reading
+	for i := 0; i < 2; i++ {
+		if err := d.checkForReceivedData(time.Second); err != nil {
+			t.Fatalf("failed to check for received data: %v", err)
+		}
+	}
+	if d.recvMsgCount[s2] != 2 {
+		t.Fatalf("expected 2 queued datagrams, got %d", d.recvMsgCount[s2])
+	}
+
+	buf := make([]byte, 64)
+	for _, want := range []string{"first", "second"} {
+		n, err := d.connectionRead(d.connections[s2], buf, 0)
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if string(buf[:n]) != want {
+			t.Errorf("expected datagram %q, got %q", want, buf[:n])
+		}
+	}
+
+	// A short buffer truncates the datagram and drops the rest of it
+	uart.SetRxBuffer([]byte("+RECEIVE,2,9:\r\ntruncated"))
+	n, err := d.connectionRead(d.connections[s2], buf[:5], 0)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(buf[:n]) != "trunc" {
+		t.Errorf("expected truncated datagram %q, got %q", "trunc", buf[:n])
+	}
+	if d.recvBufLengths[s2] != 0 || d.recvMsgCount[s2] != 0 {
+		t.Errorf("expected empty receive queue, got %d bytes in %d datagrams",
+			d.recvBufLengths[s2], d.recvMsgCount[s2])
+	}
+}
+
+func Test_connectionSendProgress(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CIPSEND=0,1024": "\r\n> ",
+		"AT+CIPSEND=0,452":  "\r\n> ",
+	})
+	modem.dataReply = "\r\n0, SEND OK\r\n"
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	conn := &Connection{ID: 0, Type: TCP, Device: d, state: StateConnected}
+	d.connections[0] = conn
+
+	var reports []SendProgress
+	conn.OnProgress(func(p SendProgress) {
+		reports = append(reports, p)
+	})
+
+	n, err := conn.Write(bytes.Repeat([]byte("x"), 2500))
+	if err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if n != 2500 {
+		t.Fatalf("expected 2500 bytes written, got %d", n)
+	}
+
+	if len(reports) != 3 {
+		t.Fatalf("expected 3 progress reports, got %d", len(reports))
+	}
+	for i, sent := range []int{1024, 2048, 2500} {
+		if reports[i].Sent != sent || reports[i].Total != 2500 {
+			t.Errorf("report %d: expected %d/2500, got %d/%d", i, sent, reports[i].Sent, reports[i].Total)
+		}
+	}
+	if last := reports[2]; last.ETA != 0 || last.Throughput <= 0 {
+		t.Errorf("expected finished write with throughput, got %+v", last)
+	}
+
+	// Small writes do not report progress
+	reports = nil
+	modem.responses["AT+CIPSEND=0,5"] = "\r\n> "
+	if _, err := conn.Write([]byte("small")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if len(reports) != 0 {
+		t.Errorf("expected no progress for small write, got %d reports", len(reports))
+	}
+}
+
+func Test_sendProgress(t *testing.T) {
+	p := sendProgress(1000, 3000, 2*time.Second)
+	if p.Throughput != 500 {
+		t.Errorf("expected 500 B/s, got %d", p.Throughput)
+	}
+	if p.ETA != 4*time.Second {
+		t.Errorf("expected 4s ETA, got %v", p.ETA)
+	}
+}
+
+func Test_connectionFlush(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CIPACK=0": "\r\n+CIPACK: 120,100,20\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	conn := &Connection{ID: 0, Type: TCP, Device: d, state: StateConnected}
+	d.connections[0] = conn
+
+	n, err := conn.Unacked()
+	if err != nil {
+		t.Fatalf("failed to query unacked bytes: %v", err)
+	}
+	if n != 20 {
+		t.Errorf("expected 20 unacked bytes, got %d", n)
+	}
+
+	// The remote host never acknowledges the rest
+	if err := d.Flush(time.Now().Add(time.Second)); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+
+	modem.responses["AT+CIPACK=0"] = "\r\n+CIPACK: 120,120,0\r\n\r\nOK\r\n"
+	if err := conn.Flush(time.Now().Add(time.Second)); err != nil {
+		t.Errorf("expected flush to complete, got %v", err)
+	}
+}
+
+func Test_parseInts(t *testing.T) {
+	var dst [3]int
+	if n := parseInts([]byte("120, 100,20"), dst[:]); n != 3 || dst != [3]int{120, 100, 20} {
+		t.Errorf("expected [120 100 20], got %d values %v", n, dst)
+	}
+	if n := parseInts([]byte("5,\"x\",7"), dst[:]); n != 1 {
+		t.Errorf("expected parsing to stop at the first non-number, got %d values", n)
+	}
+}
+
+func TestDevice_DialContextCancelled(t *testing.T) {
+	// The remote host never answers the connection attempt
+	modem := newMockModem(map[string]string{
+		"AT+CPAS": "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		"AT+CIPSTART=0,\"TCP\",\"example.com\",\"80\"": "\r\nOK\r\n",
+		"AT+CIPCLOSE=0": "\r\n0, CLOSE OK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.IP = "10.0.0.1"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := d.DialContext(ctx, "tcp", "example.com:80")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("dial returned after %v, long past the deadline", elapsed)
+	}
+	if last := modem.commands[len(modem.commands)-1]; last != "AT+CIPCLOSE=0" {
+		t.Errorf("expected the attempt to be aborted, last command was %q", last)
+	}
+	if d.connections[0] != nil {
+		t.Error("connection slot 0 still in use")
+	}
+}
+
+func TestDevice_QuickSend(t *testing.T) {
+	modem := newSessionModem()
+	modem.responses["AT+CIPQSEND=1"] = "\r\nOK\r\n"
+	modem.responses["AT+CIPACK=0"] = "\r\n+CIPACK: 4,0,4\r\n\r\nOK\r\n"
+	modem.dataReply = "\r\nDATA ACCEPT:0,4\r\n"
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.SetLogLevel(SubsystemCommand, slog.LevelWarn)
+	d.SetLogLevel(SubsystemURC, slog.LevelWarn)
+	d.Configure(Config{QuickSend: true})
+
+	if err := d.Connect("internet", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	enabled := false
+	for _, cmd := range modem.commands {
+		enabled = enabled || cmd == "AT+CIPQSEND=1"
+	}
+	if !enabled {
+		t.Errorf("expected quick send to be enabled, commands %q", modem.commands)
+	}
+
+	c, err := d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	conn := c.(*Connection)
+	if n, err := conn.Write([]byte("ping")); err != nil || n != 4 {
+		t.Fatalf("write returned %d, %v", n, err)
+	}
+
+	// The module has the data, the remote host hasn't acknowledged it yet
+	if n, err := conn.Acked(); err != nil || n != 0 {
+		t.Errorf("expected 0 acknowledged bytes, got %d, %v", n, err)
+	}
+	if n, err := conn.Unacked(); err != nil || n != 4 {
+		t.Errorf("expected 4 unacknowledged bytes, got %d, %v", n, err)
+	}
+
+	modem.responses["AT+CIPACK=0"] = "\r\n+CIPACK: 4,4,0\r\n\r\nOK\r\n"
+	if err := conn.Flush(time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if n, err := conn.Acked(); err != nil || n != 4 {
+		t.Errorf("expected 4 acknowledged bytes, got %d, %v", n, err)
+	}
+}
+
+func TestDevice_GetConnectionStatus(t *testing.T) {
+	s1 := slot(t, 1)
+	modem := newMockModem(map[string]string{
+		"AT+CIPSTATUS": "\r\nOK\r\n\r\nSTATE: IP PROCESSING\r\n\r\n" +
+			"C: 0,0,\"TCP\",\"93.184.216.34\",\"80\",\"CONNECTED\"\r\n" +
+			"C: 1,0,\"UDP\",\"10.0.0.53\",\"53\",\"CLOSED\"\r\n" +
+			"C: 2,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 3,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 4,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 5,,\"\",\"\",\"\",\"INITIAL\"\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	open := &Connection{ID: 0, Type: TCP, state: StateConnecting, Device: d}
+	closed := &Connection{ID: 1, Type: UDP, state: StateConnected, Device: d}
+	d.connections[0], d.connections[s1] = open, closed
+
+	statuses, err := d.GetConnectionStatus()
+	if err != nil {
+		t.Fatalf("status query failed: %v", err)
+	}
+	if d.IPStatus != "IP PROCESSING" {
+		t.Errorf("expected IP status %q, got %q", "IP PROCESSING", d.IPStatus)
+	}
+	if len(statuses) != 6 {
+		t.Fatalf("expected 6 channels, got %d", len(statuses))
+	}
+	expected := ConnectionStatus{ID: 0, Type: TCP, RemoteIP: "93.184.216.34", RemotePort: "80", State: StateConnected}
+	if statuses[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, statuses[0])
+	}
+	if statuses[1].Type != UDP || statuses[1].State != StateClosed {
+		t.Errorf("expected closed UDP channel, got %+v", statuses[1])
+	}
+	if statuses[5].ID != 5 || statuses[5].State != StateInitial {
+		t.Errorf("expected initial channel 5, got %+v", statuses[5])
+	}
+
+	// The driver's view follows the module
+	if open.State() != StateConnected || d.connections[0] != open {
+		t.Errorf("expected connection 0 to be connected, got %v", open.State())
+	}
+	if closed.State() != StateClosed || d.connections[s1] != nil {
+		t.Errorf("expected connection 1 to be closed and released, got %v", closed.State())
+	}
+	if _, err := closed.Read(make([]byte, 16)); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestDevice_GetConnectionStatusDrainsClosed(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CIPSTATUS": "\r\nOK\r\n\r\nSTATE: IP PROCESSING\r\n\r\n" +
+			"C: 0,0,\"TCP\",\"93.184.216.34\",\"80\",\"CLOSED\"\r\n" +
+			"C: 1,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 2,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 3,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 4,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 5,,\"\",\"\",\"\",\"INITIAL\"\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	conn := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+	d.connections[0] = conn
+	d.claimRecvBuffer(0)
+	d.recvBufLengths[0] = copy(d.recvBuffers[0][:], "tail")
+
+	if _, err := d.GetConnectionStatus(); err != nil {
+		t.Fatalf("status query failed: %v", err)
+	}
+
+	// The tail of the response is read before io.EOF
+	buf := make([]byte, 16)
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "tail" {
+		t.Fatalf("expected %q, got %q, %v", "tail", buf[:n], err)
+	}
+	if _, err := conn.Read(buf); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Errorf("expected closing after io.EOF to succeed, got %v", err)
+	}
+	if err := conn.Close(); err != ErrConnectionClosed {
+		t.Errorf("expected ErrConnectionClosed on the second close, got %v", err)
+	}
+}
+
+func TestConnection_ReadFrom(t *testing.T) {
+	s1, s2 := slot(t, 1), slot(t, 2)
+	modem := newMockModem(nil)
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	udp := &Connection{ID: 1, Type: UDP, state: StateConnected, Device: d}
+	tcp := &Connection{ID: 2, Type: TCP, state: StateConnected, RemoteIP: "10.0.0.5", Device: d}
+	d.connections[s1] = udp
+	d.connections[s2] = tcp
+
+	modem.inject("+RECEIVE,1,3,10.0.0.7:5000\r\none" +
+		"+RECEIVE,1,3,10.0.0.8:5001\r\ntwo" +
+		"+RECEIVE,2,4,10.0.0.9:80\r\nhijk")
+
+	buf := make([]byte, 16)
+	for _, want := range []struct{ data, from string }{
+		{"one", "10.0.0.7:5000"},
+		{"two", "10.0.0.8:5001"},
+	} {
+		n, addr, err := udp.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if string(buf[:n]) != want.data || addr == nil || addr.String() != want.from {
+			t.Errorf("expected %q from %s, got %q from %v", want.data, want.from, buf[:n], addr)
+		}
+	}
+
+	// Data on a TCP slot from another host is counted
+	stats, err := tcp.Stats()
+	if err != nil {
+		t.Fatalf("stats failed: %v", err)
+	}
+	if stats.ForeignData != 1 || stats.LastSender.String() != "10.0.0.9:80" {
+		t.Errorf("expected one foreign notification from 10.0.0.9:80, got %+v", stats)
+	}
+}
+
+func TestDevice_ConnectTimeout(t *testing.T) {
+	// The remote host never answers the connection attempt
+	modem := newMockModem(map[string]string{
+		"AT+CPAS": "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		"AT+CIPSTART=0,\"TCP\",\"example.com\",\"80\"": "\r\nOK\r\n",
+		"AT+CIPCLOSE=0": "\r\n0, CLOSE OK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.IP = "10.0.0.1"
+
+	dials := []struct {
+		name string
+		dial func() (net.Conn, error)
+	}{
+		{"DialTimeout", func() (net.Conn, error) {
+			return d.DialTimeout("tcp", "example.com:80", 100*time.Millisecond)
+		}},
+		{"SetConnectTimeout", func() (net.Conn, error) {
+			d.SetConnectTimeout(100 * time.Millisecond)
+			defer d.SetConnectTimeout(0)
+			return d.Dial("tcp", "example.com:80")
+		}},
+	}
+	for _, tt := range dials {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			if _, err := tt.dial(); err == nil {
+				t.Fatal("expected the dial to time out")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("dial returned after %v, long past the timeout", elapsed)
+			}
+			if last := modem.commands[len(modem.commands)-1]; last != "AT+CIPCLOSE=0" {
+				t.Errorf("expected the attempt to be aborted, last command was %q", last)
+			}
+		})
+	}
+}
+
+func TestDevice_GPRSPrimitives(t *testing.T) {
+	modem := newSessionModem()
+	modem.responses["AT+CGATT?"] = "\r\n+CGATT: 0\r\n\r\nOK\r\n"
+	modem.responses["AT+CGATT=1"] = "\r\nOK\r\n"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	// Attach early, without bringing up the PDP context
+	if err := d.Attach(); err != nil {
+		t.Fatalf("attach failed: %v", err)
+	}
+	if modem.commandCount("AT+CGATT=1") != 1 || d.IP != "" {
+		t.Errorf("expected an attach without IP address, got commands %q", modem.commands)
+	}
+	modem.responses["AT+CGATT?"] = "\r\n+CGATT: 1\r\n\r\nOK\r\n"
+	if err := d.Attach(); err != nil {
+		t.Fatalf("attach failed: %v", err)
+	}
+	if modem.commandCount("AT+CGATT=1") != 1 {
+		t.Error("expected no attach when already attached")
+	}
+
+	// Activate lazily before the first Dial
+	if _, err := d.Dial("tcp", "example.com:80"); !errors.Is(err, ErrNoIP) {
+		t.Fatalf("expected ErrNoIP before activation, got %v", err)
+	}
+	if err := d.ActivateContext("internet", "", ""); err != nil {
+		t.Fatalf("activate failed: %v", err)
+	}
+	if d.IP != "10.0.0.1" {
+		t.Errorf("expected IP 10.0.0.1, got %q", d.IP)
+	}
+	conn, err := d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	// Deactivating closes the connections but stays attached
+	if err := d.DeactivateContext(); err != nil {
+		t.Fatalf("deactivate failed: %v", err)
+	}
+	if d.IP != "" || conn.(*Connection).GetState() != StateClosed.String() {
+		t.Errorf("expected no IP address and a closed connection, got %q and %s", d.IP, conn.(*Connection).GetState())
+	}
+	if modem.commandCount("AT+CGATT=0") != 0 {
+		t.Error("expected no detach when deactivating the context")
+	}
+	if err := d.Detach(); err != nil {
+		t.Fatalf("detach failed: %v", err)
+	}
+	if modem.commandCount("AT+CGATT=0") != 1 {
+		t.Error("expected a detach")
+	}
+}
+
+func TestDevice_ConnectWithConfig(t *testing.T) {
+	modem := newSessionModem()
+	modem.responses["AT+CREG?"] = "\r\n+CREG: 0,3\r\n\r\nOK\r\n"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	// Settings that would break out of their quotes send nothing
+	for _, bad := range []GPRSConfig{
+		{APN: "internet\"\r\nAT+CPOWD=1\r\n"},
+		{APN: "internet", User: "a\nb", Password: "secret"},
+		{APN: "internet", User: "user", Password: "se\"cret"},
+	} {
+		if err := d.ConnectWithConfig(bad); !errors.Is(err, ErrBadParameter) {
+			t.Errorf("%+v: expected ErrBadParameter, got %v", bad, err)
+		}
+	}
+	if len(modem.commands) != 0 {
+		t.Fatalf("expected no commands, got %q", modem.commands)
+	}
+
+	// Registration is checked before attaching
+	cfg := GPRSConfig{APN: "internet", RegistrationTimeout: time.Second}
+	if err := d.ConnectWithConfig(cfg); !errors.Is(err, ErrRegistrationDenied) {
+		t.Fatalf("expected ErrRegistrationDenied, got %v", err)
+	}
+	if modem.commandCount("AT+CGATT?") != 0 {
+		t.Error("expected no attach before registration")
+	}
+
+	// Credentials are left out with AuthNone
+	modem.responses["AT+CREG?"] = "\r\n+CREG: 0,1\r\n\r\nOK\r\n"
+	cfg.User, cfg.Password, cfg.Auth = "user", "secret", AuthNone
+	cfg.DialTimeout = 5 * time.Second
+	if err := d.ConnectWithConfig(cfg); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if modem.commandCount("AT+CSTT=\"internet\"") != 1 || d.IP != "10.0.0.1" {
+		t.Errorf("expected the APN without credentials, got %q", modem.commands)
+	}
+	if d.gprs.DialTimeout != 5*time.Second || d.connectTimeout != 0 {
+		t.Errorf("expected the dial timeout kept with the settings only, got %v and %v", d.gprs.DialTimeout, d.connectTimeout)
+	}
+	if d.gprs != cfg {
+		t.Errorf("expected the settings to be kept for restores, got %+v", d.gprs)
+	}
+}
+
+func TestDevice_ConnectContext(t *testing.T) {
+	modem := newSessionModem()
+	modem.responses["AT+CIICR"] = "" // The network never answers
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.ConnectContext(ctx, "internet", "", ""); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if modem.commandCount("AT+CGATT?") != 0 {
+		t.Error("expected a cancelled connect to send nothing but the busy check")
+	}
+
+	// Abandoned while the context comes up, the half set up context is shut down
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := d.ConnectContext(ctx, "internet", "", ""); err == nil {
+		t.Fatal("expected the connect to fail")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the connect to give up at the deadline, took %v", elapsed)
+	}
+	if last := modem.commands[len(modem.commands)-1]; last != "AT+CIPSHUT" || d.IP != "" {
+		t.Errorf("expected the PDP context to be shut down, last command %q", last)
+	}
+
+	// Disconnect stops between steps too
+	modem.responses["AT+CIICR"] = "\r\nOK\r\n"
+	if err := d.Connect("internet", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := d.DisconnectContext(ctx); !errors.Is(err, context.Canceled) || d.IP == "" {
+		t.Errorf("expected a cancelled disconnect to leave the session up, got %v", err)
+	}
+	if err := d.DisconnectContext(context.Background()); err != nil || d.IP != "" {
+		t.Errorf("expected the session to be shut down, got %v", err)
+	}
+}
+
+func TestDevice_DialAllSlotsInUse(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CPAS": "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.IP = "10.0.0.1"
+	for i := uint8(0); i < MaxConnections; i++ {
+		d.connections[i] = &Connection{ID: i, Type: TCP, state: StateConnected, Device: d}
+	}
+
+	if _, err := d.Dial("tcp", "example.com:80"); !errors.Is(err, ErrMaxConn) {
+		t.Errorf("expected ErrMaxConn, got %v", err)
+	}
+	for _, cmd := range modem.commands {
+		if strings.HasPrefix(cmd, "AT+CIPSTART") {
+			t.Errorf("expected no connection attempt with all slots in use, got %q", cmd)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the bounded hex dumps of connection payloads.
+package sim800l
+
+import "log/slog"
+
+// HexDumpBytes is the number of bytes at the start and at the end of a
+// payload shown in its hex dump
+const HexDumpBytes = 16
+
+// logPayload logs data sent or received on connection id as a hex dump
+// at debug level, if built with the sim800l_hexdump tag. Binary data
+// would garble a serial console, so payloads are never logged as text.
+func (d *Device) logPayload(msg string, id uint8, data []byte) {
+	if !hexDumpPayloads || slog.LevelDebug < d.logLevels[SubsystemData].Level() {
+		return
+	}
+	var buf [4*HexDumpBytes + 4]byte
+	d.log(SubsystemData, slog.LevelDebug, msg, "id", id, "len", len(data),
+		"hex", string(appendHexDump(buf[:0], data)))
+}
+
+// appendHexDump appends data in hex to dst. Of data longer than
+// 2*HexDumpBytes only the first and last HexDumpBytes are shown, with
+// " .. " in between, so the dump stays small whatever the payload.
+func appendHexDump(dst, data []byte) []byte {
+	if len(data) <= 2*HexDumpBytes {
+		return appendHex(dst, data)
+	}
+	dst = appendHex(dst, data[:HexDumpBytes])
+	dst = append(dst, " .. "...)
+	return appendHex(dst, data[len(data)-HexDumpBytes:])
+}
+
+// appendHex appends data in lowercase hex to dst
+func appendHex(dst, data []byte) []byte {
+	for _, b := range data {
+		dst = append(dst, hexDigits[b>>4], hexDigits[b&0x0f])
+	}
+	return dst
+}
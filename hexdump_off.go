@@ -0,0 +1,8 @@
+//go:build !sim800l_hexdump
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file disables the hex dumps of connection payloads by default.
+package sim800l
+
+// hexDumpPayloads is false unless built with the sim800l_hexdump tag
+const hexDumpPayloads = false
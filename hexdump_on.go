@@ -0,0 +1,8 @@
+//go:build sim800l_hexdump
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file enables the hex dumps of connection payloads.
+package sim800l
+
+// hexDumpPayloads makes the data path log payloads as hex dumps
+const hexDumpPayloads = true
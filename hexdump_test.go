@@ -0,0 +1,54 @@
+package sim800l
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_appendHexDump(t *testing.T) {
+	long := make([]byte, 1000)
+	long[0], long[999] = 0xab, 0xcd
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, ""},
+		{"short", []byte{0x00, 0x7f, 0xff}, "007fff"},
+		{"long", long, "ab" + strings.Repeat("00", HexDumpBytes-1) + " .. " + strings.Repeat("00", HexDumpBytes-1) + "cd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf [4*HexDumpBytes + 4]byte
+			if got := string(appendHexDump(buf[:0], tt.data)); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDevice_logPayload(t *testing.T) {
+	var out bytes.Buffer
+	d := New(newMockModem(nil), nil, slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	d.logPayload("data received", 1, []byte("\x00\x1b[2J"))
+	if !hexDumpPayloads {
+		if out.Len() != 0 {
+			t.Errorf("expected no payload logging without the sim800l_hexdump tag, got %q", out.String())
+		}
+		return
+	}
+	if !strings.Contains(out.String(), "hex=001b5b324a") || !strings.Contains(out.String(), "len=5") {
+		t.Errorf("expected a hex dump, got %q", out.String())
+	}
+
+	// Quiet data logging skips the dump
+	out.Reset()
+	d.SetLogLevel(SubsystemData, slog.LevelInfo)
+	d.logPayload("data received", 1, []byte("x"))
+	if out.Len() != 0 {
+		t.Errorf("expected no record above debug level, got %q", out.String())
+	}
+}
@@ -0,0 +1,298 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the module's HTTP stack and the bearer it uses.
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPTimeout bounds the wait of HTTPRequest for the server's response
+// when no timeout is given
+const HTTPTimeout = 60 * time.Second
+
+// httpDataTime is the time the module waits for the body of a POST
+const httpDataTime = 10 * time.Second
+
+// HTTPMethod is the method of a request made with the module's HTTP stack,
+// with the values of AT+HTTPACTION
+type HTTPMethod uint8
+
+const (
+	HTTPGet  HTTPMethod = iota // GET
+	HTTPPost                   // POST
+	HTTPHead                   // HEAD
+)
+
+var (
+	cmdBearerQuery  = []byte("+SAPBR=2,1")   // Query the state of bearer 1
+	cmdBearerParam  = []byte("+SAPBR=3,1,")  // Set a parameter of bearer 1
+	cmdBearerOpen   = []byte("+SAPBR=1,1")   // Open bearer 1
+	cmdBearerClose  = []byte("+SAPBR=0,1")   // Close bearer 1
+	cmdHTTPInit     = []byte("+HTTPINIT")    // Start the HTTP service
+	cmdHTTPTerm     = []byte("+HTTPTERM")    // Stop the HTTP service
+	cmdHTTPParam    = []byte("+HTTPPARA=")   // Set a parameter of the request
+	cmdHTTPSSL      = []byte("+HTTPSSL=1")   // Make the request over SSL
+	cmdHTTPData     = []byte("+HTTPDATA=")   // Input the request body, followed by its length and time limit
+	cmdHTTPAction   = []byte("+HTTPACTION=") // Make the request, followed by the method
+	cmdHTTPRead     = []byte("+HTTPREAD=0,") // Read the response body, followed by its length
+	bearerStatus    = []byte("+SAPBR")       // State of a bearer
+	httpActionDone  = []byte("+HTTPACTION")  // Result of a request, sent after OK
+	httpReadLength  = []byte("+HTTPREAD")    // Length of the body data that follows
+	httpDataPrompt  = []byte("DOWNLOAD")     // The module waits for the request body
+	httpBearerParam = []byte("\"CID\",1")    // Makes the request use bearer 1
+)
+
+var ErrHTTPFailed = errors.New("HTTP request failed")
+
+// HTTPResult is the outcome of a request made with HTTPRequest
+type HTTPResult struct {
+	Status int // Status code sent by the server
+	Length int // Length of the body sent by the server
+	Read   int // Bytes of the body read into dst, at most Length
+}
+
+// OpenBearer opens bearer 1, the GPRS bearer of the module's application
+// stack used by HTTPRequest, with the APN and credentials in cfg, or those
+// of the last Connect if cfg.APN is empty. It needs network registration
+// but not Connect, and an open bearer is left as it is. An APN, user or
+// password with a quote, CR or LF returns ErrBadParameter.
+func (d *Device) OpenBearer(cfg GPRSConfig) error {
+	d.lock()
+	defer d.unlock()
+
+	if cfg.APN == "" {
+		cfg = d.gprs
+	}
+	if cfg.APN == "" {
+		return fmt.Errorf("%w: no APN", ErrBadParameter)
+	}
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	if open, err := d.bearerOpen(); err != nil {
+		return err
+	} else if open {
+		return nil
+	}
+
+	params := [...]struct{ tag, val string }{
+		{"CONTYPE", "GPRS"},
+		{"APN", cfg.APN},
+		{"USER", cfg.User},
+		{"PWD", cfg.Password},
+	}
+	for _, p := range params {
+		if p.val == "" {
+			continue
+		}
+		var buf [MaxCommandSize]byte
+		cmd := fmt.Appendf(append(buf[:0], cmdBearerParam...), "\"%s\",\"%s\"", p.tag, p.val)
+		if err := d.send(cmd); err != nil {
+			return fmt.Errorf("failed to set bearer %s: %w", p.tag, err)
+		}
+	}
+	if err := d.sendWithOptions(cmdBearerOpen, defaultResponseCheck, ConnectTimeout); err != nil {
+		return fmt.Errorf("failed to open bearer: %w", err)
+	}
+	d.log(SubsystemData, slog.LevelInfo, "bearer opened", "apn", cfg.APN)
+	return nil
+}
+
+// CloseBearer closes bearer 1
+func (d *Device) CloseBearer() error {
+	d.lock()
+	defer d.unlock()
+
+	if open, err := d.bearerOpen(); err != nil || !open {
+		return err
+	}
+	if err := d.sendWithOptions(cmdBearerClose, defaultResponseCheck, ConnectTimeout); err != nil {
+		return fmt.Errorf("failed to close bearer: %w", err)
+	}
+	return nil
+}
+
+// bearerOpen reports whether bearer 1 is open, with the lock held
+func (d *Device) bearerOpen() (bool, error) {
+	if err := d.sendWithOptions(cmdBearerQuery, prefixCheck(bearerStatus), DefaultTimeout); err != nil {
+		return false, fmt.Errorf("failed to query bearer: %w", err)
+	}
+	val, ok := d.parseValue(bearerStatus)
+	if !ok {
+		return false, ErrUnexpectedResponse
+	}
+	// 1,<status>,"<IP>" with status 1 for connected
+	var fields [2]int
+	if parseInts(val, fields[:]) < 2 {
+		return false, ErrUnexpectedResponse
+	}
+	return fields[1] == 1, nil
+}
+
+// HTTPRequest makes a request to url, http:// or https://, with the
+// module's HTTP stack over the bearer opened by OpenBearer, and reads up
+// to len(dst) bytes of the response body into dst. A POST sends body,
+// with contentType if it isn't empty. The module handles the headers and
+// keeps neither them nor the connection. The request, including the
+// response, must complete within timeout, HTTPTimeout if zero.
+//
+// Status codes of the server, including 4xx and 5xx, aren't errors; check
+// Status. Codes 600 and above are the module's own failures, like 601 for
+// a network error or 603 for a failed DNS lookup, and return an error
+// matching ErrHTTPFailed with the result.
+func (d *Device) HTTPRequest(method HTTPMethod, url, contentType string, body, dst []byte, timeout time.Duration) (HTTPResult, error) {
+	if method > HTTPHead || strings.ContainsAny(url+contentType, "\"\r\n") {
+		return HTTPResult{}, ErrBadParameter
+	}
+	secure := strings.HasPrefix(url, "https://")
+	if !secure && !strings.HasPrefix(url, "http://") {
+		return HTTPResult{}, fmt.Errorf("%w: URL %q", ErrBadParameter, url)
+	}
+	if timeout <= 0 {
+		timeout = HTTPTimeout
+	}
+
+	d.lock()
+	defer d.unlock()
+
+	if secure && !d.capabilities().SSL {
+		return HTTPResult{}, fmt.Errorf("%w: no SSL in firmware %q", ErrNotSupported, d.Firmware)
+	}
+	if err := d.exchange(cmdHTTPInit, defaultResponseCheck, DefaultTimeout); err != nil {
+		// The service is still running after an earlier request failed
+		// before it was stopped
+		_ = d.exchange(cmdHTTPTerm, defaultResponseCheck, DefaultTimeout)
+		if err := d.send(cmdHTTPInit); err != nil {
+			return HTTPResult{}, fmt.Errorf("failed to start HTTP service: %w", err)
+		}
+	}
+	defer func() {
+		if err := d.send(cmdHTTPTerm); err != nil {
+			d.log(SubsystemData, slog.LevelDebug, "failed to stop HTTP service", "error", err)
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	res, err := d.httpAction(method, url, contentType, body, dst, secure, deadline)
+	if err != nil {
+		return res, fmt.Errorf("HTTP %s failed: %w", url, err)
+	}
+	d.log(SubsystemData, slog.LevelDebug, "HTTP request done", "url", url, "status", res.Status, "length", res.Length)
+	return res, nil
+}
+
+// httpAction sets up the request, makes it and reads the response body
+// with the lock held and the HTTP service started
+func (d *Device) httpAction(method HTTPMethod, url, contentType string, body, dst []byte, secure bool, deadline time.Time) (HTTPResult, error) {
+	var buf [MaxCommandSize]byte
+	if err := d.send(append(append(buf[:0], cmdHTTPParam...), httpBearerParam...)); err != nil {
+		return HTTPResult{}, err
+	}
+	if err := d.send(fmt.Appendf(append(buf[:0], cmdHTTPParam...), "\"URL\",\"%s\"", url)); err != nil {
+		return HTTPResult{}, err
+	}
+	if secure {
+		if err := d.send(cmdHTTPSSL); err != nil {
+			return HTTPResult{}, err
+		}
+	}
+	if method == HTTPPost {
+		if contentType != "" {
+			if err := d.send(fmt.Appendf(append(buf[:0], cmdHTTPParam...), "\"CONTENT\",\"%s\"", contentType)); err != nil {
+				return HTTPResult{}, err
+			}
+		}
+		if err := d.httpBody(body, deadline); err != nil {
+			return HTTPResult{}, err
+		}
+	}
+
+	cmd := strconv.AppendInt(append(buf[:0], cmdHTTPAction...), int64(method), 10)
+	if err := d.send(cmd); err != nil {
+		return HTTPResult{}, err
+	}
+	// +HTTPACTION: <method>,<status>,<length> once the server answered
+	if err := d.readResponse(cmd, prefixCheck(httpActionDone), time.Until(deadline)); err != nil {
+		return HTTPResult{}, err
+	}
+	val, ok := d.parseValue(httpActionDone)
+	var fields [3]int
+	if !ok || parseInts(val, fields[:]) < 3 {
+		return HTTPResult{}, ErrUnexpectedResponse
+	}
+	res := HTTPResult{Status: fields[1], Length: fields[2]}
+	if res.Status >= 600 {
+		return res, fmt.Errorf("%w: status %d", ErrHTTPFailed, res.Status)
+	}
+	if method == HTTPHead || res.Length == 0 || len(dst) == 0 {
+		return res, nil
+	}
+
+	n, err := d.httpRead(dst[:min(len(dst), res.Length)], deadline)
+	res.Read = n
+	return res, err
+}
+
+// httpBody inputs the body of a POST with AT+HTTPDATA, with the lock held
+func (d *Device) httpBody(body []byte, deadline time.Time) error {
+	var buf [32]byte
+	cmd := strconv.AppendInt(append(buf[:0], cmdHTTPData...), int64(len(body)), 10)
+	cmd = append(cmd, ',')
+	cmd = strconv.AppendInt(cmd, httpDataTime.Milliseconds(), 10)
+	if err := d.sendRaw(cmd); err != nil {
+		return err
+	}
+	if err := d.readResponse(cmd, func(buffer []byte) error {
+		if bytes.Equal(buffer, httpDataPrompt) {
+			return nil
+		}
+		return defaultResponseCheck(buffer)
+	}, DefaultTimeout); err != nil {
+		return err
+	}
+	if _, err := d.uart.Write(body); err != nil {
+		return fmt.Errorf("failed to send body: %w", err)
+	}
+	return d.readResponse(nil, defaultResponseCheck, time.Until(deadline))
+}
+
+// httpRead reads the start of the response body into dst with
+// AT+HTTPREAD, with the lock held
+func (d *Device) httpRead(dst []byte, deadline time.Time) (int, error) {
+	var buf [32]byte
+	cmd := strconv.AppendInt(append(buf[:0], cmdHTTPRead...), int64(len(dst)), 10)
+	if err := d.sendWithOptions(cmd, prefixCheck(httpReadLength), time.Until(deadline)); err != nil {
+		return 0, err
+	}
+	val, ok := d.parseValue(httpReadLength)
+	if !ok {
+		return 0, ErrUnexpectedResponse
+	}
+	size, err := strconv.Atoi(string(val))
+	if err != nil || size < 0 || size > len(dst) {
+		return 0, ErrUnexpectedResponse
+	}
+
+	// The body follows the length line as is, line breaks included
+	n := 0
+	for n < size {
+		if !time.Now().Before(deadline) {
+			return n, ErrTimeout
+		}
+		m, err := d.input().Read(dst[n:size])
+		if err != nil {
+			return n, fmt.Errorf("failed to read body: %w", err)
+		}
+		if m == 0 {
+			time.Sleep(readPollInterval)
+		}
+		n += m
+	}
+	return n, d.readResponse(nil, defaultResponseCheck, time.Until(deadline))
+}
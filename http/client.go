@@ -0,0 +1,69 @@
+// Package http makes HTTP requests with the HTTP stack of a SIM800L module.
+// The module builds the request, parses the response and keeps its
+// headers, so a request costs a few AT commands and one body buffer, far
+// lighter than running a Go HTTP client over Dial.
+package http
+
+import (
+	"time"
+
+	"github.com/m-s-sh/sim800l"
+)
+
+// BodySize is the size of the body buffer of a Client. Longer bodies are
+// cut short; Response.Length tells their full length.
+const BodySize = 1024
+
+// Response is the response to a request made by a Client
+type Response struct {
+	StatusCode int    // Status code sent by the server
+	Body       []byte // Body, without headers, at most BodySize bytes
+	Length     int    // Length of the body sent by the server
+}
+
+// Client makes HTTP requests with the module's HTTP stack. Its responses
+// share one body buffer, so a Client isn't safe for concurrent use and a
+// Response's Body is only valid until the next request.
+type Client struct {
+	// ContentType is sent with the body of Post, e.g. application/json;
+	// none if empty
+	ContentType string
+
+	// Timeout bounds each request, sim800l.HTTPTimeout if zero
+	Timeout time.Duration
+
+	device *sim800l.Device
+	bearer sim800l.GPRSConfig
+	body   [BodySize]byte
+}
+
+// New returns a client making requests with d, which must be initialized
+// and registered on a network. The requests open the module's bearer with
+// the APN and credentials in bearer, or those of the last Connect if
+// bearer.APN is empty.
+func New(d *sim800l.Device, bearer sim800l.GPRSConfig) *Client {
+	return &Client{device: d, bearer: bearer}
+}
+
+// Get requests url, http:// or https://, with a GET
+func (c *Client) Get(url string) (Response, error) {
+	return c.do(sim800l.HTTPGet, url, nil)
+}
+
+// Post sends body to url, http:// or https://, with a POST
+func (c *Client) Post(url string, body []byte) (Response, error) {
+	return c.do(sim800l.HTTPPost, url, body)
+}
+
+// do opens the bearer, unless it is open, and makes the request. Status
+// codes of the server, including 4xx and 5xx, aren't errors.
+func (c *Client) do(method sim800l.HTTPMethod, url string, body []byte) (Response, error) {
+	if err := c.device.OpenBearer(c.bearer); err != nil {
+		return Response{}, err
+	}
+	res, err := c.device.HTTPRequest(method, url, c.ContentType, body, c.body[:], c.Timeout)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{StatusCode: res.Status, Body: c.body[:res.Read], Length: res.Length}, nil
+}
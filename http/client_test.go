@@ -0,0 +1,113 @@
+package http
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/m-s-sh/sim800l"
+)
+
+// scriptedModem answers commands with the replies in responses and
+// ERROR to any other
+type scriptedModem struct {
+	mu        sync.Mutex
+	rx        bytes.Buffer
+	line      []byte
+	inData    bool
+	responses map[string]string
+	commands  []string
+}
+
+func (m *scriptedModem) Read(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rx.Len() == 0 {
+		return 0, nil
+	}
+	return m.rx.Read(p)
+}
+
+func (m *scriptedModem) Buffered() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rx.Len()
+}
+
+func (m *scriptedModem) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.inData {
+		m.inData = false
+		m.rx.WriteString("\r\nOK\r\n")
+		return len(p), nil
+	}
+	m.line = append(m.line, p...)
+	for {
+		i := bytes.Index(m.line, []byte("\r\n"))
+		if i < 0 {
+			return len(p), nil
+		}
+		cmd := string(m.line[:i])
+		m.line = m.line[i+2:]
+		m.commands = append(m.commands, cmd)
+		reply, ok := m.responses[cmd]
+		if !ok {
+			reply = "\r\nERROR\r\n"
+		}
+		m.inData = strings.HasSuffix(reply, "DOWNLOAD\r\n")
+		m.rx.WriteString(reply)
+	}
+}
+
+func TestClient(t *testing.T) {
+	modem := &scriptedModem{responses: map[string]string{
+		"AT+SAPBR=2,1":                                "\r\n+SAPBR: 1,3,\"0.0.0.0\"\r\n\r\nOK\r\n",
+		"AT+SAPBR=3,1,\"CONTYPE\",\"GPRS\"":           "\r\nOK\r\n",
+		"AT+SAPBR=3,1,\"APN\",\"internet\"":           "\r\nOK\r\n",
+		"AT+SAPBR=1,1":                                "\r\nOK\r\n",
+		"AT+HTTPINIT":                                 "\r\nOK\r\n",
+		"AT+HTTPTERM":                                 "\r\nOK\r\n",
+		"AT+HTTPPARA=\"CID\",1":                       "\r\nOK\r\n",
+		"AT+HTTPPARA=\"URL\",\"http://example.com/\"": "\r\nOK\r\n",
+		"AT+HTTPPARA=\"CONTENT\",\"text/plain\"":      "\r\nOK\r\n",
+		"AT+HTTPACTION=0":                             "\r\nOK\r\n\r\n+HTTPACTION: 0,200,2000\r\n",
+		"AT+HTTPREAD=0,1024":                          "\r\n+HTTPREAD: 1024\r\n" + strings.Repeat("x", 1024) + "\r\nOK\r\n",
+		"AT+HTTPDATA=5,10000":                         "\r\nDOWNLOAD\r\n",
+		"AT+HTTPACTION=1":                             "\r\nOK\r\n\r\n+HTTPACTION: 1,404,3\r\n",
+		"AT+HTTPREAD=0,3":                             "\r\n+HTTPREAD: 3\r\nnop\r\nOK\r\n",
+	}}
+	d := sim800l.New(modem, nil, slog.New(slog.DiscardHandler))
+	c := New(d, sim800l.GPRSConfig{APN: "internet"})
+	c.ContentType = "text/plain"
+
+	// A long body is cut to the buffer, Length tells its full length
+	res, err := c.Get("http://example.com/")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	if res.StatusCode != 200 || res.Length != 2000 || len(res.Body) != BodySize {
+		t.Errorf("unexpected response %d, %d bytes of %d", res.StatusCode, len(res.Body), res.Length)
+	}
+
+	// Server errors aren't errors, the bearer is opened once
+	modem.responses["AT+SAPBR=2,1"] = "\r\n+SAPBR: 1,1,\"10.1.2.3\"\r\n\r\nOK\r\n"
+	res, err = c.Post("http://example.com/", []byte("hello"))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	if res.StatusCode != 404 || string(res.Body) != "nop" || res.Length != 3 {
+		t.Errorf("unexpected response %d, %q", res.StatusCode, res.Body)
+	}
+	n := 0
+	for _, cmd := range modem.commands {
+		if cmd == "AT+SAPBR=1,1" {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("expected the bearer to be opened once, got %d", n)
+	}
+}
@@ -0,0 +1,156 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDevice_Bearer(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+SAPBR=2,1":                      "\r\n+SAPBR: 1,3,\"0.0.0.0\"\r\n\r\nOK\r\n",
+		"AT+SAPBR=3,1,\"CONTYPE\",\"GPRS\"": "\r\nOK\r\n",
+		"AT+SAPBR=3,1,\"APN\",\"internet\"": "\r\nOK\r\n",
+		"AT+SAPBR=1,1":                      "\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	if err := d.OpenBearer(GPRSConfig{}); !errors.Is(err, ErrBadParameter) {
+		t.Fatalf("expected ErrBadParameter without an APN, got %v", err)
+	}
+	for _, bad := range []GPRSConfig{
+		{APN: "internet\"\r\nAT+CPOWD=1"},
+		{APN: "internet", User: "a\rb"},
+		{APN: "internet", Password: "se\"cret"},
+	} {
+		if err := d.OpenBearer(bad); !errors.Is(err, ErrBadParameter) {
+			t.Errorf("%+v: expected ErrBadParameter, got %v", bad, err)
+		}
+	}
+	if len(modem.commands) != 0 {
+		t.Fatalf("expected no commands, got %q", modem.commands)
+	}
+	if err := d.OpenBearer(GPRSConfig{APN: "internet"}); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	want := "AT+SAPBR=2,1|AT+SAPBR=3,1,\"CONTYPE\",\"GPRS\"|AT+SAPBR=3,1,\"APN\",\"internet\"|AT+SAPBR=1,1"
+	if got := strings.Join(modem.commands, "|"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// An open bearer is left as it is, closing it closes it
+	modem.responses["AT+SAPBR=2,1"] = "\r\n+SAPBR: 1,1,\"10.1.2.3\"\r\n\r\nOK\r\n"
+	modem.responses["AT+SAPBR=0,1"] = "\r\nOK\r\n"
+	modem.commands = nil
+	if err := d.OpenBearer(GPRSConfig{APN: "internet"}); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	if err := d.CloseBearer(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if got := strings.Join(modem.commands, "|"); got != "AT+SAPBR=2,1|AT+SAPBR=2,1|AT+SAPBR=0,1" {
+		t.Errorf("unexpected commands %q", got)
+	}
+}
+
+func TestDevice_HTTPRequest(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+HTTPINIT":           "\r\nOK\r\n",
+		"AT+HTTPTERM":           "\r\nOK\r\n",
+		"AT+HTTPPARA=\"CID\",1": "\r\nOK\r\n",
+		"AT+HTTPPARA=\"URL\",\"http://example.com/a\"":  "\r\nOK\r\n",
+		"AT+HTTPPARA=\"URL\",\"https://example.com/a\"": "\r\nOK\r\n",
+		"AT+HTTPPARA=\"URL\",\"http://nowhere/\"":       "\r\nOK\r\n",
+		"AT+HTTPPARA=\"CONTENT\",\"application/json\"":  "\r\nOK\r\n",
+		"AT+HTTPSSL=1":        "\r\nOK\r\n",
+		"AT+HTTPACTION=0":     "\r\nOK\r\n\r\n+HTTPACTION: 0,200,12\r\n",
+		"AT+HTTPACTION=1":     "\r\nOK\r\n\r\n+HTTPACTION: 1,201,0\r\n",
+		"AT+HTTPREAD=0,12":    "\r\n+HTTPREAD: 12\r\nhello\r\nworld\r\nOK\r\n",
+		"AT+HTTPREAD=0,5":     "\r\n+HTTPREAD: 5\r\nhello\r\nOK\r\n",
+		"AT+HTTPDATA=7,10000": "\r\nDOWNLOAD\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	// The body is read as is, line breaks included
+	var dst [64]byte
+	res, err := d.HTTPRequest(HTTPGet, "http://example.com/a", "", nil, dst[:], 0)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	if res.Status != 200 || res.Length != 12 || string(dst[:res.Read]) != "hello\r\nworld" {
+		t.Errorf("unexpected result %+v, body %q", res, dst[:res.Read])
+	}
+	want := "AT+HTTPINIT|AT+HTTPPARA=\"CID\",1|AT+HTTPPARA=\"URL\",\"http://example.com/a\"|" +
+		"AT+HTTPACTION=0|AT+HTTPREAD=0,12|AT+HTTPTERM"
+	if got := strings.Join(modem.commands, "|"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// A short buffer reads the start of the body
+	res, err = d.HTTPRequest(HTTPGet, "http://example.com/a", "", nil, dst[:5], 0)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	if res.Length != 12 || string(dst[:res.Read]) != "hello" {
+		t.Errorf("unexpected result %+v, body %q", res, dst[:res.Read])
+	}
+
+	// A POST inputs its body at the DOWNLOAD prompt
+	modem.commands = nil
+	modem.dataReply = "\r\nOK\r\n"
+	res, err = d.HTTPRequest(HTTPPost, "http://example.com/a", "application/json", []byte(`{"t":1}`), dst[:], 0)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	if res.Status != 201 || res.Length != 0 || res.Read != 0 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if !strings.Contains(modem.tx.String(), `{"t":1}`) {
+		t.Error("body not sent")
+	}
+	want = "AT+HTTPINIT|AT+HTTPPARA=\"CID\",1|AT+HTTPPARA=\"URL\",\"http://example.com/a\"|" +
+		"AT+HTTPPARA=\"CONTENT\",\"application/json\"|AT+HTTPDATA=7,10000|AT+HTTPACTION=1|AT+HTTPTERM"
+	if got := strings.Join(modem.commands, "|"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// HTTPS needs SSL in the firmware
+	if _, err := d.HTTPRequest(HTTPGet, "https://example.com/a", "", nil, dst[:], 0); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+	d.Firmware = "1418B05SIM800L24"
+	if _, err := d.HTTPRequest(HTTPGet, "https://example.com/a", "", nil, dst[:], 0); err != nil {
+		t.Errorf("HTTPS GET failed: %v", err)
+	}
+	if modem.commandCount("AT+HTTPSSL=1") != 1 {
+		t.Error("expected SSL to be enabled")
+	}
+
+	// Codes of the module are errors, and the service is stopped anyway
+	modem.responses["AT+HTTPACTION=0"] = "\r\nOK\r\n\r\n+HTTPACTION: 0,603,0\r\n"
+	modem.commands = nil
+	res, err = d.HTTPRequest(HTTPGet, "http://nowhere/", "", nil, dst[:], 0)
+	if !errors.Is(err, ErrHTTPFailed) || res.Status != 603 {
+		t.Errorf("expected ErrHTTPFailed with status 603, got %v, %+v", err, res)
+	}
+	if n := modem.commandCount("AT+HTTPTERM"); n != 1 {
+		t.Errorf("expected the service to be stopped, got %d AT+HTTPTERM", n)
+	}
+
+	// A service left running is stopped before starting it again
+	modem.responses["AT+HTTPINIT"] = "\r\nERROR\r\n"
+	modem.commands = nil
+	if _, err := d.HTTPRequest(HTTPGet, "http://nowhere/", "", nil, dst[:], 0); err == nil {
+		t.Error("expected an error")
+	}
+	if got := strings.Join(modem.commands[:3], "|"); got != "AT+HTTPINIT|AT+HTTPTERM|AT+HTTPINIT" {
+		t.Errorf("unexpected commands %q", got)
+	}
+
+	for _, url := range []string{"ftp://example.com/", "http://example.com/\"", "example.com"} {
+		if _, err := d.HTTPRequest(HTTPGet, url, "", nil, dst[:], 0); !errors.Is(err, ErrBadParameter) {
+			t.Errorf("%q: expected ErrBadParameter, got %v", url, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the identity of the module and its SIM.
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+var (
+	cmdICCID       = []byte("+CCID")     // Query the ICCID of the SIM
+	cmdModel       = []byte("+CGMM")     // Query the model
+	cmdFirmware    = []byte("+CGMR")     // Query the firmware revision
+	firmwarePrefix = []byte("Revision:") // Precedes the firmware revision
+)
+
+// ModuleInfo identifies the module and its SIM
+type ModuleInfo struct {
+	IMEI     string // Module IMEI, from AT+GSN
+	Model    string // Module model, e.g. SIMCOM_SIM800L, from AT+CGMM
+	Firmware string // Firmware revision, e.g. 1418B05SIM800L24, from AT+CGMR
+	IMSI     string // SIM IMSI, from AT+CIMI; empty without a ready SIM
+	ICCID    string // SIM serial number, from AT+CCID; empty without a SIM
+}
+
+// Info reads the identity of the module and its SIM in one pass. Fields
+// that can't be read are left empty and the first failure is returned with
+// the rest, e.g. the IMSI of a SIM waiting for its PIN.
+func (d *Device) Info() (ModuleInfo, error) {
+	d.lock()
+	defer d.unlock()
+	return d.identify(context.Background(), true)
+}
+
+// identify reads the identity with the lock held, the SIM's only if sim is
+// set, and keeps the module's in IMEI, Model and Firmware
+func (d *Device) identify(ctx context.Context, sim bool) (ModuleInfo, error) {
+	var info ModuleInfo
+	var first error
+	read := func(name string, cmd []byte, check ResponseCheckFunc, dst *string) {
+		val, err := d.identityField(ctx, cmd, check)
+		if err != nil {
+			d.log(SubsystemCommand, slog.LevelDebug, "failed to read identity", "field", name, "error", err)
+			if first == nil {
+				first = fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			return
+		}
+		*dst = val
+	}
+
+	read("IMEI", cmdGetImei, imeiCheck, &info.IMEI)
+	read("model", cmdModel, valueLineCheck, &info.Model)
+	read("firmware", cmdFirmware, valueLineCheck, &info.Firmware)
+	info.Firmware = strings.TrimSpace(strings.TrimPrefix(info.Firmware, string(firmwarePrefix)))
+	if sim {
+		read("IMSI", cmdIMSI, imeiCheck, &info.IMSI)
+		read("ICCID", cmdICCID, iccidCheck, &info.ICCID)
+	}
+
+	if info.IMEI != "" {
+		d.IMEI = info.IMEI
+	}
+	if info.Model != "" {
+		d.Model = info.Model
+	}
+	if info.Firmware != "" {
+		d.Firmware = info.Firmware
+	}
+	return info, first
+}
+
+// identityField sends cmd and returns its response line. It doesn't log
+// failures as errors, as a SIM that isn't ready can't answer some.
+func (d *Device) identityField(ctx context.Context, cmd []byte, check ResponseCheckFunc) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := d.exchange(cmd, check, contextTimeout(ctx, DefaultTimeout)); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(d.rxBuffer[:d.end])), nil
+}
+
+// firmwareRelease returns the release of a firmware revision like
+// 1418B05SIM800L24 as a number like 1418, for R14.18
+func firmwareRelease(revision string) (int, bool) {
+	if len(revision) < 4 {
+		return 0, false
+	}
+	release := 0
+	for _, c := range []byte(revision[:4]) {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		release = release*10 + int(c-'0')
+	}
+	return release, true
+}
+
+// valueLineCheck accepts the first line of a response that isn't a
+// result code, like the model answering AT+CGMM
+func valueLineCheck(buffer []byte) error {
+	if bytes.Equal(buffer, okToken) {
+		return fmt.Errorf("%w: no value", ErrUnexpectedResponse)
+	}
+	if bytes.Contains(buffer, errorToken) {
+		return defaultResponseCheck(buffer)
+	}
+	return nil
+}
+
+// iccidCheck accepts the ICCID, a line of digits that may end in hex
+// digits like F
+func iccidCheck(buffer []byte) error {
+	if len(buffer) == 0 || bytes.Equal(buffer, okToken) {
+		return fmt.Errorf("%w: no ICCID", ErrUnexpectedResponse)
+	}
+	for _, c := range buffer {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'F') && (c < 'a' || c > 'f') {
+			return defaultResponseCheck(buffer)
+		}
+	}
+	return nil
+}
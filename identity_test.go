@@ -0,0 +1,44 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_Info(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+GSN":  "\r\n866782042145078\r\n\r\nOK\r\n",
+		"AT+CGMM": "\r\nSIMCOM_SIM800L\r\n\r\nOK\r\n",
+		"AT+CGMR": "\r\nRevision:1418B05SIM800L24\r\n\r\nOK\r\n",
+		"AT+CIMI": "\r\n262019876543210\r\n\r\nOK\r\n",
+		"AT+CCID": "\r\n8949226180000123456F\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	info, err := d.Info()
+	if err != nil {
+		t.Fatalf("info failed: %v", err)
+	}
+	expected := ModuleInfo{
+		IMEI:     "866782042145078",
+		Model:    "SIMCOM_SIM800L",
+		Firmware: "1418B05SIM800L24",
+		IMSI:     "262019876543210",
+		ICCID:    "8949226180000123456F",
+	}
+	if info != expected {
+		t.Errorf("expected %+v, got %+v", expected, info)
+	}
+	if d.IMEI != expected.IMEI || d.Model != expected.Model || d.Firmware != expected.Firmware {
+		t.Errorf("expected the module's identity to be kept, got %q, %q, %q", d.IMEI, d.Model, d.Firmware)
+	}
+
+	// Without a SIM the module's identity is still read
+	modem.responses["AT+CIMI"] = "\r\n+CME ERROR: SIM not inserted\r\n"
+	modem.responses["AT+CCID"] = "\r\n+CME ERROR: SIM not inserted\r\n"
+	info, err = d.Info()
+	if !errors.Is(err, ErrSIMNotInserted) || info.Firmware != expected.Firmware || info.IMSI != "" {
+		t.Errorf("expected the module's identity with ErrSIMNotInserted, got %+v, %v", info, err)
+	}
+}
@@ -0,0 +1,344 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the time-boxed initialization sequence.
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// initSyncAttempts is how often Init tries to reach the module
+	initSyncAttempts = 5
+
+	// initSyncInterval is the time between attempts to reach the module
+	initSyncInterval = time.Second
+
+	// initCommandDelay gives the module time between setup commands
+	initCommandDelay = 100 * time.Millisecond
+)
+
+// ReinitProbeTimeout is how long Reinit waits for a running module to answer AT
+const ReinitProbeTimeout = time.Second
+
+var (
+	cmdRegistration = []byte("+CREG?") // Query network registration
+	registration    = []byte("+CREG")  // Network registration response key
+	simStatus       = []byte("+CPIN")  // SIM status response key
+	operatorStatus  = []byte("+COPS")  // Operator response key
+	simReady        = []byte("READY")  // SIM status without a pending PIN
+)
+
+// InitStatus reports how far initialization got
+type InitStatus struct {
+	Responding bool // The module answers AT commands
+	Configured bool // Echo, error reporting, multi-connection and SMS modes are set
+	SIMReady   bool // The SIM is inserted and needs no PIN
+	Registered bool // The module is registered on a network, home or roaming
+	Roaming    bool // The network is a roaming network
+	Attached   bool // The module is attached to GPRS, checked once registered
+}
+
+// InitContext resets and initializes the module like Init, bounded by ctx.
+// It returns an error only if the module doesn't respond, can't be
+// configured or ctx is done; the status tells which steps succeeded. A
+// SIM that isn't ready or a registration still pending doesn't fail it,
+// so firmware can carry on with offline features and check Registered
+// again later. A SIM waiting for its PIN is unlocked with Config.PIN;
+// a rejected PIN fails with ErrIncorrectPIN and a SIM that needs its PUK
+// with ErrSIMPUKRequired. Without a reset pin the module is restarted with
+// AT+CFUN=1,1 like HardReset does, if it takes the command. If the
+// module's output is garbled it fails early with ErrBaudMismatch, unless
+// Config.BaudRate lets it recover the module's baud rate.
+func (d *Device) InitContext(ctx context.Context) (InitStatus, error) {
+	d.lock()
+	defer d.unlock()
+	return d.initialize(ctx, true, false)
+}
+
+// Reinit initializes a module that may still be running, e.g. after the
+// microcontroller woke from deep sleep, like InitContext but without the
+// hardware reset and its waits of ResetTime and StartupTime if the module
+// answers AT within ReinitProbeTimeout. The settings are applied again,
+// except for the connection mode if the module already has it, as it
+// can't be changed while a data session is up. A module that doesn't
+// answer is reset with the reset pin and initialized like InitContext;
+// without a reset pin it couldn't take AT+CFUN=1,1 either, so it is only
+// waited for.
+func (d *Device) Reinit(ctx context.Context) (InitStatus, error) {
+	d.lock()
+	defer d.unlock()
+
+	alive := d.sendContextTimeout(ctx, at, defaultResponseCheck, ReinitProbeTimeout) == nil
+	if !alive {
+		d.log(SubsystemPower, slog.LevelInfo, "module not responding, resetting")
+	}
+	return d.initialize(ctx, !alive && d.resetPin != nil, false)
+}
+
+// initialize runs InitContext with the lock held, without the hardware
+// reset for a module known to be running unless reset is set. If strict
+// is set it also fails, like Init, if the SIM isn't ready or the operator
+// can't be queried.
+func (d *Device) initialize(ctx context.Context, reset, strict bool) (InitStatus, error) {
+	// The module may have lost power since the settings were applied
+	d.forgetSettings()
+	d.streamLeft = 0
+	start := time.Now()
+
+	var status InitStatus
+	if reset {
+		restarted, err := true, error(nil)
+		if d.resetPin != nil {
+			err = d.hardResetContext(ctx)
+		} else {
+			restarted, err = d.restartContext(ctx)
+		}
+		if restarted || err != nil {
+			d.reportInit(start, InitStageReset, err)
+		}
+		if err != nil {
+			return status, err
+		}
+	}
+
+	err := d.sync(ctx)
+	if errors.Is(err, ErrBaudMismatch) {
+		d.log(SubsystemPower, slog.LevelWarn, "module output garbled", "error", err)
+		if d.recoverBaud(ctx) == nil {
+			err = d.sync(ctx)
+		}
+	}
+	d.reportInit(start, InitStageSync, err)
+	if err != nil {
+		return status, err
+	}
+	d.powerState = true
+	status.Responding = true
+
+	for _, cmd := range commands {
+		if bytes.Equal(cmd, cmdConnMode) {
+			cmd = d.connMode()
+			if !reset && d.connModeSet(cmd) {
+				continue
+			}
+		}
+		if err := d.sendContext(ctx, cmd, defaultResponseCheck); err != nil {
+			d.log(SubsystemCommand, slog.LevelError, "init failed on command", "command", cmd, "error", err)
+			d.reportInit(start, InitStageConfigured, err)
+			return status, err
+		}
+		// Small delay between commands for stability
+		if err := sleepContext(ctx, initCommandDelay); err != nil {
+			d.reportInit(start, InitStageConfigured, err)
+			return status, err
+		}
+	}
+	status.Configured = true
+	d.reportInit(start, InitStageConfigured, nil)
+
+	// The remaining steps are reported, not required
+	err = d.sendContext(ctx, cmdSimCheck, prefixCheck(simStatus))
+	if err == nil {
+		val, _ := d.parseValue(simStatus)
+		status.SIMReady = bytes.Equal(val, simReady)
+		if !status.SIMReady {
+			if status.SIMReady, err = d.unlockSIM(ctx, val); err != nil {
+				d.reportInit(start, InitStageSIMReady, err)
+				return status, err
+			}
+			if !status.SIMReady {
+				err = fmt.Errorf("%w: %s", ErrSIMNotReady, val)
+			}
+		}
+	}
+	d.reportInit(start, InitStageSIMReady, err)
+	if strict && err != nil {
+		if !errors.Is(err, ErrSIMNotReady) {
+			err = fmt.Errorf("%w: %w", ErrSIMNotReady, err)
+		}
+		return status, err
+	}
+
+	// Missing parts of the identity are logged, not reported
+	_, _ = d.identify(ctx, status.SIMReady)
+	caps := d.capabilities()
+	d.log(SubsystemCommand, slog.LevelInfo, "module identified", "variant", caps.Variant, "ssl", caps.SSL,
+		"bluetooth", caps.Bluetooth, "gnss", caps.GNSS)
+
+	err = d.sendContext(ctx, cmdRegistration, prefixCheck(registration))
+	if err == nil {
+		val, _ := d.parseValue(registration)
+		status.Registered, status.Roaming = parseRegistration(val)
+		err = registrationError(registrationStat(val))
+	}
+	d.reportInit(start, InitStageRegistered, err)
+
+	if status.Registered {
+		err = d.sendContext(ctx, cmdGprsAttachQuery, prefixCheck(gprsAttachStatus))
+		if err == nil {
+			val, _ := d.parseValue(gprsAttachStatus)
+			status.Attached = bytes.Equal(val, []byte("1"))
+			if !status.Attached {
+				err = ErrNotAttached
+			}
+		}
+		d.reportInit(start, InitStageGPRS, err)
+	}
+
+	if err := d.sendContext(ctx, cmdOperator, prefixCheck(operatorStatus)); err == nil {
+		// +COPS: <mode>,<format>,"<operator>"
+		if val, ok := d.parseValue(operatorStatus); ok {
+			if fields := bytes.Split(val, []byte(",")); len(fields) >= 3 {
+				d.Operator = string(bytes.Trim(fields[2], "\" "))
+			}
+		}
+	} else if strict {
+		return status, fmt.Errorf("failed to query operator: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return status, err
+	}
+
+	d.log(SubsystemPower, slog.LevelInfo, "initialized", "sim", status.SIMReady, "registered", status.Registered, "operator", d.OperatorName())
+	return status, nil
+}
+
+// connModeSet reports whether the module is in the connection mode cmd
+// sets, like +CIPMUX=1
+func (d *Device) connModeSet(cmd []byte) bool {
+	val, err := d.queryValue("+CIPMUX?")
+	_, mode, _ := bytes.Cut(cmd, []byte("="))
+	return err == nil && bytes.Equal(val, mode)
+}
+
+// parseRegistration parses the value of a registration status like 0,5
+// and reports whether the module is registered and whether it is roaming
+func parseRegistration(val []byte) (registered, roaming bool) {
+	stat := registrationStat(val)
+	return stat == regHome || stat == regRoaming, stat == regRoaming
+}
+
+// sync waits for the module to answer AT, as it may still be booting. It
+// gives up early with ErrBaudMismatch if the module's output is garbled.
+func (d *Device) sync(ctx context.Context) error {
+	d.watchBaud()
+	defer func() { d.baudWatch = false }()
+
+	for attempt := 0; attempt < initSyncAttempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, initSyncInterval); err != nil {
+				return err
+			}
+		}
+		if d.sendContext(ctx, at, defaultResponseCheck) == nil {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := d.baudMismatch(); err != nil {
+			return err
+		}
+	}
+	return ErrNotReady
+}
+
+// hardResetContext is hardReset with its waits bounded by ctx
+func (d *Device) hardResetContext(ctx context.Context) error {
+	d.log(SubsystemPower, slog.LevelDebug, "hardware reset")
+	d.resetPin.High()
+	if err := sleepContext(ctx, ResetTime); err != nil {
+		return err
+	}
+	d.resetPin.Low()
+	d.recordReset(true, ResetRequested)
+
+	// Wait for device to boot and stabilize
+	return d.waitReady(ctx)
+}
+
+// restartContext restarts the module with AT+CFUN=1,1 in place of the
+// hardware reset when there is no reset pin, like HardReset, and reports
+// whether it restarted. A module that doesn't take the command, e.g. one
+// still booting or at another baud rate, is left to the sync that follows.
+func (d *Device) restartContext(ctx context.Context) (bool, error) {
+	d.log(SubsystemPower, slog.LevelDebug, "software reset")
+	if err := d.exchange(cmdRestart, defaultResponseCheck, contextTimeout(ctx, DefaultTimeout)); err != nil {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		d.log(SubsystemPower, slog.LevelDebug, "restart not taken", "error", err)
+		return false, nil
+	}
+	d.recordReset(false, ResetRequested)
+
+	// Wait for device to boot and stabilize
+	return true, d.waitReady(ctx)
+}
+
+// sendContext sends a command, waiting for the response at most until
+// ctx's deadline or DefaultTimeout, whichever is sooner
+func (d *Device) sendContext(ctx context.Context, cmd []byte, checkFunc ResponseCheckFunc) error {
+	return d.sendContextTimeout(ctx, cmd, checkFunc, DefaultTimeout)
+}
+
+// sendContextTimeout is sendContext for commands that may take longer
+// than DefaultTimeout
+func (d *Device) sendContextTimeout(ctx context.Context, cmd []byte, checkFunc ResponseCheckFunc, timeout time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.sendRetrying(ctx, cmd, checkFunc, contextTimeout(ctx, timeout), true)
+}
+
+// contextExpired reports whether ctx is done or its deadline has passed,
+// which a command bounded by the deadline may notice first
+func contextExpired(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	return ctx.Err() != nil || (ok && !time.Now().Before(deadline))
+}
+
+// contextTimeout returns timeout, shortened to ctx's deadline if sooner
+func contextTimeout(ctx context.Context, timeout time.Duration) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline))
+	}
+	return timeout
+}
+
+// prefixCheck accepts a response line starting with prefix
+func prefixCheck(prefix []byte) ResponseCheckFunc {
+	return func(buffer []byte) error {
+		if bytes.HasPrefix(buffer, prefix) {
+			return nil
+		}
+		return defaultResponseCheck(buffer)
+	}
+}
+
+// imeiCheck accepts the IMEI, a line of digits
+func imeiCheck(buffer []byte) error {
+	for _, c := range buffer {
+		if c < '0' || c > '9' {
+			return defaultResponseCheck(buffer)
+		}
+	}
+	return nil
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,184 @@
+package sim800l
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDevice_InitContext(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT":          "\r\nOK\r\n",
+		"ATE0":        "\r\nOK\r\n",
+		"AT+CMEE=2":   "\r\nOK\r\n",
+		"AT+IPR=0":    "\r\nOK\r\n",
+		"AT+CFUN=1":   "\r\nOK\r\n",
+		"AT+CIPMUX=1": "\r\nOK\r\n",
+		"AT+CMGF=1":   "\r\nOK\r\n",
+		"AT+CPIN?":    "\r\n+CPIN: READY\r\n\r\nOK\r\n",
+		"AT+GSN":      "\r\n866782042145078\r\n\r\nOK\r\n",
+		// Still searching for a network
+		"AT+CREG?": "\r\n+CREG: 0,2\r\n\r\nOK\r\n",
+		"AT+COPS?": "\r\n+COPS: 0\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	status, err := d.InitContext(ctx)
+	if err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	expected := InitStatus{Responding: true, Configured: true, SIMReady: true}
+	if status != expected {
+		t.Errorf("expected %+v, got %+v", expected, status)
+	}
+	if d.IMEI != "866782042145078" {
+		t.Errorf("expected IMEI 866782042145078, got %q", d.IMEI)
+	}
+
+	// Registration completes later
+	modem.responses["AT+CREG?"] = "\r\n+CREG: 0,5\r\n\r\nOK\r\n"
+	modem.responses["AT+COPS?"] = "\r\n+COPS: 0,0,\"Vodafone\"\r\n\r\nOK\r\n"
+	modem.responses["AT+CGATT?"] = "\r\n+CGATT: 1\r\n\r\nOK\r\n"
+	status, err = d.InitContext(ctx)
+	if err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	if !status.Registered || !status.Roaming || d.Operator != "Vodafone" {
+		t.Errorf("expected roaming registration on Vodafone, got %+v on %q", status, d.Operator)
+	}
+}
+
+func TestDevice_InitContextDeadline(t *testing.T) {
+	// The module never answers
+	d := New(newMockModem(nil), nil, slog.New(slog.DiscardHandler))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	status, err := d.InitContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if status.Responding {
+		t.Error("expected the module to be reported as not responding")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("init returned after %v, long past the deadline", elapsed)
+	}
+}
+
+func TestDevice_Reinit(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT":         "\r\nOK\r\n",
+		"ATE0":       "\r\nOK\r\n",
+		"AT+CMEE=2":  "\r\nOK\r\n",
+		"AT+IPR=0":   "\r\nOK\r\n",
+		"AT+CFUN=1":  "\r\nOK\r\n",
+		"AT+CIPMUX?": "\r\n+CIPMUX: 1\r\n\r\nOK\r\n",
+		"AT+CMGF=1":  "\r\nOK\r\n",
+		"AT+CPIN?":   "\r\n+CPIN: READY\r\n\r\nOK\r\n",
+		"AT+GSN":     "\r\n866782042145078\r\n\r\nOK\r\n",
+		"AT+CREG?":   "\r\n+CREG: 0,1\r\n\r\nOK\r\n",
+		"AT+CGATT?":  "\r\n+CGATT: 1\r\n\r\nOK\r\n",
+		"AT+COPS?":   "\r\n+COPS: 0,0,\"Vodafone\"\r\n\r\nOK\r\n",
+	})
+	pin := &MockPin{}
+	d := New(modem, pin, slog.New(&MockHandler{t: t}))
+
+	start := time.Now()
+	status, err := d.Reinit(context.Background())
+	if err != nil {
+		t.Fatalf("reinit failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > StartupTime/2 {
+		t.Errorf("expected no reset waits, took %v", elapsed)
+	}
+	if !status.Configured || !status.Registered || status.Roaming {
+		t.Errorf("expected a configured, registered module, got %+v", status)
+	}
+	if resets := d.ResetCounters(); resets.Hard != 0 {
+		t.Errorf("expected no hardware reset, got %d", resets.Hard)
+	}
+	// The connection mode is kept, a data session may be up
+	for _, cmd := range modem.commands {
+		if cmd == "AT+CIPMUX=1" {
+			t.Error("connection mode set again")
+		}
+	}
+}
+
+func TestDevice_InitProgress(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT":          "\r\nOK\r\n",
+		"ATE0":        "\r\nOK\r\n",
+		"AT+CMEE=2":   "\r\nOK\r\n",
+		"AT+IPR=0":    "\r\nOK\r\n",
+		"AT+CFUN=1":   "\r\nOK\r\n",
+		"AT+CIPMUX=1": "\r\nOK\r\n",
+		"AT+CMGF=1":   "\r\nOK\r\n",
+		"AT+CPIN?":    "\r\n+CPIN: READY\r\n\r\nOK\r\n",
+		"AT+CREG?":    "\r\n+CREG: 0,2\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	var stages []InitProgress
+	d.Configure(Config{InitProgress: func(p InitProgress) { stages = append(stages, p) }})
+
+	if _, err := d.InitContext(context.Background()); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	expected := []InitStage{InitStageSync, InitStageConfigured, InitStageSIMReady, InitStageRegistered}
+	if len(stages) != len(expected) {
+		t.Fatalf("expected stages %v, got %+v", expected, stages)
+	}
+	for i, stage := range expected {
+		if stages[i].Stage != stage {
+			t.Errorf("report %d: expected %v, got %v", i, stage, stages[i].Stage)
+		}
+		if i > 0 && stages[i].Elapsed < stages[i-1].Elapsed {
+			t.Errorf("report %d: elapsed time went back", i)
+		}
+	}
+	// Registration stalls while the module searches for a network
+	if stages[2].Err != nil || !errors.Is(stages[3].Err, ErrNetworkSearching) {
+		t.Errorf("expected only registration to fail with ErrNetworkSearching, got %v, %v", stages[2].Err, stages[3].Err)
+	}
+}
+
+func TestDevice_InitFailsWithoutSIM(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT":          "\r\nOK\r\n",
+		"ATE0":        "\r\nOK\r\n",
+		"AT+CMEE=2":   "\r\nOK\r\n",
+		"AT+IPR=0":    "\r\nOK\r\n",
+		"AT+CFUN=1":   "\r\nOK\r\n",
+		"AT+CIPMUX=1": "\r\nOK\r\n",
+		"AT+CMGF=1":   "\r\nOK\r\n",
+		"AT+CPIN?":    "\r\n+CME ERROR: SIM not inserted\r\n",
+		"AT+CREG?":    "\r\n+CREG: 0,0\r\n\r\nOK\r\n",
+		"AT+COPS?":    "\r\n+COPS: 0\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	// InitContext carries on for offline features, Init fails as it always did
+	status, err := d.InitContext(context.Background())
+	if err != nil || !status.Configured || status.SIMReady {
+		t.Fatalf("expected a configured module without SIM, got %+v, %v", status, err)
+	}
+	if err := d.Init(); !errors.Is(err, ErrSIMNotReady) {
+		t.Errorf("expected ErrSIMNotReady, got %v", err)
+	}
+
+	// So does a failed operator query
+	modem.responses["AT+CPIN?"] = "\r\n+CPIN: READY\r\n\r\nOK\r\n"
+	modem.responses["AT+COPS?"] = "\r\nERROR\r\n"
+	if _, err := d.InitContext(context.Background()); err != nil {
+		t.Errorf("expected InitContext to carry on, got %v", err)
+	}
+	if err := d.Init(); err == nil {
+		t.Error("expected Init to fail on the operator query")
+	}
+}
@@ -0,0 +1,164 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains detection and refresh of a dead PDP context.
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// DefaultProbeHost is pinged to check the data session unless Config.ProbeHost is set
+const DefaultProbeHost = "8.8.8.8"
+
+const (
+	// ProbeTimeout bounds the ping sent by CheckIPStack
+	ProbeTimeout = 10 * time.Second
+
+	// pingTimeout is how long the module waits for the echo reply, in 100 ms units
+	pingTimeout = 50
+
+	// pingLost is the reply time the module reports for a lost echo request
+	pingLost = 600
+)
+
+var (
+	cmdPing    = []byte("+CIPPING") // Ping a remote host
+	pingStatus = []byte("+CIPPING") // Ping result line
+)
+
+var ErrIPStackDead = errors.New("IP stack not responding")
+
+// CheckIPStack pings the probe host to tell whether the data session
+// works. After a long idle period the module may still report an IP
+// address while the PDP context is dead and every Dial times out; in that
+// case CheckIPStack returns an error matching ErrIPStackDead.
+func (d *Device) CheckIPStack() error {
+	d.lock()
+	defer d.unlock()
+	return d.probe()
+}
+
+// probe pings the probe host with the lock held
+func (d *Device) probe() error {
+	if d.IP == "" {
+		return ErrNoIP
+	}
+	host := d.probeHost
+	if host == "" {
+		host = DefaultProbeHost
+	}
+
+	var buf [MaxCommandSize]byte
+	cmd := append(buf[:0], cmdPing...)
+	cmd = fmt.Appendf(cmd, "=\"%s\",1,32,%d", host, pingTimeout)
+	err := d.sendWithOptions(cmd, func(buffer []byte) error {
+		if bytes.HasPrefix(buffer, pingStatus) {
+			return nil
+		}
+		return defaultResponseCheck(buffer)
+	}, ProbeTimeout)
+	// The module refuses to ping without a working PDP context
+	var atErr *ATError
+	if errors.As(err, &atErr) || errors.Is(err, ErrTimeout) {
+		return fmt.Errorf("%w: %w", ErrIPStackDead, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	// +CIPPING: <n>,<host>,<reply time>,<ttl>
+	val, ok := d.parseValue(pingStatus)
+	if !ok {
+		return ErrUnexpectedResponse
+	}
+	fields := bytes.Split(val, []byte(","))
+	if len(fields) < 4 {
+		return ErrUnexpectedResponse
+	}
+	replyTime, err := strconv.Atoi(string(bytes.TrimSpace(fields[2])))
+	if err != nil {
+		return ErrUnexpectedResponse
+	}
+	if replyTime >= pingLost {
+		return fmt.Errorf("%w: no reply from %s", ErrIPStackDead, host)
+	}
+	return nil
+}
+
+// RefreshIPStack shuts the PDP context down and brings it up again with
+// the APN of the last Connect. Open connections and the listener are
+// closed by the module; their Read returns io.EOF.
+func (d *Device) RefreshIPStack() error {
+	d.lock()
+	defer d.unlock()
+	return d.refresh()
+}
+
+// refresh brings the PDP context up again with the lock held
+func (d *Device) refresh() error {
+	if !d.sessionUp {
+		return fmt.Errorf("%w: not connected", ErrNoIP)
+	}
+
+	if err := d.send(cmdShutPdp); err != nil {
+		return fmt.Errorf("failed to shut down PDP context: %w", err)
+	}
+	// Shutting down the PDP context closed every connection
+	for i := 0; i < MaxConnections; i++ {
+		if conn := d.connections[i]; conn != nil {
+			conn.dropped = true
+			conn.setState(StateClosed)
+			d.releaseConnection(uint8(i))
+		}
+	}
+	d.forgetListener()
+	d.IP = ""
+
+	if err := d.connect(context.Background(), d.gprs); err != nil {
+		return err
+	}
+	d.log(SubsystemCommand, slog.LevelInfo, "PDP context refreshed", "ip", d.IP)
+	d.emit(Event{Type: EventIPStackRefreshed})
+	return nil
+}
+
+// WatchIPStack calls CheckIPStack every interval until ctx is done and
+// refreshes the PDP context when it is dead. It pauses while the
+// application has the session down with Disconnect or DeactivateContext. Failed checks and refreshes
+// are logged and retried at the next interval.
+func (d *Device) WatchIPStack(ctx context.Context, interval time.Duration) error {
+	ctx, done, err := d.background(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		d.lock()
+		if !d.sessionUp {
+			d.unlock()
+			continue // Disconnected, nothing to watch
+		}
+		err := d.probe()
+		if errors.Is(err, ErrIPStackDead) {
+			d.log(SubsystemCommand, slog.LevelWarn, "PDP context dead, refreshing", "error", err)
+			err = d.refresh()
+		}
+		d.unlock()
+		if err != nil {
+			d.log(SubsystemCommand, slog.LevelWarn, "IP stack check failed", "error", err)
+		}
+	}
+}
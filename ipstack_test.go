@@ -0,0 +1,72 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_CheckIPStack(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		dead  bool
+	}{
+		{"reply", "\r\n+CIPPING: 1,\"8.8.8.8\",4,118\r\n\r\nOK\r\n", false},
+		{"lost", "\r\n+CIPPING: 1,\"8.8.8.8\",600,255\r\n\r\nOK\r\n", true},
+		{"error", "\r\nERROR\r\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modem := newMockModem(map[string]string{
+				"AT+CIPPING=\"8.8.8.8\",1,32,50": tt.reply,
+			})
+			d := New(modem, nil, slog.New(slog.DiscardHandler))
+			d.IP = "10.0.0.1"
+
+			err := d.CheckIPStack()
+			if dead := errors.Is(err, ErrIPStackDead); dead != tt.dead {
+				t.Errorf("expected dead %v, got error %v", tt.dead, err)
+			}
+		})
+	}
+}
+
+func TestDevice_RefreshIPStack(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CPAS":         "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		"AT+CIPSHUT":      "\r\nSHUT OK\r\n",
+		"AT+CGATT?":       "\r\n+CGATT: 1\r\n\r\nOK\r\n",
+		"AT+CIPMUX=1":     "\r\nOK\r\n",
+		"AT+CSTT=\"iot\"": "\r\nOK\r\n",
+		"AT+CIICR":        "\r\nOK\r\n",
+		"AT+CIFSR":        "\r\n10.0.0.2\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	var events []EventType
+	d.SetEventHandler(func(e Event) { events = append(events, e.Type) })
+
+	if err := d.RefreshIPStack(); !errors.Is(err, ErrNoIP) {
+		t.Fatalf("expected ErrNoIP before Connect, got %v", err)
+	}
+
+	if err := d.Connect("iot", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	conn := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+	d.connections[0] = conn
+	d.IP = "10.0.0.1"
+
+	if err := d.RefreshIPStack(); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if d.IP != "10.0.0.2" {
+		t.Errorf("expected IP 10.0.0.2, got %s", d.IP)
+	}
+	if conn.State() != StateClosed || d.connections[0] != nil {
+		t.Error("expected the connection to be closed by the refresh")
+	}
+	if len(events) != 1 || events[0] != EventIPStackRefreshed {
+		t.Errorf("expected an IPStackRefreshed event, got %v", events)
+	}
+}
@@ -0,0 +1,228 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains an allocation-free JSON encoder for telemetry payloads.
+package sim800l
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+var ErrBufferFull = errors.New("buffer full")
+
+const hexDigits = "0123456789abcdef"
+
+// JSONBuffer builds a JSON document in a caller-provided buffer without
+// allocating, so telemetry payloads can be written to a Connection without
+// fmt or encoding/json. Errors are sticky: once the buffer is full every
+// further call is ignored and Err returns ErrBufferFull.
+//
+//	var storage [128]byte
+//	var j sim800l.JSONBuffer
+//	j.Reset(storage[:])
+//	j.BeginObject()
+//	j.StringField("id", "pico-1")
+//	j.FloatField("temp", 21.5, 1)
+//	j.EndObject()
+//	conn.Write(j.Bytes())
+type JSONBuffer struct {
+	buf       []byte // Output storage, never grown
+	n         int    // Bytes written to buf
+	needComma bool   // A value was written and the next one needs a separator
+	err       error  // First error encountered
+}
+
+// Reset discards any output and starts a new document in buf
+func (j *JSONBuffer) Reset(buf []byte) {
+	j.buf = buf
+	j.n = 0
+	j.needComma = false
+	j.err = nil
+}
+
+// Bytes returns the encoded document
+func (j *JSONBuffer) Bytes() []byte {
+	return j.buf[:j.n]
+}
+
+// Len returns the number of bytes encoded so far
+func (j *JSONBuffer) Len() int {
+	return j.n
+}
+
+// Err returns ErrBufferFull if the document did not fit in the buffer
+func (j *JSONBuffer) Err() error {
+	return j.err
+}
+
+// BeginObject starts a JSON object
+func (j *JSONBuffer) BeginObject() {
+	j.separator()
+	j.writeByte('{')
+	j.needComma = false
+}
+
+// EndObject ends the current JSON object
+func (j *JSONBuffer) EndObject() {
+	j.writeByte('}')
+	j.needComma = true
+}
+
+// BeginArray starts a JSON array
+func (j *JSONBuffer) BeginArray() {
+	j.separator()
+	j.writeByte('[')
+	j.needComma = false
+}
+
+// EndArray ends the current JSON array
+func (j *JSONBuffer) EndArray() {
+	j.writeByte(']')
+	j.needComma = true
+}
+
+// Key writes an object key; it must be followed by a value
+func (j *JSONBuffer) Key(k string) {
+	j.String(k)
+	j.writeByte(':')
+	j.needComma = false
+}
+
+// String writes a quoted and escaped string value
+func (j *JSONBuffer) String(s string) {
+	j.separator()
+	j.writeByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			j.writeByte('\\')
+			j.writeByte(c)
+		case c == '\n':
+			j.writeString(`\n`)
+		case c == '\r':
+			j.writeString(`\r`)
+		case c == '\t':
+			j.writeString(`\t`)
+		case c < 0x20:
+			j.writeString(`\u00`)
+			j.writeByte(hexDigits[c>>4])
+			j.writeByte(hexDigits[c&0xF])
+		default:
+			j.writeByte(c)
+		}
+	}
+	j.writeByte('"')
+	j.needComma = true
+}
+
+// Int writes an integer value
+func (j *JSONBuffer) Int(v int64) {
+	j.separator()
+	j.appendNumber(strconv.AppendInt(j.free(), v, 10))
+}
+
+// Uint writes an unsigned integer value
+func (j *JSONBuffer) Uint(v uint64) {
+	j.separator()
+	j.appendNumber(strconv.AppendUint(j.free(), v, 10))
+}
+
+// Float writes a floating point value with prec digits after the decimal point.
+// NaN and infinities have no JSON representation and are written as null.
+func (j *JSONBuffer) Float(v float64, prec int) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		j.Null()
+		return
+	}
+	j.separator()
+	j.appendNumber(strconv.AppendFloat(j.free(), v, 'f', prec, 64))
+}
+
+// Bool writes a boolean value
+func (j *JSONBuffer) Bool(v bool) {
+	j.separator()
+	if v {
+		j.writeString("true")
+	} else {
+		j.writeString("false")
+	}
+	j.needComma = true
+}
+
+// Null writes a null value
+func (j *JSONBuffer) Null() {
+	j.separator()
+	j.writeString("null")
+	j.needComma = true
+}
+
+// StringField writes a key with a string value
+func (j *JSONBuffer) StringField(k, v string) {
+	j.Key(k)
+	j.String(v)
+}
+
+// IntField writes a key with an integer value
+func (j *JSONBuffer) IntField(k string, v int64) {
+	j.Key(k)
+	j.Int(v)
+}
+
+// FloatField writes a key with a floating point value
+func (j *JSONBuffer) FloatField(k string, v float64, prec int) {
+	j.Key(k)
+	j.Float(v, prec)
+}
+
+// BoolField writes a key with a boolean value
+func (j *JSONBuffer) BoolField(k string, v bool) {
+	j.Key(k)
+	j.Bool(v)
+}
+
+// separator writes a comma if a value precedes the next one
+func (j *JSONBuffer) separator() {
+	if j.needComma {
+		j.writeByte(',')
+	}
+}
+
+// free returns the unused part of the buffer as an empty slice to append to
+func (j *JSONBuffer) free() []byte {
+	return j.buf[j.n:j.n:len(j.buf)]
+}
+
+// appendNumber accepts digits appended by strconv to free()
+func (j *JSONBuffer) appendNumber(digits []byte) {
+	// strconv only reallocates when the digits do not fit
+	if j.err != nil || len(digits) > len(j.buf)-j.n {
+		j.err = ErrBufferFull
+		return
+	}
+	j.n += len(digits)
+	j.needComma = true
+}
+
+func (j *JSONBuffer) writeString(s string) {
+	if j.err != nil {
+		return
+	}
+	if len(j.buf)-j.n < len(s) {
+		j.err = ErrBufferFull
+		return
+	}
+	j.n += copy(j.buf[j.n:], s)
+}
+
+func (j *JSONBuffer) writeByte(c byte) {
+	if j.err != nil {
+		return
+	}
+	if j.n >= len(j.buf) {
+		j.err = ErrBufferFull
+		return
+	}
+	j.buf[j.n] = c
+	j.n++
+}
@@ -0,0 +1,73 @@
+package sim800l
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestJSONBuffer(t *testing.T) {
+	var storage [256]byte
+	var j JSONBuffer
+	j.Reset(storage[:])
+
+	j.BeginObject()
+	j.StringField("id", "pico \"1\"\n")
+	j.IntField("seq", -42)
+	j.FloatField("temp", 21.456, 2)
+	j.FloatField("bad", math.NaN(), 2)
+	j.BoolField("ok", true)
+	j.Key("samples")
+	j.BeginArray()
+	j.Uint(1)
+	j.Uint(2)
+	j.BeginObject()
+	j.EndObject()
+	j.EndArray()
+	j.EndObject()
+
+	if err := j.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"id":"pico \"1\"\n","seq":-42,"temp":21.46,"bad":null,"ok":true,"samples":[1,2,{}]}`
+	if got := string(j.Bytes()); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if !json.Valid(j.Bytes()) {
+		t.Errorf("invalid JSON: %s", j.Bytes())
+	}
+}
+
+func TestJSONBuffer_Full(t *testing.T) {
+	var storage [12]byte
+	var j JSONBuffer
+	j.Reset(storage[:])
+
+	j.BeginObject()
+	j.IntField("n", 1234567890)
+	j.EndObject()
+
+	if !errors.Is(j.Err(), ErrBufferFull) {
+		t.Fatalf("expected ErrBufferFull, got %v", j.Err())
+	}
+	if j.Len() > len(storage) {
+		t.Errorf("wrote past the buffer: %d bytes", j.Len())
+	}
+}
+
+func TestJSONBuffer_NoAllocs(t *testing.T) {
+	var storage [128]byte
+	var j JSONBuffer
+	allocs := testing.AllocsPerRun(100, func() {
+		j.Reset(storage[:])
+		j.BeginObject()
+		j.StringField("id", "pico-1")
+		j.IntField("rssi", -71)
+		j.FloatField("volt", 3.712, 3)
+		j.EndObject()
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
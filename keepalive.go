@@ -0,0 +1,87 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains keep-alive probing of idle connections.
+package sim800l
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// DefaultKeepAliveIdle is how long a connection may be idle before
+// KeepAlive probes it. Carriers often drop NAT mappings after a few
+// minutes without traffic.
+const DefaultKeepAliveIdle = 2 * time.Minute
+
+// KeepAliveConfig controls how KeepAlive probes idle connections
+type KeepAliveConfig struct {
+	Idle time.Duration // Probe connections without traffic for this long, DefaultKeepAliveIdle if zero
+
+	// Payload is written to idle TCP connections, keeping the carrier's
+	// NAT mapping alive; it must be something the server ignores. If
+	// empty, or for UDP connections, AT+CIPSTATUS checks them instead.
+	Payload []byte
+}
+
+// KeepAlive probes connections that have been idle for cfg.Idle until ctx
+// is done. A connection whose probe fails or that the module no longer
+// has is closed, so its Read returns io.EOF; a ReconnectingConn then
+// dials it again.
+func (d *Device) KeepAlive(ctx context.Context, cfg KeepAliveConfig) error {
+	ctx, done, err := d.background(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	if cfg.Idle <= 0 {
+		cfg.Idle = DefaultKeepAliveIdle
+	}
+	ticker := time.NewTicker(cfg.Idle / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		d.lock()
+		d.keepAlive(cfg)
+		d.unlock()
+	}
+}
+
+// keepAlive probes the idle connections once with the lock held
+func (d *Device) keepAlive(cfg KeepAliveConfig) {
+	check := false
+	for id, conn := range d.connections {
+		if conn == nil || conn.state != StateConnected {
+			continue
+		}
+		last := conn.lastActivity
+		if last.IsZero() {
+			last = conn.connectedAt
+		}
+		if time.Since(last) < cfg.Idle {
+			continue
+		}
+		if len(cfg.Payload) == 0 || conn.Type != TCP {
+			check = true
+			continue
+		}
+
+		if _, err := d.sendData(uint8(id), cfg.Payload); err != nil {
+			d.log(SubsystemData, slog.LevelWarn, "keep-alive failed, closing connection", "id", id, "error", err)
+			d.abortConnection(uint8(id))
+			conn.dropped = true
+			d.markClosed(uint8(id))
+		}
+	}
+
+	// The status query closes the connections the module no longer has
+	if check {
+		if _, err := d.connectionStatus(); err != nil {
+			d.log(SubsystemData, slog.LevelWarn, "keep-alive status check failed", "error", err)
+		}
+	}
+}
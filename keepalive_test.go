@@ -0,0 +1,65 @@
+package sim800l
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDevice_KeepAlive(t *testing.T) {
+	s1, s2, s3 := slot(t, 1), slot(t, 2), slot(t, 3)
+	modem := newMockModem(map[string]string{
+		"AT+CIPSEND=0,1": "\r\n> ",
+		"AT+CIPSEND=1,1": "\r\n> ",
+		"AT+CIPCLOSE=1":  "\r\n1, CLOSE OK\r\n",
+		"AT+CIPSTATUS": "\r\nOK\r\n\r\nSTATE: IP PROCESSING\r\n\r\n" +
+			"C: 0,0,\"TCP\",\"10.0.0.5\",\"80\",\"CONNECTED\"\r\n" +
+			"C: 1,0,\"TCP\",\"10.0.0.6\",\"80\",\"CONNECTED\"\r\n" +
+			"C: 2,0,\"UDP\",\"10.0.0.7\",\"53\",\"CLOSED\"\r\n" +
+			"C: 3,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 4,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 5,,\"\",\"\",\"\",\"INITIAL\"\r\n",
+	})
+	modem.dataReply = "\r\n0, SEND OK\r\n"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	idle := time.Now().Add(-time.Hour)
+	alive := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d, connectedAt: idle}
+	busy := &Connection{ID: 3, Type: TCP, state: StateConnected, Device: d, connectedAt: time.Now()}
+	udp := &Connection{ID: 2, Type: UDP, state: StateConnected, Device: d, connectedAt: idle}
+	d.connections[0], d.connections[s2], d.connections[s3] = alive, udp, busy
+
+	d.lock()
+	d.keepAlive(KeepAliveConfig{Idle: time.Minute, Payload: []byte("\n")})
+	d.unlock()
+
+	if n := modem.commandCount("AT+CIPSEND=0,1"); n != 1 {
+		t.Errorf("expected one probe on the idle connection, got %d", n)
+	}
+	if modem.commandCount("AT+CIPSEND=3,1") != 0 {
+		t.Error("expected no probe on the busy connection")
+	}
+	if stats, _ := alive.Stats(); time.Since(stats.LastActivity) > time.Second {
+		t.Errorf("expected the probe to count as activity, got %v", stats.LastActivity)
+	}
+	// The UDP connection is checked with the status query
+	if _, err := udp.Read(make([]byte, 8)); err != io.EOF {
+		t.Errorf("expected the dead UDP connection to be closed, got %v", err)
+	}
+
+	// A failed probe closes the connection
+	failing := &Connection{ID: 1, Type: TCP, state: StateConnected, Device: d, connectedAt: idle}
+	d.connections[s1] = failing
+	modem.dataReply = "\r\n1, SEND FAIL\r\n"
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	_ = d.KeepAlive(ctx, KeepAliveConfig{Idle: 100 * time.Millisecond, Payload: []byte("\n")})
+	if _, err := failing.Read(make([]byte, 8)); err != io.EOF {
+		t.Errorf("expected the connection to be closed after a failed probe, got %v", err)
+	}
+	if modem.commandCount("AT+CIPCLOSE=1") != 1 {
+		t.Error("expected the dead connection to be closed on the module")
+	}
+}
@@ -0,0 +1,61 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains closing the driver and its background loops.
+package sim800l
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrDeviceClosed = errors.New("device closed")
+
+// Close releases the driver, e.g. before the microcontroller goes to deep
+// sleep. It stops the background loops, Run, KeepAlive, WatchIPStack,
+// WatchOutbox and SuperviseSession, and waits for them to return, then
+// closes the open connections, shuts down the PDP context and detaches
+// from GPRS like Shutdown, within ShutdownTimeout. With
+// Config.PowerDownOnClose set it powers the module down as well.
+//
+// Background loops return context.Canceled when Close stops them and
+// ErrDeviceClosed if started afterwards. Commands can still be sent, e.g.
+// to wake the module up again with Init.
+func (d *Device) Close() error {
+	// A loop registers under loopsMu, so once lifetime is cancelled none
+	// can be added while Wait runs
+	d.loopsMu.Lock()
+	if d.endLifetime != nil {
+		d.endLifetime()
+	}
+	d.loopsMu.Unlock()
+	d.loops.Wait()
+
+	d.lock()
+	powerDown := d.powerDownOnClose
+	d.unlock()
+	return d.shutdown(context.Background(), powerDown)
+}
+
+// background registers a background loop bound by ctx. It returns ctx,
+// cancelled as well when the device is closed, and the function the loop
+// calls when it returns, or ErrDeviceClosed.
+func (d *Device) background(ctx context.Context) (context.Context, func(), error) {
+	if d.lifetime == nil {
+		// Device not made by New, it can't be closed
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, nil
+	}
+	d.loopsMu.Lock()
+	defer d.loopsMu.Unlock()
+	if d.lifetime.Err() != nil {
+		return nil, nil, ErrDeviceClosed
+	}
+
+	d.loops.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(d.lifetime, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		d.loops.Done()
+	}, nil
+}
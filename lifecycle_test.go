@@ -0,0 +1,93 @@
+package sim800l
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDevice_Close(t *testing.T) {
+	modem := newShutdownModem()
+	pin := &MockPin{state: true}
+	d := New(modem, pin, slog.New(slog.DiscardHandler))
+	d.IP = "10.0.0.1"
+	d.connections[0] = &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+
+	// Background loops stop when the device is closed
+	stopped := make(chan error, 2)
+	go func() { stopped <- d.Run(context.Background()) }()
+	go func() { stopped <- d.KeepAlive(context.Background(), KeepAliveConfig{Idle: time.Hour}) }()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	for range 2 {
+		select {
+		case err := <-stopped:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+		default:
+			t.Fatal("background loop still running after Close")
+		}
+	}
+
+	expected := []string{"AT+CIPACK=0", "AT+CIPCLOSE=0", "AT+CIPSHUT", "AT+CGATT=0"}
+	if len(modem.commands) != len(expected) {
+		t.Fatalf("expected commands %q, got %q", expected, modem.commands)
+	}
+	for i, cmd := range expected {
+		if modem.commands[i] != cmd {
+			t.Errorf("command %d: expected %q, got %q", i, cmd, modem.commands[i])
+		}
+	}
+	if !pin.Get() {
+		t.Error("reset pin released without powering down")
+	}
+
+	if err := d.WatchIPStack(context.Background(), time.Second); !errors.Is(err, ErrDeviceClosed) {
+		t.Errorf("expected ErrDeviceClosed starting a loop after Close, got %v", err)
+	}
+}
+
+func TestDevice_ClosePowersDown(t *testing.T) {
+	modem := newShutdownModem()
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Configure(Config{PowerDownOnClose: true})
+	d.powerState = true
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if last := modem.commands[len(modem.commands)-1]; last != "AT+CPOWD=1" || d.powerState {
+		t.Errorf("expected the module powered down, last command %q", last)
+	}
+}
+
+func TestDevice_CloseWhileStartingLoops(t *testing.T) {
+	modem := newShutdownModem()
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	// Every loop started around Close either doesn't start or is stopped
+	// by it
+	stopped := make(chan error, 8)
+	for range cap(stopped) {
+		go func() { stopped <- d.WatchIPStack(context.Background(), time.Hour) }()
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	for range cap(stopped) {
+		select {
+		case err := <-stopped:
+			if !errors.Is(err, context.Canceled) && !errors.Is(err, ErrDeviceClosed) {
+				t.Errorf("expected context.Canceled or ErrDeviceClosed, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("background loop still running after Close")
+		}
+	}
+}
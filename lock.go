@@ -0,0 +1,92 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the locking that serializes access to the module.
+package sim800l
+
+import (
+	"slices"
+	"time"
+)
+
+// lockRetryInterval is the time a regular operation waits before trying
+// again to take the device while an alert is pending
+const lockRetryInterval = 10 * time.Millisecond
+
+// lock takes the device for a regular operation. It yields to alerts
+// waiting in lockUrgent, so they run before anything queued behind them.
+func (d *Device) lock() {
+	for {
+		d.mu.Lock()
+		if d.urgent.Load() == 0 {
+			return
+		}
+		d.mu.Unlock()
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// unlock releases the device taken with lock
+func (d *Device) unlock() {
+	d.mu.Unlock()
+}
+
+// lockUrgent takes the device ahead of regular operations. Operations
+// that hold it for a long time notice through preempted and give up.
+func (d *Device) lockUrgent() {
+	d.urgent.Add(1)
+	d.mu.Lock()
+	d.urgentHeld = true
+}
+
+// unlockUrgent releases the device taken with lockUrgent
+func (d *Device) unlockUrgent() {
+	d.urgentHeld = false
+	d.mu.Unlock()
+	d.urgent.Add(-1)
+}
+
+// preempted reports whether the operation holding the lock should give
+// up the device because an alert is waiting for it
+func (d *Device) preempted() bool {
+	return !d.urgentHeld && d.urgent.Load() > 0
+}
+
+// takeSendTurn waits with the lock held until connection id may send the
+// next chunk of a write. Connections with a write in progress send one
+// chunk each in turn, so a large write doesn't hold up the writers of
+// other connections; a write that just started goes before the writes
+// that have already sent a chunk.
+func (d *Device) takeSendTurn(id uint8) {
+	if !slices.Contains(d.sendQueue[:d.sendQueueLen], id) {
+		copy(d.sendQueue[d.sendFresh+1:], d.sendQueue[d.sendFresh:d.sendQueueLen])
+		d.sendQueue[d.sendFresh] = id
+		d.sendFresh++
+		d.sendQueueLen++
+	}
+	for d.sendQueue[0] != id {
+		d.unlock()
+		time.Sleep(lockRetryInterval)
+		d.lock()
+	}
+}
+
+// passSendTurn moves connection id to the back of the send queue after
+// it sent a chunk
+func (d *Device) passSendTurn(id uint8) {
+	d.leaveSendQueue(id)
+	d.sendQueue[d.sendQueueLen] = id
+	d.sendQueueLen++
+}
+
+// leaveSendQueue removes connection id from the send queue when its
+// write ends
+func (d *Device) leaveSendQueue(id uint8) {
+	i := slices.Index(d.sendQueue[:d.sendQueueLen], id)
+	if i < 0 {
+		return
+	}
+	if i < d.sendFresh {
+		d.sendFresh--
+	}
+	copy(d.sendQueue[i:], d.sendQueue[i+1:d.sendQueueLen])
+	d.sendQueueLen--
+}
@@ -0,0 +1,36 @@
+//go:build sim800l_atomiclock
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains a lightweight lock for single-core TinyGo targets.
+package sim800l
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// mutex is a lock on an atomic flag that yields to other goroutines while
+// it waits. It is smaller and cheaper than sync.Mutex and has no wait
+// queue, which suits single-core targets where the driver is used from a
+// few goroutines. Masking interrupts isn't an option: the UART needs them
+// while the lock is held.
+type mutex struct {
+	locked atomic.Bool
+}
+
+// Lock takes the lock, yielding until it is free
+func (m *mutex) Lock() {
+	for !m.locked.CompareAndSwap(false, true) {
+		runtime.Gosched()
+	}
+}
+
+// TryLock takes the lock if it is free and reports whether it did
+func (m *mutex) TryLock() bool {
+	return m.locked.CompareAndSwap(false, true)
+}
+
+// Unlock releases the lock
+func (m *mutex) Unlock() {
+	m.locked.Store(false)
+}
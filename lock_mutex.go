@@ -0,0 +1,12 @@
+//go:build !sim800l_atomiclock
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the default mutex used to serialize access to the module.
+package sim800l
+
+import "sync"
+
+// mutex is the lock protecting the module and the driver's shared state.
+// Build with the sim800l_atomiclock tag to use a spin lock on an atomic
+// flag instead.
+type mutex = sync.Mutex
@@ -0,0 +1,56 @@
+package sim800l
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMutex_Exclusive(t *testing.T) {
+	var m mutex
+	var wg sync.WaitGroup
+	counter := 0
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				m.Lock()
+				counter++
+				m.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if counter != 4000 {
+		t.Errorf("expected counter 4000, got %d", counter)
+	}
+}
+
+func TestDevice_LockYieldsToUrgent(t *testing.T) {
+	d := &Device{}
+	d.lock()
+
+	order := make(chan string, 2)
+	go func() {
+		d.lockUrgent()
+		order <- "urgent"
+		d.unlockUrgent()
+	}()
+	// Let the alert start waiting before the regular operation queues up
+	for d.urgent.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		d.lock()
+		order <- "regular"
+		d.unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	d.unlock()
+
+	if first := <-order; first != "urgent" {
+		t.Errorf("expected the urgent operation first, got %s", first)
+	}
+	<-order
+}
@@ -0,0 +1,52 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains per-subsystem logging.
+package sim800l
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Subsystem identifies a part of the driver that logs independently
+type Subsystem uint8
+
+const (
+	SubsystemCommand Subsystem = iota // AT command engine
+	SubsystemData                     // Connection data path
+	SubsystemURC                      // Unsolicited result code dispatch
+	SubsystemPower                    // Reset and power management
+	numSubsystems
+)
+
+func (s Subsystem) String() string {
+	switch s {
+	case SubsystemCommand:
+		return "command"
+	case SubsystemData:
+		return "data"
+	case SubsystemURC:
+		return "urc"
+	case SubsystemPower:
+		return "power"
+	default:
+		return "unknown"
+	}
+}
+
+// SetLogLevel sets the minimum level logged by a subsystem. It can be
+// called at any time, e.g. to debug the data path while keeping command
+// logging quiet. Records passing this level are still filtered by the
+// logger's own handler.
+func (d *Device) SetLogLevel(s Subsystem, level slog.Level) {
+	if s < numSubsystems {
+		d.logLevels[s].Set(level)
+	}
+}
+
+// log writes a record to the logger if the subsystem's level allows it
+func (d *Device) log(s Subsystem, level slog.Level, msg string, args ...any) {
+	if level < d.logLevels[s].Level() {
+		return
+	}
+	d.logger.Log(context.Background(), level, msg, args...)
+}
@@ -0,0 +1,48 @@
+package sim800l
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+)
+
+// countHandler counts the records that reach it
+type countHandler struct {
+	count int
+}
+
+func (h *countHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *countHandler) Handle(context.Context, slog.Record) error {
+	h.count++
+	return nil
+}
+func (h *countHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *countHandler) WithGroup(string) slog.Handler      { return h }
+
+func TestDevice_LogLevels(t *testing.T) {
+	h := &countHandler{}
+	d := New(newMockModem(nil), nil, slog.New(h))
+
+	// Everything passes until configured otherwise
+	d.log(SubsystemData, slog.LevelDebug, "data")
+	if h.count != 1 {
+		t.Fatalf("expected debug record to pass by default, got %d records", h.count)
+	}
+
+	d.Configure(Config{LogLevels: map[Subsystem]slog.Level{
+		SubsystemCommand: slog.LevelWarn,
+	}})
+	d.log(SubsystemCommand, slog.LevelInfo, "quiet")
+	d.log(SubsystemCommand, slog.LevelError, "loud")
+	d.log(SubsystemData, slog.LevelDebug, "data")
+	if h.count != 3 {
+		t.Errorf("expected 3 records, got %d", h.count)
+	}
+
+	// Levels can be changed at runtime
+	d.SetLogLevel(SubsystemData, slog.LevelError)
+	d.log(SubsystemData, slog.LevelWarn, "data")
+	if h.count != 3 {
+		t.Errorf("expected data warning to be filtered, got %d records", h.count)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !sim800l_maxconn1 && !sim800l_maxconn2
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the default number of connection slots.
+package sim800l
+
+// MaxConnections is the number of connections the driver keeps state for.
+// The SIM800L supports up to 6 connections (0-5). Build with the
+// sim800l_maxconn1 or sim800l_maxconn2 tag to reclaim the RAM of the
+// slots a device never uses.
+const MaxConnections = 5
@@ -0,0 +1,9 @@
+//go:build sim800l_maxconn1
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file limits the driver to a single connection slot.
+package sim800l
+
+// MaxConnections is the number of connections the driver keeps state for,
+// reduced to one by the sim800l_maxconn1 tag
+const MaxConnections = 1
@@ -0,0 +1,8 @@
+//go:build sim800l_maxconn1 && sim800l_maxconn2
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file fails the build when both connection slot tags are set.
+package sim800l
+
+// The sim800l_maxconn1 and sim800l_maxconn2 tags are exclusive, set one
+const _ = sim800l_maxconn1_and_sim800l_maxconn2_are_exclusive
@@ -0,0 +1,361 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains an adapter to the socket API of tinygo.org/x/drivers.
+package sim800l
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"time"
+)
+
+// Socket constants of the netdev API, with the values of tinygo.org/x/drivers/netdev
+const (
+	AF_INET     = 0x2
+	SOCK_STREAM = 0x1
+	SOCK_DGRAM  = 0x2
+	IPPROTO_TCP = 0x06
+	IPPROTO_UDP = 0x11
+)
+
+var ErrNoSocket = errors.New("no free socket")
+
+// NetEvent is a change of the network link reported to the callback given
+// to NetNotify, with the values of tinygo.org/x/drivers/netlink.Event
+type NetEvent int
+
+const (
+	NetEventUp   NetEvent = iota // The GPRS session is up
+	NetEventDown                 // The GPRS session is down
+)
+
+// netdevSocket is a socket of the adapter. It gets a connection slot on
+// the module only once it is connected or accepted.
+type netdevSocket struct {
+	used       bool
+	connecting bool // Connect is dialing
+	protocol   int
+	port       uint16      // Port given to Bind
+	conn       *Connection // Set once connected or accepted
+	listener   *Listener   // Set once listening
+}
+
+// Netdev adapts a Device to the socket API of the networking drivers in
+// tinygo.org/x/drivers, so firmware written for ESP-AT or WiFiNINA boards
+// can switch to the SIM800L with few changes. Its methods match the
+// netdev.Netdever interface, so it can be handed to netdev.UseNetdev, and
+// net.Dial and net/http on TinyGo use the module. The GPRS session takes
+// the place of the WiFi access point: NetConnect, NetDisconnect, NetNotify
+// and GetHardwareAddr make up the netlink.Netlinker interface on TinyGo,
+// and take the driver's own types on other builds.
+//
+// Sockets map onto the module's connection slots, so at most
+// MaxConnections can be open. TLS sockets aren't supported.
+type Netdev struct {
+	device  *Device
+	config  GPRSConfig
+	mu      mutex
+	sockets [MaxConnections]netdevSocket
+	notify  atomic.Pointer[func(NetEvent)] // Link change callback, if set
+}
+
+// NewNetdev returns an adapter for d, which must be configured and
+// initialized. NetConnect uses cfg to set up the GPRS session.
+func NewNetdev(d *Device, cfg GPRSConfig) *Netdev {
+	n := &Netdev{device: d, config: cfg}
+	d.lock()
+	d.netdev = n
+	d.unlock()
+	return n
+}
+
+// connect sets up the GPRS session with cfg and reports the link up
+func (n *Netdev) connect(cfg GPRSConfig) error {
+	if err := n.device.ConnectWithConfig(cfg); err != nil {
+		return err
+	}
+	n.emit(NetEventUp)
+	return nil
+}
+
+// NetDisconnect closes all sockets and tears down the GPRS session
+func (n *Netdev) NetDisconnect() {
+	for fd := range n.sockets {
+		_ = n.Close(fd)
+	}
+	_ = n.device.Disconnect()
+	n.emit(NetEventDown)
+}
+
+// notifyFunc sets the link change callback, nil to stop the calls
+func (n *Netdev) notifyFunc(cb func(NetEvent)) {
+	if cb == nil {
+		n.notify.Store(nil)
+		return
+	}
+	n.notify.Store(&cb)
+}
+
+// emit passes a link change to the NetNotify callback, if any. It runs
+// with the device locked when the session drops, so it takes no lock.
+func (n *Netdev) emit(e NetEvent) {
+	if cb := n.notify.Load(); cb != nil {
+		(*cb)(e)
+	}
+}
+
+// GetHardwareAddr fails with ErrNotSupported: a cellular link has no MAC
+// address. The module is identified by its IMEI instead.
+func (n *Netdev) GetHardwareAddr() (net.HardwareAddr, error) {
+	return nil, fmt.Errorf("%w: no hardware address", ErrNotSupported)
+}
+
+// GetHostByName resolves name to its first IPv4 address
+func (n *Netdev) GetHostByName(name string) (netip.Addr, error) {
+	if addr, err := netip.ParseAddr(name); err == nil {
+		return addr, nil
+	}
+	addrs, err := n.device.LookupHost(name)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	for _, a := range addrs {
+		if addr, err := netip.ParseAddr(a); err == nil {
+			return addr, nil
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("%w: %s", ErrLookupFailed, name)
+}
+
+// Addr returns the IP address of the GPRS session
+func (n *Netdev) Addr() (netip.Addr, error) {
+	d := n.device
+	d.lock()
+	ip := d.IP
+	d.unlock()
+	if ip == "" {
+		return netip.Addr{}, ErrNoIP
+	}
+	return netip.ParseAddr(ip)
+}
+
+// Socket creates a TCP or UDP socket and returns its descriptor
+func (n *Netdev) Socket(domain int, stype int, protocol int) (int, error) {
+	if domain != AF_INET {
+		return -1, fmt.Errorf("%w: address family %d", ErrNotSupported, domain)
+	}
+	switch {
+	case stype == SOCK_STREAM && (protocol == 0 || protocol == IPPROTO_TCP):
+		protocol = IPPROTO_TCP
+	case stype == SOCK_DGRAM && (protocol == 0 || protocol == IPPROTO_UDP):
+		protocol = IPPROTO_UDP
+	default:
+		return -1, fmt.Errorf("%w: socket type %d, protocol %d", ErrNotSupported, stype, protocol)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for fd := range n.sockets {
+		if !n.sockets[fd].used {
+			n.sockets[fd] = netdevSocket{used: true, protocol: protocol}
+			return fd, nil
+		}
+	}
+	return -1, ErrNoSocket
+}
+
+// socket returns the socket with descriptor fd, with n.mu held
+func (n *Netdev) socket(fd int) (*netdevSocket, error) {
+	if fd < 0 || fd >= len(n.sockets) || !n.sockets[fd].used {
+		return nil, ErrInvalidConnection
+	}
+	return &n.sockets[fd], nil
+}
+
+// Bind sets the port a TCP socket listens on. The module picks the local
+// port of outgoing connections itself.
+func (n *Netdev) Bind(sockfd int, ip netip.AddrPort) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	s, err := n.socket(sockfd)
+	if err != nil {
+		return err
+	}
+	s.port = ip.Port()
+	return nil
+}
+
+// Connect connects the socket to host, or to ip if host is empty. A host
+// name is resolved by the module.
+func (n *Netdev) Connect(sockfd int, host string, ip netip.AddrPort) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	s, err := n.socket(sockfd)
+	if err != nil {
+		return err
+	}
+	if s.conn != nil || s.listener != nil || s.connecting {
+		return fmt.Errorf("%w: socket %d in use", ErrBadParameter, sockfd)
+	}
+
+	network := "tcp"
+	if s.protocol == IPPROTO_UDP {
+		network = "udp"
+	}
+	address := ip.String()
+	if host != "" {
+		address = fmt.Sprintf("%s:%d", host, ip.Port())
+	}
+
+	s.connecting = true
+	n.mu.Unlock()
+
+	// Dial without holding n.mu, so the other sockets stay usable
+	d := n.device
+	d.lock()
+	conn, err := d.dial(context.Background(), network, address)
+	d.unlock()
+
+	n.mu.Lock()
+	if !s.connecting {
+		// Close cleared the socket while dialing
+		if err == nil {
+			_ = conn.Close()
+		}
+		return ErrConnectionClosed
+	}
+	s.connecting = false
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// Listen starts the module's TCP server on the port given to Bind. The
+// module has a single server, so only one socket can listen at a time;
+// backlog is ignored.
+func (n *Netdev) Listen(sockfd int, backlog int) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	s, err := n.socket(sockfd)
+	if err != nil {
+		return err
+	}
+	if s.protocol != IPPROTO_TCP {
+		return fmt.Errorf("%w: listening on UDP", ErrNotSupported)
+	}
+	if s.conn != nil || s.listener != nil || s.connecting {
+		return fmt.Errorf("%w: socket %d in use", ErrBadParameter, sockfd)
+	}
+	l, err := n.device.Listen("tcp", s.port)
+	if err != nil {
+		return err
+	}
+	s.listener = l.(*Listener)
+	return nil
+}
+
+// Accept waits for a connection on a listening socket and returns a new
+// socket for it, with the address of the remote host
+func (n *Netdev) Accept(sockfd int) (int, netip.AddrPort, error) {
+	n.mu.Lock()
+	s, err := n.socket(sockfd)
+	var l *Listener
+	if err == nil {
+		l = s.listener
+	}
+	n.mu.Unlock()
+	if err != nil {
+		return -1, netip.AddrPort{}, err
+	}
+	if l == nil {
+		return -1, netip.AddrPort{}, fmt.Errorf("%w: socket %d not listening", ErrBadParameter, sockfd)
+	}
+
+	// Wait without holding n.mu, so the other sockets stay usable
+	c, err := l.Accept()
+	if err != nil {
+		return -1, netip.AddrPort{}, err
+	}
+	conn := c.(*Connection)
+	remote, _ := netip.ParseAddrPort(conn.RemoteIP + ":" + conn.RemotePort)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for fd := range n.sockets {
+		if !n.sockets[fd].used {
+			n.sockets[fd] = netdevSocket{used: true, protocol: IPPROTO_TCP, conn: conn}
+			return fd, remote, nil
+		}
+	}
+	_ = conn.Close()
+	return -1, netip.AddrPort{}, ErrNoSocket
+}
+
+// conn returns the connection of a connected socket
+func (n *Netdev) conn(sockfd int) (*Connection, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	s, err := n.socket(sockfd)
+	if err != nil {
+		return nil, err
+	}
+	if s.conn == nil {
+		return nil, ErrConnectionNotEstablished
+	}
+	return s.conn, nil
+}
+
+// Send writes buf to a connected socket, giving up at deadline unless it
+// is zero. flags is ignored.
+func (n *Netdev) Send(sockfd int, buf []byte, flags int, deadline time.Time) (int, error) {
+	conn, err := n.conn(sockfd)
+	if err != nil {
+		return 0, err
+	}
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		return 0, err
+	}
+	return conn.Write(buf)
+}
+
+// Recv reads received data from a connected socket into buf, waiting
+// until deadline unless it is zero. flags is ignored.
+func (n *Netdev) Recv(sockfd int, buf []byte, flags int, deadline time.Time) (int, error) {
+	conn, err := n.conn(sockfd)
+	if err != nil {
+		return 0, err
+	}
+	return conn.ReadInto(buf, deadline)
+}
+
+// Close closes the socket and frees its descriptor
+func (n *Netdev) Close(sockfd int) error {
+	n.mu.Lock()
+	s, err := n.socket(sockfd)
+	if err != nil {
+		n.mu.Unlock()
+		return err
+	}
+	closed := *s
+	*s = netdevSocket{}
+	n.mu.Unlock()
+
+	switch {
+	case closed.conn != nil:
+		return closed.conn.Close()
+	case closed.listener != nil:
+		return closed.listener.Close()
+	}
+	return nil
+}
+
+// SetSockOpt sets a socket option. The module has none the driver can
+// set per socket, so it always returns an error matching ErrNotSupported.
+func (n *Netdev) SetSockOpt(sockfd int, level int, opt int, value interface{}) error {
+	return fmt.Errorf("%w: socket option %d", ErrNotSupported, opt)
+}
@@ -0,0 +1,45 @@
+//go:build tinygo
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the link methods of the netdev adapter with the types
+// of tinygo.org/x/drivers.
+package sim800l
+
+import (
+	"tinygo.org/x/drivers/netdev"
+	"tinygo.org/x/drivers/netlink"
+)
+
+var (
+	_ netdev.Netdever   = (*Netdev)(nil)
+	_ netlink.Netlinker = (*Netdev)(nil)
+)
+
+// NetConnect sets up the GPRS session, the equivalent of joining an access
+// point, with the configuration given to NewNetdev. The SSID of params, if
+// set, replaces its APN, and the connect timeout bounds the wait for
+// network registration. params may be nil.
+func (n *Netdev) NetConnect(params *netlink.ConnectParams) error {
+	cfg := n.config
+	if params != nil {
+		if params.Ssid != "" {
+			cfg.APN = params.Ssid
+		}
+		if params.ConnectTimeout > 0 {
+			cfg.RegistrationTimeout = params.ConnectTimeout
+		}
+	}
+	return n.connect(cfg)
+}
+
+// NetNotify sets the function called when the link goes up or down: when
+// NetConnect brings it up, NetDisconnect takes it down, or the session
+// drops and is restored. On a drop cb runs inside the driver and must not
+// call back into the Device. Pass nil to stop the calls.
+func (n *Netdev) NetNotify(cb func(netlink.Event)) {
+	if cb == nil {
+		n.notifyFunc(nil)
+		return
+	}
+	n.notifyFunc(func(e NetEvent) { cb(netlink.Event(e)) })
+}
@@ -0,0 +1,23 @@
+//go:build !tinygo
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the link methods of the netdev adapter on builds
+// without tinygo.org/x/drivers.
+package sim800l
+
+// NetConnect sets up the GPRS session, the equivalent of joining an access
+// point. A nil cfg uses the one given to NewNetdev.
+func (n *Netdev) NetConnect(cfg *GPRSConfig) error {
+	if cfg == nil {
+		cfg = &n.config
+	}
+	return n.connect(*cfg)
+}
+
+// NetNotify sets the function called when the link goes up or down: when
+// NetConnect brings it up, NetDisconnect takes it down, or the session
+// drops and is restored. On a drop cb runs inside the driver and must not
+// call back into the Device. Pass nil to stop the calls.
+func (n *Netdev) NetNotify(cb func(NetEvent)) {
+	n.notifyFunc(cb)
+}
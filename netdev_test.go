@@ -0,0 +1,103 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestNetdev(t *testing.T) {
+	modem := newSessionModem()
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	n := NewNetdev(d, GPRSConfig{APN: "internet"})
+	var events []NetEvent
+	// NetNotify takes netlink.Event on TinyGo
+	n.notifyFunc(func(e NetEvent) { events = append(events, e) })
+
+	if err := n.NetConnect(nil); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if addr, err := n.Addr(); err != nil || addr != netip.MustParseAddr("10.0.0.1") {
+		t.Errorf("expected address 10.0.0.1, got %v, %v", addr, err)
+	}
+
+	fd, err := n.Socket(AF_INET, SOCK_STREAM, IPPROTO_TCP)
+	if err != nil {
+		t.Fatalf("socket failed: %v", err)
+	}
+	if err := n.Connect(fd, "example.com", netip.AddrPortFrom(netip.Addr{}, 80)); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	if sent, err := n.Send(fd, []byte("ping"), 0, time.Time{}); err != nil || sent != 4 {
+		t.Fatalf("expected 4 bytes sent, got %d, %v", sent, err)
+	}
+	var buf [16]byte
+	got, err := n.Recv(fd, buf[:], 0, time.Now().Add(time.Second))
+	if err != nil || string(buf[:got]) != "ping" {
+		t.Fatalf("expected the echo, got %q, %v", buf[:got], err)
+	}
+	if err := n.SetSockOpt(fd, 0xffff, 0x8, true); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+	if err := n.Close(fd); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if _, err := n.Send(fd, []byte("ping"), 0, time.Time{}); !errors.Is(err, ErrInvalidConnection) {
+		t.Errorf("expected ErrInvalidConnection after close, got %v", err)
+	}
+
+	n.NetDisconnect()
+	if _, err := n.Addr(); !errors.Is(err, ErrNoIP) {
+		t.Errorf("expected ErrNoIP after disconnect, got %v", err)
+	}
+	if len(events) != 2 || events[0] != NetEventUp || events[1] != NetEventDown {
+		t.Errorf("expected the link to go up and down, got %v", events)
+	}
+	if _, err := n.GetHardwareAddr(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestNetdev_Socket(t *testing.T) {
+	n := NewNetdev(New(newMockModem(nil), nil, slog.New(slog.DiscardHandler)), GPRSConfig{})
+
+	if _, err := n.Socket(10, SOCK_STREAM, IPPROTO_TCP); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported for IPv6, got %v", err)
+	}
+	if _, err := n.Socket(AF_INET, SOCK_STREAM, IPPROTO_UDP); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported for UDP over a stream, got %v", err)
+	}
+	for i := 0; i < MaxConnections; i++ {
+		if _, err := n.Socket(AF_INET, SOCK_DGRAM, 0); err != nil {
+			t.Fatalf("socket %d failed: %v", i, err)
+		}
+	}
+	if _, err := n.Socket(AF_INET, SOCK_DGRAM, 0); !errors.Is(err, ErrNoSocket) {
+		t.Errorf("expected ErrNoSocket, got %v", err)
+	}
+	if err := n.Listen(0, 1); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported listening on UDP, got %v", err)
+	}
+}
+
+func TestNetdev_SessionLost(t *testing.T) {
+	modem := newSessionModem()
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	n := NewNetdev(d, GPRSConfig{APN: "internet"})
+	var events []NetEvent
+	n.notifyFunc(func(e NetEvent) { events = append(events, e) })
+
+	if err := n.NetConnect(nil); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	// The network drops the session
+	modem.inject("\r\n+PDP: DEACT\r\n")
+	if err := d.Poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if len(events) != 2 || events[1] != NetEventDown {
+		t.Errorf("expected the link to go down with the session, got %v", events)
+	}
+}
@@ -0,0 +1,32 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the lookup of operator names by numeric PLMN.
+package sim800l
+
+import "strings"
+
+// OperatorName returns the name of the operator with the numeric PLMN, the
+// MCC followed by the MNC as reported with AT+COPS=3,2, e.g. "26201". The
+// table is only compiled in with the sim800l_operators build tag, to save
+// flash; without it no name is found.
+func OperatorName(plmn string) (string, bool) {
+	// operatorTable holds one "<plmn> <name>" line per operator
+	for table := operatorTable; table != ""; {
+		line, rest, _ := strings.Cut(table, "\n")
+		table = rest
+		code, name, _ := strings.Cut(line, " ")
+		if code == plmn {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// OperatorName returns the name of the network operator, looked up in the
+// operator table if the module reported a numeric PLMN, so it can be
+// logged either way
+func (d *Device) OperatorName() string {
+	if name, ok := OperatorName(d.Operator); ok {
+		return name
+	}
+	return d.Operator
+}
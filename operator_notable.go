@@ -0,0 +1,8 @@
+//go:build !sim800l_operators
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the empty operator table used by default.
+package sim800l
+
+// operatorTable is empty unless built with the sim800l_operators tag
+const operatorTable = ""
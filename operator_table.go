@@ -0,0 +1,88 @@
+//go:build sim800l_operators
+
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the table of operator names by numeric PLMN.
+package sim800l
+
+// operatorTable maps the numeric PLMN of common operators to their names,
+// one "<plmn> <name>" line each. It is a single string constant so it
+// stays in flash.
+const operatorTable = "" +
+	"20201 Cosmote\n" +
+	"20205 Vodafone GR\n" +
+	"20404 Vodafone NL\n" +
+	"20408 KPN\n" +
+	"20416 Odido\n" +
+	"20601 Proximus\n" +
+	"20610 Orange BE\n" +
+	"20801 Orange F\n" +
+	"20810 SFR\n" +
+	"20815 Free Mobile\n" +
+	"20820 Bouygues Telecom\n" +
+	"21401 Vodafone ES\n" +
+	"21403 Orange ES\n" +
+	"21407 Movistar\n" +
+	"21630 Telekom HU\n" +
+	"21670 Vodafone HU\n" +
+	"22201 TIM\n" +
+	"22210 Vodafone IT\n" +
+	"22288 WINDTRE\n" +
+	"22601 Vodafone RO\n" +
+	"22610 Orange RO\n" +
+	"22801 Swisscom\n" +
+	"22802 Sunrise\n" +
+	"22803 Salt\n" +
+	"23001 T-Mobile CZ\n" +
+	"23002 O2 CZ\n" +
+	"23003 Vodafone CZ\n" +
+	"23201 A1 AT\n" +
+	"23203 Magenta\n" +
+	"23410 O2 UK\n" +
+	"23415 Vodafone UK\n" +
+	"23420 Three UK\n" +
+	"23430 EE\n" +
+	"23801 TDC\n" +
+	"24001 Telia SE\n" +
+	"24201 Telenor NO\n" +
+	"24405 Elisa\n" +
+	"24491 Telia FI\n" +
+	"25001 MTS\n" +
+	"25002 MegaFon\n" +
+	"25099 Beeline\n" +
+	"25501 Vodafone UA\n" +
+	"25503 Kyivstar\n" +
+	"26001 Plus\n" +
+	"26002 T-Mobile PL\n" +
+	"26003 Orange PL\n" +
+	"26006 Play\n" +
+	"26201 Telekom DE\n" +
+	"26202 Vodafone DE\n" +
+	"26203 O2 DE\n" +
+	"26801 Vodafone PT\n" +
+	"26806 MEO\n" +
+	"28401 A1 BG\n" +
+	"28405 Yettel BG\n" +
+	"28601 Turkcell\n" +
+	"28602 Vodafone TR\n" +
+	"302220 Telus\n" +
+	"302610 Bell\n" +
+	"302720 Rogers\n" +
+	"310260 T-Mobile US\n" +
+	"310410 AT&T\n" +
+	"311480 Verizon\n" +
+	"334020 Telcel\n" +
+	"44010 NTT docomo\n" +
+	"44020 SoftBank\n" +
+	"45005 SK Telecom\n" +
+	"46000 China Mobile\n" +
+	"46001 China Unicom\n" +
+	"50501 Telstra\n" +
+	"50502 Optus\n" +
+	"50503 Vodafone AU\n" +
+	"52501 Singtel\n" +
+	"53001 One NZ\n" +
+	"65501 Vodacom\n" +
+	"65510 MTN ZA\n" +
+	"72402 TIM BR\n" +
+	"72405 Claro BR\n" +
+	"72406 Vivo\n"
@@ -0,0 +1,37 @@
+package sim800l
+
+import "testing"
+
+func TestDevice_OperatorName(t *testing.T) {
+	d := &Device{Operator: "Example Mobile"}
+	if got := d.OperatorName(); got != "Example Mobile" {
+		t.Errorf("expected the operator name unchanged, got %q", got)
+	}
+
+	// Codes missing from the table are returned as reported
+	d.Operator = "99999"
+	if got := d.OperatorName(); got != "99999" {
+		t.Errorf("expected an unknown code unchanged, got %q", got)
+	}
+	if _, ok := OperatorName(""); ok {
+		t.Error("expected no name for an empty code")
+	}
+
+	d.Operator = "26201"
+	name, ok := OperatorName("26201")
+	if ok != d.Supports(FeatureOperatorNames) {
+		t.Fatalf("expected lookups to match FeatureOperatorNames, got %v", ok)
+	}
+	if !ok {
+		t.Skip("built without the sim800l_operators tag")
+	}
+	if name != "Telekom DE" || d.OperatorName() != "Telekom DE" {
+		t.Errorf("expected Telekom DE, got %q", name)
+	}
+	if name, _ := OperatorName("310410"); name != "AT&T" {
+		t.Errorf("expected a three digit MNC to match, got %q", name)
+	}
+	if _, ok := OperatorName("2620"); ok {
+		t.Error("expected a partial code not to match")
+	}
+}
@@ -0,0 +1,223 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the persistent outbox for at-least-once delivery.
+package sim800l
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	MaxOutboxMessages    = 16               // Messages the outbox holds
+	MaxOutboxPayload     = 512              // Largest TCP payload in the outbox
+	OutboxDeliverTimeout = 30 * time.Second // Wait for a TCP payload to be acknowledged
+)
+
+var ErrOutboxFull = errors.New("outbox full")
+
+// OutboxKind tells how an outbox message is delivered
+type OutboxKind uint8
+
+const (
+	OutboxSMS OutboxKind = iota // Text sent as an SMS to a phone number
+	OutboxTCP                   // Payload sent to a host:port and acknowledged by it on the TCP level
+)
+
+// String returns the name of the outbox kind
+func (k OutboxKind) String() string {
+	switch k {
+	case OutboxSMS:
+		return "SMS"
+	case OutboxTCP:
+		return "TCP"
+	default:
+		return "unknown"
+	}
+}
+
+// OutboxMessage is a message waiting in the outbox
+type OutboxMessage struct {
+	ID       uint32     // Assigned by Enqueue
+	Kind     OutboxKind // How the message is delivered
+	To       string     // Phone number or host:port
+	Payload  []byte     // Text of the SMS or the TCP payload
+	Attempts uint32     // Failed delivery attempts so far
+}
+
+// OutboxStore keeps the outbox across restarts of the microcontroller,
+// e.g. in flash. SaveOutbox is called with the device's lock held every
+// time the outbox changes, so it must not call back into the Device.
+type OutboxStore interface {
+	LoadOutbox() ([]OutboxMessage, error)
+	SaveOutbox(msgs []OutboxMessage) error
+}
+
+// Enqueue adds a message to the outbox and returns its ID. With
+// Config.OutboxStore set, the message is saved before Enqueue returns, so
+// it survives power loss; if it can't be saved it isn't queued. Messages
+// are delivered by FlushOutbox or WatchOutbox.
+func (d *Device) Enqueue(kind OutboxKind, to string, payload []byte) (uint32, error) {
+	switch {
+	case to == "":
+		return 0, ErrBadParameter
+	case kind == OutboxSMS && len(payload) > MaxSMSLength:
+		return 0, fmt.Errorf("%w: SMS longer than %d bytes", ErrBadParameter, MaxSMSLength)
+	case kind == OutboxTCP && (len(payload) == 0 || len(payload) > MaxOutboxPayload):
+		return 0, fmt.Errorf("%w: payload of %d bytes", ErrBadParameter, len(payload))
+	case kind > OutboxTCP:
+		return 0, ErrBadParameter
+	}
+
+	d.lock()
+	defer d.unlock()
+	if len(d.outbox) >= MaxOutboxMessages {
+		return 0, ErrOutboxFull
+	}
+	d.outboxID++
+	d.outbox = append(d.outbox, OutboxMessage{
+		ID:      d.outboxID,
+		Kind:    kind,
+		To:      to,
+		Payload: append([]byte(nil), payload...),
+	})
+	if err := d.saveOutbox(); err != nil {
+		d.outbox = d.outbox[:len(d.outbox)-1]
+		return 0, fmt.Errorf("failed to save outbox: %w", err)
+	}
+	return d.outboxID, nil
+}
+
+// Outbox returns the messages waiting in the outbox
+func (d *Device) Outbox() []OutboxMessage {
+	d.lock()
+	defer d.unlock()
+	return append([]OutboxMessage(nil), d.outbox...)
+}
+
+// FlushOutbox tries to deliver every message in the outbox once and
+// returns how many were delivered, with the last delivery error. A
+// message leaves the outbox only after it was delivered, so a power loss
+// in between sends it again: delivery is at least once.
+func (d *Device) FlushOutbox(ctx context.Context) (int, error) {
+	// Deliver the messages queued now, one per lock, so other operations
+	// don't wait for the whole outbox
+	var ids [MaxOutboxMessages]uint32
+	d.lock()
+	n := len(d.outbox)
+	for i := range d.outbox {
+		ids[i] = d.outbox[i].ID
+	}
+	d.unlock()
+
+	delivered := 0
+	var lastErr error
+	for _, id := range ids[:n] {
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+		d.lock()
+		err := d.deliverQueued(ctx, id)
+		d.unlock()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		delivered++
+	}
+	return delivered, lastErr
+}
+
+// deliverQueued delivers the outbox message with the given ID, with the
+// lock held. A message removed in the meantime counts as delivered.
+func (d *Device) deliverQueued(ctx context.Context, id uint32) error {
+	i := 0
+	for i < len(d.outbox) && d.outbox[i].ID != id {
+		i++
+	}
+	if i == len(d.outbox) {
+		return nil
+	}
+	msg := &d.outbox[i]
+
+	var err error
+	switch msg.Kind {
+	case OutboxSMS:
+		err = d.sendSMS(msg.To, string(msg.Payload))
+	case OutboxTCP:
+		err = d.sendAcked(ctx, msg.To, msg.Payload, nil, OutboxDeliverTimeout)
+	default:
+		err = ErrBadParameter
+	}
+	if err != nil {
+		msg.Attempts++
+		d.log(SubsystemCommand, slog.LevelWarn, "outbox delivery failed",
+			"id", id, "kind", msg.Kind, "attempts", msg.Attempts, "error", err)
+		if err := d.saveOutbox(); err != nil {
+			d.log(SubsystemCommand, slog.LevelWarn, "failed to save outbox", "error", err)
+		}
+		return fmt.Errorf("failed to deliver message %d: %w", id, err)
+	}
+
+	d.log(SubsystemCommand, slog.LevelInfo, "outbox message delivered", "id", id, "kind", msg.Kind)
+	d.outbox = append(d.outbox[:i], d.outbox[i+1:]...)
+	if err := d.saveOutbox(); err != nil {
+		// The message stays saved and is sent again after a restart
+		d.log(SubsystemCommand, slog.LevelWarn, "failed to save outbox", "error", err)
+	}
+	return nil
+}
+
+// WatchOutbox flushes the outbox every interval until ctx is done, so
+// messages loaded from Config.OutboxStore after a restart, or queued while
+// the network was down, are retried
+func (d *Device) WatchOutbox(ctx context.Context, interval time.Duration) error {
+	ctx, done, err := d.background(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if _, err := d.FlushOutbox(ctx); err != nil && ctx.Err() == nil {
+			d.log(SubsystemCommand, slog.LevelWarn, "outbox not empty", "error", err)
+		}
+	}
+}
+
+// loadOutbox replaces the outbox with the messages kept by the store
+func (d *Device) loadOutbox() {
+	if d.outboxStore == nil {
+		return
+	}
+	msgs, err := d.outboxStore.LoadOutbox()
+	if err != nil {
+		d.log(SubsystemCommand, slog.LevelWarn, "failed to load outbox", "error", err)
+		return
+	}
+	if len(msgs) > MaxOutboxMessages {
+		d.log(SubsystemCommand, slog.LevelWarn, "dropping outbox messages", "count", len(msgs)-MaxOutboxMessages)
+		msgs = msgs[:MaxOutboxMessages]
+	}
+	d.outbox = append(d.outbox[:0], msgs...)
+	for _, msg := range d.outbox {
+		d.outboxID = max(d.outboxID, msg.ID)
+	}
+}
+
+// saveOutbox hands the outbox to the store, if any
+func (d *Device) saveOutbox() error {
+	if d.outboxStore == nil {
+		return nil
+	}
+	return d.outboxStore.SaveOutbox(d.outbox)
+}
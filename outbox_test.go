@@ -0,0 +1,106 @@
+package sim800l
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+// memoryOutboxStore keeps the outbox in memory
+type memoryOutboxStore struct {
+	msgs []OutboxMessage
+	err  error // Returned by SaveOutbox
+}
+
+func (s *memoryOutboxStore) LoadOutbox() ([]OutboxMessage, error) {
+	return append([]OutboxMessage(nil), s.msgs...), nil
+}
+
+func (s *memoryOutboxStore) SaveOutbox(msgs []OutboxMessage) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.msgs = append(s.msgs[:0], msgs...)
+	return nil
+}
+
+func TestDevice_Outbox(t *testing.T) {
+	store := &memoryOutboxStore{}
+	modem := newMockModem(map[string]string{
+		`AT+CMGS="+15550100"`: "\r\n+CMS ERROR: 38\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Configure(Config{OutboxStore: store})
+
+	sms, err := d.Enqueue(OutboxSMS, "+15550100", []byte("door open"))
+	if err != nil {
+		t.Fatalf("enqueue failed: %v", err)
+	}
+	if _, err := d.Enqueue(OutboxTCP, "example.com:9000", []byte(`{"door":1}`)); err != nil {
+		t.Fatalf("enqueue failed: %v", err)
+	}
+	if len(store.msgs) != 2 {
+		t.Fatalf("expected 2 saved messages, got %d", len(store.msgs))
+	}
+
+	// Neither the SMS nor, without an IP address, the payload gets through
+	if n, err := d.FlushOutbox(context.Background()); n != 0 || !errors.Is(err, ErrNoIP) {
+		t.Errorf("expected nothing delivered and ErrNoIP, got %d, %v", n, err)
+	}
+	if store.msgs[0].Attempts != 1 || store.msgs[1].Attempts != 1 {
+		t.Errorf("expected one failed attempt each, got %+v", store.msgs)
+	}
+
+	// After a restart the messages are loaded and retried
+	modem = newMockModem(map[string]string{
+		`AT+CMGS="+15550100"`: "\r\n> ",
+	})
+	modem.dataReply = "\r\n+CMGS: 7\r\n\r\nOK\r\n"
+	d = New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Configure(Config{OutboxStore: store})
+	if got := d.Outbox(); len(got) != 2 || got[0].ID != sms || string(got[0].Payload) != "door open" {
+		t.Fatalf("expected the saved messages, got %+v", got)
+	}
+	if n, _ := d.FlushOutbox(context.Background()); n != 1 {
+		t.Errorf("expected the SMS to be delivered, got %d", n)
+	}
+	if len(store.msgs) != 1 || store.msgs[0].Kind != OutboxTCP || store.msgs[0].Attempts != 2 {
+		t.Errorf("expected the payload left with two attempts, got %+v", store.msgs)
+	}
+	if id, err := d.Enqueue(OutboxSMS, "+15550100", []byte("door closed")); err != nil || id != sms+2 {
+		t.Errorf("expected ID %d, got %d, %v", sms+2, id, err)
+	}
+}
+
+func TestDevice_Enqueue(t *testing.T) {
+	store := &memoryOutboxStore{}
+	d := New(newMockModem(map[string]string{}), nil, slog.New(slog.DiscardHandler))
+	d.Configure(Config{OutboxStore: store})
+
+	if _, err := d.Enqueue(OutboxSMS, "+15550100", make([]byte, MaxSMSLength+1)); !errors.Is(err, ErrBadParameter) {
+		t.Errorf("expected ErrBadParameter for a long SMS, got %v", err)
+	}
+	if _, err := d.Enqueue(OutboxTCP, "", []byte("x")); !errors.Is(err, ErrBadParameter) {
+		t.Errorf("expected ErrBadParameter without a receiver, got %v", err)
+	}
+
+	// A message that can't be saved isn't queued
+	store.err = errors.New("flash full")
+	if _, err := d.Enqueue(OutboxSMS, "+15550100", []byte("x")); !errors.Is(err, store.err) {
+		t.Errorf("expected the store error, got %v", err)
+	}
+	if got := d.Outbox(); len(got) != 0 {
+		t.Errorf("expected an empty outbox, got %+v", got)
+	}
+	store.err = nil
+
+	for i := 0; i < MaxOutboxMessages; i++ {
+		if _, err := d.Enqueue(OutboxSMS, "+15550100", []byte("x")); err != nil {
+			t.Fatalf("enqueue %d failed: %v", i, err)
+		}
+	}
+	if _, err := d.Enqueue(OutboxSMS, "+15550100", []byte("x")); !errors.Is(err, ErrOutboxFull) {
+		t.Errorf("expected ErrOutboxFull, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains definition and activation of PDP contexts.
+package sim800l
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// MaxPDPContexts is the number of PDP contexts the module can define,
+// with context IDs 1 to MaxPDPContexts
+const MaxPDPContexts = 3
+
+var (
+	cmdDefineContext  = []byte("+CGDCONT")  // Define a PDP context
+	cmdContextActive  = []byte("+CGACT")    // Activate or deactivate a PDP context
+	cmdListContexts   = []byte("+CGDCONT?") // List the defined PDP contexts
+	cmdContextsActive = []byte("+CGACT?")   // List the state of the PDP contexts
+)
+
+// PDPType is the packet data protocol of a PDP context
+type PDPType string
+
+const (
+	PDPTypeIP  PDPType = "IP"  // Internet Protocol, version 4
+	PDPTypePPP PDPType = "PPP" // Point to Point Protocol
+)
+
+// PDPContext describes a PDP context defined in the module
+type PDPContext struct {
+	CID    int     // Context ID, 1 to MaxPDPContexts
+	Type   PDPType // Packet data protocol
+	APN    string  // Access point name
+	Active bool    // The context is activated
+}
+
+// DefinePDPContext defines PDP context cid with the given protocol and
+// APN using AT+CGDCONT, so contexts for data and e.g. SMS over IP can use
+// separate APNs. The definition lasts until the module restarts. Context 1
+// is the one Connect brings up with AT+CIICR.
+func (d *Device) DefinePDPContext(cid int, pdpType PDPType, apn string) error {
+	if cid < 1 || cid > MaxPDPContexts || (pdpType != PDPTypeIP && pdpType != PDPTypePPP) {
+		return ErrBadParameter
+	}
+
+	d.lock()
+	defer d.unlock()
+
+	var buf [MaxCommandSize]byte
+	cmd := append(buf[:0], cmdDefineContext...)
+	cmd = fmt.Appendf(cmd, "=%d,\"%s\",\"%s\"", cid, pdpType, apn)
+	if err := d.send(cmd); err != nil {
+		return fmt.Errorf("failed to define PDP context %d: %w", cid, err)
+	}
+	return nil
+}
+
+// ActivatePDPContext activates PDP context cid with AT+CGACT. The module
+// must be attached to GPRS, see Attach.
+func (d *Device) ActivatePDPContext(cid int) error {
+	return d.setPDPContextActive(cid, true)
+}
+
+// DeactivatePDPContext deactivates PDP context cid with AT+CGACT
+func (d *Device) DeactivatePDPContext(cid int) error {
+	return d.setPDPContextActive(cid, false)
+}
+
+// setPDPContextActive activates or deactivates PDP context cid
+func (d *Device) setPDPContextActive(cid int, active bool) error {
+	if cid < 1 || cid > MaxPDPContexts {
+		return ErrBadParameter
+	}
+
+	d.lock()
+	defer d.unlock()
+
+	state := 0
+	if active {
+		state = 1
+	}
+	var buf [MaxCommandSize]byte
+	cmd := append(buf[:0], cmdContextActive...)
+	cmd = fmt.Appendf(cmd, "=%d,%d", state, cid)
+	if err := d.sendWithOptions(cmd, defaultResponseCheck, ConnectTimeout); err != nil {
+		if active {
+			return fmt.Errorf("failed to activate PDP context %d: %w", cid, err)
+		}
+		return fmt.Errorf("failed to deactivate PDP context %d: %w", cid, err)
+	}
+	return nil
+}
+
+// PDPContexts returns the PDP contexts defined in the module and whether
+// each is active
+func (d *Device) PDPContexts() ([]PDPContext, error) {
+	d.lock()
+	defer d.unlock()
+
+	contexts := make([]PDPContext, 0, MaxPDPContexts)
+	err := d.readList(cmdListContexts, cmdDefineContext, func(val []byte) error {
+		// +CGDCONT: <cid>,"<type>","<apn>","<address>",<d_comp>,<h_comp>
+		fields := bytes.Split(val, []byte(","))
+		if len(fields) < 3 {
+			return ErrUnexpectedResponse
+		}
+		cid, err := strconv.Atoi(string(bytes.TrimSpace(fields[0])))
+		if err != nil {
+			return ErrUnexpectedResponse
+		}
+		if len(contexts) < MaxPDPContexts {
+			contexts = append(contexts, PDPContext{
+				CID:  cid,
+				Type: PDPType(bytes.Trim(fields[1], "\" ")),
+				APN:  string(bytes.Trim(fields[2], "\" ")),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PDP contexts: %w", err)
+	}
+
+	err = d.readList(cmdContextsActive, cmdContextActive, func(val []byte) error {
+		// +CGACT: <cid>,<state>
+		cid, state, ok := bytes.Cut(val, []byte(","))
+		if !ok {
+			return ErrUnexpectedResponse
+		}
+		for i := range contexts {
+			if strconv.Itoa(contexts[i].CID) == string(bytes.TrimSpace(cid)) {
+				contexts[i].Active = bytes.Equal(bytes.TrimSpace(state), []byte("1"))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query PDP context state: %w", err)
+	}
+	return contexts, nil
+}
+
+// readList sends a query answered by one line per item, each starting with
+// prefix, followed by OK, and calls fn with the value of each line
+func (d *Device) readList(cmd, prefix []byte, fn func(val []byte) error) error {
+	if err := d.sendRaw(cmd); err != nil {
+		return err
+	}
+	for {
+		if err := d.readResponse(cmd, prefixCheck(prefix), DefaultTimeout); err != nil {
+			return err
+		}
+		val, ok := d.parseValue(prefix)
+		if !ok {
+			return nil // The OK that ends the list
+		}
+		if err := fn(val); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package sim800l
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_PDPContexts(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CGDCONT=2,\"IP\",\"ims.Example\"": "\r\nOK\r\n",
+		"AT+CGACT=1,2":                        "\r\nOK\r\n",
+		"AT+CGDCONT?":                         "\r\n+CGDCONT: 1,\"IP\",\"internet\",\"0.0.0.0\",0,0\r\n+CGDCONT: 2,\"IP\",\"ims.Example\",\"0.0.0.0\",0,0\r\n\r\nOK\r\n",
+		"AT+CGACT?":                           "\r\n+CGACT: 1,0\r\n+CGACT: 2,1\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+
+	if err := d.DefinePDPContext(0, PDPTypeIP, "internet"); err != ErrBadParameter {
+		t.Errorf("expected ErrBadParameter for context 0, got %v", err)
+	}
+	if err := d.DefinePDPContext(1, "IPV6", "internet"); err != ErrBadParameter {
+		t.Errorf("expected ErrBadParameter for an unsupported type, got %v", err)
+	}
+	if err := d.DefinePDPContext(2, PDPTypeIP, "ims.Example"); err != nil {
+		t.Fatalf("define failed: %v", err)
+	}
+	if err := d.ActivatePDPContext(2); err != nil {
+		t.Fatalf("activate failed: %v", err)
+	}
+
+	contexts, err := d.PDPContexts()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	want := []PDPContext{
+		{CID: 1, Type: PDPTypeIP, APN: "internet"},
+		{CID: 2, Type: PDPTypeIP, APN: "ims.Example", Active: true},
+	}
+	if len(contexts) != len(want) {
+		t.Fatalf("expected %+v, got %+v", want, contexts)
+	}
+	for i := range want {
+		if contexts[i] != want[i] {
+			t.Errorf("expected %+v, got %+v", want[i], contexts[i])
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains unlocking a SIM protected by a PIN.
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// SIMUnlockTimeout bounds the wait for the SIM to get ready after the
+	// PIN was accepted
+	SIMUnlockTimeout = 10 * time.Second
+
+	// simPollInterval is the time between SIM status queries while it unlocks
+	simPollInterval = 500 * time.Millisecond
+)
+
+var (
+	cmdEnterPIN = []byte("+CPIN=")  // Enter the SIM PIN
+	simPIN      = []byte("SIM PIN") // SIM status waiting for the PIN
+	simPUK      = []byte("SIM PUK") // SIM status locked after wrong PINs
+)
+
+// unlockSIM enters the configured PIN if the SIM status state asks for it
+// and waits for the SIM to get ready. It reports whether the SIM is ready;
+// without a PIN configured a locked SIM isn't an error. A SIM that needs
+// its PUK fails with ErrSIMPUKRequired and a rejected PIN with
+// ErrIncorrectPIN; the same PIN isn't tried again, as the SIM locks after
+// three attempts, until Configure sets another one.
+func (d *Device) unlockSIM(ctx context.Context, state []byte) (bool, error) {
+	switch {
+	case bytes.Equal(state, simPUK):
+		return false, ErrSIMPUKRequired
+	case !bytes.Equal(state, simPIN):
+		return false, nil
+	case d.pin == "":
+		d.log(SubsystemPower, slog.LevelWarn, "SIM needs a PIN, none configured")
+		return false, nil
+	case !validPIN(d.pin):
+		return false, fmt.Errorf("%w: PIN must be 4 to 8 digits", ErrBadParameter)
+	case d.pinRejected:
+		return false, fmt.Errorf("%w: not tried again", ErrIncorrectPIN)
+	}
+
+	var buf [MaxCommandSize]byte
+	cmd := append(buf[:0], cmdEnterPIN...)
+	cmd = append(cmd, '"')
+	cmd = append(cmd, d.pin...)
+	cmd = append(cmd, '"')
+	// The PIN is redacted from the command kept for errors and logs, see
+	// secretParams
+	if err := d.exchange(cmd, defaultResponseCheck, contextTimeout(ctx, DefaultTimeout)); err != nil {
+		if errors.Is(err, ErrIncorrectPIN) {
+			d.pinRejected = true
+		}
+		d.log(SubsystemPower, slog.LevelError, "SIM PIN not accepted", "error", err)
+		return false, err
+	}
+
+	// The SIM takes a moment to get ready after accepting the PIN
+	deadline := time.Now().Add(SIMUnlockTimeout)
+	for time.Now().Before(deadline) {
+		if err := d.sendContext(ctx, cmdSimCheck, prefixCheck(simStatus)); err == nil {
+			if val, _ := d.parseValue(simStatus); bytes.Equal(val, simReady) {
+				d.log(SubsystemPower, slog.LevelInfo, "SIM unlocked")
+				return true, nil
+			}
+		}
+		if err := sleepContext(ctx, simPollInterval); err != nil {
+			return false, err
+		}
+	}
+	return false, fmt.Errorf("%w: SIM not ready after PIN", ErrTimeout)
+}
+
+// validPIN reports whether pin is a SIM PIN, 4 to 8 digits
+func validPIN(pin string) bool {
+	if len(pin) < 4 || len(pin) > 8 {
+		return false
+	}
+	for _, c := range []byte(pin) {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// pinModem is a module with a SIM that gets ready once the PIN 1234 is entered
+type pinModem struct {
+	*mockModem
+}
+
+func (m *pinModem) Write(p []byte) (int, error) {
+	n, err := m.mockModem.Write(p)
+	if len(m.commands) > 0 && m.commands[len(m.commands)-1] == `AT+CPIN="1234"` {
+		m.responses["AT+CPIN?"] = "\r\n+CPIN: READY\r\n\r\nOK\r\n"
+	}
+	return n, err
+}
+
+func newPINModem() *pinModem {
+	return &pinModem{newMockModem(map[string]string{
+		"AT":             "\r\nOK\r\n",
+		"ATE0":           "\r\nOK\r\n",
+		"AT+CMEE=2":      "\r\nOK\r\n",
+		"AT+IPR=0":       "\r\nOK\r\n",
+		"AT+CFUN=1":      "\r\nOK\r\n",
+		"AT+CIPMUX=1":    "\r\nOK\r\n",
+		"AT+CMGF=1":      "\r\nOK\r\n",
+		"AT+CPIN?":       "\r\n+CPIN: SIM PIN\r\n\r\nOK\r\n",
+		`AT+CPIN="1234"`: "\r\nOK\r\n",
+		`AT+CPIN="4321"`: "\r\n+CME ERROR: incorrect password\r\n",
+	})}
+}
+
+func TestDevice_InitUnlocksSIM(t *testing.T) {
+	modem := newPINModem()
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Configure(Config{PIN: "1234"})
+
+	status, err := d.InitContext(context.Background())
+	if err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	if !status.SIMReady {
+		t.Errorf("expected the SIM to be unlocked, got %+v", status)
+	}
+}
+
+func TestDevice_InitRejectsPIN(t *testing.T) {
+	modem := newPINModem()
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Configure(Config{PIN: "4321"})
+
+	status, err := d.InitContext(context.Background())
+	if !errors.Is(err, ErrIncorrectPIN) || status.SIMReady || !status.Configured {
+		t.Fatalf("expected ErrIncorrectPIN after configuring, got %+v, %v", status, err)
+	}
+
+	// The rejected PIN isn't entered again
+	sent := len(modem.commands)
+	if _, err := d.InitContext(context.Background()); !errors.Is(err, ErrIncorrectPIN) {
+		t.Errorf("expected ErrIncorrectPIN again, got %v", err)
+	}
+	for _, cmd := range modem.commands[sent:] {
+		if cmd == `AT+CPIN="4321"` {
+			t.Error("rejected PIN entered again")
+		}
+	}
+
+	// A locked SIM needs its PUK
+	modem.responses["AT+CPIN?"] = "\r\n+CPIN: SIM PUK\r\n\r\nOK\r\n"
+	if _, err := d.InitContext(context.Background()); !errors.Is(err, ErrSIMPUKRequired) {
+		t.Errorf("expected ErrSIMPUKRequired, got %v", err)
+	}
+}
+
+func TestDevice_PINRedacted(t *testing.T) {
+	modem := newPINModem()
+	modem.responses[`AT+CPIN="4321"`] = "\r\n+CME ERROR: 16\r\n"
+	var logs bytes.Buffer
+	d := New(modem, nil, slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	var traced []string
+	d.SetTraceHook(func(r TraceRecord) { traced = append(traced, string(r.Data)) })
+	d.Configure(Config{PIN: "4321"})
+
+	_, err := d.InitContext(context.Background())
+	if !errors.Is(err, ErrIncorrectPIN) {
+		t.Fatalf("expected ErrIncorrectPIN, got %v", err)
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Command != `AT+CPIN="***"` {
+		t.Errorf("expected the PIN redacted from the failed command, got %v", err)
+	}
+
+	recorded := []string{err.Error(), string(d.lastCmd[:d.lastCmdLen]), logs.String()}
+	recorded = append(recorded, traced...)
+	for _, e := range d.Diagnostics().RecentErrors {
+		recorded = append(recorded, e.Command)
+	}
+	for _, s := range recorded {
+		if strings.Contains(s, "4321") {
+			t.Errorf("PIN recorded in %q", s)
+		}
+	}
+	if n := len(d.Diagnostics().RecentErrors); n == 0 {
+		t.Error("expected the rejected PIN in the error history")
+	}
+}
+
+func TestAppendRedacted(t *testing.T) {
+	tests := []struct{ cmd, want string }{
+		{`AT+CPIN="1234"`, `AT+CPIN="***"`},
+		{`+cpin="1234"`, `+cpin="***"`},
+		{`AT+CPIN?`, `AT+CPIN?`},
+		{`AT+CPIN="12`, `AT+CPIN="12`},
+		{`AT+CSTT="internet"`, `AT+CSTT="internet"`},
+	}
+	for _, tt := range tests {
+		if got := string(appendRedacted(nil, []byte(tt.cmd))); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.cmd, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the shutdown sequence.
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ShutdownTimeout bounds Shutdown when its context has no deadline
+const ShutdownTimeout = 30 * time.Second
+
+var (
+	cmdPowerDown   = []byte("+CPOWD=1")   // Normal power down
+	powerDownToken = []byte("POWER DOWN") // Sent by the module before it switches off
+)
+
+// shutdownStep is one step of the shutdown sequence, run with the lock held
+type shutdownStep struct {
+	name string
+	run  func(timeout time.Duration) error
+}
+
+// Shutdown brings the module down in a defined order: it flushes data sent
+// on open connections, closes them, shuts down the PDP context, detaches
+// from GPRS, powers the module down and releases the reset pin.
+//
+// The sequence is bounded by ctx, or by ShutdownTimeout if ctx has no
+// deadline. A failing step is logged and the sequence continues, so the
+// module is powered down whenever possible; the first error is returned.
+// If ctx is done before the sequence completes the remaining steps are
+// skipped and the context's error is returned.
+func (d *Device) Shutdown(ctx context.Context) error {
+	return d.shutdown(ctx, true)
+}
+
+// shutdown runs the shutdown sequence, up to detaching from GPRS unless
+// powerDown is set
+func (d *Device) shutdown(ctx context.Context, powerDown bool) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(ShutdownTimeout)
+	}
+
+	var first error
+	fail := func(step string, err error) {
+		d.log(SubsystemPower, slog.LevelWarn, "shutdown step failed", "step", step, "error", err)
+		if first == nil {
+			first = fmt.Errorf("failed to %s: %w", step, err)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// Give the remote hosts a chance to acknowledge what was sent
+	if err := d.Flush(deadline); err != nil {
+		fail("flush connections", err)
+	}
+
+	d.lock()
+	defer d.unlock()
+
+	steps := [...]shutdownStep{
+		{"close connections", d.closeAll},
+		{"shut down PDP context", func(timeout time.Duration) error {
+			d.IP = ""
+			d.forgetListener()
+			return d.sendWithOptions(cmdShutPdp, defaultResponseCheck, timeout)
+		}},
+		{"detach from GPRS", func(timeout time.Duration) error {
+			return d.sendWithOptions(cmdGprsDetach, defaultResponseCheck, timeout)
+		}},
+		{"power down", d.powerDown}, // Last, skipped unless powerDown
+	}
+	n := len(steps)
+	if !powerDown {
+		n--
+	}
+	for _, step := range steps[:n] {
+		if err := ctx.Err(); err != nil {
+			d.log(SubsystemPower, slog.LevelWarn, "shutdown interrupted", "step", step.name, "error", err)
+			return err
+		}
+		timeout := min(DefaultTimeout, time.Until(deadline))
+		if timeout <= 0 {
+			d.log(SubsystemPower, slog.LevelWarn, "shutdown ran out of time", "step", step.name)
+			return context.DeadlineExceeded
+		}
+		d.log(SubsystemPower, slog.LevelDebug, "shutdown step", "step", step.name)
+		if err := step.run(timeout); err != nil {
+			fail(step.name, err)
+		}
+	}
+
+	// The module is off, release the reset pin
+	if powerDown && d.resetPin != nil {
+		d.resetPin.Low()
+	}
+	return first
+}
+
+// closeAll closes every open connection, waiting at most timeout for each
+func (d *Device) closeAll(timeout time.Duration) error {
+	var first error
+	for i := 0; i < MaxConnections; i++ {
+		if d.connections[i] == nil {
+			continue
+		}
+		if err := d.closeConnection(uint8(i), timeout); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// powerDown switches the module off with AT+CPOWD
+func (d *Device) powerDown(timeout time.Duration) error {
+	err := d.sendWithOptions(cmdPowerDown, func(buffer []byte) error {
+		if bytes.Contains(buffer, powerDownToken) {
+			return nil
+		}
+		return defaultResponseCheck(buffer)
+	}, timeout)
+	if err != nil {
+		return err
+	}
+	d.powerState = false
+	d.forgetSettings()
+	return nil
+}
@@ -0,0 +1,85 @@
+package sim800l
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func newShutdownModem() *mockModem {
+	return newMockModem(map[string]string{
+		"AT+CIPACK=0":   "\r\n+CIPACK: 4,4,0\r\n\r\nOK\r\n",
+		"AT+CIPCLOSE=0": "\r\n0, CLOSE OK\r\n",
+		"AT+CIPSHUT":    "\r\nSHUT OK\r\n",
+		"AT+CGATT=0":    "\r\nOK\r\n",
+		"AT+CPOWD=1":    "\r\nNORMAL POWER DOWN\r\n",
+	})
+}
+
+func TestDevice_Shutdown(t *testing.T) {
+	modem := newShutdownModem()
+	pin := &MockPin{state: true}
+	d := New(modem, pin, slog.New(&MockHandler{t: t}))
+	d.IP = "10.0.0.1"
+	d.powerState = true
+	d.connections[0] = &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	expected := []string{"AT+CIPACK=0", "AT+CIPCLOSE=0", "AT+CIPSHUT", "AT+CGATT=0", "AT+CPOWD=1"}
+	if len(modem.commands) != len(expected) {
+		t.Fatalf("expected commands %q, got %q", expected, modem.commands)
+	}
+	for i, cmd := range expected {
+		if modem.commands[i] != cmd {
+			t.Errorf("command %d: expected %q, got %q", i, cmd, modem.commands[i])
+		}
+	}
+	if d.connections[0] != nil {
+		t.Error("connection 0 still open")
+	}
+	if d.IP != "" {
+		t.Errorf("IP address %q still set", d.IP)
+	}
+	if d.powerState {
+		t.Error("device still marked as powered")
+	}
+	if pin.Get() {
+		t.Error("reset pin not released")
+	}
+}
+
+func TestDevice_ShutdownContinuesAfterFailedStep(t *testing.T) {
+	modem := newShutdownModem()
+	delete(modem.responses, "AT+CGATT=0")
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	err := d.Shutdown(context.Background())
+	var atErr *ATError
+	if !errors.As(err, &atErr) {
+		t.Fatalf("expected AT error from the failed detach, got %v", err)
+	}
+	if last := modem.commands[len(modem.commands)-1]; last != "AT+CPOWD=1" {
+		t.Errorf("expected power down to run last, got %q", last)
+	}
+}
+
+func TestDevice_ShutdownCancelled(t *testing.T) {
+	modem := newShutdownModem()
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(modem.commands) != 0 {
+		t.Errorf("expected no commands, got %q", modem.commands)
+	}
+}
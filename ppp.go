@@ -0,0 +1,271 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the PPP dial-up mode, which hands the UART to a
+// host-side IP stack.
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	cmdDialPPP   = []byte("D*99#") // Dial the packet data service of context 1
+	cmdResumePPP = []byte("O")     // Return to data mode
+)
+
+// pppHangUp is sent by the module when the call ends, e.g. when the
+// network drops it, after which it is back in command mode
+var pppHangUp = []byte("\r\nNO CARRIER\r\n")
+
+var ErrPPPMode = errors.New("module in PPP data mode")
+
+// PPPSession is a data call carrying PPP frames between the module and a
+// host-side IP stack. While it is in data mode every other operation of
+// the Device fails with ErrPPPMode.
+type PPPSession struct {
+	device       *Device
+	escaped      bool         // In command mode with the call up, see Escape
+	closed       bool         // Hung up by Close
+	hungUp       bool         // The call ended and the module left data mode
+	hangUpSeen   int          // Bytes of pppHangUp matched so far
+	readDeadline atomic.Int64 // Unix nanoseconds, zero for none
+}
+
+// DialPPP defines PDP context 1 with apn and dials it with ATD*99#. Once
+// the module answers CONNECT the UART carries PPP frames, read and written
+// through the session, until Escape or Close. The module's own TCP/IP
+// stack must be down. An APN with a quote, CR or LF returns
+// ErrBadParameter.
+func (d *Device) DialPPP(apn string) (*PPPSession, error) {
+	if err := (GPRSConfig{APN: apn}).validate(); err != nil {
+		return nil, err
+	}
+	d.lock()
+	defer d.unlock()
+
+	if d.ppp != nil {
+		return nil, ErrPPPMode
+	}
+	if d.IP != "" {
+		return nil, fmt.Errorf("%w: disconnect the module's TCP/IP stack first", ErrDeviceBusy)
+	}
+
+	var buf [MaxCommandSize]byte
+	cmd := append(buf[:0], cmdDefinePdp...)
+	cmd = append(cmd, "\"IP\",\""...)
+	cmd = append(cmd, apn...)
+	cmd = append(cmd, '"')
+	if err := d.send(cmd); err != nil {
+		return nil, fmt.Errorf("failed to define PDP context: %w", err)
+	}
+	if err := d.sendRaw(cmdDialPPP); err != nil {
+		return nil, err
+	}
+	if err := d.awaitConnect(ConnectTimeout); err != nil {
+		return nil, fmt.Errorf("failed to dial PPP: %w", err)
+	}
+
+	// The session of the module's stack stays down while PPP runs
+	d.sessionUp = false
+	d.ppp = &PPPSession{device: d}
+	d.log(SubsystemData, slog.LevelInfo, "PPP session started", "apn", apn)
+	return d.ppp, nil
+}
+
+// Read reads PPP frames sent by the module. Like a net.Conn it waits
+// until data arrives or the read deadline passes. Once the call ends,
+// which the module reports with NO CARRIER, it returns io.EOF and the
+// Device is back in command mode.
+func (p *PPPSession) Read(b []byte) (int, error) {
+	d := p.device
+	for {
+		deadline := p.readDeadline.Load()
+		if deadline != 0 && time.Now().UnixNano() >= deadline {
+			return 0, ErrDeadlineExceeded
+		}
+
+		d.lock()
+		if p.hungUp {
+			d.unlock()
+			return 0, io.EOF
+		}
+		if p.closed || p.escaped {
+			d.unlock()
+			return 0, ErrConnectionClosed
+		}
+		in := d.input()
+		if in.Buffered() > 0 {
+			n, err := in.Read(b)
+			p.watchHangUp(b[:n])
+			d.unlock()
+			if n > 0 || err != nil {
+				return n, err
+			}
+		} else {
+			d.unlock()
+		}
+
+		// Wait unlocked so the writer can use the UART
+		time.Sleep(readPollInterval)
+	}
+}
+
+// watchHangUp looks for NO CARRIER in the bytes read, which may split it,
+// and ends the session when it is complete, with the device locked
+func (p *PPPSession) watchHangUp(b []byte) {
+	for _, c := range b {
+		switch {
+		case c == pppHangUp[p.hangUpSeen]:
+			p.hangUpSeen++
+		case c == pppHangUp[0]:
+			p.hangUpSeen = 1
+		default:
+			p.hangUpSeen = 0
+		}
+		if p.hangUpSeen == len(pppHangUp) {
+			d := p.device
+			p.hungUp = true
+			d.ppp = nil
+			d.log(SubsystemData, slog.LevelInfo, "PPP call ended by the network")
+			return
+		}
+	}
+}
+
+// Write writes PPP frames to the module
+func (p *PPPSession) Write(b []byte) (int, error) {
+	d := p.device
+	d.lock()
+	defer d.unlock()
+
+	if p.closed || p.escaped || p.hungUp {
+		return 0, ErrConnectionClosed
+	}
+	return d.uart.Write(b)
+}
+
+// SetReadDeadline sets the deadline for Read calls. A zero value means
+// Read waits until data arrives.
+func (p *PPPSession) SetReadDeadline(t time.Time) error {
+	p.readDeadline.Store(deadlineNanos(t))
+	return nil
+}
+
+// Escape returns the module to command mode with the +++ escape sequence,
+// keeping the call up, so commands like Signal work again. The host's IP
+// stack must stop using the session until Resume. Frames in flight are
+// dropped.
+func (p *PPPSession) Escape() error {
+	d := p.device
+	d.lock()
+	defer d.unlock()
+
+	if p.closed || p.hungUp {
+		return ErrConnectionClosed
+	}
+	if p.escaped {
+		return nil
+	}
+	if err := d.escapeDataMode(); err != nil {
+		return fmt.Errorf("failed to escape PPP data mode: %w", err)
+	}
+	p.escaped = true
+	return nil
+}
+
+// Resume returns the module to data mode with ATO after Escape
+func (p *PPPSession) Resume() error {
+	d := p.device
+	d.lock()
+	defer d.unlock()
+
+	if p.closed || p.hungUp {
+		return ErrConnectionClosed
+	}
+	if !p.escaped {
+		return nil
+	}
+	if err := d.sendRaw(cmdResumePPP); err != nil {
+		return err
+	}
+	if err := d.awaitConnect(DefaultTimeout); err != nil {
+		return fmt.Errorf("failed to resume PPP data mode: %w", err)
+	}
+	p.escaped = false
+	return nil
+}
+
+// Close escapes to command mode, if needed, and hangs up with ATH
+func (p *PPPSession) Close() error {
+	d := p.device
+	d.lock()
+	defer d.unlock()
+
+	if p.closed {
+		return ErrConnectionClosed
+	}
+	if p.hungUp {
+		// The call is over and the module in command mode already
+		p.closed = true
+		return nil
+	}
+	if !p.escaped {
+		// The module leaves data mode by itself when the network ends
+		// the call, so a failed escape isn't fatal
+		if err := d.escapeDataMode(); err != nil {
+			d.log(SubsystemData, slog.LevelDebug, "failed to escape PPP data mode", "error", err)
+		}
+		p.escaped = true
+	}
+	p.closed = true
+	d.ppp = nil
+	d.log(SubsystemData, slog.LevelInfo, "PPP session closed")
+	return d.send(cmdHangUp)
+}
+
+// inPPP reports whether the UART carries the frames of a PPP session
+func (d *Device) inPPP() bool {
+	return d.ppp != nil && !d.ppp.escaped
+}
+
+// awaitConnect waits for the CONNECT that starts data mode after a dial
+// command. Another final response, like NO CARRIER, fails it.
+func (d *Device) awaitConnect(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return d.commandError(ErrTimeout)
+		}
+		t, err := d.readLine(remaining)
+		if err != nil {
+			return d.commandError(err)
+		}
+		if t != TokenLine {
+			continue
+		}
+		line := d.rxBuffer[:d.end]
+		if isConnectBanner(line) {
+			return nil
+		}
+		if bytes.HasPrefix(line, at) {
+			continue // Echo
+		}
+		handled, err := d.dispatchInput(line)
+		if err != nil {
+			return err
+		}
+		if handled || d.queueURC(nil, line) {
+			continue
+		}
+		if err := defaultResponseCheck(line); err != nil {
+			return d.commandError(err)
+		}
+		return d.commandError(fmt.Errorf("%w: %s", ErrUnexpectedResponse, line))
+	}
+}
@@ -0,0 +1,140 @@
+package sim800l
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDevice_DialPPP(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CGDCONT=1,\"IP\",\"internet\"": "\r\nOK\r\n",
+		"ATD*99#":                          "\r\nCONNECT\r\n",
+		"+++":                              "\r\nOK\r\n",
+		"AT+CSQ":                           "\r\n+CSQ: 21,0\r\n\r\nOK\r\n",
+		"ATO":                              "\r\nCONNECT 115200\r\n",
+		"ATH":                              "\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	session, err := d.DialPPP("internet")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	if _, err := d.DialPPP("internet"); !errors.Is(err, ErrPPPMode) {
+		t.Errorf("expected ErrPPPMode dialing twice, got %v", err)
+	}
+
+	// Frames pass through untouched
+	frame := "~\xff\x7d\x23\xc0\x21~"
+	modem.inject(frame)
+	buf := make([]byte, 16)
+	if n, err := session.Read(buf); err != nil || string(buf[:n]) != frame {
+		t.Errorf("expected the frame, got %q, %v", buf[:n], err)
+	}
+	if _, err := session.Write([]byte(frame)); err != nil {
+		t.Errorf("write failed: %v", err)
+	}
+	if !strings.HasSuffix(modem.tx.String(), frame) {
+		t.Errorf("expected the frame to be written, got %q", modem.tx.String())
+	}
+	modem.line = nil // The frame isn't a command
+
+	// Commands wait for command mode
+	if _, err := d.QueryInt("+CSQ"); !errors.Is(err, ErrPPPMode) {
+		t.Errorf("expected ErrPPPMode, got %v", err)
+	}
+	if err := session.Escape(); err != nil {
+		t.Fatalf("escape failed: %v", err)
+	}
+	if rssi, err := d.QueryInt("+CSQ"); err != nil || rssi != 21 {
+		t.Errorf("expected the signal in command mode, got %d, %v", rssi, err)
+	}
+	if err := session.Resume(); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+
+	session.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := session.Read(buf); !errors.Is(err, ErrDeadlineExceeded) {
+		t.Errorf("expected ErrDeadlineExceeded, got %v", err)
+	}
+	if err := session.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if _, err := session.Write([]byte(frame)); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed, got %v", err)
+	}
+}
+
+func TestDevice_DialPPPNoCarrier(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CGDCONT=1,\"IP\",\"internet\"": "\r\nOK\r\n",
+		"ATD*99#":                          "\r\nNO CARRIER\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	if _, err := d.DialPPP("internet"); !errors.Is(err, ErrUnexpectedResponse) {
+		t.Errorf("expected ErrUnexpectedResponse, got %v", err)
+	}
+	if d.ppp != nil {
+		t.Error("expected no session")
+	}
+
+	// An APN that would break out of its quotes sends nothing
+	modem.commands = nil
+	if _, err := d.DialPPP("internet\"\r\nAT+CPOWD=1"); !errors.Is(err, ErrBadParameter) {
+		t.Errorf("expected ErrBadParameter, got %v", err)
+	}
+	if len(modem.commands) != 0 {
+		t.Errorf("expected no commands, got %q", modem.commands)
+	}
+}
+
+func TestPPPSession_HangUp(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CGDCONT=1,\"IP\",\"internet\"": "\r\nOK\r\n",
+		"ATD*99#":                          "\r\nCONNECT\r\n",
+		"AT+CSQ":                           "\r\n+CSQ: 21,0\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	session, err := d.DialPPP("internet")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	// Without a deadline Read waits for the frame
+	frame := "~\xff\x7d\x23\xc0\x21~"
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		modem.inject(frame + "\r\nNO CARRIER\r\n")
+	}()
+	var got []byte
+	buf := make([]byte, 4) // Splits NO CARRIER across reads
+	for {
+		n, err := session.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+	}
+	if !strings.HasPrefix(string(got), frame) {
+		t.Errorf("expected the frame before the hang-up, got %q", got)
+	}
+
+	// The module is back in command mode
+	if rssi, err := d.QueryInt("+CSQ"); err != nil || rssi != 21 {
+		t.Errorf("expected commands after the hang-up, got %d, %v", rssi, err)
+	}
+	if _, err := session.Write([]byte(frame)); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed, got %v", err)
+	}
+	if err := session.Close(); err != nil {
+		t.Errorf("close failed: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the module's factory defaults and saved profile.
+package sim800l
+
+var (
+	cmdFactoryDefaults = []byte("&F") // Restore the factory defaults
+	cmdSaveProfile     = []byte("&W") // Save the settings to the user profile
+	cmdLoadProfile     = []byte("Z")  // Load the settings of the user profile
+)
+
+// FactoryReset restores the module's factory defaults with AT&F. They
+// include echo and auto-baud, but not the driver's settings, like verbose
+// errors and multi-connection mode; call Reinit afterwards to apply those.
+// The saved profile isn't changed until SaveProfile.
+func (d *Device) FactoryReset() error {
+	return d.applyProfile(cmdFactoryDefaults)
+}
+
+// SaveProfile saves the module's current settings to its non-volatile
+// user profile with AT&W, which the module loads at power on. Set what
+// should survive a power cycle first, e.g. a fixed baud rate with
+// Command("AT+IPR=115200"), then save it.
+func (d *Device) SaveProfile() error {
+	d.lock()
+	defer d.unlock()
+	return d.send(cmdSaveProfile)
+}
+
+// LoadProfile replaces the module's settings with those saved by
+// SaveProfile, using ATZ
+func (d *Device) LoadProfile() error {
+	return d.applyProfile(cmdLoadProfile)
+}
+
+// applyProfile sends a command that replaces the module's settings as a
+// whole
+func (d *Device) applyProfile(cmd []byte) error {
+	d.lock()
+	defer d.unlock()
+
+	err := d.send(cmd)
+	// The settings the driver applied may be gone, even if the command
+	// failed half way
+	d.forgetSettings()
+	return err
+}
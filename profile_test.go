@@ -0,0 +1,49 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDevice_Profile(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CSCLK=1": "\r\nOK\r\n",
+		"AT&F":       "\r\nOK\r\n",
+		"AT&W":       "\r\nOK\r\n",
+		"ATZ":        "\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	setSlowClock := func() {
+		t.Helper()
+		if err := d.send([]byte("AT+CSCLK=1")); err != nil {
+			t.Fatalf("setting failed: %v", err)
+		}
+	}
+	setSlowClock()
+	if err := d.SaveProfile(); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if err := d.FactoryReset(); err != nil {
+		t.Fatalf("factory reset failed: %v", err)
+	}
+	// The defaults dropped the cached setting, so it is sent again
+	setSlowClock()
+	if err := d.LoadProfile(); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	want := "AT+CSCLK=1|AT&W|AT&F|AT+CSCLK=1|ATZ"
+	if got := strings.Join(modem.commands, "|"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// A module without a user profile rejects saving it
+	delete(modem.responses, "AT&W")
+	var atErr *ATError
+	if err := d.SaveProfile(); !errors.As(err, &atErr) {
+		t.Errorf("expected an ATError, got %v", err)
+	}
+}
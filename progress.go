@@ -0,0 +1,63 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the progress reports of Init.
+package sim800l
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrSIMNotReady = errors.New("SIM not ready")
+	ErrNotAttached = errors.New("not attached to GPRS")
+)
+
+// InitStage identifies a stage of Init
+type InitStage uint8
+
+const (
+	InitStageReset      InitStage = iota // The module was reset; skipped if Reinit found it running
+	InitStageSync                        // The module answers AT
+	InitStageConfigured                  // Echo, error reporting, connection and SMS modes are set
+	InitStageSIMReady                    // The SIM is ready, unlocked with Config.PIN if needed
+	InitStageRegistered                  // The module is registered on a network
+	InitStageGPRS                        // The module is attached to GPRS; only once registered
+)
+
+func (s InitStage) String() string {
+	switch s {
+	case InitStageReset:
+		return "Reset"
+	case InitStageSync:
+		return "Sync"
+	case InitStageConfigured:
+		return "Configured"
+	case InitStageSIMReady:
+		return "SIMReady"
+	case InitStageRegistered:
+		return "Registered"
+	case InitStageGPRS:
+		return "GPRS"
+	default:
+		return "Unknown"
+	}
+}
+
+// InitProgress reports a stage of Init
+type InitProgress struct {
+	Stage   InitStage     // Stage Init got to
+	Elapsed time.Duration // Time since Init started
+	Err     error         // Why the stage wasn't reached, nil if it was
+}
+
+// InitProgressFunc is called synchronously after each stage of Init, e.g.
+// to show boot progress on LEDs. It must not call back into the Device.
+type InitProgressFunc func(p InitProgress)
+
+// reportInit passes a stage of Init started at start to the progress
+// function, if any
+func (d *Device) reportInit(start time.Time, stage InitStage, err error) {
+	if d.initProgress != nil {
+		d.initProgress(InitProgress{Stage: stage, Elapsed: time.Since(start), Err: err})
+	}
+}
@@ -0,0 +1,116 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains typed helpers for query commands.
+package sim800l
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// QueryInt sends a query command, e.g. "+CSQ", and returns the first
+// field of its response line as an integer, 21 for "+CSQ: 21,0".
+func (d *Device) QueryInt(cmd string) (int, error) {
+	var fields [1]int
+	if _, err := d.QueryInts(cmd, fields[:]); err != nil {
+		return 0, err
+	}
+	return fields[0], nil
+}
+
+// QueryInts sends a query command, e.g. "+CREG?", parses the comma
+// separated integers of its response line into dst and returns how many
+// were parsed. It fails with ErrUnexpectedResponse if the first field
+// isn't a number.
+func (d *Device) QueryInts(cmd string, dst []int) (int, error) {
+	d.lock()
+	defer d.unlock()
+
+	val, err := d.queryValue(cmd)
+	if err != nil {
+		return 0, err
+	}
+	n := parseInts(val, dst)
+	if n == 0 && len(dst) > 0 {
+		return 0, fmt.Errorf("%w: %q", ErrUnexpectedResponse, val)
+	}
+	return n, nil
+}
+
+// QueryString sends a query command, e.g. "+COPS", and returns the first
+// quoted field of its response line, the operator of
+// `+COPS: 0,0,"Operator"`, or the whole value if none is quoted, like
+// READY for "+CPIN: READY".
+func (d *Device) QueryString(cmd string) (string, error) {
+	d.lock()
+	defer d.unlock()
+
+	val, err := d.queryValue(cmd)
+	if err != nil {
+		return "", err
+	}
+	if field := quotedField(val, 0); field != nil {
+		return string(field), nil
+	}
+	return string(val), nil
+}
+
+// queryValue sends cmd and returns the value of the response line named
+// after it, "21,0" of "+CSQ: 21,0" for AT+CSQ. A command given without
+// "?" or "=", like "+COPS", is sent as is first and, if the module
+// answers without the line, once more as a read command, AT+COPS?. The
+// value is only valid until the next command.
+func (d *Device) queryValue(cmd string) ([]byte, error) {
+	if len(cmd)+1 > MaxCommandSize {
+		return nil, fmt.Errorf("%w: command too long", ErrBadParameter)
+	}
+
+	// sendRaw upper-cases the command in place, so copy it
+	var buf [MaxCommandSize]byte
+	c := append(buf[:0], cmd...)
+	name := queryName(c)
+	if len(name) == 0 {
+		return nil, fmt.Errorf("%w: %q is not a query", ErrBadParameter, cmd)
+	}
+
+	check := prefixCheck(name)
+	if err := d.sendWithOptions(c, check, DefaultTimeout); err != nil {
+		return nil, err
+	}
+	if val, ok := d.parseValue(name); ok {
+		return val, nil
+	}
+	if bytes.ContainsAny(c, "?=") {
+		return nil, fmt.Errorf("%w: no %s line", ErrUnexpectedResponse, name)
+	}
+
+	// The execute form only answered OK, ask for the current value
+	c = append(buf[:len(cmd)], '?')
+	if err := d.sendWithOptions(c, check, DefaultTimeout); err != nil {
+		return nil, err
+	}
+	if val, ok := d.parseValue(name); ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("%w: no %s line", ErrUnexpectedResponse, name)
+}
+
+// queryName returns the name of cmd that starts its response line, +CSQ
+// for "AT+CSQ" or "+CSQ?"
+func queryName(cmd []byte) []byte {
+	if len(cmd) >= 2 && (cmd[0] == 'A' || cmd[0] == 'a') && (cmd[1] == 'T' || cmd[1] == 't') {
+		cmd = cmd[2:]
+	}
+	if i := bytes.IndexAny(cmd, "?="); i >= 0 {
+		cmd = cmd[:i]
+	}
+	if len(cmd) < 2 || cmd[0] != '+' {
+		return nil
+	}
+	// The module echoes names in upper case whatever case they were sent in
+	for i, c := range cmd {
+		if c >= 'a' && c <= 'z' {
+			cmd[i] = c - 'a' + 'A'
+		}
+	}
+	return cmd
+}
@@ -0,0 +1,45 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_Query(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CSQ":    "\r\n+CSQ: 21,0\r\n\r\nOK\r\n",
+		"AT+COPS":   "\r\nOK\r\n",
+		"AT+COPS?":  "\r\n+COPS: 0,0,\"Operator, Ltd\"\r\n\r\nOK\r\n",
+		"AT+CPIN?":  "\r\n+CPIN: READY\r\n\r\nOK\r\n",
+		"AT+CREG?":  "\r\n+CREG: 0,5\r\n\r\nOK\r\n",
+		"AT+CGATT?": "\r\n+CGATT: \r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	if n, err := d.QueryInt("+CSQ"); err != nil || n != 21 {
+		t.Errorf("expected 21, got %d, %v", n, err)
+	}
+	if s, err := d.QueryString("+COPS"); err != nil || s != "Operator, Ltd" {
+		t.Errorf("expected the operator, got %q, %v", s, err)
+	}
+	if s, err := d.QueryString("AT+CPIN?"); err != nil || s != "READY" {
+		t.Errorf("expected READY, got %q, %v", s, err)
+	}
+
+	// Names are matched whatever case they are sent in
+	var fields [4]int
+	if n, err := d.QueryInts("+creg?", fields[:]); err != nil || n != 2 || fields[1] != 5 {
+		t.Errorf("expected 0,5, got %v, %v", fields[:n], err)
+	}
+
+	if _, err := d.QueryInt("+CGATT?"); !errors.Is(err, ErrUnexpectedResponse) {
+		t.Errorf("expected ErrUnexpectedResponse for an empty value, got %v", err)
+	}
+	if _, err := d.QueryInt("+CBC"); err == nil {
+		t.Error("expected an error for an unsupported command")
+	}
+	if _, err := d.QueryInt("ATI"); !errors.Is(err, ErrBadParameter) {
+		t.Errorf("expected ErrBadParameter for a command without a name, got %v", err)
+	}
+}
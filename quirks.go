@@ -0,0 +1,44 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains carrier specific adjustments of the connect sequence.
+package sim800l
+
+var (
+	cmdOperatorNumeric = []byte("+COPS=3,2")   // Report the operator as numeric PLMN
+	cmdDefinePdp       = []byte("+CGDCONT=1,") // Define PDP context 1
+)
+
+// CarrierQuirks adjusts the connect sequence for SIMs that do not work with
+// the defaults, typically roaming IoT SIMs. Select one with Config.Quirks.
+type CarrierQuirks struct {
+	NoAuth           bool // Never send user and password with the APN
+	NumericOperator  bool // Report the operator as numeric PLMN, as names are often missing when roaming
+	DefinePDPContext bool // Define the PDP context with AT+CGDCONT before setting the APN
+}
+
+var (
+	// Quirks1NCE is the profile for 1NCE IoT SIMs (APN "iot.1nce.net")
+	Quirks1NCE = CarrierQuirks{NoAuth: true, NumericOperator: true, DefinePDPContext: true}
+
+	// QuirksHologram is the profile for Hologram SIMs (APN "hologram")
+	QuirksHologram = CarrierQuirks{NoAuth: true, NumericOperator: true}
+)
+
+// applyQuirks runs the carrier specific steps that precede setting the APN
+func (d *Device) applyQuirks(apn string) error {
+	if d.quirks.NumericOperator {
+		if err := d.send(cmdOperatorNumeric); err != nil {
+			return err
+		}
+	}
+	if d.quirks.DefinePDPContext {
+		var buf [MaxCommandSize]byte
+		cmd := append(buf[:0], cmdDefinePdp...)
+		cmd = append(cmd, "\"IP\",\""...)
+		cmd = append(cmd, apn...)
+		cmd = append(cmd, '"')
+		if err := d.send(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
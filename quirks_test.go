@@ -0,0 +1,87 @@
+package sim800l
+
+import (
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+func TestDevice_ConnectQuirks(t *testing.T) {
+	tests := []struct {
+		name       string
+		quirks     CarrierQuirks
+		apn        string
+		transcript []string
+	}{
+		{
+			name:   "1NCE",
+			quirks: Quirks1NCE,
+			apn:    "iot.1nce.net",
+			transcript: []string{
+				"AT+CPAS",
+				"AT+CGATT?",
+				"AT+CIPMUX=1",
+				"AT+COPS=3,2",
+				"AT+CGDCONT=1,\"IP\",\"iot.1nce.net\"",
+				"AT+CSTT=\"iot.1nce.net\"",
+				"AT+CIICR",
+				"AT+CIFSR",
+			},
+		},
+		{
+			name:   "Hologram",
+			quirks: QuirksHologram,
+			apn:    "hologram",
+			transcript: []string{
+				"AT+CPAS",
+				"AT+CGATT?",
+				"AT+CIPMUX=1",
+				"AT+COPS=3,2",
+				"AT+CSTT=\"hologram\"",
+				"AT+CIICR",
+				"AT+CIFSR",
+			},
+		},
+		{
+			name: "Default",
+			apn:  "internet",
+			transcript: []string{
+				"AT+CPAS",
+				"AT+CGATT?",
+				"AT+CIPMUX=1",
+				"AT+CSTT=\"internet\",\"user\",\"Secret\"",
+				"AT+CIICR",
+				"AT+CIFSR",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			modem := newMockModem(map[string]string{
+				"AT+CPAS":                                "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+				"AT+CGATT?":                              "\r\n+CGATT: 1\r\n\r\nOK\r\n",
+				"AT+CIPMUX=1":                            "\r\nOK\r\n",
+				"AT+COPS=3,2":                            "\r\nOK\r\n",
+				"AT+CGDCONT=1,\"IP\",\"" + tc.apn + "\"": "\r\nOK\r\n",
+				"AT+CSTT=\"" + tc.apn + "\"":             "\r\nOK\r\n",
+				"AT+CSTT=\"internet\",\"user\",\"Secret\"": "\r\nOK\r\n",
+				"AT+CIICR": "\r\nOK\r\n",
+				"AT+CIFSR": "\r\n100.64.12.7\r\n",
+			})
+			d := New(modem, nil, slog.New(&MockHandler{t: t}))
+			d.Configure(Config{Quirks: tc.quirks})
+
+			// IoT SIMs reject credentials, so they must be dropped
+			if err := d.Connect(tc.apn, "user", "Secret"); err != nil {
+				t.Fatalf("connect failed: %v, transcript %q", err, modem.commands)
+			}
+			if !slices.Equal(modem.commands, tc.transcript) {
+				t.Errorf("expected transcript %q, got %q", tc.transcript, modem.commands)
+			}
+			if d.IP != "100.64.12.7" {
+				t.Errorf("expected IP 100.64.12.7, got %q", d.IP)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the goroutine that pumps the UART input into a queue.
+package sim800l
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+)
+
+const (
+	ReaderBufferSize = 1024                 // Input the reader goroutine buffers
+	readerInterval   = 2 * time.Millisecond // Pause of the reader goroutine when no input arrived
+)
+
+var ErrReaderRunning = errors.New("reader already running")
+
+// input is the reading side of a UART
+type input interface {
+	io.Reader
+	Buffered() int
+}
+
+// inputQueue buffers the input of the UART. All reads of the UART go
+// through it while Run is active, so the module's output is read in one
+// place and in order, by the reader goroutine or by the operation holding
+// the device, whichever asks first.
+type inputQueue struct {
+	mu      mutex
+	uart    UART
+	buf     [ReaderBufferSize]byte
+	head    int  // Index of the oldest byte
+	n       int  // Bytes buffered
+	running bool // Run is active; changed with the device's lock held
+}
+
+// pull moves the input waiting in the UART into the queue, as far as it
+// fits, with q.mu held
+func (q *inputQueue) pull() error {
+	for q.n < len(q.buf) {
+		avail := q.uart.Buffered()
+		if avail == 0 {
+			return nil
+		}
+		// Fill the free space up to the end of buf, then wrap around
+		tail := (q.head + q.n) % len(q.buf)
+		free := min(len(q.buf)-q.n, len(q.buf)-tail, avail)
+		m, err := q.uart.Read(q.buf[tail : tail+free])
+		q.n += m
+		if err != nil {
+			return err
+		}
+		if m == 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Buffered returns the number of bytes that can be read.
+// Implements the UART input
+func (q *inputQueue) Buffered() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_ = q.pull()
+	return q.n
+}
+
+// Read reads buffered input into p, oldest first.
+// Implements the UART input
+func (q *inputQueue) Read(p []byte) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	err := q.pull()
+	n := 0
+	for n < len(p) && q.n > 0 {
+		m := copy(p[n:], q.buf[q.head:min(len(q.buf), q.head+q.n)])
+		q.head = (q.head + m) % len(q.buf)
+		q.n -= m
+		n += m
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, err
+}
+
+// input returns where the module's output is read from: the queue filled
+// by Run while it is active or still holds input, the UART otherwise
+func (d *Device) input() input {
+	if q := d.queue; q != nil && (q.running || q.Buffered() > 0) {
+		return q
+	}
+	return d.uart
+}
+
+// Run makes the calling goroutine pump the UART into a queue of
+// ReaderBufferSize bytes until ctx is done, so the UART's receive buffer
+// doesn't overflow while an operation waits between reads. Whenever no
+// operation holds the device it also dispatches the queued input like
+// Poll, passing received data to the connections, unsolicited result
+// codes to their handlers and new SMS to the SMS handler, so Poll isn't
+// needed.
+//
+// Run doesn't own the protocol: it doesn't split the input into lines or
+// route responses to the waiting command. The holder of the device does,
+// reading the queue in order, so a command's response is read by the
+// command itself, and +RECEIVE data or URCs arriving before it are
+// dispatched to their connection's receive buffer or handler on the way
+// and never taken for the response.
+//
+// Only one Run may be active. Input buffered when it returns is still
+// read before the UART.
+func (d *Device) Run(ctx context.Context) error {
+	ctx, done, err := d.background(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	d.lock()
+	if d.queue != nil && d.queue.running {
+		d.unlock()
+		return ErrReaderRunning
+	}
+	if d.queue == nil {
+		d.queue = &inputQueue{uart: d.uart}
+	}
+	q := d.queue
+	q.running = true
+	d.unlock()
+	defer func() {
+		d.lock()
+		q.running = false
+		d.unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		q.mu.Lock()
+		before := q.n
+		err := q.pull()
+		pulled := q.n > before
+		pending := q.n > 0
+		q.mu.Unlock()
+		if err != nil {
+			d.log(SubsystemURC, slog.LevelWarn, "failed to read UART", "error", err)
+		}
+
+		// Dispatch input that arrived while no operation runs, and the SMS
+		// announced while one did
+		if d.tryLock(time.Time{}) {
+			if pending {
+				if err := d.poll(); err != nil {
+					d.log(SubsystemURC, slog.LevelDebug, "failed to process pending data", "error", err)
+				}
+			}
+			sms := d.smsPendingCount > 0
+			d.unlock()
+			if sms {
+				d.handlePendingSMS()
+			}
+		}
+		if !pulled {
+			time.Sleep(readerInterval)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package sim800l
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDevice_Run(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CSQ": "\r\n+CSQ: 20,0\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	urcs := make(chan string, 1)
+	if err := d.RegisterURCHandler("+CMTI", func(tok Token) {
+		urcs <- string(tok.Data)
+	}); err != nil {
+		t.Fatalf("failed to register handler: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+
+	// The reader dispatches URCs without Poll
+	modem.inject("\r\n+CMTI: \"SM\",3\r\n")
+	select {
+	case got := <-urcs:
+		if got != "+CMTI: \"SM\",3" {
+			t.Errorf("expected the +CMTI URC, got %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("URC not dispatched")
+	}
+
+	// Commands read their responses from the reader's queue
+	if got := d.Signal(); got != 20 {
+		t.Errorf("expected signal quality 20, got %d", got)
+	}
+
+	// Data arriving before a response goes to its connection, not the command
+	conn := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+	d.lock()
+	d.connections[0] = conn
+	d.unlock()
+	modem.responses["AT+CSQ"] = "\r\n+RECEIVE,0,4:\r\npong\r\n+CSQ: 21,0\r\n\r\nOK\r\n"
+	if got := d.Signal(); got != 21 {
+		t.Errorf("expected signal quality 21, got %d", got)
+	}
+	buf := make([]byte, 8)
+	if n, err := conn.Read(buf); err != nil || string(buf[:n]) != "pong" {
+		t.Errorf("expected the interleaved data, got %q, %v", buf[:n], err)
+	}
+	modem.responses["AT+CSQ"] = "\r\n+CSQ: 20,0\r\n\r\nOK\r\n"
+
+	// Only one reader may run
+	deadline := time.Now().Add(time.Second)
+	for {
+		d.lock()
+		running := d.queue != nil && d.queue.running
+		d.unlock()
+		if running || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := d.Run(ctx); !errors.Is(err, ErrReaderRunning) {
+		t.Errorf("expected ErrReaderRunning, got %v", err)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if got := d.Signal(); got != 20 {
+		t.Errorf("expected signal quality 20 after the reader stopped, got %d", got)
+	}
+}
+
+func Test_inputQueueWraps(t *testing.T) {
+	modem := newMockModem(nil)
+	q := &inputQueue{uart: modem}
+	q.head = ReaderBufferSize - 2
+
+	modem.inject("hello")
+	var buf [8]byte
+	n, err := q.Read(buf[:])
+	if err != nil || string(buf[:n]) != "hello" {
+		t.Errorf("expected hello, got %q, %v", buf[:n], err)
+	}
+	if q.Buffered() != 0 {
+		t.Errorf("expected an empty queue, got %d bytes", q.Buffered())
+	}
+}
@@ -0,0 +1,243 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains a net.Conn that re-dials when its connection is lost.
+package sim800l
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Default backoff between reconnect attempts
+const (
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = time.Minute
+)
+
+// ReconnectConfig controls how a ReconnectingConn re-dials. The zero value
+// retries forever with the default backoff.
+type ReconnectConfig struct {
+	MinBackoff  time.Duration // Wait before the first retry, DefaultMinBackoff if zero
+	MaxBackoff  time.Duration // Longest wait between retries, DefaultMaxBackoff if zero
+	MaxAttempts int           // Attempts per reconnect before giving up, unlimited if zero
+
+	// OnReconnect is called with the new connection before it is used,
+	// e.g. to log in again or resubscribe. If it fails the connection is
+	// closed and dialed again.
+	OnReconnect func(conn net.Conn) error
+}
+
+// ReconnectingConn is a net.Conn that dials its address again, with
+// backoff, when the connection is lost. A Read or Write that fails because
+// the connection was lost with the data session, closed by the module or
+// KeepAlive, or a send failed waits for the new connection and carries on,
+// so simple protocol clients get resilience without handling connection
+// events. A connection the remote host closed is done: Read returns io.EOF
+// like a net.Conn, while a Write dials again. Data the remote host didn't
+// receive before the connection was lost isn't sent again, except for the
+// rest of a failed Write.
+type ReconnectingConn struct {
+	device  *Device
+	network string
+	address string
+	cfg     ReconnectConfig
+
+	mu     mutex // Serializes reconnects
+	conn   atomic.Pointer[net.Conn]
+	closed atomic.Bool
+	done   chan struct{} // Closed by Close to stop waiting for a reconnect
+
+	readDeadline  atomic.Int64 // Applied to every new connection
+	writeDeadline atomic.Int64
+}
+
+var ErrReconnectFailed = errors.New("reconnect failed")
+
+// DialReconnecting connects to the address like Dial and returns a
+// connection that re-dials it when it is lost. The first dial isn't
+// retried, so configuration errors surface at once.
+func (d *Device) DialReconnecting(network, address string, cfg ReconnectConfig) (*ReconnectingConn, error) {
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	conn, err := d.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	rc := &ReconnectingConn{
+		device:  d,
+		network: network,
+		address: address,
+		cfg:     cfg,
+		done:    make(chan struct{}),
+	}
+	rc.conn.Store(&conn)
+	return rc, nil
+}
+
+// Read reads from the current connection, reconnecting if it was lost
+func (rc *ReconnectingConn) Read(b []byte) (int, error) {
+	for {
+		conn := rc.current()
+		n, err := conn.Read(b)
+		if n > 0 || !connectionLost(err) {
+			return n, err
+		}
+		if errors.Is(err, io.EOF) && !rc.closed.Load() && !rc.device.lostUncleanly(conn) {
+			return 0, err
+		}
+		if err := rc.reconnect(conn); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Write writes to the current connection. If the connection is lost the
+// rest of b is written to the new connection.
+func (rc *ReconnectingConn) Write(b []byte) (int, error) {
+	written := 0
+	for {
+		conn := rc.current()
+		n, err := conn.Write(b[written:])
+		written += n
+		if !connectionLost(err) {
+			return written, err
+		}
+		if err := rc.reconnect(conn); err != nil {
+			return written, err
+		}
+	}
+}
+
+// Close closes the current connection and stops reconnecting
+func (rc *ReconnectingConn) Close() error {
+	if rc.closed.Swap(true) {
+		return ErrConnectionClosed
+	}
+	close(rc.done)
+	return rc.current().Close()
+}
+
+// LocalAddr returns the local address of the current connection
+func (rc *ReconnectingConn) LocalAddr() net.Addr {
+	return rc.current().LocalAddr()
+}
+
+// RemoteAddr returns the remote address of the current connection
+func (rc *ReconnectingConn) RemoteAddr() net.Addr {
+	return rc.current().RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines of the current connection
+// and of the connections dialed after it
+func (rc *ReconnectingConn) SetDeadline(t time.Time) error {
+	rc.readDeadline.Store(deadlineNanos(t))
+	rc.writeDeadline.Store(deadlineNanos(t))
+	return rc.current().SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the current connection and of
+// the connections dialed after it
+func (rc *ReconnectingConn) SetReadDeadline(t time.Time) error {
+	rc.readDeadline.Store(deadlineNanos(t))
+	return rc.current().SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the current connection and
+// of the connections dialed after it
+func (rc *ReconnectingConn) SetWriteDeadline(t time.Time) error {
+	rc.writeDeadline.Store(deadlineNanos(t))
+	return rc.current().SetWriteDeadline(t)
+}
+
+// current returns the connection in use
+func (rc *ReconnectingConn) current() net.Conn {
+	return *rc.conn.Load()
+}
+
+// reconnect replaces the lost connection unless another goroutine did already
+func (rc *ReconnectingConn) reconnect(lost net.Conn) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.closed.Load() {
+		return ErrConnectionClosed
+	}
+	if rc.current() != lost {
+		return nil
+	}
+	_ = lost.Close()
+
+	var err error
+	backoff := rc.cfg.MinBackoff
+	for attempt := 1; rc.cfg.MaxAttempts == 0 || attempt <= rc.cfg.MaxAttempts; attempt++ {
+		var conn net.Conn
+		if conn, err = rc.dial(); err == nil {
+			rc.conn.Store(&conn)
+			rc.device.log(SubsystemData, slog.LevelInfo, "reconnected", "address", rc.address, "attempt", attempt)
+			return nil
+		}
+		rc.device.log(SubsystemData, slog.LevelWarn, "reconnect failed", "address", rc.address, "attempt", attempt, "error", err)
+		if attempt == rc.cfg.MaxAttempts {
+			break
+		}
+
+		timer := time.NewTimer(rc.device.jitter(backoff))
+		select {
+		case <-rc.done:
+			timer.Stop()
+			return ErrConnectionClosed
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, rc.cfg.MaxBackoff)
+	}
+	return fmt.Errorf("%w: %w", ErrReconnectFailed, err)
+}
+
+// dial opens a new connection and prepares it for use
+func (rc *ReconnectingConn) dial() (net.Conn, error) {
+	conn, err := rc.device.Dial(rc.network, rc.address)
+	if err != nil {
+		return nil, err
+	}
+	if t := rc.readDeadline.Load(); t != 0 {
+		_ = conn.SetReadDeadline(time.Unix(0, t))
+	}
+	if t := rc.writeDeadline.Load(); t != 0 {
+		_ = conn.SetWriteDeadline(time.Unix(0, t))
+	}
+	if rc.cfg.OnReconnect != nil {
+		if err := rc.cfg.OnReconnect(conn); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// lostUncleanly reports whether conn ended other than by the remote host
+// closing it: with the data session, closed by the module or KeepAlive, or
+// after a failed send. Connections not dialed by the driver count as lost.
+func (d *Device) lostUncleanly(conn net.Conn) bool {
+	c, ok := conn.(*Connection)
+	if !ok {
+		return true
+	}
+	d.lock()
+	defer d.unlock()
+	return c.dropped || c.state == StateError
+}
+
+// connectionLost reports whether err means the connection is gone
+func connectionLost(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, ErrConnectionClosed) ||
+		errors.Is(err, ErrConnectionNotEstablished) ||
+		errors.Is(err, ErrCannotSend)
+}
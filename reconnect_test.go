@@ -0,0 +1,105 @@
+package sim800l
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+// droppedStatus is the connection status of a module that dropped
+// connection 0 without notice
+const droppedStatus = "\r\nOK\r\n\r\nSTATE: IP PROCESSING\r\n\r\n" +
+	"C: 0,0,\"TCP\",\"93.184.216.34\",\"80\",\"CLOSED\"\r\n" +
+	"C: 1,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+	"C: 2,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+	"C: 3,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+	"C: 4,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+	"C: 5,,\"\",\"\",\"\",\"INITIAL\"\r\n"
+
+func TestReconnectingConn(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CPAS": "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		"AT+CIPSTART=0,\"TCP\",\"example.com\",\"80\"": "\r\nOK\r\n\r\n0, CONNECT OK\r\n",
+		"AT+CIPCLOSE=0": "\r\n0, CLOSE OK\r\n",
+		"AT+CIPSTATUS":  droppedStatus,
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.IP = "10.0.0.1"
+
+	resumed := 0
+	rc, err := d.DialReconnecting("tcp", "example.com:80", ReconnectConfig{
+		MinBackoff: time.Millisecond,
+		OnReconnect: func(conn net.Conn) error {
+			resumed++
+			// The server answers the resumed session
+			modem.inject("+RECEIVE,0,2:\r\nok")
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	first := rc.current()
+
+	// The module dropped the connection without notice
+	if _, err := d.GetConnectionStatus(); err != nil {
+		t.Fatalf("status query failed: %v", err)
+	}
+	buf := make([]byte, 16)
+	n, err := rc.Read(buf)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(buf[:n]) != "ok" {
+		t.Errorf("expected %q, got %q", "ok", buf[:n])
+	}
+	if resumed != 1 || rc.current() == first {
+		t.Errorf("expected one reconnect, got %d", resumed)
+	}
+
+	// A connection the remote host closed ends like a net.Conn
+	modem.inject("\r\n0, CLOSED\r\n")
+	if _, err := rc.Read(buf); err != io.EOF {
+		t.Errorf("expected io.EOF after a remote close, got %v", err)
+	}
+	if resumed != 1 {
+		t.Errorf("expected no reconnect after a remote close, got %d", resumed)
+	}
+
+	if err := rc.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if _, err := rc.Read(buf); !errors.Is(err, ErrConnectionClosed) {
+		t.Errorf("expected ErrConnectionClosed after close, got %v", err)
+	}
+}
+
+func TestReconnectingConn_GivesUp(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CPAS": "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		"AT+CIPSTART=0,\"TCP\",\"example.com\",\"80\"": "\r\nOK\r\n\r\n0, CONNECT OK\r\n",
+		"AT+CIPSTATUS": droppedStatus,
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.IP = "10.0.0.1"
+
+	rc, err := d.DialReconnecting("tcp", "example.com:80", ReconnectConfig{
+		MinBackoff:  time.Millisecond,
+		MaxAttempts: 2,
+	})
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	// The host can't be reached any more
+	modem.responses["AT+CIPSTART=0,\"TCP\",\"example.com\",\"80\""] = "\r\nOK\r\n\r\n0, CONNECT FAIL\r\n"
+	if _, err := d.GetConnectionStatus(); err != nil {
+		t.Fatalf("status query failed: %v", err)
+	}
+	if _, err := rc.Read(make([]byte, 16)); !errors.Is(err, ErrReconnectFailed) || !errors.Is(err, ErrCannotConnect) {
+		t.Fatalf("expected ErrReconnectFailed wrapping ErrCannotConnect, got %v", err)
+	}
+}
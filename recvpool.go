@@ -0,0 +1,60 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the pool of connection receive buffers.
+package sim800l
+
+import "errors"
+
+var ErrNoReceiveBuffer = errors.New("no free receive buffer")
+
+// recvPoolBuffers returns the number of receive buffers in the pool
+func (d *Device) recvPoolBuffers() int {
+	if d.recvBufCount <= 0 {
+		return MaxConnections
+	}
+	return min(d.recvBufCount, MaxConnections)
+}
+
+// claimRecvBuffer gives connection id a receive buffer of RecvBufSize
+// bytes from the pool and reports whether one was free. A buffer is
+// allocated the first time it is claimed and reused after it is released,
+// so the pool only grows to the most connections open at once. A
+// connection keeps its buffer until it is released.
+func (d *Device) claimRecvBuffer(id uint8) bool {
+	if d.recvBuffers[id] != nil {
+		return true
+	}
+	count := d.recvPoolBuffers()
+	for i := 0; i < count; i++ {
+		if d.recvPoolUsed&(1<<i) != 0 {
+			continue
+		}
+		if d.recvPool[i] == nil {
+			d.recvPool[i] = make([]byte, RecvBufSize)
+		}
+		d.recvPoolUsed |= 1 << i
+		d.recvSegment[id] = uint8(i)
+		d.recvBuffers[id] = d.recvPool[i][:RecvBufSize:RecvBufSize]
+		return true
+	}
+	return false
+}
+
+// releaseRecvBuffer returns the receive buffer of connection id to the pool
+func (d *Device) releaseRecvBuffer(id uint8) {
+	if d.recvBuffers[id] == nil {
+		return
+	}
+	d.recvPoolUsed &^= 1 << d.recvSegment[id]
+	d.recvBuffers[id] = nil
+}
+
+// recvBufferFree reports whether the pool has a receive buffer left
+func (d *Device) recvBufferFree() bool {
+	count := d.recvPoolBuffers()
+	for i := 0; i < count; i++ {
+		if d.recvPoolUsed&(1<<i) == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_ReceiveBuffers(t *testing.T) {
+	slot(t, 1)
+	modem := newSessionModem()
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Configure(Config{ReceiveBuffers: 1})
+	if n := allocatedRecvBuffers(d); n != 0 {
+		t.Fatalf("expected no buffer before the first connection, got %d", n)
+	}
+
+	if err := d.Connect("internet", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	conn, err := d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	if n := allocatedRecvBuffers(d); n != 1 {
+		t.Errorf("expected one buffer, got %d", n)
+	}
+	if _, err := d.Dial("tcp", "example.com:80"); !errors.Is(err, ErrNoReceiveBuffer) {
+		t.Errorf("expected ErrNoReceiveBuffer, got %v", err)
+	}
+
+	// The echo lands in the pooled buffer
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	buf := make([]byte, 8)
+	if n, err := conn.Read(buf); err != nil || string(buf[:n]) != "ping" {
+		t.Fatalf("expected the echo, got %q, %v", buf[:n], err)
+	}
+
+	// Closing returns the buffer to the pool
+	if err := conn.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if d.recvPoolUsed != 0 {
+		t.Errorf("expected the buffer to be returned, got %08b", d.recvPoolUsed)
+	}
+	conn, err = d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("dial after close failed: %v", err)
+	}
+	_ = conn.Close()
+}
+
+func TestDevice_claimRecvBuffer(t *testing.T) {
+	s1 := slot(t, 1)
+	d := New(newMockModem(nil), nil, slog.New(slog.DiscardHandler))
+
+	// A buffer is allocated only when a connection claims it
+	if !d.claimRecvBuffer(0) {
+		t.Fatal("expected a buffer for connection 0")
+	}
+	if n := allocatedRecvBuffers(d); n != 1 {
+		t.Errorf("expected one buffer for one connection, got %d", n)
+	}
+	for i := uint8(1); i < MaxConnections; i++ {
+		if !d.claimRecvBuffer(i) {
+			t.Fatalf("expected a buffer for connection %d", i)
+		}
+	}
+	if n := allocatedRecvBuffers(d); n != MaxConnections {
+		t.Errorf("expected a buffer per connection by default, got %d", n)
+	}
+
+	// Buffers don't overlap
+	d.recvBuffers[s1][0] = 'x'
+	d.recvBuffers[0] = append(d.recvBuffers[0][:RecvBufSize], 'y')
+	if d.recvBuffers[s1][0] != 'x' {
+		t.Error("appending to a full buffer overwrote the next one")
+	}
+
+	// A released buffer is reused, not allocated again
+	buf := &d.recvBuffers[s1][0]
+	d.releaseRecvBuffer(s1)
+	if !d.claimRecvBuffer(s1) || &d.recvBuffers[s1][0] != buf {
+		t.Error("expected the released buffer to be reused")
+	}
+	if n := allocatedRecvBuffers(d); n != MaxConnections {
+		t.Errorf("expected no more buffers after reuse, got %d", n)
+	}
+}
+
+// allocatedRecvBuffers returns the number of receive buffers allocated
+func allocatedRecvBuffers(d *Device) int {
+	n := 0
+	for _, buf := range d.recvPool {
+		if buf != nil {
+			n++
+		}
+	}
+	return n
+}
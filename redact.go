@@ -0,0 +1,64 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the redaction of secrets from the commands the driver
+// keeps for errors, diagnostics, traces and logs.
+package sim800l
+
+import "bytes"
+
+var redacted = []byte("***") // Stands for a secret in recorded commands
+
+// secretParam is a command whose quoted parameter at index field is a secret
+type secretParam struct {
+	prefix []byte // Command, without AT
+	field  int    // Index of the secret among the quoted parameters
+}
+
+// secretParams are the commands that carry secrets
+var secretParams = [...]secretParam{
+	{cmdEnterPIN, 0},   // SIM PIN
+	{cmdSSLSetCert, 1}, // Password of a certificate's private key
+}
+
+// secretField returns the bounds in cmd of the secret it carries, without
+// its quotes, or false if it carries none
+func secretField(cmd []byte) (int, int, bool) {
+	body := cmd
+	if len(body) >= len(at) && bytes.EqualFold(body[:len(at)], at) {
+		body = body[len(at):]
+	}
+	for _, s := range secretParams {
+		if len(body) < len(s.prefix) || !bytes.EqualFold(body[:len(s.prefix)], s.prefix) {
+			continue
+		}
+		field := 0
+		for i := len(cmd) - len(body) + len(s.prefix); i < len(cmd); i++ {
+			if cmd[i] != '"' {
+				continue
+			}
+			end := bytes.IndexByte(cmd[i+1:], '"')
+			if end < 0 {
+				return 0, 0, false
+			}
+			end += i + 1
+			if field == s.field {
+				return i + 1, end, true
+			}
+			field++
+			i = end
+		}
+		return 0, 0, false
+	}
+	return 0, 0, false
+}
+
+// appendRedacted appends cmd to dst with the secret it carries, if any,
+// replaced by ***
+func appendRedacted(dst, cmd []byte) []byte {
+	start, end, ok := secretField(cmd)
+	if !ok {
+		return append(dst, cmd...)
+	}
+	dst = append(dst, cmd[:start]...)
+	dst = append(dst, redacted...)
+	return append(dst, cmd[end:]...)
+}
@@ -0,0 +1,106 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains waiting for network registration.
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// registrationPollInterval is the time between registration checks of
+// WaitForNetwork
+const registrationPollInterval = time.Second
+
+// Registration states of +CREG: <n>,<stat>
+const (
+	regNotSearching byte = '0' // Not registered and not searching
+	regHome         byte = '1' // Registered on the home network
+	regSearching    byte = '2' // Not registered, searching for a network
+	regDenied       byte = '3' // Registration denied by the network
+	regUnknown      byte = '4' // Unknown
+	regRoaming      byte = '5' // Registered on a roaming network
+)
+
+var (
+	ErrRegistrationDenied = errors.New("network registration denied")
+	ErrNetworkSearching   = errors.New("still searching for a network")
+)
+
+// WaitForNetwork waits until the module is registered on a network, home
+// or roaming, checking +CREG every second for at most timeout. After a
+// reset the module takes a while to find a network, and attaching to GPRS
+// fails until it has, so call it between Init and Connect. A network that
+// denies registration, e.g. for a SIM without service, returns
+// ErrRegistrationDenied at once. If timeout passes it returns an error
+// matching ErrTimeout and ErrNetworkSearching while the module is still
+// searching, or ErrNotRegistered if it has stopped.
+func (d *Device) WaitForNetwork(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		d.lock()
+		stat, err := d.registration()
+		d.unlock()
+		if err != nil {
+			return err
+		}
+
+		switch stat {
+		case regHome, regRoaming:
+			return nil
+		case regDenied:
+			return ErrRegistrationDenied
+		}
+		if !time.Now().Add(registrationPollInterval).Before(deadline) {
+			if stat == regSearching {
+				return fmt.Errorf("%w: %w", ErrTimeout, ErrNetworkSearching)
+			}
+			return fmt.Errorf("%w: %w", ErrTimeout, ErrNotRegistered)
+		}
+		d.log(SubsystemCommand, slog.LevelDebug, "waiting for network registration", "status", string(stat))
+		time.Sleep(registrationPollInterval)
+	}
+}
+
+// registrationError returns why the registration state stat isn't a
+// registration, or nil if it is one
+func registrationError(stat byte) error {
+	switch stat {
+	case regHome, regRoaming:
+		return nil
+	case regDenied:
+		return ErrRegistrationDenied
+	case regSearching:
+		return ErrNetworkSearching
+	}
+	return ErrNotRegistered
+}
+
+// registration queries the registration state with the lock held
+func (d *Device) registration() (byte, error) {
+	err := d.sendWithOptions(cmdRegistration, prefixCheck(registration), DefaultTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check registration: %w", err)
+	}
+	val, ok := d.parseValue(registration)
+	if !ok {
+		return 0, ErrUnexpectedResponse
+	}
+	return registrationStat(val), nil
+}
+
+// registrationStat returns the state of a registration status like 0,5
+// or 2,1,"1A2B","0C3D", or regUnknown if it is missing
+func registrationStat(val []byte) byte {
+	fields := bytes.Split(val, []byte(","))
+	if len(fields) < 2 {
+		return regUnknown
+	}
+	stat := bytes.TrimSpace(fields[1])
+	if len(stat) != 1 {
+		return regUnknown
+	}
+	return stat[0]
+}
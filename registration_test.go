@@ -0,0 +1,40 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_WaitForNetwork(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		wantErr []error
+	}{
+		{name: "home", reply: "+CREG: 0,1"},
+		{name: "roaming with location", reply: "+CREG: 2,5,\"1A2B\",\"0C3D\""},
+		{name: "denied", reply: "+CREG: 0,3", wantErr: []error{ErrRegistrationDenied}},
+		{name: "searching", reply: "+CREG: 0,2", wantErr: []error{ErrTimeout, ErrNetworkSearching}},
+		{name: "not searching", reply: "+CREG: 0,0", wantErr: []error{ErrTimeout, ErrNotRegistered}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modem := newMockModem(map[string]string{
+				"AT+CREG?": "\r\n" + tt.reply + "\r\n\r\nOK\r\n",
+			})
+			d := New(modem, nil, slog.New(&MockHandler{t: t}))
+
+			// Without time to wait, the first answer decides
+			err := d.WaitForNetwork(0)
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Fatalf("expected registration, got %v", err)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("expected %v, got %v", want, err)
+				}
+			}
+		})
+	}
+}
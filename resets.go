@@ -0,0 +1,166 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains module uptime and reset accounting.
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var (
+	cmdRestart       = []byte("+CFUN=1,1")           // Restart the module
+	voltagePowerDown = []byte("-VOLTAGE POWER DOWN") // Ends the under- and over-voltage power down URCs
+)
+
+// ResetReason tells why the module was reset
+type ResetReason uint8
+
+const (
+	ResetRequested ResetReason = iota // The application called Init, HardReset or SoftReset
+	ResetWatchdog                     // The application's watchdog reset a module that stopped responding
+	ResetBrownout                     // The module powered itself down on under- or over-voltage
+	resetReasons
+)
+
+// String returns the name of the reset reason
+func (r ResetReason) String() string {
+	switch r {
+	case ResetRequested:
+		return "requested"
+	case ResetWatchdog:
+		return "watchdog"
+	case ResetBrownout:
+		return "brownout"
+	default:
+		return "unknown"
+	}
+}
+
+// ResetCounters counts module resets, to quantify field reliability
+// across firmware versions
+type ResetCounters struct {
+	Hard     uint32               // Resets with the reset pin
+	Soft     uint32               // Resets with AT+CFUN=1,1
+	ByReason [resetReasons]uint32 // Resets and brownouts per ResetReason
+}
+
+// ResetStore keeps ResetCounters across restarts of the microcontroller,
+// e.g. in flash. SaveResets is called with the device's lock held after
+// every reset, so it must not call back into the Device.
+type ResetStore interface {
+	LoadResets() (ResetCounters, error)
+	SaveResets(counters ResetCounters) error
+}
+
+// ResetCounters returns the resets counted so far, including those loaded
+// from Config.ResetStore
+func (d *Device) ResetCounters() ResetCounters {
+	d.lock()
+	defer d.unlock()
+	return d.resets
+}
+
+// Uptime returns the time since the driver last reset the module. It is
+// zero if the driver hasn't reset the module, e.g. Init without a reset
+// pin, or if the module has powered down since.
+func (d *Device) Uptime() time.Duration {
+	d.lock()
+	defer d.unlock()
+	if d.startedAt.IsZero() {
+		return 0
+	}
+	return time.Since(d.startedAt)
+}
+
+// Reset resets the module for the given reason, with the reset pin if
+// there is one and AT+CFUN=1,1 otherwise. Firmware watchdogs use it with
+// ResetWatchdog so their resets are counted apart from planned ones.
+func (d *Device) Reset(reason ResetReason) error {
+	if reason >= resetReasons {
+		return ErrBadParameter
+	}
+	d.lock()
+	defer d.unlock()
+	return d.hardReset(reason)
+}
+
+// SoftReset restarts the module with AT+CFUN=1,1, for boards without a
+// reset pin
+func (d *Device) SoftReset() error {
+	d.lock()
+	defer d.unlock()
+	return d.softReset(ResetRequested)
+}
+
+// softReset restarts the module with the lock held
+func (d *Device) softReset(reason ResetReason) error {
+	d.log(SubsystemPower, slog.LevelDebug, "software reset", "reason", reason)
+	if err := d.send(cmdRestart); err != nil {
+		return err
+	}
+	d.forgetSettings()
+	d.recordReset(false, reason)
+
+	// Wait for device to boot and stabilize
+	_ = d.waitReady(context.Background())
+	if err := d.send(at); err != nil {
+		return ErrNotReady
+	}
+	d.powerState = true
+	return nil
+}
+
+// recordReset counts a reset of the module and saves the counters
+func (d *Device) recordReset(hard bool, reason ResetReason) {
+	if hard {
+		d.resets.Hard++
+	} else {
+		d.resets.Soft++
+	}
+	d.resets.ByReason[reason]++
+	d.startedAt = time.Now()
+	d.saveResets()
+}
+
+// brownout handles the URC the module sends before it powers down on
+// under- or over-voltage. The line is still passed to a registered handler.
+func (d *Device) brownout(line []byte) bool {
+	if !bytes.HasSuffix(line, voltagePowerDown) {
+		return false
+	}
+	d.log(SubsystemPower, slog.LevelWarn, "module powered down", "reason", line)
+	d.resets.ByReason[ResetBrownout]++
+	d.startedAt = time.Time{}
+	d.powerState = false
+	d.forgetSettings()
+	d.saveResets()
+	d.sessionLost(errors.New("module powered down"))
+	d.dispatchURC(line)
+	return true
+}
+
+// loadResets replaces the counters with those kept by the store
+func (d *Device) loadResets() {
+	if d.resetStore == nil {
+		return
+	}
+	counters, err := d.resetStore.LoadResets()
+	if err != nil {
+		d.log(SubsystemPower, slog.LevelWarn, "failed to load reset counters", "error", err)
+		return
+	}
+	d.resets = counters
+}
+
+// saveResets hands the counters to the store, if any
+func (d *Device) saveResets() {
+	if d.resetStore == nil {
+		return
+	}
+	if err := d.resetStore.SaveResets(d.resets); err != nil {
+		d.log(SubsystemPower, slog.LevelWarn, "failed to save reset counters", "error", err)
+	}
+}
@@ -0,0 +1,142 @@
+package sim800l
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// memoryResetStore keeps reset counters in memory
+type memoryResetStore struct {
+	counters ResetCounters
+	saves    int
+}
+
+func (s *memoryResetStore) LoadResets() (ResetCounters, error) {
+	return s.counters, nil
+}
+
+func (s *memoryResetStore) SaveResets(counters ResetCounters) error {
+	s.counters = counters
+	s.saves++
+	return nil
+}
+
+func TestDevice_ResetCounters(t *testing.T) {
+	modem := newMockModem(map[string]string{})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	store := &memoryResetStore{counters: ResetCounters{Hard: 3}}
+	d.Configure(Config{ResetStore: store})
+
+	if got := d.ResetCounters(); got.Hard != 3 {
+		t.Fatalf("expected the stored counters to be loaded, got %+v", got)
+	}
+	if d.Uptime() != 0 {
+		t.Errorf("expected no uptime before a reset, got %v", d.Uptime())
+	}
+	if err := d.Reset(resetReasons); err != ErrBadParameter {
+		t.Errorf("expected ErrBadParameter for an unknown reason, got %v", err)
+	}
+
+	d.lock()
+	d.recordReset(true, ResetWatchdog)
+	d.unlock()
+	got := d.ResetCounters()
+	if got.Hard != 4 || got.ByReason[ResetWatchdog] != 1 || store.counters != got {
+		t.Errorf("expected a saved watchdog hard reset, got %+v, stored %+v", got, store.counters)
+	}
+	if d.Uptime() <= 0 {
+		t.Errorf("expected uptime after a reset, got %v", d.Uptime())
+	}
+
+	// The module reports a brownout, which handlers still see
+	var seen string
+	if err := d.RegisterURCHandler("UNDER-VOLTAGE", func(tok Token) { seen = string(tok.Data) }); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	modem.inject("\r\nUNDER-VOLTAGE POWER DOWN\r\n")
+	if err := d.Poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	got = d.ResetCounters()
+	if got.ByReason[ResetBrownout] != 1 || store.saves != 2 {
+		t.Errorf("expected a saved brownout, got %+v after %d saves", got, store.saves)
+	}
+	if d.Uptime() != 0 {
+		t.Errorf("expected no uptime after a brownout, got %v", d.Uptime())
+	}
+	if seen != "UNDER-VOLTAGE POWER DOWN" {
+		t.Errorf("expected the handler to see the URC, got %q", seen)
+	}
+}
+
+func TestDevice_HardResetWithoutPin(t *testing.T) {
+	// The module refuses the restart, so the test doesn't wait StartupTime
+	modem := newMockModem(map[string]string{})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	if err := d.HardReset(); err == nil {
+		t.Error("expected the refused restart to fail")
+	}
+	if len(modem.commands) != 1 || modem.commands[0] != "AT+CFUN=1,1" {
+		t.Errorf("expected a restart with AT+CFUN=1,1, got %q", modem.commands)
+	}
+}
+
+func TestDevice_SoftResetWaitsForBoot(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT":          "\r\nOK\r\n",
+		"AT+CFUN=1,1": "\r\nOK\r\n\r\nRDY\r\n\r\n+CFUN: 1\r\n\r\n+CPIN: READY\r\n\r\nCall Ready\r\n\r\nSMS Ready\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	// The boot URCs end the wait long before StartupTime
+	start := time.Now()
+	if err := d.SoftReset(); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > bootProbeDelay {
+		t.Errorf("expected the reset to end with SMS Ready, took %v", elapsed)
+	}
+
+	// A module in auto-baud mode stays silent until it is probed
+	modem.responses["AT+CFUN=1,1"] = "\r\nOK\r\n"
+	start = time.Now()
+	if err := d.SoftReset(); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < bootProbeDelay || elapsed > bootProbeDelay+2*bootPollInterval {
+		t.Errorf("expected the reset to end with the first probe, took %v", elapsed)
+	}
+	if boot := d.Diagnostics().BootTime; boot < bootProbeDelay || boot > time.Since(start) {
+		t.Errorf("expected the boot time in the diagnostics, got %v", boot)
+	}
+}
+
+func TestDevice_waitReadyBootURCsWhileProbing(t *testing.T) {
+	// The module boots slowly and answers the probes with ERROR
+	modem := newMockModem(nil)
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	go func() {
+		time.Sleep(bootProbeDelay + bootPollInterval/2)
+		modem.inject("\r\nRDY\r\n\r\n+CFUN: 1\r\n\r\n+CPIN: READY\r\n")
+		time.Sleep(2 * bootPollInterval)
+		modem.inject("\r\nCall Ready\r\n\r\nSMS Ready\r\n")
+	}()
+
+	// The boot URCs switch back to waiting for SMS Ready
+	start := time.Now()
+	d.lock()
+	err := d.waitReady(context.Background())
+	d.unlock()
+	if err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > bootProbeDelay+4*bootPollInterval {
+		t.Errorf("expected the wait to end with SMS Ready, took %v", elapsed)
+	}
+	if boot := d.Diagnostics().BootTime; boot < bootProbeDelay+2*bootPollInterval {
+		t.Errorf("expected the boot to end with SMS Ready, not a probe, after %v", boot)
+	}
+}
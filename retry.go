@@ -0,0 +1,67 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the retry policy for transient command failures.
+package sim800l
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Default retry policy for commands that fail with a transient error
+const (
+	DefaultRetryAttempts = 3
+	DefaultRetryBackoff  = 500 * time.Millisecond
+)
+
+// RetryPolicy controls how commands failing with a transient error, like
+// +CME ERROR: 14 while the SIM is still busy after a reset, are sent again.
+// The zero value makes DefaultRetryAttempts with the default backoff.
+type RetryPolicy struct {
+	Attempts int           // Attempts per command, DefaultRetryAttempts if zero; 1 disables retries
+	Backoff  time.Duration // Wait before the first retry, doubled for each further one, DefaultRetryBackoff if zero
+
+	// Retryable classifies the errors worth sending the command again
+	// for, IsTransient if nil
+	Retryable func(err error) bool
+}
+
+// IsTransient reports whether err is a failure that may pass if the
+// command is sent again a little later: the SIM or the module is busy.
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrSIMBusy) || errors.Is(err, ErrDeviceBusy)
+}
+
+// retryAfter reports whether a command that failed with err on attempt,
+// counted from 1, is to be sent again, and waits the backoff if so. It
+// gives up if ctx's deadline comes before the backoff ends, and returns
+// ctx's error if ctx is done while waiting, err otherwise.
+func (d *Device) retryAfter(ctx context.Context, attempt int, err error) (bool, error) {
+	attempts := d.retry.Attempts
+	if attempts == 0 {
+		attempts = DefaultRetryAttempts
+	}
+	retryable := d.retry.Retryable
+	if retryable == nil {
+		retryable = IsTransient
+	}
+	if attempt >= attempts || !retryable(err) {
+		return false, err
+	}
+
+	backoff := d.retry.Backoff
+	if backoff == 0 {
+		backoff = DefaultRetryBackoff
+	}
+	backoff = d.jitter(backoff << (attempt - 1))
+	// No time would be left to send the command again
+	if contextTimeout(ctx, backoff) < backoff {
+		return false, err
+	}
+	d.log(SubsystemCommand, slog.LevelWarn, "retrying command", "attempt", attempt+1, "backoff", backoff, "error", err)
+	if cerr := sleepContext(ctx, backoff); cerr != nil {
+		return false, cerr
+	}
+	return true, err
+}
@@ -0,0 +1,95 @@
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// busyModem answers the next busy commands with a busy SIM
+type busyModem struct {
+	*mockModem
+	busy int
+}
+
+func (m *busyModem) Write(p []byte) (int, error) {
+	if m.busy > 0 && bytes.HasSuffix(p, crlf) {
+		m.busy--
+		m.inject("\r\n+CME ERROR: 14\r\n")
+		return len(p), nil
+	}
+	return m.mockModem.Write(p)
+}
+
+func TestDevice_RetryTransient(t *testing.T) {
+	modem := &busyModem{mockModem: newMockModem(map[string]string{
+		"AT+CPIN?": "\r\n+CPIN: READY\r\n\r\nOK\r\n",
+	})}
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Configure(Config{Retry: RetryPolicy{Backoff: time.Millisecond}})
+
+	modem.busy = DefaultRetryAttempts - 1
+	if s, err := d.QueryString("+CPIN?"); err != nil || s != "READY" {
+		t.Errorf("expected READY after the SIM was busy, got %q, %v", s, err)
+	}
+
+	modem.busy = DefaultRetryAttempts
+	if _, err := d.QueryString("+CPIN?"); !errors.Is(err, ErrSIMBusy) {
+		t.Errorf("expected ErrSIMBusy after %d attempts, got %v", DefaultRetryAttempts, err)
+	}
+
+	// Other errors aren't retried
+	sent := len(modem.commands)
+	if _, err := d.QueryString("+COPS?"); err == nil || len(modem.commands) != sent+1 {
+		t.Errorf("expected one failed attempt, got %d, %v", len(modem.commands)-sent, err)
+	}
+
+	d.Configure(Config{Retry: RetryPolicy{Attempts: 1}})
+	modem.busy = 1
+	if _, err := d.QueryString("+CPIN?"); !errors.Is(err, ErrSIMBusy) {
+		t.Errorf("expected ErrSIMBusy without retries, got %v", err)
+	}
+}
+
+func TestDevice_RetryContext(t *testing.T) {
+	modem := &busyModem{mockModem: newMockModem(map[string]string{
+		"AT+CPIN?": "\r\n+CPIN: READY\r\n\r\nOK\r\n",
+	})}
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Configure(Config{Retry: RetryPolicy{Attempts: 5, Backoff: time.Second}})
+
+	// The backoff doesn't fit before the deadline; a busy command left
+	// over shows no retry was sent
+	modem.busy = 2
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := d.sendContext(ctx, []byte("+CPIN?"), defaultResponseCheck); !errors.Is(err, ErrSIMBusy) {
+		t.Errorf("expected ErrSIMBusy, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond || modem.busy != 1 {
+		t.Errorf("expected one attempt without waiting, got %d in %v", 2-modem.busy, elapsed)
+	}
+
+	// Cancelling ends the backoff
+	modem.busy = 1
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start = time.Now()
+	if err := d.sendContext(ctx, []byte("+CPIN?"), defaultResponseCheck); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the backoff to end on cancel, took %v", elapsed)
+	}
+
+	// TryCommand doesn't retry
+	modem.busy = 2
+	var resp [32]byte
+	if _, err := d.TryCommand([]byte("AT+CPIN?"), resp[:], 5*time.Second); !errors.Is(err, ErrSIMBusy) || modem.busy != 1 {
+		t.Errorf("expected one attempt failing with ErrSIMBusy, got %d, %v", 2-modem.busy, err)
+	}
+}
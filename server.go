@@ -0,0 +1,185 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the TCP server mode (net.Listener).
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	cmdServer         = []byte("+CIPSERVER=")  // Start (1,<port>) or stop (0) the TCP server
+	serverStarted     = []byte("SERVER OK")    // Sent once the server listens
+	remoteConnectInfo = []byte(", REMOTE IP:") // Follows the ID of an accepted connection
+)
+
+var ErrListenerOpen = errors.New("listener already open")
+
+// Listener accepts inbound TCP connections on the module.
+// Implements the net.Listener interface
+type Listener struct {
+	device *Device
+	port   uint16
+	closed atomic.Bool
+}
+
+// Listen starts the module's TCP server on port and returns a listener
+// for the connections it accepts. Accepted connections use connection
+// slots like dialed ones. Only "tcp" is supported and only one listener
+// can be open at a time.
+func (d *Device) Listen(network string, port uint16) (net.Listener, error) {
+	d.lock()
+	defer d.unlock()
+
+	if strings.ToLower(network) != "tcp" {
+		return nil, fmt.Errorf("unsupported network type: %s", network)
+	}
+	if d.singleConn {
+		return nil, fmt.Errorf("%w: listening in single-connection mode", ErrNotSupported)
+	}
+	if d.IP == "" {
+		return nil, ErrNoIP
+	}
+	if d.listener != nil {
+		return nil, ErrListenerOpen
+	}
+
+	var buf [24]byte
+	cmd := append(buf[:0], cmdServer...)
+	cmd = append(cmd, '1', ',')
+	cmd = strconv.AppendUint(cmd, uint64(port), 10)
+	if err := d.send(cmd); err != nil {
+		return nil, fmt.Errorf("failed to start server: %w", err)
+	}
+	if err := d.readResponse(cmdServer, func(buffer []byte) error {
+		if bytes.Contains(buffer, serverStarted) {
+			return nil
+		}
+		return defaultResponseCheck(buffer)
+	}, DefaultTimeout); err != nil {
+		return nil, fmt.Errorf("failed to start server: %w", err)
+	}
+
+	d.acceptCount = 0
+	d.listener = &Listener{device: d, port: port}
+	return d.listener, nil
+}
+
+// Accept waits for and returns the next inbound connection.
+// Implements the net.Listener interface
+func (l *Listener) Accept() (net.Conn, error) {
+	d := l.device
+	for {
+		if l.closed.Load() {
+			return nil, net.ErrClosed
+		}
+
+		d.lock()
+		if d.acceptCount == 0 {
+			if err := d.poll(); err != nil {
+				d.log(SubsystemData, slog.LevelDebug, "error checking for connections", "error", err)
+			}
+		}
+		if d.acceptCount > 0 {
+			conn := d.connections[d.acceptQueue[0]]
+			copy(d.acceptQueue[:], d.acceptQueue[1:d.acceptCount])
+			d.acceptCount--
+			d.unlock()
+			// The connection may have been closed while queued
+			if conn == nil {
+				continue
+			}
+			return conn, nil
+		}
+		d.unlock()
+
+		// Wait unlocked so other goroutines can use the device
+		time.Sleep(readPollInterval)
+	}
+}
+
+// Close stops the module's TCP server. Connections already accepted stay open.
+// Implements the net.Listener interface
+func (l *Listener) Close() error {
+	if l.closed.Swap(true) {
+		return net.ErrClosed
+	}
+
+	d := l.device
+	d.lock()
+	defer d.unlock()
+	d.listener = nil
+	d.acceptCount = 0
+
+	var buf [16]byte
+	cmd := append(buf[:0], cmdServer...)
+	cmd = append(cmd, '0')
+	if err := d.send(cmd); err != nil {
+		return fmt.Errorf("failed to stop server: %w", err)
+	}
+	return nil
+}
+
+// Addr returns the address the listener accepts connections on.
+// Implements the net.Listener interface
+func (l *Listener) Addr() net.Addr {
+	return simpleAddr{
+		network: "tcp",
+		address: l.device.IP + ":" + strconv.Itoa(int(l.port)),
+	}
+}
+
+// acceptRemote registers the connection announced by a line like
+// "1, REMOTE IP: 10.0.0.2" and reports whether line was such an announcement
+func (d *Device) acceptRemote(line []byte) bool {
+	idx := bytes.Index(line, remoteConnectInfo)
+	if idx < 0 {
+		return false
+	}
+	id, err := strconv.Atoi(string(line[:idx]))
+	if err != nil || id < 0 || id >= MaxConnections {
+		return false
+	}
+	if d.listener == nil {
+		d.log(SubsystemURC, slog.LevelWarn, "inbound connection without listener", "id", id)
+		return true
+	}
+
+	d.releaseConnection(uint8(id))
+	d.connections[id] = &Connection{
+		ID:       uint8(id),
+		Type:     TCP,
+		state:    StateConnected,
+		RemoteIP: string(bytes.TrimSpace(line[idx+len(remoteConnectInfo):])),
+		// The module doesn't report the remote port
+		RemotePort:  "0",
+		Device:      d,
+		connectedAt: time.Now(),
+	}
+	if !d.claimRecvBuffer(uint8(id)) {
+		d.log(SubsystemURC, slog.LevelWarn, "no receive buffer for accepted connection", "id", id)
+	}
+	if d.acceptCount < len(d.acceptQueue) {
+		d.acceptQueue[d.acceptCount] = uint8(id)
+		d.acceptCount++
+	}
+	d.log(SubsystemURC, slog.LevelDebug, "accepted connection", "id", id, "remote", d.connections[id].RemoteIP)
+	return true
+}
+
+// forgetListener closes the open listener after the module stopped its
+// server, e.g. together with the PDP context
+func (d *Device) forgetListener() {
+	if d.listener != nil {
+		d.listener.closed.Store(true)
+		d.listener = nil
+	}
+	d.acceptCount = 0
+}
@@ -0,0 +1,87 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDevice_Listen(t *testing.T) {
+	s2 := slot(t, 2)
+	modem := newMockModem(map[string]string{
+		"AT+CIPSERVER=1,8080": "\r\nOK\r\n\r\nSERVER OK\r\n",
+		"AT+CIPSERVER=0":      "\r\nOK\r\n\r\nSERVER CLOSE\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.IP = "10.0.0.1"
+
+	l, err := d.Listen("tcp", 8080)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	if got := l.Addr().String(); got != "10.0.0.1:8080" {
+		t.Errorf("expected address 10.0.0.1:8080, got %s", got)
+	}
+	if _, err := d.Listen("tcp", 8081); err != ErrListenerOpen {
+		t.Errorf("expected ErrListenerOpen, got %v", err)
+	}
+
+	modem.inject("\r\n2, REMOTE IP: 10.0.0.2\r\n+RECEIVE,2,5:\r\nhello")
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept failed: %v", err)
+	}
+	if got := conn.RemoteAddr().String(); got != "10.0.0.2:0" {
+		t.Errorf("expected remote address 10.0.0.2:0, got %s", got)
+	}
+	buf := make([]byte, 16)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(buf[:n]) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", buf[:n])
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if d.connections[s2] == nil {
+		t.Error("closing the listener closed an accepted connection")
+	}
+}
+
+func TestListener_CloseUnblocksAccept(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CIPSERVER=1,8080": "\r\nOK\r\n\r\nSERVER OK\r\n",
+		"AT+CIPSERVER=0":      "\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.IP = "10.0.0.1"
+
+	l, err := d.Listen("tcp", 8080)
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	acceptErr := make(chan error, 1)
+	go func() {
+		_, err := l.Accept()
+		acceptErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := l.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	select {
+	case err := <-acceptErr:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("expected net.ErrClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("accept still blocked after close")
+	}
+}
@@ -0,0 +1,143 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains supervision of the GPRS data session.
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// DefaultSessionInterval is the time between checks of SuperviseSession
+const DefaultSessionInterval = 30 * time.Second
+
+var pdpDeactivated = []byte("+PDP: DEACT") // The network deactivated the PDP context
+
+var ErrNotRegistered = errors.New("not registered on a network")
+
+// SessionConfig controls how SuperviseSession watches the data session.
+// The zero value uses the defaults.
+type SessionConfig struct {
+	Interval   time.Duration // Time between checks, DefaultSessionInterval if zero
+	MinBackoff time.Duration // Wait before the first retry of a failed restore, DefaultMinBackoff if zero
+	MaxBackoff time.Duration // Longest wait between retries, DefaultMaxBackoff if zero
+}
+
+// SuperviseSession keeps the data session of the last Connect up until
+// ctx is done or the application ends it with Disconnect or
+// DeactivateContext. It notices +PDP: DEACT from the network and a module that
+// is no longer attached, and once the module is registered again brings
+// the session back up with the same APN, restoring IP without the
+// application's help. Failed restores are retried with backoff. Open
+// connections don't survive a dropped session; their Read returns the
+// data received before the drop, then io.EOF.
+// Losing and restoring the session emit EventSessionLost and
+// EventSessionRestored.
+func (d *Device) SuperviseSession(ctx context.Context, cfg SessionConfig) error {
+	ctx, done, err := d.background(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultSessionInterval
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+
+	wait, backoff := cfg.Interval, cfg.MinBackoff
+	for {
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+
+		d.lock()
+		err := d.superviseSession()
+		d.unlock()
+		if err == nil {
+			wait, backoff = cfg.Interval, cfg.MinBackoff
+			continue
+		}
+		d.log(SubsystemCommand, slog.LevelWarn, "data session not restored", "error", err, "retry", backoff)
+		wait, backoff = d.jitter(backoff), min(2*backoff, cfg.MaxBackoff)
+	}
+}
+
+// superviseSession checks the data session once with the lock held and
+// brings it up again if it dropped
+func (d *Device) superviseSession() error {
+	if !d.sessionUp {
+		return nil // Never connected or disconnected, nothing to keep up
+	}
+
+	// Pick up a +PDP: DEACT that arrived while idle
+	if err := d.poll(); err != nil {
+		d.log(SubsystemURC, slog.LevelDebug, "error checking for URCs", "error", err)
+	}
+	if d.IP != "" {
+		attached, err := d.attached()
+		if err != nil || attached {
+			return err
+		}
+		d.sessionLost(errors.New("detached from GPRS"))
+	}
+
+	// Attaching fails until the module is registered again
+	if stat, err := d.registration(); err != nil {
+		return err
+	} else if stat != regHome && stat != regRoaming {
+		return ErrNotRegistered
+	}
+
+	// The dropped PDP context must be shut down before it comes up again
+	if err := d.send(cmdShutPdp); err != nil {
+		return fmt.Errorf("failed to shut down PDP context: %w", err)
+	}
+	if err := d.connect(context.Background(), d.gprs); err != nil {
+		return err
+	}
+	d.log(SubsystemCommand, slog.LevelInfo, "data session restored", "ip", d.IP)
+	d.emit(Event{Type: EventSessionRestored})
+	if d.netdev != nil {
+		d.netdev.emit(NetEventUp)
+	}
+	return nil
+}
+
+// pdpDeact handles +PDP: DEACT, sent when the network drops the PDP context
+func (d *Device) pdpDeact(line []byte) bool {
+	if !bytes.HasPrefix(line, pdpDeactivated) {
+		return false
+	}
+	d.sessionLost(errors.New("PDP context deactivated by the network"))
+	return true
+}
+
+// sessionLost forgets the data session after it dropped. The module lost
+// every connection with it, so they are marked closed like after a remote
+// close: Read returns the data received before the drop, then io.EOF.
+func (d *Device) sessionLost(reason error) {
+	if d.IP == "" {
+		return
+	}
+	d.log(SubsystemCommand, slog.LevelWarn, "data session lost", "reason", reason)
+	for i := 0; i < MaxConnections; i++ {
+		if conn := d.connections[i]; conn != nil && conn.state != StateClosed {
+			conn.dropped = true
+			d.markClosed(uint8(i))
+		}
+	}
+	d.forgetListener()
+	d.IP = ""
+	d.emit(Event{Type: EventSessionLost, Err: reason})
+	if d.netdev != nil {
+		d.netdev.emit(NetEventDown)
+	}
+}
@@ -0,0 +1,143 @@
+package sim800l
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDevice_SuperviseSession(t *testing.T) {
+	modem := newSessionModem()
+	modem.responses["AT+CREG?"] = "\r\n+CREG: 0,1\r\n\r\nOK\r\n"
+	modem.responses["AT+CGATT=1"] = "\r\nOK\r\n"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	var events []EventType
+	d.SetEventHandler(func(e Event) { events = append(events, e.Type) })
+
+	if err := d.Connect("internet", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	conn, err := d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	// The network drops the PDP context
+	modem.inject("\r\n+PDP: DEACT\r\n")
+	modem.responses["AT+CIFSR"] = "\r\n10.0.0.2\r\n"
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := d.SuperviseSession(ctx, SessionConfig{Interval: 10 * time.Millisecond}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the supervisor to run until the deadline, got %v", err)
+	}
+	if d.IP != "10.0.0.2" {
+		t.Errorf("expected the session to be restored with IP 10.0.0.2, got %q", d.IP)
+	}
+	if _, err := conn.Read(make([]byte, 8)); err != io.EOF {
+		t.Errorf("expected the dropped connection to return io.EOF, got %v", err)
+	}
+	if len(events) != 2 || events[0] != EventSessionLost || events[1] != EventSessionRestored {
+		t.Errorf("expected SessionLost and SessionRestored, got %v", events)
+	}
+
+	// A silent detach is noticed, but the session waits for registration
+	modem.responses["AT+CGATT?"] = "\r\n+CGATT: 0\r\n\r\nOK\r\n"
+	modem.responses["AT+CREG?"] = "\r\n+CREG: 0,2\r\n\r\nOK\r\n"
+	d.lock()
+	err = d.superviseSession()
+	d.unlock()
+	if !errors.Is(err, ErrNotRegistered) || d.IP != "" {
+		t.Fatalf("expected ErrNotRegistered without IP, got %v with %q", err, d.IP)
+	}
+
+	modem.responses["AT+CREG?"] = "\r\n+CREG: 0,5\r\n\r\nOK\r\n"
+	d.lock()
+	err = d.superviseSession()
+	d.unlock()
+	if err != nil || d.IP == "" {
+		t.Fatalf("expected the session to be restored, got %v", err)
+	}
+	if modem.commandCount("AT+CGATT=1") != 1 {
+		t.Error("expected the module to attach again")
+	}
+}
+
+func TestDevice_pdpDeact(t *testing.T) {
+	modem := newSessionModem()
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	var events []Event
+	d.SetEventHandler(func(e Event) { events = append(events, e) })
+
+	if err := d.Connect("internet", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	conn, err := d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	var states []ConnectionState
+	conn.(*Connection).OnStateChange(func(_, to ConnectionState) { states = append(states, to) })
+	// The echo arrives before the drop and isn't read yet
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	// Noticed by any command, without SuperviseSession
+	modem.inject("\r\n+PDP: DEACT\r\n")
+	if err := d.Poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if d.IP != "" {
+		t.Errorf("expected the IP to be cleared, got %q", d.IP)
+	}
+	if len(states) != 1 || states[0] != StateClosed {
+		t.Errorf("expected the connection to move to CLOSED, got %v", states)
+	}
+	buf := make([]byte, 8)
+	if n, err := conn.Read(buf); err != nil || string(buf[:n]) != "ping" {
+		t.Errorf("expected the data received before the drop, got %q, %v", buf[:n], err)
+	}
+	if _, err := conn.Read(buf); err != io.EOF {
+		t.Errorf("expected Read to return io.EOF, got %v", err)
+	}
+	if _, err := conn.Write([]byte("x")); !errors.Is(err, ErrConnectionNotEstablished) {
+		t.Errorf("expected Write to fail, got %v", err)
+	}
+	if len(events) != 1 || events[0].Type != EventSessionLost || events[0].Err == nil {
+		t.Errorf("expected EventSessionLost with a reason, got %+v", events)
+	}
+}
+
+func TestDevice_SuperviseSessionAfterDisconnect(t *testing.T) {
+	modem := newSessionModem()
+	modem.responses["AT+CREG?"] = "\r\n+CREG: 0,1\r\n\r\nOK\r\n"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	if err := d.Connect("internet", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.SuperviseSession(ctx, SessionConfig{Interval: 5 * time.Millisecond}) }()
+
+	// A deliberate disconnect stays down while the supervisor runs
+	time.Sleep(20 * time.Millisecond)
+	if err := d.Disconnect(); err != nil {
+		t.Fatalf("disconnect failed: %v", err)
+	}
+	connects := modem.commandCount("AT+CIICR")
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the supervisor to stop on cancel, got %v", err)
+	}
+	if n := modem.commandCount("AT+CIICR"); n != connects || d.IP != "" {
+		t.Errorf("expected the session to stay down, got %d dials and IP %q", n-connects, d.IP)
+	}
+	if err := d.RefreshIPStack(); !errors.Is(err, ErrNoIP) {
+		t.Errorf("expected ErrNoIP after Disconnect, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the cache of idempotent settings applied to the module.
+package sim800l
+
+import (
+	"bytes"
+	"log/slog"
+)
+
+// idempotentSettings are settings that sending again with the same value
+// doesn't change. Their applied values are cached so reconnect cycles
+// don't repeat them; AT+CIPMUX even fails once the PDP context is up.
+var idempotentSettings = [...][]byte{
+	[]byte("+CMEE="),    // Error reporting mode
+	[]byte("+CIPMUX="),  // Multi-connection mode
+	[]byte("+CIPHEAD="), // IP header on received data
+	[]byte("+CSCLK="),   // Slow clock mode
+	[]byte("+CIPSSL="),  // SSL of the connections started next
+}
+
+// idempotentSetting returns the index in idempotentSettings and the value
+// of cmd if it sets one of them to a single digit value
+func idempotentSetting(cmd []byte) (int, byte, bool) {
+	if len(cmd) >= len(at) && bytes.EqualFold(cmd[:len(at)], at) {
+		cmd = cmd[len(at):]
+	}
+	for i, prefix := range idempotentSettings {
+		if len(cmd) == len(prefix)+1 && bytes.EqualFold(cmd[:len(prefix)], prefix) {
+			if v := cmd[len(prefix)]; v >= '0' && v <= '9' {
+				return i, v, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// settingApplied reports whether cmd sets an idempotent setting to the
+// value it already has, so it needn't be sent
+func (d *Device) settingApplied(cmd []byte) bool {
+	i, v, ok := idempotentSetting(cmd)
+	if !ok || d.settings[i] != v {
+		return false
+	}
+	d.log(SubsystemCommand, slog.LevelDebug, "setting unchanged, not sent", "command", cmd)
+	return true
+}
+
+// recordSetting caches the value of an idempotent setting after cmd was
+// sent, or forgets it if the command failed
+func (d *Device) recordSetting(cmd []byte, err error) {
+	i, v, ok := idempotentSetting(cmd)
+	if !ok {
+		return
+	}
+	if err != nil {
+		v = 0
+	}
+	d.settings[i] = v
+}
+
+// forgetSettings drops the cached settings after the module was reset or
+// powered down. Uploaded certificates stay in its flash, the loaded one
+// doesn't.
+func (d *Device) forgetSettings() {
+	d.settings = [len(idempotentSettings)]byte{}
+	d.sslCert, d.sslCertPassword = "", ""
+}
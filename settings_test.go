@@ -0,0 +1,68 @@
+package sim800l
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_IdempotentSettings(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CPAS":         "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		"AT+CGATT?":       "\r\n+CGATT: 1\r\n\r\nOK\r\n",
+		"AT+CIPMUX=1":     "\r\nOK\r\n",
+		"AT+CSTT=\"iot\"": "\r\nOK\r\n",
+		"AT+CIICR":        "\r\nOK\r\n",
+		"AT+CIFSR":        "\r\n10.0.0.1\r\n",
+		"AT+CIPSHUT":      "\r\nSHUT OK\r\n",
+		"AT+CGATT=0":      "\r\nOK\r\n",
+		"AT+CSCLK=1":      "\r\nOK\r\n",
+		"AT+CSCLK=0":      "\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	count := func(cmd string) int {
+		n := 0
+		for _, c := range modem.commands {
+			if c == cmd {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Reconnect cycles set the multi-connection mode once
+	for range 3 {
+		if err := d.Connect("iot", "", ""); err != nil {
+			t.Fatalf("connect failed: %v", err)
+		}
+		if err := d.Disconnect(); err != nil {
+			t.Fatalf("disconnect failed: %v", err)
+		}
+	}
+	if n := count("AT+CIPMUX=1"); n != 1 {
+		t.Errorf("expected AT+CIPMUX=1 to be sent once, got %d", n)
+	}
+
+	// A changed value is sent, a repeated one isn't
+	d.lock()
+	for _, cmd := range []string{"+CSCLK=1", "+csclk=1", "+CSCLK=0", "AT+CSCLK=0", "+CSCLK=1"} {
+		if err := d.send([]byte(cmd)); err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+	}
+	d.unlock()
+	if n, m := count("AT+CSCLK=1"), count("AT+CSCLK=0"); n != 2 || m != 1 {
+		t.Errorf("expected AT+CSCLK=1 twice and AT+CSCLK=0 once, got %d and %d", n, m)
+	}
+
+	// A failed setting is sent again
+	delete(modem.responses, "AT+CSCLK=0")
+	d.lock()
+	for range 2 {
+		_ = d.send([]byte("+CSCLK=0"))
+	}
+	d.unlock()
+	if n := count("AT+CSCLK=0"); n != 3 {
+		t.Errorf("expected a failed AT+CSCLK=0 to be retried, sent %d times", n)
+	}
+}
@@ -4,24 +4,29 @@ package sim800l
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/netip"
 	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Constants for the SIM800L module
 const (
-	DefaultTimeout = time.Second * 10      // Default timeout for AT commands
-	ConnectTimeout = time.Second * 75      // Longer timeout for connection operations
-	ResetTime      = time.Second * 3       // Time to hold reset pin high
-	StartupTime    = time.Second * 15      // Time to wait after reset
-	MaxBufferSize  = 256                   // Maximum buffer size for UART operations
-	MaxCommandSize = MaxBufferSize - 2 - 2 // Maximum size of an AT command AT at the beginning, and CR+LF at the end
-	MaxConnections = 5                     // SIM800L supports up to 6 connections (0-5)
-	RecvBufSize    = 1024                  // Buffer size for receiving data
+	DefaultTimeout  = time.Second * 10      // Default timeout for AT commands
+	ConnectTimeout  = time.Second * 75      // Longer timeout for connection operations
+	ResetTime       = time.Second * 3       // Time to hold reset pin high
+	StartupTime     = time.Second * 15      // Longest wait for the module to boot after reset
+	MaxBufferSize   = 256                   // Maximum buffer size for UART operations
+	MaxCommandSize  = MaxBufferSize - 2 - 2 // Maximum size of an AT command AT at the beginning, and CR+LF at the end
+	RecvBufSize     = 1024                  // Buffer size for receiving data
+	MaxDatagrams    = 8                     // Maximum queued datagrams per UDP connection
+	EscapeGuardTime = time.Second           // Silence required before and after the +++ escape sequence
+	IdleTimeout     = time.Second * 2       // Default silence after which a partly received line is given up
 )
 
 // AT Command constants
@@ -39,6 +44,8 @@ var (
 	cmdGetSignal = []byte("+CSQ")      // Get signal strength
 	at           = []byte("AT")        // AT command prefix
 	crlf         = []byte("\r\n")      // CR+LF sequence for AT commands
+	cmdEscape    = []byte("+++")       // Escape from data mode to command mode
+	connectToken = []byte("CONNECT")   // Banner sent when entering data mode
 )
 
 // Common error types
@@ -51,11 +58,17 @@ var (
 	ErrMaxConn            = errors.New("maximum connections reached")
 	ErrUnimplemented      = errors.New("operation not implemented")
 	ErrNotReady           = errors.New("device not ready or not responding, after reset")
+	ErrDataMode           = errors.New("modem unexpectedly entered data mode")
+
+	// ErrIdleTimeout is returned when the modem goes silent in the middle
+	// of a line. It matches ErrTimeout with errors.Is.
+	ErrIdleTimeout = fmt.Errorf("%w: modem went silent mid-response", ErrTimeout)
 )
 
 // ATError represents an error returned by an AT command
 type ATError struct {
 	Command string // The AT command that caused the error
+	Err     error  // Typed cause, a *CMEError or *CMSError, or nil
 }
 
 // Error returns the error message, implementing the error interface
@@ -66,6 +79,48 @@ func (e *ATError) Error() string {
 	return fmt.Sprintf("%s command error", e.Command)
 }
 
+// Unwrap returns the typed cause of the error, if any
+func (e *ATError) Unwrap() error {
+	return e.Err
+}
+
+// CommandError describes a failed AT command. It wraps the cause, such as
+// ErrTimeout or an *ATError, so errors.Is and errors.As see through it.
+type CommandError struct {
+	Command  string        // Command sent, e.g. "AT+CIICR"
+	Elapsed  time.Duration // Time from sending the command to the failure
+	Response string        // Last line received, or the part of it that arrived
+	Err      error         // Cause of the failure
+}
+
+// Error returns the error message, implementing the error interface
+func (e *CommandError) Error() string {
+	if e.Response == "" {
+		return fmt.Sprintf("%s: %v (after %v)", e.Command, e.Err, e.Elapsed.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%s: %v (after %v, last response %q)", e.Command, e.Err, e.Elapsed.Round(time.Millisecond), e.Response)
+}
+
+// Unwrap returns the cause of the failure
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// commandError wraps err in a CommandError for the last command sent,
+// unless it is one already
+func (d *Device) commandError(err error) error {
+	var cmdErr *CommandError
+	if err == nil || errors.As(err, &cmdErr) {
+		return err
+	}
+	return &CommandError{
+		Command:  string(d.lastCmd[:d.lastCmdLen]),
+		Elapsed:  time.Since(d.lastCmdTime),
+		Response: string(d.rxBuffer[:d.end]),
+		Err:      err,
+	}
+}
+
 // TokenType represents the type of token parsed from AT command responses
 type TokenType int
 
@@ -83,25 +138,157 @@ type Device struct {
 	logger      *slog.Logger                // Logger for debug output
 	connections [MaxConnections]*Connection // Active connections
 	IP          string                      // Current IP address
-	buffer      [MaxBufferSize]byte         // Fixed buffer for UART operations
-	end         int                         // Current end index in the buffer
+	IPStatus    string                      // IP state last reported by AT+CIPSTATUS, e.g. "IP STATUS"
+	rxBuffer    [MaxBufferSize]byte         // Line or data read from the UART
+	end         int                         // Current end index in rxBuffer
+	txBuffer    [MaxBufferSize]byte         // Command written to the UART
 	powerState  bool                        // Current power state
 	IMEI        string                      // Module IMEI
+	Model       string                      // Module model, e.g. SIMCOM_SIM800L
+	Firmware    string                      // Firmware revision, e.g. 1418B05SIM800L24
 	Operator    string                      // Network operator
 
 	// Receive buffers for each connection (fixed size arrays)
-	recvBuffers    [MaxConnections][RecvBufSize]byte // Data buffers for received data
-	recvBufLengths [MaxConnections]int               // Length of data in each buffer
+	recvBuffers    [MaxConnections][]byte // Receive buffers of the connections, taken from recvPool
+	recvBufLengths [MaxConnections]int    // Length of data in each buffer
+
+	// Datagram boundaries for UDP connections, oldest first
+	recvMsgLengths [MaxConnections][MaxDatagrams]int // Length of each queued datagram
+	recvMsgCount   [MaxConnections]int               // Number of queued datagrams
+
+	// Senders reported with AT+CIPSRIP, invalid if unknown
+	recvMsgFrom [MaxConnections][MaxDatagrams]netip.AddrPort // Sender of each queued datagram
+	recvFrom    [MaxConnections]netip.AddrPort               // Sender of the latest data on TCP connections
+
+	lastCmd     [MaxBufferSize]byte          // Last command written to the UART
+	lastCmdLen  int                          // Length of the last command
+	lastCmdTime time.Time                    // When the last command was written
+	errHistory  [MaxErrorHistory]ErrorRecord // Ring of recent CME/CMS errors
+	errCount    int                          // Total number of errors recorded
+
+	smsHandler SMSHandler // Called for accepted inbound SMS
+	smsFilter  SMSFilter  // Sender filter for inbound SMS
+
+	smsPending      [MaxPendingSMS]int // Indexes announced by +CMTI, oldest first
+	smsPendingCount int                // Number of pending indexes
+
+	eventHandler EventHandler               // Called for asynchronous events
+	urcHandlers  [MaxURCHandlers]urcHandler // Registered URC handlers
+
+	mu         mutex        // Serializes commands and access to the shared buffers
+	urgent     atomic.Int32 // Number of alerts waiting for or holding the device
+	urgentHeld bool         // The lock is held by an alert
+
+	logLevels [numSubsystems]slog.LevelVar // Minimum log level per subsystem
+	quirks    CarrierQuirks                // Carrier specific connect adjustments
+	alert     AlertConfig                  // Alert sent by Alert
+
+	gprs         GPRSConfig // Settings of the last Connect, used to refresh the PDP context
+	sessionUp    bool       // The application wants the data session up, from Connect until Disconnect
+	probeHost    string     // Host pinged by CheckIPStack, DefaultProbeHost if empty
+	dnsPrimary   string     // DNS server set with SetDNS, the carrier's if empty
+	dnsSecondary string     // Secondary DNS server set with SetDNS
+
+	idleTimeout    time.Duration // Silence that ends a partly received line, IdleTimeout if zero
+	connectTimeout time.Duration // Wait of Dial for the remote host, ConnectTimeout if zero
+	truncated      bool          // The last line read didn't fit the buffer
+	quickSend      bool          // Enable quick send mode on Connect
+	senderAddress  bool          // Enable sender addresses of received data on Connect
+	blockingRead   bool          // Read waits for data instead of returning ErrWouldBlock
+	readTimeout    time.Duration // Wait of a blocking Read without a deadline, none if zero
+	polling        bool          // Lines being read are unsolicited
+
+	traceFn    TraceFunc // Receives a record of every command and line
+	traceSeq   uint64    // Sequence number of the last trace record
+	truncCount int       // Number of truncated lines since New
+
+	tempHigh      float64 // Temperature that emits EventOverheating, none if zero
+	tempLow       float64 // Temperature that ends overheating
+	overheated    bool    // The last reading reached tempHigh
+	noTemperature bool    // The firmware rejected AT+CMTE
+
+	settings [len(idempotentSettings)]byte // Applied values of idempotent settings, 0 if unknown
+
+	certs           [MaxCerts]certFile // Certificates uploaded by UploadCert
+	sslCert         string             // Certificate loaded by SelectCert, empty if unknown
+	sslCertPassword string             // Password it was loaded with
+
+	baudRate    uint32 // UART baud rate to recover the module to, none if zero
+	baudWatch   bool   // Count the bytes read while syncing with the module
+	baudToken   bool   // A recognizable line was read while syncing
+	baudSeen    int    // Bytes read while syncing
+	baudGarbage int    // Bytes read while syncing that a module never sends
+
+	listener    *Listener             // Open TCP server listener, if any
+	acceptQueue [MaxConnections]uint8 // Inbound connections not yet accepted
+	acceptCount int                   // Number of queued inbound connections
+
+	bt  *BTConn     // Bluetooth SPP connection, if open
+	ppp *PPPSession // PPP session, if dialed
+
+	netdev *Netdev // Adapter told when the session drops or is restored, if any
+
+	random RandomSource // Jitter of retry backoff, math/rand/v2 if nil
+	retry  RetryPolicy  // Retries of commands failing with a transient error
+
+	lifetime         context.Context    // Cancelled by Close to stop the background loops
+	endLifetime      context.CancelFunc // Cancels lifetime
+	loops            sync.WaitGroup     // Background loops running
+	loopsMu          sync.Mutex         // Orders registering loops with Close cancelling lifetime
+	powerDownOnClose bool               // Close powers the module down
+
+	pin         string // SIM PIN entered by Init, if set
+	pinRejected bool   // The SIM rejected pin, don't try it again
+
+	initProgress InitProgressFunc // Called after each stage of Init, if set
+
+	resets     ResetCounters // Resets counted so far
+	resetStore ResetStore    // Keeps the reset counters, if set
+	startedAt  time.Time     // When the driver last reset the module, zero if unknown
+	bootTime   time.Duration // Time the module took to boot after the last reset
+
+	strict bool // Fail on protocol anomalies instead of recovering
+
+	queue *inputQueue // Input buffered by Run, nil if it never ran
+
+	recvPool     [MaxConnections][]byte // Receive buffers, each allocated when first claimed
+	recvPoolUsed uint8                  // Bit set for every receive buffer in use
+	recvSegment  [MaxConnections]uint8  // Receive buffer of each connection, if it has one
+	recvBufCount int                    // Receive buffers in the pool, MaxConnections if zero
+
+	outbox      []OutboxMessage // Messages waiting for delivery
+	outboxID    uint32          // ID of the latest queued message
+	outboxStore OutboxStore     // Keeps the outbox, if set
+
+	singleConn bool // Use single-connection mode, AT+CIPMUX=0
+
+	streamID   uint8 // Connection of the received data left on the UART
+	streamLeft int   // Bytes of received data left on the UART
+
+	sendQueue    [MaxConnections]uint8 // Connections with a write in progress, in the order they send
+	sendQueueLen int                   // Number of connections in sendQueue
+	sendFresh    int                   // Connections at the front of sendQueue that haven't sent yet
+
+	urcQueue [MaxQueuedURCs]queuedURC // Known URCs that arrived without a handler
+	urcHead  int                      // Index of the oldest queued URC
+	urcCount int                      // Number of queued URCs
 }
 
 // New creates a new SIM800L device instance.
-// For now we accept that resetPin is always configured as output.
+// For now we accept that resetPin is always configured as output. On
+// boards with RST hard-wired it may be nil; resets then use AT+CFUN=1,1.
 func New(uart UART, resetPin Pin, logger *slog.Logger) *Device {
 	d := &Device{
 		uart:     uart,
 		resetPin: resetPin,
 		logger:   logger,
 	}
+	d.lifetime, d.endLifetime = context.WithCancel(context.Background())
+
+	// Leave filtering to the logger's handler until configured otherwise
+	for i := range d.logLevels {
+		d.logLevels[i].Set(slog.LevelDebug)
+	}
 
 	// Initialize connection state
 	for i := 0; i < MaxConnections; i++ {
@@ -113,65 +300,69 @@ func New(uart UART, resetPin Pin, logger *slog.Logger) *Device {
 
 var (
 	commands = [][]byte{
-		[]byte(at),           // Basic AT check
-		[]byte(cmdEchoOff),   // Disable echo
-		[]byte(cmdErrorMode), // Enable verbose error messages
-		[]byte(cmdBaudAuto),  // Auto-baud rate
-		[]byte(cmdFuncFull),  // Full functionality
-		[]byte(cmdSimCheck),  // Check if SIM is ready
-		[]byte(cmdOperator),  // Get operator info
-		[]byte(cmdConnMode),  // Enable multi-connection mode
+		[]byte(cmdEchoOff),     // Disable echo
+		[]byte(cmdErrorMode),   // Enable verbose error messages
+		[]byte(cmdBaudAuto),    // Auto-baud rate
+		[]byte(cmdFuncFull),    // Full functionality
+		[]byte(cmdConnMode),    // Enable multi-connection mode
+		[]byte(cmdSmsTextMode), // Use text mode for SMS
 	}
 )
 
-// Init initializes the SIM800L device
+// Init initializes the SIM800L device. It fails if the module doesn't
+// respond or can't be configured, if the SIM is missing or locked, with
+// an error matching ErrSIMNotReady, or if the operator can't be queried.
+// Use InitContext to bound the time it takes and to carry on without a
+// usable SIM or network.
 func (d *Device) Init() error {
-	// Perform hardware reset
-	err := d.HardReset()
-	if err != nil {
-		return err
-	}
-
-	// Initial setup sequence optimized for SIM800L
+	d.lock()
+	defer d.unlock()
+	_, err := d.initialize(context.Background(), true, true)
+	return err
+}
 
-	// Execute initialization sequence
-	for _, cmd := range commands {
-		err = d.send([]byte(cmd))
-		if err != nil {
-			d.logger.Error("init failed on command", "command", cmd, "error", err)
-			return err // For TinyGo, we'll just return the original error
-		}
+// Signal returns the signal quality reported by AT+CSQ, or 0 if it can't be read
+func (d *Device) Signal() int {
+	d.lock()
+	defer d.unlock()
 
-		// Small delay between commands for stability
-		time.Sleep(100 * time.Millisecond)
+	if err := d.sendWithOptions(cmdGetSignal, prefixCheck(cmdGetSignal), DefaultTimeout); err != nil {
+		return 0
 	}
-
-	// Get IMEI
-	err = d.send([]byte(cmdGetImei))
-	if err == nil {
-		d.IMEI = strings.TrimSpace(string(d.buffer[:d.end]))
+	var fields [1]int
+	if val, ok := d.parseValue(cmdGetSignal); ok && parseInts(val, fields[:]) == 1 {
+		return fields[0]
 	}
+	return 0
+}
 
-	return nil
+// HardReset performs a hardware reset of the SIM800L device. Without a
+// reset pin it restarts the module with AT+CFUN=1,1 instead, which needs
+// a module that still answers commands.
+func (d *Device) HardReset() error {
+	d.lock()
+	defer d.unlock()
+	return d.hardReset(ResetRequested)
 }
 
-func (d *Device) Signal() int {
-	err := d.send([]byte(cmdGetSignal))
-	if err != nil {
-		return 0
+// hardReset performs the reset sequence with the lock held, or restarts
+// the module with AT+CFUN=1,1 if there is no reset pin
+func (d *Device) hardReset(reason ResetReason) error {
+	if d.resetPin == nil {
+		return d.softReset(reason)
 	}
-	return d.parseSignal(d.buffer[:d.end])
-}
 
-// HardReset performs a hardware reset of the SIM800L device
-func (d *Device) HardReset() error {
 	// Reset sequence
+	d.log(SubsystemPower, slog.LevelDebug, "hardware reset", "reason", reason)
+	d.forgetSettings()
+	d.streamLeft = 0 // Data the module was sending is gone
 	d.resetPin.High()
 	time.Sleep(ResetTime)
 	d.resetPin.Low()
+	d.recordReset(true, reason)
 
 	// Wait for device to boot and stabilize
-	time.Sleep(StartupTime)
+	_ = d.waitReady(context.Background())
 
 	// Check if device is responsive
 	err := d.send(at)
@@ -179,6 +370,7 @@ func (d *Device) HardReset() error {
 		return ErrNotReady
 	}
 
+	d.powerState = true
 	return nil
 }
 
@@ -192,9 +384,9 @@ func defaultResponseCheck(buffer []byte) error {
 		return nil // OK response
 	}
 	if bytes.Contains(buffer, errorToken) {
-		return &ATError{Command: string(buffer)} // Error response
+		return &ATError{Command: string(buffer), Err: moduleError(buffer)} // Error response
 	}
-	return fmt.Errorf("unexpected response: %s", string(buffer)) // Unexpected response
+	return fmt.Errorf("%w: %s", ErrUnexpectedResponse, buffer) // Unexpected response
 }
 
 func (d *Device) send(cmd []byte) error {
@@ -204,73 +396,193 @@ func (d *Device) send(cmd []byte) error {
 
 // send is a simplified version of sendWithOptions that always waits for OK pattern
 func (d *Device) sendWithOptions(cmd []byte, checkFunc ResponseCheckFunc, timeout time.Duration) error {
+	return d.sendRetrying(context.Background(), cmd, checkFunc, timeout, true)
+}
 
-	if err := d.sendRaw(cmd); err != nil {
-		return err
+// sendRetrying is sendWithOptions bounded by ctx: retries and their
+// backoff stop at its deadline or when it is done. Without retry a
+// transient error is returned at once.
+func (d *Device) sendRetrying(ctx context.Context, cmd []byte, checkFunc ResponseCheckFunc, timeout time.Duration, retry bool) error {
+	if d.settingApplied(cmd) {
+		return nil
 	}
 
-	// Read and parse the response
-	if err := d.readResponse(cmd, checkFunc, timeout); err != nil {
-		d.logger.Error("command error", "command", cmd, "ERROR", err)
+	err := d.exchange(cmd, checkFunc, timeout)
+	for attempt := 1; err != nil && retry; attempt++ {
+		var again bool
+		if again, err = d.retryAfter(ctx, attempt, err); !again {
+			break
+		}
+		// cmd may point into the line last read, send the copy kept of it,
+		// unless a secret was redacted from the copy
+		var buf [MaxCommandSize]byte
+		resend := append(buf[:0], bytes.TrimPrefix(d.lastCmd[:d.lastCmdLen], at)...)
+		if _, _, secret := secretField(cmd); secret {
+			resend = cmd
+		}
+		err = d.exchange(resend, checkFunc, contextTimeout(ctx, timeout))
+	}
+	d.recordSetting(cmd, err)
+	if err != nil {
+		d.log(SubsystemCommand, slog.LevelError, "command error", "command", appendRedacted(nil, cmd), "ERROR", err)
 		return err
 	}
 
 	return nil
 }
 
+// exchange sends cmd and reads its response
+func (d *Device) exchange(cmd []byte, checkFunc ResponseCheckFunc, timeout time.Duration) error {
+	if err := d.sendRaw(cmd); err != nil {
+		return err
+	}
+	return d.readResponse(cmd, checkFunc, timeout)
+}
+
 func (d *Device) sendRaw(cmd []byte) error {
 	// Clear UART buffer before sending.
 	if len(cmd) > MaxCommandSize {
-		return fmt.Errorf("command too long: %d bytes, max %d bytes", len(cmd), MaxCommandSize)
+		return fmt.Errorf("%w: command too long: %d bytes, max %d bytes", ErrBadParameter, len(cmd), MaxCommandSize)
+	}
+	// The UART carries PPP frames, the module wouldn't take commands
+	if d.inPPP() {
+		return ErrPPPMode
 	}
 
-	d.clearBuffer()
 	cmd = toUpperNoCopy(cmd)
 
-	d.end = 0
+	// Build the command in its own buffer before clearing the UART, which
+	// reads into rxBuffer, so cmd may point into the line last read.
+	n := 0
 	// Add AT prefix if needed.
 	if !bytes.HasPrefix(cmd, at) {
-		// Copy AT prefix to the beginning of buffer.
-		d.end += copy(d.buffer[:], at)
+		n += copy(d.txBuffer[:], at)
 	}
 
-	d.end += copy(d.buffer[d.end:], cmd)
-	d.end += copy(d.buffer[d.end:], crlf)
+	n += copy(d.txBuffer[n:], cmd)
+	n += copy(d.txBuffer[n:], crlf)
+
+	// Clearing the buffer would drop received data still on the UART
+	if err := d.drainStream(time.Now().Add(DefaultTimeout)); err != nil {
+		return err
+	}
+	d.clearBuffer()
+	d.end = 0
+
+	// Remember the command for diagnostics, without the trailing CR+LF
+	// and secrets like the SIM PIN.
+	d.lastCmdLen = copy(d.lastCmd[:], appendRedacted(d.lastCmd[:0], d.txBuffer[:n-len(crlf)]))
+	d.lastCmdTime = time.Now()
+	d.trace(TraceCommand, d.lastCmd[:d.lastCmdLen])
 
 	// Write the command to the UART.
-	if _, err := d.uart.Write(d.buffer[:d.end]); err != nil {
-		return &ATError{Command: string(cmd)}
+	if _, err := d.uart.Write(d.txBuffer[:n]); err != nil {
+		return d.commandError(&ATError{Command: string(appendRedacted(nil, cmd))})
 	}
 
 	return nil
 }
 
 func toUpperNoCopy(b []byte) []byte {
-	// Convert bytes to uppercase without copying the slice
+	// Convert bytes to uppercase without copying the slice.
+	// Quoted parameters such as APN credentials are case sensitive.
+	quoted := false
 	for i := range b {
-		if b[i] >= 'a' && b[i] <= 'z' {
+		if b[i] == '"' {
+			quoted = !quoted
+		}
+		if !quoted && b[i] >= 'a' && b[i] <= 'z' {
 			b[i] -= 32 // Convert to uppercase
 		}
 	}
 	return b
 }
 
-// readResponse reads and parses the device response
+// readResponse reads and parses the device response. A failure returns a
+// CommandError for the last command sent.
 func (d *Device) readResponse(cmd []byte, checkFunc ResponseCheckFunc, timeout time.Duration) error {
+	return d.commandError(d.awaitResponse(cmd, checkFunc, timeout))
+}
+
+// awaitResponse reads the response line of a command and checks it
+func (d *Device) awaitResponse(cmd []byte, checkFunc ResponseCheckFunc, timeout time.Duration) error {
 	// Reset the raw length counter and clear the buffer
 	t, err := d.readLine(timeout)
+	// Skip the echo of the command while echo is still enabled, dispatch
+	// received data and unsolicited messages that have a handler, and
+	// queue known unsolicited messages that don't
+	for err == nil && t == TokenLine {
+		if !bytes.HasPrefix(d.rxBuffer[:d.end], at) {
+			handled, err := d.dispatchInput(d.rxBuffer[:d.end])
+			if err != nil {
+				return err
+			}
+			if !handled && !d.queueURC(cmd, d.rxBuffer[:d.end]) {
+				break
+			}
+		}
+		t, err = d.readLine(timeout)
+	}
 	if err != nil {
 		return err
 	}
 	if t != TokenLine {
-		return &ATError{Command: string(cmd)}
+		return &ATError{Command: string(appendRedacted(nil, cmd))}
+	}
+	d.recordModuleError(d.rxBuffer[:d.end])
+	if d.truncated {
+		d.log(SubsystemCommand, slog.LevelWarn, "response line truncated", "command", cmd, "kept", d.end)
+		if err := d.anomaly("response line truncated", d.rxBuffer[:d.end]); err != nil {
+			return err
+		}
+	}
+	if isConnectBanner(d.rxBuffer[:d.end]) {
+		// Whatever follows is data, not responses, so don't wait for it
+		d.log(SubsystemCommand, slog.LevelWarn, "modem entered data mode, escaping")
+		err := d.escapeDataMode()
+		d.emit(Event{Type: EventDataModeEscaped, Err: err})
+		return ErrDataMode
 	}
 	if checkFunc != nil {
-		return checkFunc(d.buffer[:d.end])
+		return checkFunc(d.rxBuffer[:d.end])
 	}
 	return nil // No custom check function provided, return nil
 }
 
+// isConnectBanner reports whether line is the bare "CONNECT" (optionally
+// with a baud rate) the modem prints when it switches to data mode
+func isConnectBanner(line []byte) bool {
+	if !bytes.HasPrefix(line, connectToken) {
+		return false
+	}
+	rest := line[len(connectToken):]
+	return len(rest) == 0 || (len(rest) > 1 && rest[0] == ' ' && rest[1] >= '0' && rest[1] <= '9')
+}
+
+// escapeDataMode returns the modem to command mode with the +++ escape
+// sequence, which must be surrounded by silence on the UART
+func (d *Device) escapeDataMode() error {
+	time.Sleep(EscapeGuardTime)
+	d.clearBuffer()
+	if _, err := d.uart.Write(cmdEscape); err != nil {
+		return err
+	}
+	time.Sleep(EscapeGuardTime)
+
+	// Data may still be in flight before the OK
+	deadline := time.Now().Add(DefaultTimeout)
+	for time.Now().Before(deadline) {
+		t, err := d.readLine(time.Until(deadline))
+		if err != nil {
+			return err
+		}
+		if t == TokenLine && bytes.Equal(d.rxBuffer[:d.end], okToken) {
+			return nil
+		}
+	}
+	return ErrTimeout
+}
+
 // parseErrorMessage extracts the error message from response containing CME/CMS errors
 func parseErrorMessage(data []byte) []byte {
 
@@ -285,68 +597,67 @@ func parseErrorMessage(data []byte) []byte {
 
 // clearBuffer clears any data in the UART buffer
 func (d *Device) clearBuffer() {
+	// Let unsolicited messages and received data reach their handlers
+	if err := d.poll(); err != nil {
+		d.log(SubsystemURC, slog.LevelDebug, "failed to process pending data", "error", err)
+	}
+
 	// Read all available data
-	for d.uart.Buffered() > 0 {
-		_, _ = d.uart.Read(d.buffer[:min(len(d.buffer), d.uart.Buffered())])
+	in := d.input()
+	for in.Buffered() > 0 {
+		_, _ = in.Read(d.rxBuffer[:min(len(d.rxBuffer), in.Buffered())])
 	}
 }
 
-// parseOperator extracts network operator information
-func (d *Device) parseOperator(line []byte) {
-	// Format: +COPS: 0,0,"Operator"
-	if bytes.Contains(line, []byte("\"")) {
-		start := bytes.Index(line, []byte("\""))
-		end := bytes.LastIndex(line, []byte("\""))
-		if start >= 0 && end > start {
-			// TODO to bytes ???
-			d.Operator = string(line[start+1 : end])
-		}
+// parseValue returns the value of the response line in the buffer if the
+// line is the one named k, "21,0" of "+CSQ: 21,0" for +CSQ
+func (d *Device) parseValue(k []byte) ([]byte, bool) {
+	v, ok := bytes.CutPrefix(bytes.TrimSpace(d.rxBuffer[:d.end]), k)
+	if !ok || len(v) == 0 || v[0] != ':' {
+		return nil, false // Another line or a longer name with the same prefix
 	}
+	v = bytes.TrimSpace(v[1:])
+	return v, len(v) > 0
 }
 
-// parseSignal extracts signal strength information
-func (d *Device) parseSignal(line []byte) int {
-	// Format: +CSQ: 21,0
-	if bytes.Contains(line, []byte("+CSQ:")) {
-		parts := bytes.Split(line, []byte(":"))
-		if len(parts) == 2 {
-			signalParts := bytes.Split(bytes.TrimSpace(parts[1]), []byte(","))
-			if len(signalParts) > 0 {
-				// Parse signal strength (0-31, 99=unknown)
-				// TODO to bytes
-				signal, err := strconv.Atoi(string(bytes.TrimSpace(signalParts[0])))
-				if err == nil {
-					return signal
-				}
-			}
+// parseInts parses the comma separated integers in v into dst and returns
+// how many were parsed. Parsing stops at the first field that is not a number.
+func parseInts(v []byte, dst []int) int {
+	n := 0
+	for n < len(dst) && len(v) > 0 {
+		field := v
+		if i := bytes.IndexByte(v, ','); i >= 0 {
+			field, v = v[:i], v[i+1:]
+		} else {
+			v = nil
+		}
+		val, err := strconv.Atoi(string(bytes.TrimSpace(field)))
+		if err != nil {
+			break
 		}
+		dst[n] = val
+		n++
 	}
-	return 0
+	return n
 }
 
-func (d *Device) parseValue(k []byte) ([]byte, bool) {
-	// Find the key in the buffer
-	start := bytes.Index(d.buffer[:d.end], k)
-	if start < 0 {
-		return nil, false
-	}
+func (d *Device) readLine(t time.Duration) (TokenType, error) {
+	deadline := time.Now().Add(t)
+	d.end = 0 // Reset the end index of the buffer
+	d.truncated = false
 
-	start += len(k) + 1 // Move to the start of the value (after ":")
-	if start >= d.end {
-		return nil, false // No value found after the key
+	// The deadline bounds the whole wait, while the idle timeout detects
+	// a modem that died after starting to answer
+	idle := d.idleTimeout
+	if idle <= 0 {
+		idle = IdleTimeout
 	}
+	var lastByte time.Time
 
-	// Extract the value
-	v := bytes.TrimSpace(d.buffer[start:d.end])
-	if len(v) == 0 {
-		return nil, false
+	// Received data still on the UART comes before the next line
+	if err := d.drainStream(deadline); err != nil {
+		return TokenInvalid, err
 	}
-	return v, true
-}
-
-func (d *Device) readLine(t time.Duration) (TokenType, error) {
-	deadline := time.Now().Add(t)
-	d.end = 0 // Reset the end index of the buffer
 
 	var b [1]byte // single-byte read buffer
 	const (
@@ -356,12 +667,17 @@ func (d *Device) readLine(t time.Duration) (TokenType, error) {
 	state := stateStart
 
 	for time.Now().Before(deadline) {
-		if d.uart.Buffered() == 0 {
+		in := d.input()
+		if in.Buffered() == 0 {
+			inLine := d.end > 0 || state == stateEndLine
+			if inLine && time.Since(lastByte) > idle {
+				return TokenInvalid, ErrIdleTimeout
+			}
 			time.Sleep(1 * time.Millisecond)
 			continue
 		}
 
-		n, err := d.uart.Read(b[:]) // directly read one byte
+		n, err := in.Read(b[:]) // directly read one byte
 		if err != nil {
 			break // or handle errors like io.EOF
 		}
@@ -370,6 +686,10 @@ func (d *Device) readLine(t time.Duration) (TokenType, error) {
 			time.Sleep(10 * time.Millisecond) // avoid busy waiting
 			continue                          // no data read, skip
 		}
+		lastByte = time.Now()
+		if d.baudWatch {
+			d.countBaudByte(b[0])
+		}
 
 		switch state {
 		case stateStart:
@@ -377,20 +697,40 @@ func (d *Device) readLine(t time.Duration) (TokenType, error) {
 				state = stateEndLine
 				continue
 			}
-			if b[0] == '>' {
+			if b[0] == '>' && d.end == 0 && !d.truncated {
+				d.trace(TracePrompt, d.rxBuffer[:0])
 				return TokenPrompt, nil // special prompt character
 			}
+			if b[0] == ' ' && d.end == 0 {
+				continue // Skip the space following the "> " prompt
+			}
 			if err := d.append(b[0]); err != nil {
-				return TokenInvalid, err
+				// Keep what fits and drop the rest of the line, so
+				// the next line is still read from its start
+				d.truncated = true
+			}
+			if b[0] == ':' && d.singleConn && bytes.HasPrefix(d.rxBuffer[:d.end], ipdPrefix) {
+				// The data follows +IPD,<length>: on the same line
+				d.traceLine(d.rxBuffer[:d.end])
+				return TokenLine, nil
 			}
 		case stateEndLine:
 			if b[0] == '\n' {
 				// Escape empty lines
-				if d.end <= 2 {
+				if d.end == 0 {
 					state = stateStart // reset state for next line
 					continue
 				}
+				if d.truncated {
+					d.truncCount++
+				}
+				d.traceLine(d.rxBuffer[:d.end])
+				if d.baudWatch {
+					d.countBaudLine(d.rxBuffer[:d.end])
+				}
 				return TokenLine, nil
+			} else if b[0] == '\r' {
+				continue // Tolerate "\r\r\n" after a command echo
 			} else {
 				d.end = 0 // Reset buffer if we receive a character after \r
 				// If we receive a character after \r, treat it as normal data
@@ -411,11 +751,11 @@ func (d *Device) readLine(t time.Duration) (TokenType, error) {
 }
 
 func (d *Device) append(b byte) error {
-	if d.end >= len(d.buffer) {
-		return errors.New("buffer overflow") // or handle as needed
+	if d.end >= len(d.rxBuffer) {
+		return ErrBufferFull
 	}
 
-	d.buffer[d.end] = b
+	d.rxBuffer[d.end] = b
 	d.end++
 	return nil
 }
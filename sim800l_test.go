@@ -7,7 +7,9 @@ package sim800l
 
 import (
 	"bytes"
+	"errors"
 	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
@@ -82,7 +84,7 @@ func Test_readResponse(t *testing.T) {
 			}
 
 			if tc.shouldLogBuffer {
-				t.Logf("Buffer content: %s, value: %s", string(d.buffer[:d.end]), value)
+				t.Logf("Buffer content: %s, value: %s", string(d.rxBuffer[:d.end]), value)
 			}
 		})
 	}
@@ -109,3 +111,155 @@ func Test_sendRaw(t *testing.T) {
 		t.Errorf("Expected sent data '%s', got '%s'", string(data), string(sentData))
 	}
 }
+
+func Test_sendRawFromReceiveBuffer(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CSQ": "\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	// A command taken from the line last read survives the pending output
+	// read while clearing the UART
+	d.end = copy(d.rxBuffer[:], "AT+CSQ")
+	modem.inject("\r\nRING\r\n")
+	if err := d.send(d.rxBuffer[:d.end]); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	if last := modem.commands[len(modem.commands)-1]; last != "AT+CSQ" {
+		t.Errorf("expected AT+CSQ to be sent, got %q", last)
+	}
+}
+
+func Test_sendEscapesDataMode(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CSQ": "\r\nCONNECT\r\n\x00\x7f\x13binary",
+		"+++":    "\r\nOK\r\n",
+	})
+	// The failed command is logged as an error, which MockHandler reports
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	var events []Event
+	d.SetEventHandler(func(e Event) {
+		events = append(events, e)
+	})
+
+	err := d.sendWithOptions([]byte("+CSQ"), nil, DefaultTimeout)
+	if !errors.Is(err, ErrDataMode) {
+		t.Fatalf("expected ErrDataMode, got %v", err)
+	}
+	if len(events) != 1 || events[0].Type != EventDataModeEscaped || events[0].Err != nil {
+		t.Errorf("expected one successful escape event, got %+v", events)
+	}
+	if !bytes.HasSuffix(modem.tx.Bytes(), cmdEscape) {
+		t.Errorf("expected +++ escape to be sent, got %q", modem.tx.Bytes())
+	}
+}
+
+func Test_commandError(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CIICR": "\r\n+CME ERROR: 148\r\n",
+		"AT+CSQ":   "",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	err := d.sendWithOptions([]byte("+CIICR"), defaultResponseCheck, time.Second)
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected a CommandError, got %v", err)
+	}
+	if cmdErr.Command != "AT+CIICR" {
+		t.Errorf("expected command AT+CIICR, got %q", cmdErr.Command)
+	}
+	if cmdErr.Response != "+CME ERROR: 148" {
+		t.Errorf("expected the last response, got %q", cmdErr.Response)
+	}
+	if cmdErr.Elapsed <= 0 || cmdErr.Elapsed > time.Second {
+		t.Errorf("expected the elapsed time, got %v", cmdErr.Elapsed)
+	}
+	var atErr *ATError
+	if !errors.As(err, &atErr) {
+		t.Errorf("expected the ATError to be wrapped, got %v", err)
+	}
+
+	// A command the module doesn't answer
+	err = d.sendWithOptions([]byte("+CSQ"), defaultResponseCheck, 50*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) || !errors.As(err, &cmdErr) || cmdErr.Command != "AT+CSQ" {
+		t.Errorf("expected a timeout for AT+CSQ, got %v", err)
+	}
+}
+
+func Test_isConnectBanner(t *testing.T) {
+	tests := map[string]bool{
+		"CONNECT":       true,
+		"CONNECT 9600":  true,
+		"CONNECT OK":    false,
+		"0, CONNECT OK": false,
+		"CONNECT FAIL":  false,
+	}
+	for line, want := range tests {
+		if got := isConnectBanner([]byte(line)); got != want {
+			t.Errorf("isConnectBanner(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
+
+func Test_readLineIdleTimeout(t *testing.T) {
+	modem := newMockModem(nil)
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.Configure(Config{IdleTimeout: 50 * time.Millisecond})
+
+	// Silence before a line starts only ends at the deadline
+	start := time.Now()
+	if _, err := d.readLine(200 * time.Millisecond); err != ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("gave up after %v, before the deadline", elapsed)
+	}
+
+	// The modem dies halfway through a line
+	modem.inject("\r\n+CSQ: 2")
+	start = time.Now()
+	_, err := d.readLine(time.Minute)
+	if err != ErrIdleTimeout {
+		t.Fatalf("expected ErrIdleTimeout, got %v", err)
+	}
+	if !errors.Is(err, ErrTimeout) {
+		t.Error("expected ErrIdleTimeout to match ErrTimeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %v, long after the idle timeout", elapsed)
+	}
+}
+
+func Test_readLineTruncatesOversizedLines(t *testing.T) {
+	modem := newMockModem(nil)
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+
+	// E.g. an AT+COPS=? operator scan, with a '>' that isn't a prompt
+	long := strings.Repeat("(2,\"Operator\",\"Op\",\"28401\")>", 20)
+	modem.inject("\r\n" + long + "\r\n\r\nOK\r\n")
+
+	tt, err := d.readLine(time.Second)
+	if err != nil || tt != TokenLine {
+		t.Fatalf("expected a line, got %v, %v", tt, err)
+	}
+	if !d.truncated || d.end != MaxBufferSize {
+		t.Errorf("expected a truncated line of %d bytes, got %d bytes, truncated %v", MaxBufferSize, d.end, d.truncated)
+	}
+	if string(d.rxBuffer[:d.end]) != long[:MaxBufferSize] {
+		t.Errorf("expected the start of the line, got %q", d.rxBuffer[:d.end])
+	}
+
+	// The session stays in sync
+	tt, err = d.readLine(time.Second)
+	if err != nil || tt != TokenLine || string(d.rxBuffer[:d.end]) != "OK" {
+		t.Fatalf("expected OK, got %v, %v, %q", tt, err, d.rxBuffer[:d.end])
+	}
+	if d.truncated {
+		t.Error("expected OK not to be marked truncated")
+	}
+	if diag := d.Diagnostics(); diag.TruncatedLines != 1 {
+		t.Errorf("expected 1 truncated line, got %d", diag.TruncatedLines)
+	}
+}
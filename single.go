@@ -0,0 +1,104 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the single-connection mode (AT+CIPMUX=0).
+package sim800l
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+var (
+	cmdSingleConn = []byte("+CIPMUX=0")  // Enable single-connection mode
+	cmdIPHeader   = []byte("+CIPHEAD=1") // Announce received data with +IPD in single-connection mode
+	ipdPrefix     = []byte("+IPD")       // Received data notification prefix in single-connection mode
+	singleClosed  = []byte("CLOSED")     // Sent when the remote host closes the connection in single-connection mode
+	singleConnOK  = []byte("CONNECT OK") // IP state while the connection is open in single-connection mode
+)
+
+// connMode returns the command that selects the connection mode
+func (d *Device) connMode() []byte {
+	if d.singleConn {
+		return cmdSingleConn
+	}
+	return cmdMultiConn
+}
+
+// connSlots returns the number of connection slots Dial can use, no more
+// than the module has
+func (d *Device) connSlots() int {
+	if d.singleConn {
+		return 1
+	}
+	return min(MaxConnections, d.capabilities().MaxConnections)
+}
+
+// appendConnCommand appends cmd addressed to connection id to dst. In
+// single-connection mode the commands take no connection ID.
+func (d *Device) appendConnCommand(dst, cmd []byte, id uint8) []byte {
+	dst = append(dst, cmd...)
+	if d.singleConn {
+		return dst
+	}
+	dst = append(dst, '=')
+	return strconv.AppendInt(dst, int64(id), 10)
+}
+
+// isDataNotice reports whether line announces received data
+func (d *Device) isDataNotice(line []byte) bool {
+	return bytes.HasPrefix(line, receivePrefix) || (d.singleConn && bytes.HasPrefix(line, ipdPrefix))
+}
+
+// singleClosedNotice handles the "CLOSED" line sent in single-connection
+// mode when the remote host closes the connection
+func (d *Device) singleClosedNotice(line []byte) bool {
+	if !d.singleConn || !bytes.Equal(line, singleClosed) {
+		return false
+	}
+	d.log(SubsystemURC, slog.LevelDebug, "connection closed by remote host", "id", 0)
+	d.markClosed(0)
+	return true
+}
+
+// receiveIPD parses a +IPD,<length>: notification line and reads the data
+// that follows it into the receive buffer of connection 0
+func (d *Device) receiveIPD(line []byte, deadline time.Time) error {
+	v, ok := bytes.CutPrefix(line, ipdPrefix)
+	if !ok || len(v) < 2 || v[0] != ',' || v[len(v)-1] != ':' {
+		return fmt.Errorf("%w: invalid +IPD format: %s", ErrUnexpectedResponse, line)
+	}
+	dataLength, err := strconv.Atoi(string(v[1 : len(v)-1]))
+	if err != nil || dataLength <= 0 {
+		return fmt.Errorf("%w: invalid data length in +IPD: %s", ErrUnexpectedResponse, line)
+	}
+	return d.receivePayload(0, dataLength, netip.AddrPort{}, deadline)
+}
+
+// singleConnectionStatus turns the IP state reported by AT+CIPSTATUS in
+// single-connection mode, like "CONNECT OK" or "TCP CLOSED", into the
+// status of connection 0
+func singleConnectionStatus(state []byte, conn *Connection) ConnectionStatus {
+	status := ConnectionStatus{ID: 0}
+	if conn != nil {
+		status.Type = conn.Type
+		status.RemoteIP = conn.RemoteIP
+		status.RemotePort = conn.RemotePort
+	}
+	switch {
+	case bytes.Equal(state, singleConnOK):
+		status.State = StateConnected
+	case bytes.HasSuffix(state, []byte("CONNECTING")):
+		status.State = StateConnecting
+	case bytes.HasSuffix(state, []byte("CLOSING")):
+		status.State = StateClosing
+	case bytes.HasSuffix(state, []byte("CLOSED")):
+		status.State = StateClosed
+	default:
+		// IP INITIAL, IP STATUS and the like: there is no connection
+		status.State = StateInitial
+	}
+	return status
+}
@@ -0,0 +1,95 @@
+package sim800l
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_SingleConnection(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CPAS":              "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		"AT+CGATT?":            "\r\n+CGATT: 1\r\n\r\nOK\r\n",
+		"AT+CIPMUX=0":          "\r\nOK\r\n",
+		"AT+CIPHEAD=1":         "\r\nOK\r\n",
+		"AT+CSTT=\"internet\"": "\r\nOK\r\n",
+		"AT+CIICR":             "\r\nOK\r\n",
+		"AT+CIFSR":             "\r\n10.0.0.1\r\n",
+		"AT+CIPSTART=\"TCP\",\"example.com\",\"80\"": "\r\nOK\r\n\r\nCONNECT OK\r\n",
+		"AT+CIPSEND=4":      "\r\n> ",
+		"AT+CIPSTATUS":      "\r\nOK\r\n\r\nSTATE: CONNECT OK\r\n",
+		"AT+CIPACK":         "\r\n+CIPACK: 4,4,0\r\n\r\nOK\r\n",
+		"AT+CIPCLOSE":       "\r\nCLOSE OK\r\n",
+		"AT+CIPSERVER=1,80": "\r\nOK\r\n",
+	})
+	// The server echoes every write back, the data follows the header
+	modem.dataReply = "\r\nSEND OK\r\n+IPD,4:ping"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	d.Configure(Config{SingleConnection: true})
+
+	if err := d.Connect("internet", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	c, err := d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	conn := c.(*Connection)
+	if n := allocatedRecvBuffers(d); n != 1 {
+		t.Errorf("expected one receive buffer, got %d", n)
+	}
+	if _, err := d.Dial("tcp", "example.com:80"); !errors.Is(err, ErrMaxConn) {
+		t.Errorf("expected ErrMaxConn for a second connection, got %v", err)
+	}
+	if _, err := d.Listen("tcp", 80); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported listening, got %v", err)
+	}
+
+	if n, err := conn.Write([]byte("ping")); err != nil || n != 4 {
+		t.Fatalf("write returned %d, %v", n, err)
+	}
+	buf := make([]byte, 8)
+	if n, err := conn.Read(buf); err != nil || string(buf[:n]) != "ping" {
+		t.Fatalf("expected the echo, got %q, %v", buf[:n], err)
+	}
+	if n, err := conn.Acked(); err != nil || n != 4 {
+		t.Errorf("expected 4 acknowledged bytes, got %d, %v", n, err)
+	}
+
+	// The IP state is the state of the connection
+	statuses, err := d.GetConnectionStatus()
+	if err != nil {
+		t.Fatalf("status query failed: %v", err)
+	}
+	expected := ConnectionStatus{ID: 0, Type: TCP, RemoteIP: "example.com", RemotePort: "80", State: StateConnected}
+	if len(statuses) != 1 || statuses[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, statuses)
+	}
+
+	// The remote host closes the connection
+	modem.inject("\r\nCLOSED\r\n")
+	if err := d.Poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if conn.State() != StateClosed || d.connections[0] != nil {
+		t.Errorf("expected connection 0 to be closed and released, got %v", conn.State())
+	}
+	if _, err := conn.Read(buf); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+
+	// The slot is free for the next connection
+	c, err = d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("dial after close failed: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	for _, cmd := range modem.commands {
+		if cmd == "AT+CIPMUX=1" || cmd == "AT+CIPCLOSE=0" {
+			t.Errorf("unexpected multi-connection command %q", cmd)
+		}
+	}
+}
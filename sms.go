@@ -0,0 +1,319 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains SMS sending, inbound SMS handling and sender filtering.
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MaxSMSLength   = 160              // Longest text sent as a single SMS
+	SMSSendTimeout = 60 * time.Second // Time the network may take to accept an SMS
+	MaxPendingSMS  = 4                // +CMTI notices kept for the SMS handler
+)
+
+// SMS command constants
+var (
+	cmdSmsTextMode = []byte("+CMGF=1") // Use text mode for SMS
+	cmdSmsRead     = []byte("+CMGR=")  // Read SMS at index
+	cmdSmsDelete   = []byte("+CMGD=")  // Delete SMS at index
+	smsReadPrefix  = []byte("+CMGR:")  // SMS read response prefix
+	cmdSmsSend     = []byte("+CMGS=")  // Send SMS to a number
+	smsSendPrefix  = []byte("+CMGS:")  // SMS sent response prefix
+	smsNotice      = []byte("+CMTI:")  // New SMS stored, with its index
+	ctrlZ          = byte(0x1A)        // Ends the text of an SMS
+)
+
+var (
+	ErrNoSMS       = errors.New("no SMS at index")
+	ErrSMSRejected = errors.New("SMS sender rejected by filter")
+)
+
+// SMS is a text message stored on the module
+type SMS struct {
+	Index  int    // Storage index on the module
+	Sender string // Sender phone number
+	Text   string // Message body
+}
+
+// SMSHandler is called for every inbound SMS accepted by the filter
+type SMSHandler func(msg SMS)
+
+// SMSFilter decides which senders may deliver SMS to the application.
+// Entries are matched against the sender number exactly, or as a prefix
+// when they end with '*' (e.g. "+359*").
+type SMSFilter struct {
+	Allow          []string // If not empty, only these senders are accepted
+	Deny           []string // Senders that are always rejected
+	DeleteRejected bool     // Delete rejected messages from module storage
+}
+
+// Accepts reports whether a message from sender passes the filter
+func (f *SMSFilter) Accepts(sender string) bool {
+	if matchSender(f.Deny, sender) {
+		return false
+	}
+	return len(f.Allow) == 0 || matchSender(f.Allow, sender)
+}
+
+// matchSender reports whether sender matches any of the patterns
+func matchSender(patterns []string, sender string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(sender, prefix) {
+				return true
+			}
+		} else if p == sender {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSMSHandler sets the function called for accepted inbound SMS. While
+// it is set, the SMS announced by +CMTI are passed to HandleSMS once no
+// operation holds the device, by Run or the next Poll.
+func (d *Device) SetSMSHandler(fn SMSHandler) {
+	d.lock()
+	defer d.unlock()
+	d.smsHandler = fn
+}
+
+// SetSMSFilter sets the sender filter applied before SMS reach the handler.
+// Passing the zero SMSFilter accepts every sender.
+func (d *Device) SetSMSFilter(f SMSFilter) {
+	d.lock()
+	defer d.unlock()
+	d.smsFilter = f
+}
+
+// SendSMS sends text to number. The text must fit a single SMS of
+// MaxSMSLength characters from the GSM alphabet.
+func (d *Device) SendSMS(number, text string) error {
+	d.lock()
+	defer d.unlock()
+	return d.sendSMS(number, text)
+}
+
+// sendSMS sends an SMS with the lock held
+func (d *Device) sendSMS(number, text string) error {
+	if number == "" || len(text) > MaxSMSLength {
+		return ErrBadParameter
+	}
+
+	var buf [MaxCommandSize]byte
+	cmd := append(buf[:0], cmdSmsSend...)
+	cmd = append(cmd, '"')
+	cmd = append(cmd, number...)
+	cmd = append(cmd, '"')
+	if err := d.sendRaw(cmd); err != nil {
+		return err
+	}
+
+	t, err := d.readLine(DefaultTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to read prompt: %w", err)
+	}
+	if t != TokenPrompt {
+		// E.g. +CMS ERROR when the SIM can't send
+		d.recordModuleError(d.rxBuffer[:d.end])
+		if err := defaultResponseCheck(d.rxBuffer[:d.end]); err != nil {
+			return err
+		}
+		return ErrUnexpectedResponse
+	}
+
+	var msg [MaxSMSLength + 1]byte
+	payload := append(msg[:0], text...)
+	payload = append(payload, ctrlZ)
+	if _, err := d.uart.Write(payload); err != nil {
+		return fmt.Errorf("failed to send text: %w", err)
+	}
+
+	return d.readResponse(cmdSmsSend, func(buffer []byte) error {
+		if bytes.HasPrefix(buffer, smsSendPrefix) {
+			return nil
+		}
+		return defaultResponseCheck(buffer)
+	}, SMSSendTimeout)
+}
+
+// ReadSMS reads the SMS stored at index. A message from a sender the
+// filter rejects returns ErrSMSRejected and is deleted if the filter asks
+// for it.
+func (d *Device) ReadSMS(index int) (SMS, error) {
+	d.lock()
+	defer d.unlock()
+	return d.readAccepted(index)
+}
+
+// readAccepted reads an SMS and applies the sender filter with the lock held
+func (d *Device) readAccepted(index int) (SMS, error) {
+	msg, err := d.readSMS(index)
+	if err != nil {
+		return SMS{}, err
+	}
+	if d.smsFilter.Accepts(msg.Sender) {
+		return msg, nil
+	}
+
+	d.log(SubsystemURC, slog.LevelDebug, "rejected SMS", "sender", msg.Sender, "index", index)
+	if d.smsFilter.DeleteRejected {
+		if err := d.deleteSMS(index); err != nil {
+			return SMS{}, err
+		}
+	}
+	return SMS{}, ErrSMSRejected
+}
+
+// readSMS reads an SMS with the lock held
+func (d *Device) readSMS(index int) (SMS, error) {
+	var buf [16]byte
+	cmd := append(buf[:0], cmdSmsRead...)
+	cmd = strconv.AppendInt(cmd, int64(index), 10)
+	err := d.sendWithOptions(cmd, func(buffer []byte) error {
+		if bytes.HasPrefix(buffer, smsReadPrefix) {
+			return nil
+		}
+		// An empty slot answers with a bare OK
+		if bytes.Contains(buffer, okToken) {
+			return ErrNoSMS
+		}
+		return defaultResponseCheck(buffer)
+	}, DefaultTimeout)
+	if err != nil {
+		return SMS{}, err
+	}
+
+	// Format: +CMGR: "REC UNREAD","+31628870634","","11/01/09,10:26:26+04"
+	msg := SMS{
+		Index:  index,
+		Sender: string(quotedField(d.rxBuffer[:d.end], 1)),
+	}
+
+	// The message body follows, one or more lines up to the final OK
+	var body [2 * MaxSMSLength]byte
+	text := body[:0]
+	for lines := 0; ; lines++ {
+		t, err := d.readLine(DefaultTimeout)
+		if err != nil {
+			return SMS{}, err
+		}
+		if t != TokenLine {
+			return SMS{}, ErrUnexpectedResponse
+		}
+		line := d.rxBuffer[:d.end]
+		if bytes.Equal(line, okToken) {
+			break
+		}
+		if lines > 0 {
+			text = append(text, '\n')
+		}
+		text = append(text, line...)
+	}
+	msg.Text = string(text)
+	return msg, nil
+}
+
+// DeleteSMS deletes the SMS stored at index
+func (d *Device) DeleteSMS(index int) error {
+	d.lock()
+	defer d.unlock()
+	return d.deleteSMS(index)
+}
+
+// deleteSMS deletes an SMS with the lock held
+func (d *Device) deleteSMS(index int) error {
+	var buf [16]byte
+	cmd := append(buf[:0], cmdSmsDelete...)
+	cmd = strconv.AppendInt(cmd, int64(index), 10)
+	return d.send(cmd)
+}
+
+// HandleSMS reads the SMS at index (as announced by a +CMTI notification),
+// applies the sender filter and passes accepted messages to the SMS handler.
+// Rejected messages return ErrSMSRejected and are deleted if the filter asks for it.
+func (d *Device) HandleSMS(index int) error {
+	d.lock()
+	msg, err := d.readAccepted(index)
+	handler := d.smsHandler
+	d.unlock()
+	if err != nil {
+		return err
+	}
+
+	// The handler runs unlocked so it may use the Device
+	if handler != nil {
+		handler(msg)
+	}
+	return nil
+}
+
+// smsArrived keeps the index of line if it is a +CMTI notice and an SMS
+// handler is set, for handlePendingSMS, and reports whether it did
+func (d *Device) smsArrived(line []byte) bool {
+	if d.smsHandler == nil || !bytes.HasPrefix(line, smsNotice) {
+		return false
+	}
+	// Format: +CMTI: "SM",3
+	field := line[bytes.LastIndexByte(line, ',')+1:]
+	index, err := strconv.Atoi(string(bytes.TrimSpace(field)))
+	if err != nil {
+		d.log(SubsystemURC, slog.LevelWarn, "malformed SMS notice", "line", line)
+		return true
+	}
+	if d.smsPendingCount == MaxPendingSMS {
+		d.log(SubsystemURC, slog.LevelWarn, "too many pending SMS, leaving SMS in storage", "index", index)
+		return true
+	}
+	d.smsPending[d.smsPendingCount] = index
+	d.smsPendingCount++
+	return true
+}
+
+// handlePendingSMS passes the SMS announced while the device was held to
+// HandleSMS. It is called without the lock.
+func (d *Device) handlePendingSMS() {
+	for {
+		d.lock()
+		if d.smsPendingCount == 0 {
+			d.unlock()
+			return
+		}
+		index := d.smsPending[0]
+		d.smsPendingCount--
+		copy(d.smsPending[:], d.smsPending[1:d.smsPendingCount+1])
+		d.unlock()
+
+		if err := d.HandleSMS(index); err != nil && !errors.Is(err, ErrSMSRejected) {
+			d.log(SubsystemURC, slog.LevelWarn, "failed to handle SMS", "index", index, "error", err)
+		}
+	}
+}
+
+// quotedField returns the n-th (zero based) double-quoted value in line,
+// without the quotes, or nil if there are not enough quoted values
+func quotedField(line []byte, n int) []byte {
+	for {
+		start := bytes.IndexByte(line, '"')
+		if start < 0 {
+			return nil
+		}
+		line = line[start+1:]
+		end := bytes.IndexByte(line, '"')
+		if end < 0 {
+			return nil
+		}
+		if n == 0 {
+			return line[:end]
+		}
+		line = line[end+1:]
+		n--
+	}
+}
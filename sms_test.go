@@ -0,0 +1,148 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestSMSFilter_Accepts(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter SMSFilter
+		sender string
+		want   bool
+	}{
+		{"empty filter", SMSFilter{}, "+359888123456", true},
+		{"allowed exact", SMSFilter{Allow: []string{"+359888123456"}}, "+359888123456", true},
+		{"not in allow list", SMSFilter{Allow: []string{"+359888123456"}}, "+359888000000", false},
+		{"allowed prefix", SMSFilter{Allow: []string{"+359*"}}, "+359888000000", true},
+		{"denied exact", SMSFilter{Deny: []string{"SPAM"}}, "SPAM", false},
+		{"deny wins over allow", SMSFilter{Allow: []string{"+359*"}, Deny: []string{"+359888*"}}, "+359888000000", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.filter.Accepts(tc.sender); got != tc.want {
+				t.Errorf("Accepts(%q) = %v, want %v", tc.sender, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDevice_HandleSMS(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CMGR=1": "\r\n+CMGR: \"REC UNREAD\",\"+359888123456\",\"\",\"25/07/01,10:26:26+12\"\r\nRELAY ON\r\n\r\nOK\r\n",
+		"AT+CMGR=2": "\r\n+CMGR: \"REC UNREAD\",\"+441234567890\",\"\",\"25/07/01,10:27:00+12\"\r\nWIN A PRIZE\r\n\r\nOK\r\n",
+		"AT+CMGD=2": "\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.SetSMSFilter(SMSFilter{Allow: []string{"+359*"}, DeleteRejected: true})
+
+	var got []SMS
+	d.SetSMSHandler(func(msg SMS) {
+		got = append(got, msg)
+	})
+
+	if err := d.HandleSMS(1); err != nil {
+		t.Fatalf("failed to handle SMS: %v", err)
+	}
+	if err := d.HandleSMS(2); !errors.Is(err, ErrSMSRejected) {
+		t.Fatalf("expected ErrSMSRejected, got %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 delivered SMS, got %d", len(got))
+	}
+	want := SMS{Index: 1, Sender: "+359888123456", Text: "RELAY ON"}
+	if got[0] != want {
+		t.Errorf("expected %+v, got %+v", want, got[0])
+	}
+
+	last := modem.commands[len(modem.commands)-1]
+	if last != "AT+CMGD=2" {
+		t.Errorf("expected rejected SMS to be deleted, last command %q", last)
+	}
+}
+
+func TestDevice_ReadSMS(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CMGR=1": "\r\n+CMGR: \"REC READ\",\"+359888123456\",\"\",\"25/07/01,10:26:26+12\"\r\nRELAY ON\r\nPUMP OFF\r\n\r\nOK\r\n",
+		"AT+CMGR=2": "\r\n+CMGR: \"REC READ\",\"+441234567890\",\"\",\"25/07/01,10:27:00+12\"\r\nWIN A PRIZE\r\n\r\nOK\r\n",
+		"AT+CSQ":    "\r\n+CSQ: 21,0\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.SetSMSFilter(SMSFilter{Allow: []string{"+359*"}})
+
+	msg, err := d.ReadSMS(1)
+	if err != nil || msg.Text != "RELAY ON\nPUMP OFF" {
+		t.Errorf("expected both body lines, got %q, %v", msg.Text, err)
+	}
+	// The final OK was read with the body, it doesn't answer the next command
+	if s, err := d.QueryString("+CSQ"); err != nil || s != "21,0" {
+		t.Errorf("expected the signal quality, got %q, %v", s, err)
+	}
+
+	if _, err := d.ReadSMS(2); !errors.Is(err, ErrSMSRejected) {
+		t.Errorf("expected ErrSMSRejected, got %v", err)
+	}
+}
+
+func TestDevice_SMSNotice(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CMGR=3": "\r\n+CMGR: \"REC UNREAD\",\"+359888123456\",\"\",\"25/07/01,10:26:26+12\"\r\nRELAY ON\r\n\r\nOK\r\n",
+		"AT+CMGR=4": "\r\n+CMGR: \"REC UNREAD\",\"+359888123456\",\"\",\"25/07/01,10:28:00+12\"\r\nRELAY OFF\r\n\r\nOK\r\n",
+		"AT+CSQ":    "\r\n+CMTI: \"SM\",4\r\n\r\n+CSQ: 21,0\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+
+	var got []string
+	d.SetSMSHandler(func(msg SMS) {
+		got = append(got, msg.Text)
+	})
+
+	// Announced while idle
+	modem.inject("\r\n+CMTI: \"SM\",3\r\n")
+	if err := d.Poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	// Announced during a command, read once the command is done
+	if _, err := d.QueryString("+CSQ"); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected the idle SMS only before the next Poll, got %q", got)
+	}
+	if err := d.Poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if len(got) != 2 || got[0] != "RELAY ON" || got[1] != "RELAY OFF" {
+		t.Errorf("expected both SMS in order, got %q", got)
+	}
+}
+
+func TestDevice_SendSMS(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		`AT+CMGS="+15550100"`: "\r\n> ",
+		`AT+CMGS="+15550199"`: "\r\n+CMS ERROR: 38\r\n",
+	})
+	modem.dataReply = "\r\n+CMGS: 7\r\n\r\nOK\r\n"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	if err := d.SendSMS("+15550100", "hello"); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	var atErr *ATError
+	if err := d.SendSMS("+15550199", "hello"); !errors.As(err, &atErr) {
+		t.Errorf("expected AT error for rejected SMS, got %v", err)
+	}
+	if diag := d.Diagnostics(); diag.TotalErrors != 1 {
+		t.Errorf("expected the CMS error to be recorded, got %d errors", diag.TotalErrors)
+	}
+
+	long := make([]byte, MaxSMSLength+1)
+	if err := d.SendSMS("+15550100", string(long)); err != ErrBadParameter {
+		t.Errorf("expected ErrBadParameter for long text, got %v", err)
+	}
+}
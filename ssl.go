@@ -0,0 +1,31 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the module's SSL for TCP connections.
+package sim800l
+
+import "fmt"
+
+var (
+	cmdSSLOn  = []byte("+CIPSSL=1") // Encrypt the connections started next
+	cmdSSLOff = []byte("+CIPSSL=0") // Don't encrypt the connections started next
+)
+
+// setSSL makes the connections started next use the module's SSL or not,
+// with the lock held. The setting is cached, so it is only sent when it
+// changes.
+func (d *Device) setSSL(on bool) error {
+	if !on {
+		// The module starts without SSL, and firmware without it rejects
+		// AT+CIPSSL, so only switch it off if it was switched on
+		if i, v, _ := idempotentSetting(cmdSSLOn); d.settings[i] != v {
+			return nil
+		}
+		return d.send(cmdSSLOff)
+	}
+	if !d.capabilities().SSL {
+		return fmt.Errorf("%w: no SSL in firmware %q", ErrNotSupported, d.Firmware)
+	}
+	if err := d.send(cmdSSLOn); err != nil {
+		return fmt.Errorf("failed to enable SSL: %w", err)
+	}
+	return nil
+}
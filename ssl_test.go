@@ -0,0 +1,54 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDevice_DialTLS(t *testing.T) {
+	modem := newSessionModem()
+	modem.responses["AT+CIPSSL=1"] = "\r\nOK\r\n"
+	modem.responses["AT+CIPSSL=0"] = "\r\nOK\r\n"
+	modem.responses["AT+CIPSTART=0,\"TCP\",\"example.com\",\"443\""] = "\r\nOK\r\n\r\n0, CONNECT OK\r\n"
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	if err := d.Connect("internet", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+
+	// Unknown firmware isn't trusted with SSL
+	if _, err := d.Dial("tls", "example.com:443"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+
+	d.Firmware = "1418B05SIM800L24"
+	for range 2 {
+		conn, err := d.Dial("tls", "example.com:443")
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		if !conn.(*Connection).TLS {
+			t.Error("expected a TLS connection")
+		}
+		if err := conn.Close(); err != nil {
+			t.Fatalf("close failed: %v", err)
+		}
+	}
+	conn, err := d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	_ = conn.Close()
+
+	// SSL is switched on once and off again for plain TCP
+	var ssl []string
+	for _, c := range modem.commands {
+		if strings.HasPrefix(c, "AT+CIPSSL") {
+			ssl = append(ssl, c)
+		}
+	}
+	if got := strings.Join(ssl, "|"); got != "AT+CIPSSL=1|AT+CIPSSL=0" {
+		t.Errorf("expected SSL on and off, got %q", got)
+	}
+}
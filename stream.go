@@ -0,0 +1,75 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains streaming of received data larger than the receive buffer.
+package sim800l
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrStreamPending = errors.New("received data waiting to be read")
+
+// streaming reports whether connection id has received data left on the
+// UART that didn't fit its receive buffer
+func (d *Device) streaming(id uint8) bool {
+	return d.streamLeft > 0 && d.streamID == id
+}
+
+// readStream reads the stream of connection id from the UART straight
+// into b, as far as it has arrived
+func (d *Device) readStream(id uint8, b []byte) (int, error) {
+	if !d.streaming(id) {
+		return 0, nil
+	}
+	n, err := d.input().Read(b[:min(len(b), d.streamLeft)])
+	if n > 0 {
+		d.streamLeft -= n
+		if conn := d.connections[id]; conn != nil {
+			conn.bytesReceived += uint64(n)
+			conn.lastActivity = time.Now()
+		}
+		d.logPayload("data received", id, b[:n])
+	}
+	return n, err
+}
+
+// drainStream moves a pending stream off the UART before the module's
+// next output is read: into its connection's receive buffer, as far as it
+// fits, or nowhere if the connection is gone. It fails with
+// ErrStreamPending while the stream's connection has to be read first.
+func (d *Device) drainStream(deadline time.Time) error {
+	for d.streamLeft > 0 {
+		id := d.streamID
+		conn := d.connections[id]
+		discard := conn == nil || conn.closed
+		var dst []byte
+		if discard {
+			dst = d.rxBuffer[:min(len(d.rxBuffer), d.streamLeft)]
+		} else {
+			if !d.claimRecvBuffer(id) || d.recvBufLengths[id] == RecvBufSize {
+				return ErrStreamPending
+			}
+			start := d.recvBufLengths[id]
+			dst = d.recvBuffers[id][start:min(RecvBufSize, start+d.streamLeft)]
+		}
+
+		n, err := d.input().Read(dst)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			if !time.Now().Before(deadline) {
+				return ErrTimeout
+			}
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		d.streamLeft -= n
+		if !discard {
+			d.recvBufLengths[id] += n
+			conn.bytesReceived += uint64(n)
+			conn.lastActivity = time.Now()
+		}
+	}
+	return nil
+}
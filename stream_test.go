@@ -0,0 +1,57 @@
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestConnection_ReadStream(t *testing.T) {
+	modem := newSessionModem()
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	if err := d.Connect("internet", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	c, err := d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	// A response three times the size of the receive buffer
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 3*RecvBufSize/16)
+	modem.inject("+RECEIVE,0,3072:\r\n" + string(payload))
+	if err := d.Poll(); !errors.Is(err, ErrStreamPending) {
+		t.Errorf("expected ErrStreamPending, got %v", err)
+	}
+	if d.recvBufLengths[0] != RecvBufSize {
+		t.Errorf("expected a full receive buffer, got %d bytes", d.recvBufLengths[0])
+	}
+
+	// Commands wait until the data is read
+	if _, err := d.GetConnectionStatus(); !errors.Is(err, ErrStreamPending) {
+		t.Errorf("expected ErrStreamPending, got %v", err)
+	}
+
+	got := make([]byte, len(payload))
+	if _, err := io.ReadFull(c, got); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Error("received data differs from the payload")
+	}
+	if stats, err := c.(*Connection).Stats(); err != nil || stats.BytesReceived != uint64(len(payload)) {
+		t.Errorf("expected %d bytes received, got %d, %v", len(payload), stats.BytesReceived, err)
+	}
+
+	// Closing skips the rest of a stream nobody reads
+	modem.inject("+RECEIVE,0,3072:\r\n" + string(payload))
+	_ = d.Poll()
+	if err := c.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if n := modem.Buffered(); n != 0 {
+		t.Errorf("expected the stream to be skipped, %d bytes left", n)
+	}
+}
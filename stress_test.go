@@ -0,0 +1,227 @@
+package sim800l
+
+import (
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newSessionModem returns a mock modem scripted for a full
+// Connect/Dial/Write/Read/Close/Disconnect cycle on connection 0
+func newSessionModem() *mockModem {
+	modem := newMockModem(map[string]string{
+		"AT+CPAS":              "\r\n+CPAS: 0\r\n\r\nOK\r\n",
+		"AT+CGATT?":            "\r\n+CGATT: 1\r\n\r\nOK\r\n",
+		"AT+CIPMUX=1":          "\r\nOK\r\n",
+		"AT+CSTT=\"internet\"": "\r\nOK\r\n",
+		"AT+CIICR":             "\r\nOK\r\n",
+		"AT+CIFSR":             "\r\n10.0.0.1\r\n",
+		"AT+CIPSTART=0,\"TCP\",\"example.com\",\"80\"": "\r\nOK\r\n\r\n0, CONNECT OK\r\n",
+		"AT+CIPSEND=0,4": "\r\n> ",
+		"AT+CIPCLOSE=0":  "\r\n0, CLOSE OK\r\n",
+		"AT+CIPSHUT":     "\r\nSHUT OK\r\n",
+		"AT+CGATT=0":     "\r\nOK\r\n",
+	})
+	// The server echoes every write back
+	modem.dataReply = "\r\n0, SEND OK\r\n+RECEIVE,0,4:\r\nping"
+	return modem
+}
+
+// assertBaseline fails the test if the device holds on to connection slots
+// or received data, or if goroutines were left running
+func assertBaseline(t *testing.T, d *Device, goroutines int) {
+	t.Helper()
+	for i := 0; i < MaxConnections; i++ {
+		if d.connections[i] != nil {
+			t.Errorf("connection slot %d still in use", i)
+		}
+		if d.recvBufLengths[i] != 0 || d.recvMsgCount[i] != 0 {
+			t.Errorf("connection %d still buffers %d bytes in %d datagrams",
+				i, d.recvBufLengths[i], d.recvMsgCount[i])
+		}
+	}
+	if d.IP != "" {
+		t.Errorf("IP address %q still set", d.IP)
+	}
+
+	// Give goroutines that are shutting down a moment to exit
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		if runtime.NumGoroutine() <= goroutines {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("goroutines leaked: %d running, baseline %d", runtime.NumGoroutine(), goroutines)
+}
+
+func TestDevice_ConnectCyclesDoNotLeak(t *testing.T) {
+	cycles := 100
+	if testing.Short() {
+		cycles = 10
+	}
+
+	modem := newSessionModem()
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.SetLogLevel(SubsystemCommand, slog.LevelWarn)
+	goroutines := runtime.NumGoroutine()
+
+	buf := make([]byte, 16)
+	for i := 0; i < cycles; i++ {
+		if err := d.Connect("internet", "", ""); err != nil {
+			t.Fatalf("cycle %d: connect failed: %v", i, err)
+		}
+		conn, err := d.Dial("tcp", "example.com:80")
+		if err != nil {
+			t.Fatalf("cycle %d: dial failed: %v", i, err)
+		}
+		if _, err := conn.Write([]byte("ping")); err != nil {
+			t.Fatalf("cycle %d: write failed: %v", i, err)
+		}
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("cycle %d: read failed: %v", i, err)
+		}
+		if string(buf[:n]) != "ping" {
+			t.Fatalf("cycle %d: expected %q, got %q", i, "ping", buf[:n])
+		}
+		if err := conn.Close(); err != nil {
+			t.Fatalf("cycle %d: close failed: %v", i, err)
+		}
+		if err := d.Disconnect(); err != nil {
+			t.Fatalf("cycle %d: disconnect failed: %v", i, err)
+		}
+		assertBaseline(t, d, goroutines)
+		if t.Failed() {
+			t.Fatalf("leak detected after cycle %d", i)
+		}
+	}
+}
+
+func TestDevice_DisconnectReleasesOpenConnections(t *testing.T) {
+	modem := newSessionModem()
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	goroutines := runtime.NumGoroutine()
+
+	if err := d.Connect("internet", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	conn, err := d.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	// Leave the echoed data unread in the receive buffer
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := d.checkForReceivedData(time.Second); err != nil {
+		t.Fatalf("failed to receive data: %v", err)
+	}
+
+	if err := d.Disconnect(); err != nil {
+		t.Fatalf("disconnect failed: %v", err)
+	}
+	assertBaseline(t, d, goroutines)
+}
+
+func TestDevice_ConcurrentUse(t *testing.T) {
+	const writes = 5
+	slot(t, 1)
+
+	modem := newMockModem(map[string]string{
+		"AT+CSQ":         "\r\n+CSQ: 21,0\r\n\r\nOK\r\n",
+		"AT+CIPSEND=0,4": "\r\n> ",
+		"AT+CIPSEND=1,4": "\r\n> ",
+	})
+	modem.dataReply = "\r\nSEND OK\r\n"
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.SetLogLevel(SubsystemCommand, slog.LevelWarn)
+	d.SetLogLevel(SubsystemURC, slog.LevelWarn)
+	for i := uint8(0); i < 2; i++ {
+		d.connections[i] = &Connection{ID: i, Type: TCP, state: StateConnected, Device: d}
+	}
+
+	var wg sync.WaitGroup
+	for i, payload := range []string{"aaaa", "bbbb"} {
+		conn := d.connections[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				if _, err := conn.Write([]byte(payload)); err != nil {
+					t.Errorf("write on connection %d failed: %v", conn.ID, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < writes; j++ {
+			if got := d.Signal(); got != 21 {
+				t.Errorf("expected signal 21, got %d", got)
+			}
+		}
+	}()
+	wg.Wait()
+
+	// Every payload must directly follow its own send command
+	tx := modem.tx.String()
+	for _, want := range []string{"AT+CIPSEND=0,4\r\naaaa", "AT+CIPSEND=1,4\r\nbbbb"} {
+		if n := strings.Count(tx, want); n != writes {
+			t.Errorf("expected %d x %q, found %d in %q", writes, want, n, tx)
+		}
+	}
+}
+
+func TestDevice_ConcurrentWritesTakeTurns(t *testing.T) {
+	s1 := slot(t, 1)
+	modem := newMockModem(map[string]string{
+		"AT+CIPSEND=0,1024": "\r\n> ",
+		"AT+CIPSEND=1,1024": "\r\n> ",
+	})
+	modem.dataReply = "\r\nSEND OK\r\n"
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.SetLogLevel(SubsystemCommand, slog.LevelWarn)
+	for i := uint8(0); i < 2; i++ {
+		d.connections[i] = &Connection{ID: i, Type: TCP, state: StateConnected, Device: d}
+	}
+
+	write := func(conn *Connection, payload string, wg *sync.WaitGroup) {
+		defer wg.Done()
+		if _, err := conn.Write([]byte(payload)); err != nil {
+			t.Errorf("write on connection %d failed: %v", conn.ID, err)
+		}
+	}
+
+	// Large writes on two connections send their chunks in turn
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go write(d.connections[0], strings.Repeat("a", 2048), &wg)
+	go write(d.connections[s1], strings.Repeat("b", 2048), &wg)
+	wg.Wait()
+	if len(modem.commands) != 4 {
+		t.Fatalf("expected 4 sends, got %q", modem.commands)
+	}
+	for i := 1; i < len(modem.commands); i++ {
+		if modem.commands[i] == modem.commands[i-1] {
+			t.Errorf("expected the connections to take turns, got %q", modem.commands)
+			break
+		}
+	}
+
+	// Writes on the same connection don't interleave
+	modem.tx.Reset()
+	wg.Add(2)
+	go write(d.connections[0], strings.Repeat("a", 2048), &wg)
+	go write(d.connections[0], strings.Repeat("b", 2048), &wg)
+	wg.Wait()
+	data := strings.ReplaceAll(modem.tx.String(), "AT+CIPSEND=0,1024\r\n", "")
+	a, b := strings.Repeat("a", 2048), strings.Repeat("b", 2048)
+	if data != a+b && data != b+a {
+		t.Errorf("expected whole writes one after the other, got %q", data)
+	}
+}
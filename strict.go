@@ -0,0 +1,26 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the strict mode that fails on protocol anomalies.
+package sim800l
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+var ErrProtocolAnomaly = errors.New("protocol anomaly")
+
+// anomaly reports output the driver didn't expect but can recover from,
+// like a stray line or a connection the module dropped silently. Normally
+// the driver carries on and it returns nil. In strict mode it emits
+// EventProtocolAnomaly and returns an error matching ErrProtocolAnomaly,
+// which the caller returns instead of recovering.
+func (d *Device) anomaly(what string, detail []byte) error {
+	if !d.strict {
+		return nil
+	}
+	err := fmt.Errorf("%w: %s: %q", ErrProtocolAnomaly, what, detail)
+	d.log(SubsystemCommand, slog.LevelError, "protocol anomaly", "error", err)
+	d.emit(Event{Type: EventProtocolAnomaly, Err: err})
+	return err
+}
@@ -0,0 +1,44 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_Strict(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CIPSTATUS": "\r\nOK\r\n\r\nSTATE: IP PROCESSING\r\n\r\n" +
+			"C: 0,0,\"TCP\",\"10.0.0.5\",\"80\",\"CLOSED\"\r\n" +
+			"C: 1,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 2,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 3,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 4,,\"\",\"\",\"\",\"INITIAL\"\r\n" +
+			"C: 5,,\"\",\"\",\"\",\"INITIAL\"\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	var events []Event
+	d.SetEventHandler(func(e Event) { events = append(events, e) })
+
+	// By default a stray line is dropped
+	modem.inject("\r\nGARBAGE\r\n")
+	if err := d.Poll(); err != nil {
+		t.Fatalf("expected the stray line to be ignored, got %v", err)
+	}
+
+	d.Configure(Config{Strict: true})
+	modem.inject("\r\nGARBAGE\r\n")
+	if err := d.Poll(); !errors.Is(err, ErrProtocolAnomaly) {
+		t.Errorf("expected ErrProtocolAnomaly for a stray line, got %v", err)
+	}
+
+	// The module dropped a connection the driver thought open
+	d.connections[0] = &Connection{ID: 0, Type: TCP, RemoteIP: "10.0.0.5", state: StateConnected, Device: d}
+	if _, err := d.GetConnectionStatus(); !errors.Is(err, ErrProtocolAnomaly) {
+		t.Errorf("expected ErrProtocolAnomaly for a silent close, got %v", err)
+	}
+
+	if len(events) != 2 || events[0].Type != EventProtocolAnomaly || events[1].Type != EventProtocolAnomaly {
+		t.Errorf("expected two anomaly events, got %+v", events)
+	}
+}
@@ -0,0 +1,117 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains a minimal HTTP POST for sending sensor readings.
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TelemetryTimeout bounds the wait of PostTelemetry for the server's
+// status line
+const TelemetryTimeout = 30 * time.Second
+
+// maxTelemetryHeader is the size of the request header PostTelemetry
+// builds, without allocating
+const maxTelemetryHeader = 256
+
+var ErrBadHTTPResponse = errors.New("malformed HTTP response")
+
+var httpVersion = []byte("HTTP/1.") // Start of the status line
+
+// PostTelemetry sends payload, a JSON document, to http://host/path with
+// a minimal HTTP/1.1 POST and returns the status code of the response.
+// host may carry a port, 80 if it doesn't. A host starting with https://
+// is posted to over the module's SSL, port 443 by default, on firmware
+// that has it, see FeatureSSL; other firmware returns ErrNotSupported. It
+// opens a connection for the request and closes it once the status line
+// arrived, so the rest of the response isn't buffered. Status codes
+// outside 2xx aren't errors; check the code. A host or path with spaces or
+// control bytes, which would break the request, returns ErrBadParameter.
+func (d *Device) PostTelemetry(host, path string, payload []byte) (int, error) {
+	network, port := "tcp", "80"
+	if h, ok := strings.CutPrefix(host, "https://"); ok {
+		if !d.Capabilities().SSL {
+			return 0, fmt.Errorf("%w: no SSL in firmware %q", ErrNotSupported, d.Firmware)
+		}
+		network, port, host = "tls", "443", h
+	}
+	if host == "" || strings.ContainsFunc(host+path, notHeaderByte) {
+		return 0, fmt.Errorf("%w: host %q, path %q", ErrBadParameter, host, path)
+	}
+	address := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		address = net.JoinHostPort(host, port)
+	}
+
+	var buf [maxTelemetryHeader]byte
+	header := fmt.Appendf(buf[:0], "POST %s HTTP/1.1\r\nHost: %s\r\n"+
+		"Content-Type: application/json\r\nContent-Length: %d\r\n"+
+		"Connection: close\r\n\r\n", path, host, len(payload))
+	if len(header) > len(buf) {
+		return 0, fmt.Errorf("%w: request header too long", ErrBadParameter)
+	}
+
+	conn, err := d.Dial(network, address)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(header); err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	if _, err := conn.Write(payload); err != nil {
+		return 0, fmt.Errorf("failed to send payload: %w", err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(TelemetryTimeout))
+	return readStatusLine(conn, buf[:])
+}
+
+// notHeaderByte reports whether r can't be part of a request line or
+// header value: a space, a control byte or DEL
+func notHeaderByte(r rune) bool {
+	return r <= ' ' || r == 0x7f
+}
+
+// readStatusLine reads the response up to the end of its status line,
+// like HTTP/1.1 200 OK, into buf and returns the status code
+func readStatusLine(r io.Reader, buf []byte) (int, error) {
+	n := 0
+	for {
+		if end := bytes.Index(buf[:n], crlf); end >= 0 {
+			return parseStatusLine(buf[:end])
+		}
+		if n == len(buf) {
+			return 0, fmt.Errorf("%w: status line too long", ErrBadHTTPResponse)
+		}
+		m, err := r.Read(buf[n:])
+		n += m
+		if err == io.EOF && m == 0 {
+			return 0, fmt.Errorf("%w: connection closed before the status line", ErrBadHTTPResponse)
+		}
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("failed to read response: %w", err)
+		}
+	}
+}
+
+// parseStatusLine returns the status code of a status line
+func parseStatusLine(line []byte) (int, error) {
+	// HTTP/1.1 <code> <reason>
+	if !bytes.HasPrefix(line, httpVersion) || len(line) < len("HTTP/1.1 200") || line[8] != ' ' {
+		return 0, fmt.Errorf("%w: %q", ErrBadHTTPResponse, line)
+	}
+	code, err := strconv.Atoi(string(line[9:12]))
+	if err != nil || code < 100 {
+		return 0, fmt.Errorf("%w: %q", ErrBadHTTPResponse, line)
+	}
+	return code, nil
+}
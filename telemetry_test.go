@@ -0,0 +1,99 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scriptedDataModem replies to each data payload with the next reply
+type scriptedDataModem struct {
+	*mockModem
+	replies []string
+}
+
+func (m *scriptedDataModem) Write(p []byte) (int, error) {
+	if m.inData && len(m.replies) > 0 {
+		m.dataReply, m.replies = m.replies[0], m.replies[1:]
+	}
+	return m.mockModem.Write(p)
+}
+
+func TestDevice_PostTelemetry(t *testing.T) {
+	payload := []byte(`{"t":21.5}`)
+	header := "POST /v1/readings HTTP/1.1\r\nHost: example.com\r\n" +
+		"Content-Type: application/json\r\nContent-Length: 10\r\n" +
+		"Connection: close\r\n\r\n"
+	status := "HTTP/1.1 201 Created\r\nContent-Length: 0\r\n\r\n"
+
+	modem := newSessionModem()
+	modem.responses["AT+CIPSEND=0,"+strconv.Itoa(len(header))] = "\r\n> "
+	modem.responses["AT+CIPSEND=0,10"] = "\r\n> "
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	if err := d.Connect("internet", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	scripted := &scriptedDataModem{mockModem: modem, replies: []string{
+		"\r\n0, SEND OK\r\n",
+		"\r\n0, SEND OK\r\n\r\n+RECEIVE,0," + strconv.Itoa(len(status)) + ":\r\n" + status,
+	}}
+	d.uart = scripted
+
+	code, err := d.PostTelemetry("example.com", "/v1/readings", payload)
+	if err != nil {
+		t.Fatalf("post failed: %v", err)
+	}
+	if code != 201 {
+		t.Errorf("expected status 201, got %d", code)
+	}
+	if sent := modem.tx.String(); !strings.Contains(sent, header) || !strings.Contains(sent, string(payload)) {
+		t.Errorf("expected the request to be sent, got %q", sent)
+	}
+	if modem.commandCount("AT+CIPCLOSE=0") != 1 {
+		t.Error("expected the connection to be closed")
+	}
+}
+
+func TestDevice_PostTelemetryParameters(t *testing.T) {
+	modem := newSessionModem()
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+	if err := d.Connect("internet", "", ""); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+
+	// Line breaks or spaces would end the request line early
+	for _, path := range []string{"/a\r\nX-Injected: 1", "/a b", "/a\n"} {
+		if _, err := d.PostTelemetry("example.com", path, nil); !errors.Is(err, ErrBadParameter) {
+			t.Errorf("%q: expected ErrBadParameter, got %v", path, err)
+		}
+	}
+	if _, err := d.PostTelemetry("https://", "/", nil); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported for HTTPS without SSL, got %v", err)
+	}
+
+	// HTTPS dials a TLS connection to port 443 on firmware with SSL
+	d.Firmware = "1418B05SIM800L24"
+	modem.responses["AT+CIPSSL=1"] = "\r\nOK\r\n"
+	if _, err := d.PostTelemetry("https://", "/", nil); !errors.Is(err, ErrBadParameter) {
+		t.Errorf("expected ErrBadParameter without a host, got %v", err)
+	}
+	if _, err := d.PostTelemetry("https://example.com", "/v1/readings", nil); err == nil {
+		t.Error("expected the unanswered dial to fail")
+	}
+	if modem.commandCount("AT+CIPSSL=1") != 1 || modem.commandCount("AT+CIPSTART=0,\"TCP\",\"example.com\",\"443\"") != 1 {
+		t.Errorf("expected a TLS dial to port 443, got %q", modem.commands)
+	}
+}
+
+func Test_parseStatusLine(t *testing.T) {
+	if code, err := parseStatusLine([]byte("HTTP/1.0 404 Not Found")); err != nil || code != 404 {
+		t.Errorf("expected 404, got %d, %v", code, err)
+	}
+	for _, line := range []string{"", "HTTP/1.1", "HTTP/1.1 2xx OK", "SMTP 220 ready"} {
+		if _, err := parseStatusLine([]byte(line)); !errors.Is(err, ErrBadHTTPResponse) {
+			t.Errorf("%q: expected ErrBadHTTPResponse, got %v", line, err)
+		}
+	}
+}
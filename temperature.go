@@ -0,0 +1,95 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains module temperature readings and overheating events.
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// DefaultTemperatureHysteresis is how far the temperature must fall below
+// TemperatureHigh before EventTemperatureNormal, if TemperatureLow is zero
+const DefaultTemperatureHysteresis = 5.0
+
+var (
+	cmdTemperature    = []byte("+CMTE?") // Query the module temperature
+	temperatureStatus = []byte("+CMTE")  // Temperature response key
+)
+
+// cmeNotSupported is the CME error code for an operation not supported
+const cmeNotSupported = 4
+
+var ErrNotSupported = errors.New("not supported by the module firmware")
+
+// Temperature returns the module temperature in degrees Celsius. Firmware
+// without AT+CMTE returns an error matching ErrNotSupported. With
+// Config.TemperatureHigh set, crossing the thresholds emits
+// EventOverheating and EventTemperatureNormal, since transmit failures of
+// an overheated module otherwise look like network errors.
+func (d *Device) Temperature() (float64, error) {
+	d.lock()
+	defer d.unlock()
+
+	err := d.sendWithOptions(cmdTemperature, prefixCheck(temperatureStatus), DefaultTimeout)
+	if err != nil {
+		if commandUnknown(err) {
+			d.noTemperature = true
+			return 0, fmt.Errorf("%w: %w", ErrNotSupported, err)
+		}
+		return 0, err
+	}
+
+	// +CMTE: <mode>,<temperature>
+	val, ok := d.parseValue(temperatureStatus)
+	if !ok {
+		return 0, ErrUnexpectedResponse
+	}
+	i := bytes.IndexByte(val, ',')
+	if i < 0 {
+		return 0, ErrUnexpectedResponse
+	}
+	temp, err := strconv.ParseFloat(string(bytes.TrimSpace(val[i+1:])), 64)
+	if err != nil {
+		return 0, ErrUnexpectedResponse
+	}
+	d.checkTemperature(temp)
+	return temp, nil
+}
+
+// commandUnknown reports whether err is the module rejecting a command its
+// firmware lacks: a plain ERROR, or CME error 4, operation not supported.
+// Other CME errors, like a busy SIM or network, don't tell.
+func commandUnknown(err error) bool {
+	var cmeErr *CMEError
+	if errors.As(err, &cmeErr) {
+		return cmeErr.Code == cmeNotSupported
+	}
+	var atErr *ATError
+	return errors.As(err, &atErr) && atErr.Err == nil && strings.Contains(atErr.Command, string(errorToken))
+}
+
+// checkTemperature emits an event when temp crosses the configured thresholds
+func (d *Device) checkTemperature(temp float64) {
+	if d.tempHigh == 0 {
+		return
+	}
+	low := d.tempLow
+	if low == 0 {
+		low = d.tempHigh - DefaultTemperatureHysteresis
+	}
+
+	switch {
+	case !d.overheated && temp >= d.tempHigh:
+		d.overheated = true
+		d.log(SubsystemPower, slog.LevelWarn, "module overheating", "temperature", temp)
+		d.emit(Event{Type: EventOverheating, Temperature: temp})
+	case d.overheated && temp <= low:
+		d.overheated = false
+		d.log(SubsystemPower, slog.LevelInfo, "module temperature normal", "temperature", temp)
+		d.emit(Event{Type: EventTemperatureNormal, Temperature: temp})
+	}
+}
@@ -0,0 +1,89 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_Temperature(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CMTE?": "\r\n+CMTE: 0,36.50\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+
+	temp, err := d.Temperature()
+	if err != nil {
+		t.Fatalf("failed to read temperature: %v", err)
+	}
+	if temp != 36.5 {
+		t.Errorf("expected 36.5, got %v", temp)
+	}
+	if !d.Supports(FeatureTemperature) {
+		t.Error("expected temperature to be supported")
+	}
+}
+
+func TestDevice_TemperatureUnsupported(t *testing.T) {
+	modem := newMockModem(nil)
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	if _, err := d.Temperature(); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if d.Supports(FeatureTemperature) {
+		t.Error("expected temperature to be unsupported after the module rejected it")
+	}
+}
+
+func TestDevice_TemperatureEvents(t *testing.T) {
+	modem := newMockModem(map[string]string{})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.Configure(Config{TemperatureHigh: 70})
+	var events []Event
+	d.SetEventHandler(func(e Event) { events = append(events, e) })
+
+	for _, reading := range []string{"60.00", "71.00", "75.00", "66.00", "64.50", "72.00"} {
+		modem.responses["AT+CMTE?"] = "\r\n+CMTE: 0," + reading + "\r\n\r\nOK\r\n"
+		if _, err := d.Temperature(); err != nil {
+			t.Fatalf("failed to read temperature: %v", err)
+		}
+	}
+
+	want := []Event{
+		{Type: EventOverheating, Temperature: 71},
+		{Type: EventTemperatureNormal, Temperature: 64.5},
+		{Type: EventOverheating, Temperature: 72},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %v, got %v", want, events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d: expected %v, got %v", i, want[i], events[i])
+		}
+	}
+}
+
+func TestDevice_TemperatureOtherErrors(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CMTE?": "\r\n+CME ERROR: 14\r\n",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	// Only a plain ERROR or CME error 4 tells the firmware lacks AT+CMTE
+	if _, err := d.Temperature(); err == nil || errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected an error other than ErrNotSupported, got %v", err)
+	}
+	if !d.Supports(FeatureTemperature) {
+		t.Error("expected temperature to stay supported after a CME error 14")
+	}
+
+	modem.responses["AT+CMTE?"] = "\r\n+CME ERROR: operation not supported\r\n"
+	if _, err := d.Temperature(); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if d.Supports(FeatureTemperature) {
+		t.Error("expected temperature to be unsupported after CME error 4")
+	}
+}
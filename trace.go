@@ -0,0 +1,98 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the trace hook for the AT command session.
+package sim800l
+
+import (
+	"bytes"
+	"time"
+)
+
+// TraceKind tells what a trace record holds
+type TraceKind uint8
+
+const (
+	TraceCommand  TraceKind = iota // Command written to the module
+	TraceResponse                  // Line received in answer to a command
+	TraceURC                       // Unsolicited line, e.g. +RECEIVE or a registered URC
+	TracePrompt                    // "> " data prompt
+)
+
+func (k TraceKind) String() string {
+	switch k {
+	case TraceCommand:
+		return "Command"
+	case TraceResponse:
+		return "Response"
+	case TraceURC:
+		return "URC"
+	case TracePrompt:
+		return "Prompt"
+	default:
+		return "Unknown"
+	}
+}
+
+// TraceRecord is one step of the AT command session. Seq increases by one
+// for every record of a device, so interleaved logs of several modems can
+// be put back in order. Data refers to the driver's buffer and is only
+// valid during the callback.
+type TraceRecord struct {
+	Seq       uint64    // Session-level sequence number, starting at 1
+	Timestamp int64     // Unix time in milliseconds
+	Kind      TraceKind // What the record holds
+	Data      []byte    // Command or line, without line endings
+}
+
+// TraceFunc receives trace records. It is called synchronously while the
+// driver talks to the module, so it must be fast and must not call back
+// into the Device.
+type TraceFunc func(r TraceRecord)
+
+// SetTraceHook sets the function receiving a record for every command,
+// response and URC. Pass nil to stop tracing.
+func (d *Device) SetTraceHook(fn TraceFunc) {
+	d.lock()
+	defer d.unlock()
+	d.traceFn = fn
+}
+
+// trace passes a record to the trace hook, if any
+func (d *Device) trace(kind TraceKind, data []byte) {
+	if d.traceFn == nil {
+		return
+	}
+	d.traceSeq++
+	d.traceFn(TraceRecord{
+		Seq:       d.traceSeq,
+		Timestamp: time.Now().UnixMilli(),
+		Kind:      kind,
+		Data:      data,
+	})
+}
+
+// traceLine traces a line read from the module, telling URCs from responses
+func (d *Device) traceLine(line []byte) {
+	if d.traceFn == nil {
+		return
+	}
+	kind := TraceResponse
+	if d.polling || d.isUnsolicited(line) {
+		kind = TraceURC
+	}
+	d.trace(kind, line)
+}
+
+// isUnsolicited reports whether line is a known unsolicited result code
+func (d *Device) isUnsolicited(line []byte) bool {
+	if d.isDataNotice(line) || bytes.Contains(line, remoteConnectInfo) ||
+		bytes.HasSuffix(line, remoteClosedInfo) || (d.singleConn && bytes.Equal(line, singleClosed)) {
+		return true
+	}
+	for i := range d.urcHandlers {
+		h := &d.urcHandlers[i]
+		if h.fn != nil && len(line) >= len(h.prefix) && string(line[:len(h.prefix)]) == h.prefix {
+			return true
+		}
+	}
+	return false
+}
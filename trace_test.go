@@ -0,0 +1,57 @@
+package sim800l
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestDevice_SetTraceHook(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CSQ": "\r\n+CSQ: 21,0\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	if err := d.RegisterURCHandler("RING", func(Token) {}); err != nil {
+		t.Fatalf("failed to register handler: %v", err)
+	}
+
+	type record struct {
+		seq  uint64
+		kind TraceKind
+		data string
+	}
+	var records []record
+	d.SetTraceHook(func(r TraceRecord) {
+		if r.Timestamp <= 0 {
+			t.Errorf("record %d has no timestamp", r.Seq)
+		}
+		records = append(records, record{r.Seq, r.Kind, string(r.Data)})
+	})
+
+	if got := d.Signal(); got != 21 {
+		t.Fatalf("expected signal 21, got %d", got)
+	}
+	modem.inject("\r\nRING\r\n")
+	if err := d.Poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+
+	expected := []record{
+		{1, TraceCommand, "AT+CSQ"},
+		{2, TraceResponse, "+CSQ: 21,0"},
+		{3, TraceURC, "RING"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected records %v, got %v", expected, records)
+	}
+	for i, r := range expected {
+		if records[i] != r {
+			t.Errorf("record %d: expected %v, got %v", i, r, records[i])
+		}
+	}
+
+	d.SetTraceHook(nil)
+	d.Signal()
+	if len(records) != len(expected) {
+		t.Errorf("expected no records after removing the hook, got %d", len(records)-len(expected))
+	}
+}
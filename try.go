@@ -0,0 +1,117 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains command and write variants with bounded latency.
+package sim800l
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// tryLockInterval is the time between attempts of tryLock to take the device
+const tryLockInterval = time.Millisecond
+
+var ErrBusy = errors.New("device in use by another operation")
+
+// TryCommand sends an AT command, e.g. "AT+CSQ", and copies the first
+// line of the response into resp, returning its length. Unlike the other
+// methods it never blocks much longer than bound, which suits control
+// loops with fixed cycle times: if another operation holds the device
+// until bound passes it returns ErrBusy, and if the module doesn't answer
+// in time ErrDeadlineExceeded. The rest of the response is read up to its
+// final result within bound, so it isn't left in the UART; if the result
+// is late, the line is returned with ErrDeadlineExceeded and the result is
+// discarded by the next command. An ERROR response returns an *ATError,
+// a transient one too: TryCommand doesn't retry by Config.Retry.
+func (d *Device) TryCommand(cmd, resp []byte, bound time.Duration) (int, error) {
+	if len(cmd) > MaxCommandSize {
+		return 0, fmt.Errorf("%w: command too long", ErrBadParameter)
+	}
+	deadline := time.Now().Add(bound)
+	if !d.tryLock(deadline) {
+		return 0, ErrBusy
+	}
+	defer d.unlock()
+
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		return 0, ErrDeadlineExceeded
+	}
+	// sendRaw upper-cases the command in place, so leave the caller's alone
+	var buf [MaxCommandSize]byte
+	// A retry with its backoff wouldn't fit in bound
+	err := d.sendRetrying(context.Background(), append(buf[:0], cmd...), anyResponse, timeout, false)
+	if errors.Is(err, ErrTimeout) {
+		return 0, fmt.Errorf("%w: %w", ErrDeadlineExceeded, err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	n := copy(resp, d.rxBuffer[:d.end])
+	if bytes.Equal(bytes.TrimSpace(d.rxBuffer[:d.end]), okToken) {
+		return n, nil
+	}
+
+	// Read the rest of the response up to its final result
+	for {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return n, ErrDeadlineExceeded
+		}
+		err = d.readResponse(nil, defaultResponseCheck, timeout)
+		if errors.Is(err, ErrTimeout) {
+			return n, fmt.Errorf("%w: %w", ErrDeadlineExceeded, err)
+		}
+		if !errors.Is(err, ErrUnexpectedResponse) {
+			return n, err
+		}
+	}
+}
+
+// TryWrite writes b like Write, but returns ErrBusy if the device isn't
+// free before bound passes and ErrDeadlineExceeded, with the number of
+// bytes sent, if the module doesn't take the data in time. The write
+// deadline still applies if it is sooner.
+func (c *Connection) TryWrite(b []byte, bound time.Duration) (int, error) {
+	if c == nil || c.Device == nil {
+		return 0, ErrInvalidConnection
+	}
+	if c.state != StateConnected {
+		return 0, ErrConnectionNotEstablished
+	}
+
+	d := c.Device
+	deadline := time.Now().Add(bound)
+	if !d.tryLock(deadline) {
+		return 0, ErrBusy
+	}
+	defer d.unlock()
+	return d.sendDataUntil(c.ID, b, deadline.UnixNano())
+}
+
+// tryLock takes the device like lock, but gives up and reports false if
+// it isn't free by the deadline
+func (d *Device) tryLock(deadline time.Time) bool {
+	for {
+		if d.mu.TryLock() {
+			if d.urgent.Load() == 0 {
+				return true
+			}
+			d.mu.Unlock()
+		}
+		if !time.Now().Add(tryLockInterval).Before(deadline) {
+			return false
+		}
+		time.Sleep(tryLockInterval)
+	}
+}
+
+// anyResponse accepts the first response line unless it is an error
+func anyResponse(buffer []byte) error {
+	if bytes.Contains(buffer, errorToken) {
+		return defaultResponseCheck(buffer)
+	}
+	return nil
+}
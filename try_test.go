@@ -0,0 +1,83 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDevice_TryCommand(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CSQ":  "\r\n+CSQ: 20,0\r\n\r\nOK\r\n",
+		"AT+CREG": "",
+	})
+	d := New(modem, nil, slog.New(slog.DiscardHandler))
+
+	var resp [32]byte
+	cmd := []byte("at+csq")
+	n, err := d.TryCommand(cmd, resp[:], 100*time.Millisecond)
+	if err != nil || string(resp[:n]) != "+CSQ: 20,0" {
+		t.Fatalf("expected the signal quality, got %q, %v", resp[:n], err)
+	}
+	if string(cmd) != "at+csq" {
+		t.Errorf("expected the command to be left unchanged, got %q", cmd)
+	}
+	if n := modem.Buffered(); n != 0 {
+		t.Errorf("expected the final result to be read, %d bytes left", n)
+	}
+
+	// Lines up to the final result are read as well, and an ERROR after
+	// the first line is returned
+	modem.responses["AT+CPBR=1,2"] = "\r\n+CPBR: 1\r\n+CPBR: 2\r\n\r\nERROR\r\n"
+	n, err = d.TryCommand([]byte("AT+CPBR=1,2"), resp[:], 100*time.Millisecond)
+	var atErr *ATError
+	if !errors.As(err, &atErr) || string(resp[:n]) != "+CPBR: 1" {
+		t.Errorf("expected the first line with an *ATError, got %q, %v", resp[:n], err)
+	}
+	if n := modem.Buffered(); n != 0 {
+		t.Errorf("expected the final result to be read, %d bytes left", n)
+	}
+
+	// The module doesn't answer in time
+	start := time.Now()
+	if _, err := d.TryCommand([]byte("AT+CREG"), resp[:], 50*time.Millisecond); !errors.Is(err, ErrDeadlineExceeded) {
+		t.Errorf("expected ErrDeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("expected the bound to hold, took %v", elapsed)
+	}
+
+	// Another operation holds the device
+	d.lock()
+	start = time.Now()
+	_, err = d.TryCommand([]byte("AT+CSQ"), resp[:], 20*time.Millisecond)
+	d.unlock()
+	if !errors.Is(err, ErrBusy) {
+		t.Errorf("expected ErrBusy, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected the bound to hold, took %v", elapsed)
+	}
+}
+
+func TestConnection_TryWrite(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CIPSEND=0,4": "\r\n> ",
+	})
+	modem.dataReply = "\r\n0, SEND OK\r\n"
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	conn := &Connection{ID: 0, Type: TCP, state: StateConnected, Device: d}
+	d.connections[0] = conn
+
+	if n, err := conn.TryWrite([]byte("ping"), 100*time.Millisecond); err != nil || n != 4 {
+		t.Fatalf("expected 4 bytes written, got %d, %v", n, err)
+	}
+
+	d.lock()
+	_, err := conn.TryWrite([]byte("ping"), 10*time.Millisecond)
+	d.unlock()
+	if !errors.Is(err, ErrBusy) {
+		t.Errorf("expected ErrBusy, got %v", err)
+	}
+}
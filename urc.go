@@ -0,0 +1,236 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains dispatching of unsolicited result codes (URCs).
+package sim800l
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// MaxURCHandlers is the number of URC handlers that can be registered at once
+const MaxURCHandlers = 8
+
+const (
+	MaxQueuedURCs   = 4  // URCs without a handler kept for DrainURCs
+	MaxQueuedURCLen = 64 // Longest URC kept, longer ones are truncated
+)
+
+// knownURCs are the prefixes of the module's unsolicited result codes
+// that may arrive in the middle of a command's response
+var knownURCs = [...]string{
+	"RING", "+CRING:", "+CLIP:", "+CMTI:", "+CMT:", "+CDS:", "+CBM:", "+CUSD:",
+	"+CREG:", "+CGREG:", "+CPIN:", "+CFUN:", "Call Ready", "SMS Ready",
+	"*PSUTTZ:", "+CTZV:", "DST:", "UNDER-VOLTAGE", "OVER-VOLTAGE",
+}
+
+// queuedURC is a URC kept for DrainURCs
+type queuedURC struct {
+	line      [MaxQueuedURCLen]byte
+	n         int
+	truncated bool
+}
+
+// pendingLineTimeout bounds the wait for the rest of a line that has
+// started to arrive while draining pending input
+const pendingLineTimeout = 500 * time.Millisecond
+
+var ErrTooManyHandlers = errors.New("too many URC handlers")
+
+// Token is a piece of modem output, such as a line of an unsolicited result code.
+// Data refers to the driver's buffer and is only valid during the callback
+// it is passed to; copy it to keep it.
+type Token struct {
+	Type TokenType // Kind of token
+	Data []byte    // Content of the token, without line endings
+
+	// Truncated is set if the line didn't fit the driver's buffer and
+	// Data holds only its first MaxBufferSize bytes
+	Truncated bool
+}
+
+// urcHandler is a registered callback for URCs starting with prefix
+type urcHandler struct {
+	prefix string
+	fn     func(Token)
+}
+
+// RegisterURCHandler calls fn for every unsolicited line starting with prefix,
+// e.g. "+CREG", "RING", "+CMTI" or "UNDER-VOLTAGE". Registering a prefix again
+// replaces its handler.
+//
+// Handlers run from inside the driver while it reads the UART, so they must
+// return quickly and must not call back into the Device; record what is
+// needed (e.g. the SMS index of +CMTI) and act on it afterwards.
+func (d *Device) RegisterURCHandler(prefix string, fn func(Token)) error {
+	d.lock()
+	defer d.unlock()
+
+	free := -1
+	for i := range d.urcHandlers {
+		h := &d.urcHandlers[i]
+		if h.fn != nil && h.prefix == prefix {
+			h.fn = fn
+			return nil
+		}
+		if h.fn == nil && free < 0 {
+			free = i
+		}
+	}
+	if free < 0 {
+		return ErrTooManyHandlers
+	}
+	d.urcHandlers[free] = urcHandler{prefix: prefix, fn: fn}
+	return nil
+}
+
+// UnregisterURCHandler removes the handler registered for prefix
+func (d *Device) UnregisterURCHandler(prefix string) {
+	d.lock()
+	defer d.unlock()
+
+	for i := range d.urcHandlers {
+		if d.urcHandlers[i].fn != nil && d.urcHandlers[i].prefix == prefix {
+			d.urcHandlers[i] = urcHandler{}
+		}
+	}
+}
+
+// Poll processes unsolicited result codes and received data that arrived
+// while no command was running. Call it periodically when the device is
+// otherwise idle; commands do the same before they are sent. The SMS
+// announced since the last Poll are then passed to the SMS handler.
+func (d *Device) Poll() error {
+	d.lock()
+	err := d.poll()
+	d.unlock()
+	d.handlePendingSMS()
+	return err
+}
+
+// poll processes pending input with the lock held
+func (d *Device) poll() error {
+	if d.inPPP() {
+		return nil // The input is the PPP session's
+	}
+	d.polling = true
+	defer func() { d.polling = false }()
+
+	for d.input().Buffered() > 0 {
+		t, err := d.readLine(pendingLineTimeout)
+		if err != nil {
+			return err
+		}
+		if t != TokenLine {
+			continue
+		}
+
+		line := d.rxBuffer[:d.end]
+		handled, err := d.dispatchInput(line)
+		if err != nil {
+			return err
+		}
+		if !handled && !d.queueURC(nil, line) {
+			d.log(SubsystemURC, slog.LevelDebug, "discarding unexpected line", "line", line)
+			if err := d.anomaly("unexpected line", line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dispatchInput hands a line that isn't the response to a command to
+// where it belongs: the data announced by +RECEIVE or +IPD to its
+// connection's buffer and URCs to their handlers. It reports whether it took the line.
+func (d *Device) dispatchInput(line []byte) (bool, error) {
+	if d.isDataNotice(line) {
+		return true, d.receiveData(line, time.Now().Add(DefaultTimeout))
+	}
+	return d.handleUnsolicited(line), nil
+}
+
+// handleUnsolicited processes line if it is a connection URC or has a
+// registered handler, and reports whether it did
+func (d *Device) handleUnsolicited(line []byte) bool {
+	return d.acceptRemote(line) || d.remoteClosed(line) || d.singleClosedNotice(line) ||
+		d.pdpDeact(line) || d.brownout(line) || d.btInput(line) || d.smsArrived(line) || d.dispatchURC(line)
+}
+
+// queueURC keeps line for DrainURCs if it is a known URC without a
+// handler, and reports whether it did. A line that answers cmd, like
+// "+CREG: 0,1" to AT+CREG?, isn't a URC.
+func (d *Device) queueURC(cmd, line []byte) bool {
+	if !isKnownURC(cmd, line) {
+		return false
+	}
+	if d.urcCount == MaxQueuedURCs {
+		// Drop the oldest
+		d.log(SubsystemURC, slog.LevelWarn, "URC queue full, dropping URC",
+			"line", d.urcQueue[d.urcHead].line[:d.urcQueue[d.urcHead].n])
+		d.urcHead = (d.urcHead + 1) % MaxQueuedURCs
+		d.urcCount--
+	}
+	q := &d.urcQueue[(d.urcHead+d.urcCount)%MaxQueuedURCs]
+	q.n = copy(q.line[:], line)
+	q.truncated = d.truncated || q.n < len(line)
+	d.urcCount++
+	d.log(SubsystemURC, slog.LevelDebug, "queued URC", "line", line)
+	return true
+}
+
+// isKnownURC reports whether line is one of knownURCs and doesn't answer cmd
+func isKnownURC(cmd, line []byte) bool {
+	cmd = bytes.TrimPrefix(cmd, at)
+	for _, prefix := range knownURCs {
+		if !bytes.HasPrefix(line, []byte(prefix)) {
+			continue
+		}
+		name := strings.TrimSuffix(prefix, ":")
+		if bytes.HasPrefix(cmd, []byte(name)) && (len(cmd) == len(name) || !isLetter(cmd[len(name)])) {
+			return false // The response of cmd
+		}
+		return true
+	}
+	return false
+}
+
+// isLetter reports whether b is an ASCII letter
+func isLetter(b byte) bool {
+	return (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')
+}
+
+// DrainURCs passes the known unsolicited result codes that arrived
+// without a registered handler, like RING or +CMTI in the middle of a
+// command's response, to fn, oldest first, and empties the queue. At most
+// MaxQueuedURCs are kept. It returns the number of URCs passed. Like a
+// URC handler, fn must not call back into the Device.
+func (d *Device) DrainURCs(fn func(Token)) int {
+	d.lock()
+	defer d.unlock()
+
+	n := d.urcCount
+	for d.urcCount > 0 {
+		q := &d.urcQueue[d.urcHead]
+		d.urcHead = (d.urcHead + 1) % MaxQueuedURCs
+		d.urcCount--
+		fn(Token{Type: TokenLine, Data: q.line[:q.n], Truncated: q.truncated})
+	}
+	return n
+}
+
+// dispatchURC passes line to the handler registered for its prefix and
+// reports whether there was one
+func (d *Device) dispatchURC(line []byte) bool {
+	for i := range d.urcHandlers {
+		h := &d.urcHandlers[i]
+		if h.fn != nil && len(line) >= len(h.prefix) && string(line[:len(h.prefix)]) == h.prefix {
+			d.log(SubsystemURC, slog.LevelDebug, "dispatching URC", "line", line)
+			h.fn(Token{Type: TokenLine, Data: line, Truncated: d.truncated})
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package sim800l
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDevice_PollDispatchesURCs(t *testing.T) {
+	s1 := slot(t, 1)
+	modem := newMockModem(nil)
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.connections[s1] = &Connection{ID: 1, Type: TCP, Device: d, state: StateConnected}
+
+	var got []string
+	if err := d.RegisterURCHandler("+CMTI", func(tok Token) {
+		got = append(got, string(tok.Data))
+	}); err != nil {
+		t.Fatalf("failed to register handler: %v", err)
+	}
+
+	modem.inject("\r\n+CMTI: \"SM\",3\r\n+RECEIVE,1,5:\r\nhello\r\n+CREG: 5\r\n")
+	if err := d.Poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+
+	if len(got) != 1 || got[0] != "+CMTI: \"SM\",3" {
+		t.Errorf("expected one +CMTI URC, got %q", got)
+	}
+	if string(d.recvBuffers[s1][:d.recvBufLengths[s1]]) != "hello" {
+		t.Errorf("expected received data to be buffered, got %q", d.recvBuffers[s1][:d.recvBufLengths[s1]])
+	}
+}
+
+func TestDevice_CommandSkipsURCs(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT": "\r\nRING\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+
+	rings := 0
+	if err := d.RegisterURCHandler("RING", func(Token) { rings++ }); err != nil {
+		t.Fatalf("failed to register handler: %v", err)
+	}
+
+	if err := d.send(at); err != nil {
+		t.Fatalf("command failed: %v", err)
+	}
+	if rings != 1 {
+		t.Errorf("expected 1 RING, got %d", rings)
+	}
+
+	// Without a handler the URC is queued
+	d.UnregisterURCHandler("RING")
+	if err := d.send(at); err != nil {
+		t.Fatalf("command with unhandled RING failed: %v", err)
+	}
+	if n := d.DrainURCs(func(Token) {}); n != 1 {
+		t.Errorf("expected 1 queued URC, got %d", n)
+	}
+}
+
+func TestDevice_CommandQueuesURCs(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CSQ":   "\r\nRING\r\n\r\n+CMTI: \"SM\",3\r\n\r\n+CSQ: 21,0\r\n\r\nOK\r\n",
+		"AT+CREG?": "\r\n+CREG: 0,1\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+
+	if got := d.Signal(); got != 21 {
+		t.Errorf("expected signal 21, got %d", got)
+	}
+
+	// The response to a command isn't taken for a URC
+	if err := d.sendWithOptions(cmdRegistration, prefixCheck(registration), DefaultTimeout); err != nil {
+		t.Fatalf("registration query failed: %v", err)
+	}
+	_ = d.Poll() // The OK that follows +CREG
+
+	// Known URCs aren't protocol anomalies
+	d.Configure(Config{Strict: true})
+	modem.inject("\r\nCall Ready\r\n")
+	if err := d.Poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+
+	var got []string
+	if n := d.DrainURCs(func(tok Token) { got = append(got, string(tok.Data)) }); n != 3 {
+		t.Errorf("expected 3 URCs, got %d", n)
+	}
+	expected := []string{"RING", "+CMTI: \"SM\",3", "Call Ready"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if n := d.DrainURCs(func(Token) {}); n != 0 {
+		t.Errorf("expected an empty queue, got %d", n)
+	}
+
+	// Only the latest URCs are kept
+	for i := 0; i < MaxQueuedURCs+2; i++ {
+		modem.inject("\r\nRING\r\n")
+	}
+	if err := d.Poll(); err != nil {
+		t.Fatalf("poll failed: %v", err)
+	}
+	if n := d.DrainURCs(func(Token) {}); n != MaxQueuedURCs {
+		t.Errorf("expected %d URCs, got %d", MaxQueuedURCs, n)
+	}
+}
+
+func TestDevice_RegisterURCHandlerLimit(t *testing.T) {
+	d := New(newMockModem(nil), nil, slog.New(&MockHandler{t: t}))
+	for i := 0; i < MaxURCHandlers; i++ {
+		if err := d.RegisterURCHandler(string(rune('A'+i)), func(Token) {}); err != nil {
+			t.Fatalf("failed to register handler %d: %v", i, err)
+		}
+	}
+	// Replacing an existing handler still works when the table is full
+	if err := d.RegisterURCHandler("A", func(Token) {}); err != nil {
+		t.Errorf("failed to replace handler: %v", err)
+	}
+	if err := d.RegisterURCHandler("+CREG", func(Token) {}); !errors.Is(err, ErrTooManyHandlers) {
+		t.Errorf("expected ErrTooManyHandlers, got %v", err)
+	}
+}
+
+func TestDevice_CommandDispatchesReceivedData(t *testing.T) {
+	modem := newMockModem(map[string]string{
+		"AT+CSQ": "\r\n+RECEIVE,0,4:\r\nping\r\n+CSQ: 20,0\r\n\r\nOK\r\n",
+	})
+	d := New(modem, nil, slog.New(&MockHandler{t: t}))
+	d.connections[0] = &Connection{ID: 0, Type: TCP, Device: d, state: StateConnected}
+
+	// Data arriving while a command waits for its response is buffered
+	// for its connection, not taken for the response
+	if got := d.Signal(); got != 20 {
+		t.Fatalf("expected signal quality 20, got %d", got)
+	}
+	if string(d.recvBuffers[0][:d.recvBufLengths[0]]) != "ping" {
+		t.Errorf("expected received data to be buffered, got %q", d.recvBuffers[0][:d.recvBufLengths[0]])
+	}
+}
@@ -0,0 +1,117 @@
+// Package sim800l implements a driver for the SIM800L GSM/GPRS module.
+// This file contains the package version and feature negotiation.
+package sim800l
+
+// Version is the semantic version of the package API. Downstream libraries
+// can compare it to decide which APIs they may use.
+const Version = "0.1.0"
+
+// Feature identifies an optional capability of the driver or the modem
+type Feature uint8
+
+const (
+	FeatureTCP           Feature = iota // TCP client connections
+	FeatureUDP                          // UDP connections with datagram boundaries
+	FeatureSMS                          // Inbound SMS with sender filtering
+	FeatureDiagnostics                  // Diagnostics snapshot with recent module errors
+	FeatureNetworkTime                  // Network time and host clock drift compensation
+	FeatureAlert                        // High-priority alerts over SMS, call and TCP
+	FeatureTCPServer                    // Inbound TCP connections through a net.Listener
+	FeatureIPStackCheck                 // Detection and refresh of a dead PDP context
+	FeatureDNS                          // Host name lookups by the module
+	FeatureOperatorNames                // Operator names for numeric PLMN codes
+	FeatureTemperature                  // Module temperature readings with AT+CMTE
+	FeatureAPNTable                     // APN selection by the SIM's IMSI
+	FeatureSSL                          // TLS connections with AT+CIPSSL, firmware R14.00 and later
+	FeatureNTP                          // Clock synchronization with AT+CNTP, firmware R14.00 and later
+	FeatureGNSS                         // GNSS positions on a SIM808, with the sim800l_gnss tag
+	FeatureBluetooth                    // Bluetooth SPP connections, on modules with Bluetooth firmware
+	FeaturePPP                          // PPP sessions for a host IP stack over the UART
+	FeatureNetdev                       // Netdev adapter for TinyGo's netdev and netlink interfaces
+	FeatureHTTP                         // Requests with the module's HTTP stack, HTTPS on firmware with SSL
+)
+
+// minFirmwareRelease is the oldest firmware release, as returned by
+// firmwareRelease, that has AT+CIPSSL and AT+CNTP on a SIM800
+const minFirmwareRelease = 1400
+
+func (f Feature) String() string {
+	switch f {
+	case FeatureTCP:
+		return "TCP"
+	case FeatureUDP:
+		return "UDP"
+	case FeatureSMS:
+		return "SMS"
+	case FeatureDiagnostics:
+		return "Diagnostics"
+	case FeatureNetworkTime:
+		return "NetworkTime"
+	case FeatureAlert:
+		return "Alert"
+	case FeatureTCPServer:
+		return "TCPServer"
+	case FeatureIPStackCheck:
+		return "IPStackCheck"
+	case FeatureDNS:
+		return "DNS"
+	case FeatureOperatorNames:
+		return "OperatorNames"
+	case FeatureTemperature:
+		return "Temperature"
+	case FeatureAPNTable:
+		return "APNTable"
+	case FeatureSSL:
+		return "SSL"
+	case FeatureNTP:
+		return "NTP"
+	case FeatureGNSS:
+		return "GNSS"
+	case FeatureBluetooth:
+		return "Bluetooth"
+	case FeaturePPP:
+		return "PPP"
+	case FeatureNetdev:
+		return "Netdev"
+	case FeatureHTTP:
+		return "HTTP"
+	default:
+		return "Unknown"
+	}
+}
+
+// Supports reports whether the feature is available on this device. It
+// combines the subsystems compiled into the driver with what the modem has
+// been detected to support, so callers can degrade gracefully.
+func (d *Device) Supports(feature Feature) bool {
+	d.lock()
+	defer d.unlock()
+	return d.supports(feature)
+}
+
+// supports reports whether the feature is available, with the lock held
+func (d *Device) supports(feature Feature) bool {
+	switch feature {
+	case FeatureTCP, FeatureUDP, FeatureSMS, FeatureDiagnostics, FeatureNetworkTime, FeatureAlert, FeatureTCPServer,
+		FeatureIPStackCheck, FeatureDNS, FeaturePPP, FeatureNetdev, FeatureHTTP:
+		return true
+	case FeatureOperatorNames:
+		return operatorTable != ""
+	case FeatureTemperature:
+		return !d.noTemperature
+	case FeatureAPNTable:
+		return apnTable != ""
+	case FeatureSSL:
+		return d.capabilities().SSL
+	case FeatureGNSS:
+		return gnssCompiled && d.capabilities().GNSS
+	case FeatureNTP:
+		// Known once Init or Info has read the firmware revision
+		release, ok := firmwareRelease(d.Firmware)
+		return ok && release >= minFirmwareRelease
+	case FeatureBluetooth:
+		return d.capabilities().Bluetooth
+	default:
+		return false
+	}
+}
@@ -0,0 +1,59 @@
+package sim800l
+
+import "testing"
+
+func TestDevice_Supports(t *testing.T) {
+	d := &Device{}
+	for _, f := range []Feature{FeatureTCP, FeatureUDP, FeatureSMS, FeatureDiagnostics, FeatureNetworkTime, FeatureAlert, FeatureTCPServer, FeatureIPStackCheck, FeatureDNS} {
+		if !d.Supports(f) {
+			t.Errorf("expected %v to be supported", f)
+		}
+	}
+	if d.Supports(Feature(255)) {
+		t.Error("expected unknown feature to be unsupported")
+	}
+}
+
+func TestDevice_SupportsByFirmware(t *testing.T) {
+	d := &Device{}
+	if d.Supports(FeatureSSL) || d.Supports(FeatureNTP) {
+		t.Error("expected SSL and NTP to be unsupported before the firmware is known")
+	}
+	d.Firmware = "1308B08SIM800L16"
+	if d.Supports(FeatureSSL) {
+		t.Errorf("expected no SSL on %s", d.Firmware)
+	}
+	d.Firmware = "1418B05SIM800L24"
+	if !d.Supports(FeatureSSL) || !d.Supports(FeatureNTP) {
+		t.Errorf("expected SSL and NTP on %s", d.Firmware)
+	}
+}
+
+func TestDevice_SupportsBluetooth(t *testing.T) {
+	d := &Device{Model: "SIMCOM_SIM800L", Firmware: "1418B05SIM800L24"}
+	if d.Supports(FeatureBluetooth) {
+		t.Error("expected no Bluetooth without the Bluetooth firmware")
+	}
+	d.Firmware = "1418B05SIM800L24_BT"
+	if !d.Supports(FeatureBluetooth) {
+		t.Errorf("expected Bluetooth on %s", d.Firmware)
+	}
+}
+
+func TestDevice_SupportsPPP(t *testing.T) {
+	if !(&Device{}).Supports(FeaturePPP) {
+		t.Error("expected PPP to be supported")
+	}
+}
+
+func TestDevice_SupportsNetdev(t *testing.T) {
+	if !(&Device{}).Supports(FeatureNetdev) {
+		t.Error("expected the netdev adapter to be supported")
+	}
+}
+
+func TestDevice_SupportsHTTP(t *testing.T) {
+	if !(&Device{}).Supports(FeatureHTTP) {
+		t.Error("expected the HTTP stack to be supported")
+	}
+}